@@ -4,45 +4,47 @@ import (
 	"flag"
 	"fmt"
 	"os"
-	"strings"
+	"text/tabwriter"
+	"time"
 
-	"gitlab.com/btcdirect-api/go-modules/sql/migrate"
 	"gitlab.com/btcdirect-api/bootstrap-go-service/internal/app"
 	"gitlab.com/btcdirect-api/bootstrap-go-service/internal/http/server"
+	"gitlab.com/btcdirect-api/bootstrap-go-service/internal/sql"
+	"gitlab.com/btcdirect-api/bootstrap-go-service/internal/sql/migrate"
+	"gitlab.com/btcdirect-api/go-modules/logger"
 )
 
-func main() {
-	c := app.Configuration{}
+// Returns the directory of the migrations of the dialect of the database, relative to the root of the repository,
+// see migrate.Create and baseline.
+func migrationsDirectory(dsn string) string {
+	if sql.IsPostgresDSN(dsn) {
+		return "internal/db/migrations/postgres"
+	}
 
-	var env string
-	flag.StringVar(&env, "env", getenv("APP_ENV", "dev"), "Environment")
+	return "internal/db/migrations/mysql"
+}
 
-	var err error
-	c.Environment, err = getEnvironment(env)
-	if err != nil {
+func main() {
+	c := app.Configuration{}
+	if err := c.BindFlags(flag.CommandLine); err != nil {
 		panic(err)
 	}
 
-	flag.StringVar(&c.LogLevel, "loglevel", getenv("LOG_LEVEL", "info"), "Log output level")
-	flag.StringVar(&c.HTTPPort, "port", getenv("HTTP_PORT", "8080"), "HTTP port")
-	flag.StringVar(&c.DatabaseDSN, "database", os.Getenv("DATABASE_URL"), "Database dsn")
-	flag.StringVar(&c.SentryDSN, "sentry-dsn", os.Getenv("SENTRY_DSN"), "Sentry DSN")
-
-	flag.StringVar(&c.Pubsub.Emulator, "pubsub-emulator", os.Getenv("PUBSUB_EMULATOR"), "Pubsub emulator host")
-	flag.StringVar(&c.Pubsub.Project, "pubsub-project", os.Getenv("PUBSUB_PROJECT"), "Pubsub project id")
-
-	var migrate bool
+	var migrate, verify bool
 	flag.BoolVar(&migrate, "migrate", false, "Run database migrations")
+	flag.BoolVar(&verify, "verify", false, "Verify the configured credentials and exit")
 
 	flag.Parse()
 
+	if verify {
+		verif(c)
+	}
+
 	if migrate {
-		// Allow multi statement for migrations.
-		suffix := "?"
-		if strings.Contains(c.DatabaseDSN, suffix) {
-			suffix = "&"
-		}
-		c.DatabaseDSN += suffix + "multiStatements=true"
+		create(c.DatabaseDSN)
+		// The migrate command runs instead of the migrations on start.
+		c.Migrations.OnStart = false
+		c.Migrations.Command = true
 	}
 
 	application := app.Initialize(c)
@@ -57,6 +59,12 @@ func main() {
 // Run the application in migrate mode.
 func migr(application *app.App) {
 	m := migrate.ParseMigrationFlags("migrate")
+
+	// The baseline rewrites the migration files of the repository.
+	if m.Cmd == "baseline" {
+		m.Directory = migrationsDirectory(application.Config().DatabaseDSN)
+	}
+
 	if err := application.Migrate(m); err != nil {
 		application.Logger().Errorf("Error migrating: %v", err)
 		os.Exit(1)
@@ -65,6 +73,50 @@ func migr(application *app.App) {
 	os.Exit(0)
 }
 
+// Create the files of a new migration for `-migrate create <name>`, this does not need a database.
+// Returns for the other migrate commands.
+func create(dsn string) {
+	m := migrate.ParseMigrationFlags("migrate")
+	if m.Cmd != "create" {
+		return
+	}
+
+	up, down, err := migrate.Create(migrationsDirectory(dsn), m.Param)
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "Error creating migration: %v\n", err)
+		os.Exit(1)
+	}
+
+	fmt.Printf("Created %s\nCreated %s\n", up, down)
+	os.Exit(0)
+}
+
+// Run the application in verify mode, exits non-zero when a credential is rejected.
+func verif(c app.Configuration) {
+	results := app.Verify(c, logger.NewLogger(c.LogLevel))
+
+	failed := false
+	w := tabwriter.NewWriter(os.Stdout, 0, 0, 2, ' ', 0)
+	for _, result := range results {
+		status, detail := "PASS", ""
+		switch {
+		case result.Skipped:
+			status, detail = "SKIP", result.Err.Error()
+		case result.Err != nil:
+			status, detail = "FAIL", result.Err.Error()
+			failed = true
+		}
+		fmt.Fprintf(w, "%s\t%s\t%s\t%s\n", status, result.Name, result.Duration.Round(time.Millisecond), detail)
+	}
+	w.Flush()
+
+	if failed {
+		os.Exit(1)
+	}
+
+	os.Exit(0)
+}
+
 // Run the application daemon.
 func run(application *app.App) {
 	application.Logger().Info("Starting application")
@@ -74,33 +126,9 @@ func run(application *app.App) {
 
 	application.Logger().Info("Shutting down application")
 
-	application.Shutdown()
+	// Drain the requests before closing the components they use.
 	server.Shutdown()
+	application.Shutdown()
 
 	os.Exit(0)
 }
-
-func getenv(key string, fallback string) string {
-	value := os.Getenv(key)
-	if len(value) == 0 {
-		return fallback
-	}
-	return value
-}
-
-func getEnvironment(input string) (app.Environment, error) {
-	switch input {
-	case "dev":
-		return app.Dev, nil
-	case "stage":
-		return app.Stage, nil
-	case "acc":
-		return app.Acc, nil
-	case "sandbox":
-		return app.Sandbox, nil
-	case "prod":
-		return app.Prod, nil
-	default:
-		return "", fmt.Errorf("invalid environment: %s", input)
-	}
-}
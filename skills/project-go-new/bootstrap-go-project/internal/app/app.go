@@ -1,69 +1,189 @@
 package app
 
 import (
+	"context"
+	"errors"
 	"time"
 
 	"github.com/getsentry/sentry-go"
 	"github.com/jmoiron/sqlx"
-	"gitlab.com/btcdirect-api/go-modules/app"
-	msg "gitlab.com/btcdirect-api/go-modules/messenger"
-	"gitlab.com/btcdirect-api/go-modules/sql"
-	"gitlab.com/btcdirect-api/go-modules/sql/migrate"
-	"gitlab.com/btcdirect-api/bootstrap-go-service/internal/db"
+	"gitlab.com/btcdirect-api/bootstrap-go-service/internal/core"
+	"gitlab.com/btcdirect-api/bootstrap-go-service/internal/http/router"
+	"gitlab.com/btcdirect-api/bootstrap-go-service/internal/idempotency"
+	msg "gitlab.com/btcdirect-api/bootstrap-go-service/internal/messenger"
+	"gitlab.com/btcdirect-api/bootstrap-go-service/internal/messenger/outbound/migration"
+	"gitlab.com/btcdirect-api/bootstrap-go-service/internal/registry"
+	"gitlab.com/btcdirect-api/bootstrap-go-service/internal/sql"
+	"gitlab.com/btcdirect-api/bootstrap-go-service/internal/sql/migrate"
+	"gitlab.com/btcdirect-api/bootstrap-go-service/internal/task"
 	"go.uber.org/zap"
 )
 
+// Database is the database component of the application.
+type Database interface {
+	Start() *sqlx.DB
+	Connection() *sql.Connection
+	Migrate(m migrate.Migrate) error
+	Shutdown() error
+}
+
+var ErrNoDatabase = errors.New("no database configured")
+
 type App struct {
-	config   Configuration
-	database interface {
-		Start() *sqlx.DB
-		Connection() *sql.Connection
-		Migrate(m migrate.Migrate) error
-		Shutdown() error
+	config      Configuration
+	database    Database
+	messenger   msg.Messenger
+	tasks       *task.Tracker
+	idempotency *idempotency.Store
+	handlers    Handlers
+	registry    *registry.Registry
+	routes      []RouteRegistrar
+	newRouter   func() router.Router
+	core        *core.App
+
+	readinessChecks map[string]ReadinessCheck
+}
+
+// Option overrides a component of the application, e.g. to inject fakes in tests.
+type Option func(*options)
+
+type options struct {
+	providers       []func(*registry.Registry)
+	routes          []RouteRegistrar
+	newRouter       func() router.Router
+	handlers        []HandlerRegistrar
+	shutdownHooks   []func() error
+	readinessChecks map[string]ReadinessCheck
+	shutdownTimeout *time.Duration
+}
+
+// Override replaces the constructor of a component, see provideComponents for the components.
+func Override[T any](constructor func(*registry.Registry) (T, error)) Option {
+	return func(o *options) {
+		o.providers = append(o.providers, func(r *registry.Registry) {
+			registry.Provide(r, constructor)
+		})
+	}
+}
+
+// WithDatabase uses the given database instead of connecting to the configured DSN.
+func WithDatabase(d Database) Option {
+	return Override(func(r *registry.Registry) (Database, error) {
+		d.Start()
+		r.OnShutdown(d.Shutdown)
+		return d, nil
+	})
+}
+
+// WithMessenger uses the given messenger instead of creating one from the configuration.
+func WithMessenger(m msg.Messenger) Option {
+	return Override(func(*registry.Registry) (msg.Messenger, error) {
+		return m, nil
+	})
+}
+
+// WithShutdownTimeout overrides the time to wait before shutting down, which depends on the environment.
+func WithShutdownTimeout(timeout time.Duration) Option {
+	return func(o *options) {
+		o.shutdownTimeout = &timeout
 	}
-	messenger msg.Messenger
-	handlers  []msg.MessageHandler
-	core      *app.App
 }
 
 // Initialize the application.
 // This will also load the configuration.
-func Initialize(c Configuration) *App {
+func Initialize(c Configuration, opts ...Option) *App {
+	var o options
+	for _, opt := range opts {
+		opt(&o)
+	}
+
 	// In development mode, we set the shutdown timeout to 0 to allow for instant shutdowns.
 	// In production, we set it to 30 seconds to allow for graceful shutdowns.
 	shutdownTimeout := 30 * time.Second
 	if c.Environment == Dev {
 		shutdownTimeout = 0
 	}
+	if o.shutdownTimeout != nil {
+		shutdownTimeout = *o.shutdownTimeout
+	}
+
+	// Production-like environments must not leak debug information into the logs.
+	logLevel := c.LogLevel
+	if c.Environment.IsProductionLike() && logLevel == "debug" {
+		logLevel = "info"
+	}
 
-	core := app.Initialize(
-		app.WithLoggerForLevel(c.LogLevel),
-		app.WithShutdownTimeout(shutdownTimeout),
+	base := core.Initialize(
+		core.WithLoggerForLevel(logLevel),
+		core.WithShutdownTimeout(shutdownTimeout),
 	)
 
-	database := db.New(c.DatabaseDSN, core.Log)
-	database.Start()
+	if logLevel != c.LogLevel {
+		base.Log.Warnf("Log level '%s' is not allowed in %s. Using '%s'", c.LogLevel, c.Environment, logLevel)
+	}
 
-	messenger := createMessenger(&core, c)
+	r := registry.New()
+	registry.Value(r, c)
+	registry.Value(r, &base)
+	provideComponents(r)
+	for _, provide := range o.providers {
+		provide(r)
+	}
 
-	// TODO: Add your message handlers here
-	handlers := []msg.MessageHandler{}
+	handlers, err := resolveHandlers(r, o.handlers)
+	if err != nil {
+		panic(err)
+	}
 
 	app := &App{
 		config:    c,
-		database:  database,
-		messenger: messenger,
+		messenger: registry.MustResolve[msg.Messenger](r),
 		handlers:  handlers,
-		core:      &core,
+		registry:  r,
+		routes:    o.routes,
+		newRouter: o.newRouter,
+		core:      &base,
+
+		readinessChecks: o.readinessChecks,
+	}
+
+	// Services without a database run without the components depending on it.
+	if registry.Provided[Database](r) {
+		app.database = registry.MustResolve[Database](r)
+		app.tasks = registry.MustResolve[*task.Tracker](r)
+		app.idempotency = registry.MustResolve[*idempotency.Store](r)
+	} else {
+		base.Log.Info("No database configured, starting without database")
+	}
+
+	// Hooks of the service run before the components it depends on are stopped.
+	for _, hook := range o.shutdownHooks {
+		r.OnShutdown(hook)
 	}
 
 	app.initSentry()
 
+	if app.database != nil && c.Migrations.OnStart {
+		app.migrateOnStart()
+	}
+
 	return app
 }
 
+// Applies the pending up migrations before the application serves traffic.
+// Instances starting at the same time wait for the migration lock, see migrate.Migrate.
+func (a *App) migrateOnStart() {
+	m := migrate.Migrate{Cmd: "up", MaxPending: a.config.Migrations.MaxPending}
+	if err := a.Migrate(m); err != nil {
+		a.Logger().Fatalf("Could not apply the migrations on start: %v", err)
+	}
+}
+
 // Run the application and its services.
+// The registered tables are validated against the database schema first, see validateSchema.
 func (a *App) Run() {
+	a.validateSchema()
+
 	for _, handler := range a.handlers {
 		go a.messenger.Subscribe(handler)
 	}
@@ -71,15 +191,82 @@ func (a *App) Run() {
 	a.core.Run()
 }
 
+// Validates the tables registered with sql.RegisterTable or a repository against the database schema.
+// The drift is logged, or stops the application when the schema validation is set to fail.
+func (a *App) validateSchema() {
+	if a.database == nil || a.config.SchemaCheck == "off" {
+		return
+	}
+
+	drift, err := sql.ValidateSchema(context.Background(), a.database.Connection())
+	if err != nil {
+		a.Logger().Errorf("Could not validate the database schema: %v", err)
+		return
+	}
+
+	for _, d := range drift {
+		a.Logger().Warnw("Database schema drift", "table", d.Table, "column", d.Column, "issue", d.Issue)
+	}
+
+	if len(drift) > 0 && a.config.SchemaCheck == "fail" {
+		a.Logger().Fatalf("Database schema drifted from the registered tables, %d issues", len(drift))
+	}
+}
+
+// ShuttingDown returns true once a shutdown was requested, see core.App.ShuttingDown.
+func (a *App) ShuttingDown() bool {
+	return a.core.ShuttingDown()
+}
+
+// Stop requests the application to shut down, Run returns once its services stopped.
+func (a *App) Stop() {
+	a.core.Stop()
+}
+
 // Migrate the database.
+// Returns ErrNoDatabase when no database is configured.
+//
+// A migration.completed event is published with the result of the run.
 func (a *App) Migrate(m migrate.Migrate) error {
+	if a.database == nil {
+		return ErrNoDatabase
+	}
+
+	if m.Table == "" {
+		m.Table = a.config.Migrations.Table
+	}
+	if m.LockTimeout == 0 {
+		m.LockTimeout = a.config.Migrations.LockTimeout
+	}
+	if m.StepTimeout == 0 {
+		m.StepTimeout = a.config.Migrations.StepTimeout
+	}
+	if m.Timeout == 0 {
+		m.Timeout = a.config.Migrations.Timeout
+	}
+	if m.Directory == "" {
+		m.Directory = a.config.Migrations.Directory
+	}
+	// Reverting all migrations in production-like environments must be confirmed.
+	m.AllowDownAll = m.AllowDownAll || !a.config.Environment.IsProductionLike() || a.config.Migrations.ConfirmDownAll
+
+	onComplete := m.OnComplete
+	m.OnComplete = func(r migrate.Result) {
+		if err := a.messenger.Dispatch(migration.NewCompleted(r)); err != nil {
+			a.Logger().Errorf("error publishing migration result: %v", err)
+		}
+		if onComplete != nil {
+			onComplete(r)
+		}
+	}
+
 	return a.database.Migrate(m)
 }
 
 // Shutdown Shuts down all services of the application.
 func (a *App) Shutdown() {
-	if err := a.database.Shutdown(); err != nil {
-		a.Logger().Errorf("error shutting down database: %v", err)
+	if err := a.registry.Shutdown(); err != nil {
+		a.Logger().Errorf("error shutting down components: %v", err)
 	}
 	sentry.Flush(2 * time.Second)
 }
@@ -94,36 +281,43 @@ func (a *App) Logger() *zap.SugaredLogger {
 	return a.core.Log
 }
 
-// DatabaseConnection exposes the database connection.
-func (a *App) DatabaseConnection() *sql.Connection {
-	return a.database.Connection()
+// LogLevel changes the level of the logger at runtime, nil when the logger does not support it.
+func (a *App) LogLevel() *zap.AtomicLevel {
+	return a.core.LogLevel
 }
 
-func (a *App) initSentry() {
-	if "" == a.config.SentryDSN {
-		return
-	}
+// Messenger exposes the messenger.
+func (a *App) Messenger() msg.Messenger {
+	return a.messenger
+}
 
-	a.core.Log.Info("Starting to initialize Sentry - ", "DSN - ", a.config.SentryDSN)
+// Tasks exposes the task tracker for asynchronously handled requests.
+// Tasks are stored in the database, the tracker is nil when no database is configured.
+func (a *App) Tasks() *task.Tracker {
+	return a.tasks
+}
 
-	if err := sentry.Init(sentry.ClientOptions{
-		Dsn:         a.config.SentryDSN,
-		Environment: string(a.config.Environment),
-	}); err != nil {
-		a.core.Log.Panic("Failed to initialize Sentry", "error", err)
-	}
+// Idempotency exposes the store of idempotency.Middleware for unsafe endpoints.
+// Keys are stored in the database, the store is nil when no database is configured.
+func (a *App) Idempotency() *idempotency.Store {
+	return a.idempotency
 }
 
-func createMessenger(core *app.App, c Configuration) msg.Messenger {
-	return msg.New(msg.Config{
-		Log:            core.Log,
-		Shutdown:       core.Shutdown,
-		Environment:    string(c.Environment),
-		RestartTimeout: 10 * time.Second,
-		PubsubConfig: msg.PubsubConfig{
-			Emulator:        c.Pubsub.Emulator,
-			Project:         c.Pubsub.Project,
-			DeadLetterTopic: "bootstrap-go-service.dead",
-		},
-	})
+// Registry exposes the components of the application, e.g. to resolve components added with Override.
+func (a *App) Registry() *registry.Registry {
+	return a.registry
+}
+
+// HasDatabase returns true if a database is configured.
+func (a *App) HasDatabase() bool {
+	return a.database != nil
+}
+
+// DatabaseConnection exposes the database connection, it is nil when no database is configured.
+func (a *App) DatabaseConnection() *sql.Connection {
+	if a.database == nil {
+		return nil
+	}
+
+	return a.database.Connection()
 }
@@ -0,0 +1,168 @@
+// Package apptest runs the full application in-process for black-box tests of its wiring.
+//
+// The database is replaced by a sqlmock and the messenger uses the file adapter in a temporary directory,
+// so the application runs without a database, Pub/Sub or network access.
+package apptest
+
+import (
+	"flag"
+	"fmt"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/DATA-DOG/go-sqlmock"
+	"gitlab.com/btcdirect-api/bootstrap-go-service/internal/app"
+	"gitlab.com/btcdirect-api/bootstrap-go-service/internal/db"
+	"gitlab.com/btcdirect-api/bootstrap-go-service/internal/http/server"
+	"gitlab.com/btcdirect-api/bootstrap-go-service/internal/messenger"
+	"gitlab.com/btcdirect-api/bootstrap-go-service/internal/sql"
+	"gitlab.com/btcdirect-api/go-modules/logger"
+)
+
+// Maximum time to wait for the application to shut down.
+const shutdownTimeout = 30 * time.Second
+
+// Makes the sqlmock DSN unique per application, sqlmock does not allow reusing a DSN.
+var instances atomic.Int64
+
+// Instance is a running application.
+type Instance struct {
+	App *app.App
+	// URL of the test server serving the routes of the application.
+	URL    string
+	Client *http.Client
+	// Mock of the database, set the expectations for the queries of the test.
+	DB sqlmock.Sqlmock
+}
+
+// Config returns the default configuration, as if no flags or environment variables were given.
+func Config() app.Configuration {
+	c := app.Configuration{}
+	if err := c.BindFlags(flag.NewFlagSet("apptest", flag.ContinueOnError)); err != nil {
+		panic(err)
+	}
+
+	c.Environment = app.Dev
+	return c
+}
+
+// Run boots the application with the configuration and overrides, and serves its routes from a test server.
+// The application is shut down when the test finishes.
+//
+// The database and the messenger directory of the configuration are replaced by fakes.
+// Use the overrides to inject other fakes, e.g. app.WithMessenger.
+func Run(t testing.TB, c app.Configuration, overrides ...app.Option) *Instance {
+	t.Helper()
+
+	dsn := fmt.Sprintf("sqlmock_apptest_%d", instances.Add(1))
+	_, mock, err := sqlmock.NewWithDSN(dsn)
+	if err != nil {
+		t.Fatalf("apptest: create database mock: %s", err)
+	}
+
+	c.Pubsub.LocalDirectory = t.TempDir()
+	c.SentryDSN = ""
+
+	database := db.NewWithConnection(&sql.Connection{
+		Driver:             "sqlmock",
+		DSN:                dsn,
+		Log:                logger.NewLogger(c.LogLevel).With("component", "database"),
+		QueryTimeout:       c.QueryTimeout,
+		SlowQueryThreshold: c.SlowQuery,
+		DegradedLatency:    c.DegradedPing,
+		StatementCacheSize: c.StmtCache,
+	})
+
+	defaults := []app.Option{app.WithShutdownTimeout(0), app.WithDatabase(database)}
+	application := app.Initialize(c, append(defaults, overrides...)...)
+
+	srv := httptest.NewServer(server.Handler(application))
+
+	stopped := make(chan struct{})
+	go func() {
+		defer close(stopped)
+		application.Run()
+	}()
+
+	t.Cleanup(func() {
+		srv.Close()
+		application.Stop()
+
+		select {
+		case <-stopped:
+		case <-time.After(shutdownTimeout):
+			t.Errorf("apptest: application did not shut down within %s", shutdownTimeout)
+		}
+
+		mock.ExpectClose()
+		application.Shutdown()
+
+		if err := mock.ExpectationsWereMet(); err != nil {
+			t.Errorf("apptest: %s", err)
+		}
+	})
+
+	return &Instance{
+		App:    application,
+		URL:    srv.URL,
+		Client: srv.Client(),
+		DB:     mock,
+	}
+}
+
+// NewRequest creates a request to the application, the path is relative to the test server.
+func (i *Instance) NewRequest(t testing.TB, method, path string, body io.Reader) *http.Request {
+	t.Helper()
+
+	req, err := http.NewRequest(method, i.URL+path, body)
+	if err != nil {
+		t.Fatalf("apptest: create request: %s", err)
+	}
+
+	return req
+}
+
+// Do sends the request to the application, the body of the response is closed when the test finishes.
+func (i *Instance) Do(t testing.TB, req *http.Request) *http.Response {
+	t.Helper()
+
+	method, path := req.Method, req.URL.Path
+	res, err := i.Client.Do(req)
+	if err != nil {
+		t.Fatalf("apptest: %s %s: %s", method, path, err)
+	}
+	t.Cleanup(func() { res.Body.Close() })
+
+	return res
+}
+
+// Get sends a GET request to the application.
+func (i *Instance) Get(t testing.TB, path string) *http.Response {
+	t.Helper()
+
+	return i.Do(t, i.NewRequest(t, http.MethodGet, path, nil))
+}
+
+// Post sends a POST request with a JSON body to the application.
+func (i *Instance) Post(t testing.TB, path, body string) *http.Response {
+	t.Helper()
+
+	req := i.NewRequest(t, http.MethodPost, path, strings.NewReader(body))
+	req.Header.Set("Content-Type", "application/json")
+
+	return i.Do(t, req)
+}
+
+// Inject dispatches a message to the messenger of the application, so it is received by its subscribed handlers.
+func (i *Instance) Inject(t testing.TB, msg messenger.Message) {
+	t.Helper()
+
+	if err := i.App.Messenger().Dispatch(msg); err != nil {
+		t.Fatalf("apptest: inject message: %s", err)
+	}
+}
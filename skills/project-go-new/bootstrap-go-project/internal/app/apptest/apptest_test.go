@@ -0,0 +1,33 @@
+package apptest_test
+
+import (
+	"net/http"
+	"testing"
+
+	"gitlab.com/btcdirect-api/bootstrap-go-service/internal/app/apptest"
+)
+
+func TestRun(t *testing.T) {
+	i := apptest.Run(t, apptest.Config())
+
+	if !i.App.HasDatabase() {
+		t.Fatal("HasDatabase() = false, want the mock database")
+	}
+
+	tests := []struct {
+		path string
+		want int
+	}{
+		{path: "/health", want: http.StatusOK},
+		{path: "/ready", want: http.StatusOK},
+		{path: "/unknown", want: http.StatusNotFound},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.path, func(t *testing.T) {
+			if res := i.Get(t, tt.path); res.StatusCode != tt.want {
+				t.Errorf("GET %s = %d, want %d", tt.path, res.StatusCode, tt.want)
+			}
+		})
+	}
+}
@@ -0,0 +1,167 @@
+package app
+
+import (
+	"context"
+	"fmt"
+	"strings"
+	"time"
+
+	"gitlab.com/btcdirect-api/bootstrap-go-service/internal/core"
+	"gitlab.com/btcdirect-api/bootstrap-go-service/internal/db"
+	"gitlab.com/btcdirect-api/bootstrap-go-service/internal/idempotency"
+	msg "gitlab.com/btcdirect-api/bootstrap-go-service/internal/messenger"
+	"gitlab.com/btcdirect-api/bootstrap-go-service/internal/registry"
+	"gitlab.com/btcdirect-api/bootstrap-go-service/internal/sql"
+	"gitlab.com/btcdirect-api/bootstrap-go-service/internal/task"
+	"go.uber.org/zap"
+)
+
+// Handlers are the message handlers the application subscribes to.
+type Handlers []msg.MessageHandler
+
+// Registers the constructors of the application components.
+// Add new components here, constructors resolve their dependencies from the registry.
+// The Configuration and *core.App are always available.
+//
+// The database is optional, it is only provided when a DSN is configured.
+// Components depending on it are only resolved when it is provided.
+func provideComponents(r *registry.Registry) {
+	if registry.MustResolve[Configuration](r).DatabaseDSN != "" {
+		registry.Provide(r, newDatabase)
+	}
+	registry.Provide(r, newMessenger)
+	registry.Provide(r, newTasks)
+	registry.Provide(r, newIdempotency)
+	registry.Provide(r, newHandlers)
+}
+
+func newDatabase(r *registry.Registry) (Database, error) {
+	c := registry.MustResolve[Configuration](r)
+	base := registry.MustResolve[*core.App](r)
+
+	dsn := c.DatabaseDSN
+	if sql.IsSecretReference(dsn) {
+		ctx, cancel := context.WithTimeout(context.Background(), 30*time.Second)
+		defer cancel()
+
+		resolved, err := sql.ResolveSecret(ctx, dsn)
+		if err != nil {
+			return nil, fmt.Errorf("resolving the database secret: %w", err)
+		}
+		dsn = resolved
+	}
+
+	database := db.New(migrationDSN(c, dsn), base.Log, cloudSQLOptions(c))
+	database.Connection().QueryTimeout = c.QueryTimeout
+	database.Connection().ReplicaDSNs = c.ReplicaDSNs
+	database.Connection().SlowQueryThreshold = c.SlowQuery
+	database.Connection().DegradedLatency = c.DegradedPing
+	database.Connection().StatementCacheSize = c.StmtCache
+	database.Start()
+	r.OnShutdown(database.Shutdown)
+
+	if sql.IsSecretReference(c.DatabaseDSN) && c.SecretRefresh > 0 {
+		database.RefreshSecret(c.DatabaseDSN, dsn, c.SecretRefresh)
+	}
+
+	return database, nil
+}
+
+// Returns the DSN to connect with, MySQL DSNs allow multi statements when the application runs migrations.
+// The DSN is resolved first, the dialect of a Secret Manager reference is not known.
+func migrationDSN(c Configuration, dsn string) string {
+	if (!c.Migrations.Command && !c.Migrations.OnStart) || sql.IsPostgresDSN(dsn) {
+		return dsn
+	}
+
+	suffix := "?"
+	if strings.Contains(dsn, suffix) {
+		suffix = "&"
+	}
+
+	return dsn + suffix + "multiStatements=true"
+}
+
+// Returns the options of the Cloud SQL connector, also used to verify the database credentials.
+func cloudSQLOptions(c Configuration) sql.CloudSQLOptions {
+	return sql.CloudSQLOptions{
+		PublicIP:        c.CloudSQL.PublicIP,
+		LazyRefresh:     c.CloudSQL.LazyRefresh,
+		CredentialsFile: c.CloudSQL.CredentialsFile,
+		DisableIAMAuthN: c.CloudSQL.DisableIAMAuthN,
+	}
+}
+
+func newMessenger(r *registry.Registry) (msg.Messenger, error) {
+	c := registry.MustResolve[Configuration](r)
+	base := registry.MustResolve[*core.App](r)
+
+	config, err := messengerConfig(c, base.Log, base.Shutdown)
+	if err != nil {
+		return nil, err
+	}
+
+	return msg.New(config), nil
+}
+
+// Returns the messenger configuration, also used to verify the messenger credentials.
+func messengerConfig(c Configuration, log *zap.SugaredLogger, shutdown *core.GracefulShutdown) (msg.Config, error) {
+	codec, err := msg.CodecByName(c.Pubsub.Codec)
+	if err != nil {
+		return msg.Config{}, err
+	}
+
+	return msg.Config{
+		Log:                log,
+		Shutdown:           shutdown,
+		Environment:        c.Environment.String(),
+		RestartTimeout:     10 * time.Second,
+		BacklogInterval:    c.Pubsub.BacklogInterval,
+		HandlerTimeout:     c.Pubsub.HandlerTimeout,
+		MaxExtension:       c.Pubsub.MaxExtension,
+		MaxExtensionPeriod: c.Pubsub.ExtensionPeriod,
+		TraceSampleRates:   c.Pubsub.TraceRates,
+		PubsubConfig: msg.PubsubConfig{
+			Emulator:        c.Pubsub.Emulator,
+			Project:         c.Pubsub.Project,
+			DeadLetterTopic: "bootstrap-go-service.dead",
+			QueueProjects:   c.Pubsub.QueueProjects,
+			Codec:           codec,
+		},
+		FileConfig: msg.FileConfig{
+			Directory: c.Pubsub.LocalDirectory,
+		},
+	}, nil
+}
+
+func newTasks(r *registry.Registry) (*task.Tracker, error) {
+	database := registry.MustResolve[Database](r)
+	base := registry.MustResolve[*core.App](r)
+
+	return task.NewTracker(database.Connection(), base.Log), nil
+}
+
+// Interval of removing the expired idempotency keys.
+const idempotencyPurgeInterval = time.Hour
+
+func newIdempotency(r *registry.Registry) (*idempotency.Store, error) {
+	c := registry.MustResolve[Configuration](r)
+	database := registry.MustResolve[Database](r)
+	base := registry.MustResolve[*core.App](r)
+
+	store := idempotency.NewStore(database.Connection(), c.IdempotencyTTL, base.Log)
+
+	ctx, done := base.Shutdown.AddHook("idempotency")
+	go func() {
+		defer done()
+		store.PurgeEvery(ctx, idempotencyPurgeInterval)
+	}()
+
+	return store, nil
+}
+
+func newHandlers(r *registry.Registry) (Handlers, error) {
+	// TODO: Add your message handlers here
+	// Wrap handlers with tasks.Handler(h) to update the task status of their messages.
+	return Handlers{}, nil
+}
@@ -1,25 +1,97 @@
 package app
 
-const (
-	Dev     Environment = "dev"
-	Stage   Environment = "stage"
-	Acc     Environment = "acc"
-	Sandbox Environment = "sandbox"
-	Prod    Environment = "prod"
-)
-
-type Environment string
+import "time"
 
+// Configuration of the application.
+//
+// Every tagged field is bound to a command line flag by BindFlags:
+//   - flag: name of the command line flag
+//   - env: environment variable used as default for the flag
+//   - default: default when the environment variable is not set
+//   - usage: description of the flag
+//
+// Nested structs without a flag tag are bound recursively.
 type Configuration struct {
-	Environment Environment
-	LogLevel    string
-	HTTPPort    string
-	SentryDSN   string
-	DatabaseDSN string
-	Pubsub      pubsubConfig
+	Environment        Environment   `flag:"env" env:"APP_ENV" default:"dev" usage:"Environment"`
+	LogLevel           string        `flag:"loglevel" env:"LOG_LEVEL" default:"info" usage:"Log output level"`
+	HTTPPort           string        `flag:"port" env:"HTTP_PORT" default:"8080" usage:"HTTP port"`
+	HTTPSocket         string        `flag:"socket" env:"HTTP_SOCKET" usage:"HTTP Unix socket path (replaces the HTTP port)"`
+	HTTPReusePort      bool          `flag:"reuse-port" env:"HTTP_REUSE_PORT" usage:"Enable SO_REUSEPORT so a new process can listen on the same port"`
+	HTTPHandover       bool          `flag:"handover" env:"HTTP_HANDOVER" usage:"Hand over the listener to a new process on SIGUSR2"`
+	HTTPH2C            bool          `flag:"h2c" env:"HTTP_H2C" usage:"Serve HTTP/2 without TLS, for gRPC clients behind a load balancer terminating TLS"`
+	HTTPRequestTimeout time.Duration `flag:"http-request-timeout" env:"HTTP_REQUEST_TIMEOUT" default:"30s" usage:"Default deadline of the request context, override it per route with middleware.Timeout (0 disables it)"`
+	HTTPDrainTimeout   time.Duration `flag:"http-drain-timeout" env:"HTTP_DRAIN_TIMEOUT" default:"5s" usage:"Maximum time the HTTP server waits for open requests on shutdown before closing the connections"`
+	TrustedProxies     []string      `flag:"trusted-proxies" env:"TRUSTED_PROXIES" usage:"CIDRs or IP addresses of the proxies whose forwarding headers are trusted for the client IP (comma separated)"`
+	OpsPort            string        `flag:"ops-port" env:"OPS_PORT" usage:"Port of the internal listener for /health, /ready, /metrics, /debug and /log-level (served on the HTTP port when empty)"`
+	SentryDSN          string        `flag:"sentry-dsn" env:"SENTRY_DSN" usage:"Sentry DSN"`
+	Sentry             sentryConfig
+	AdminToken         string        `flag:"admin-token" env:"ADMIN_TOKEN" usage:"Bearer token for the admin endpoints (disabled when empty)"`
+	AdminAllowedIPs    []string      `flag:"admin-allowed-ips" env:"ADMIN_ALLOWED_IPS" usage:"CIDRs or IP addresses allowed to call the admin, debug and log level endpoints, all when empty (comma separated)"`
+	DebugEndpoints     bool          `flag:"debug-endpoints" env:"DEBUG_ENDPOINTS" usage:"Serve the pprof and expvar endpoints on /debug (requires the admin token)"`
+	DatabaseDSN        string        `flag:"database" env:"DATABASE_URL" usage:"Database dsn"`
+	SecretRefresh      time.Duration `flag:"database-secret-refresh" env:"DATABASE_SECRET_REFRESH_INTERVAL" usage:"Interval to refresh a Secret Manager DATABASE_URL (0 disables)"`
+	ReplicaDSNs        []string      `flag:"database-replicas" env:"DATABASE_REPLICA_URLS" usage:"Database dsns of the read replicas (comma separated)"`
+	DegradedPing       time.Duration `flag:"database-degraded-latency" env:"DATABASE_DEGRADED_LATENCY" default:"250ms" usage:"Ping latency percentile above which readiness reports the database as degraded"`
+	SlowQuery          time.Duration `flag:"database-slow-query-threshold" env:"DATABASE_SLOW_QUERY_THRESHOLD" default:"1s" usage:"Log helper queries taking longer (0 disables)"`
+	SchemaCheck        string        `flag:"database-schema-validation" env:"DATABASE_SCHEMA_VALIDATION" default:"log" usage:"Validate the registered tables against the database schema on start (off, log, fail)"`
+	StmtCache          int           `flag:"database-statement-cache-size" env:"DATABASE_STATEMENT_CACHE_SIZE" default:"100" usage:"Number of prepared helper statements kept per connection (0 disables)"`
+	IdempotencyTTL     time.Duration `flag:"idempotency-ttl" env:"IDEMPOTENCY_TTL" default:"24h" usage:"Time the responses of requests with an Idempotency-Key are replayed for retries"`
+	QueryTimeout       time.Duration `flag:"database-query-timeout" env:"DATABASE_QUERY_TIMEOUT" default:"2s" usage:"Timeout of the SQL helper queries (negative only applies the deadline of the caller)"`
+	CORS               corsConfig
+	GoogleAuth         googleAuthConfig
+	CloudSQL           cloudSQLConfig
+	Migrations         migrationsConfig
+	Pubsub             pubsubConfig
+}
+
+type sentryConfig struct {
+	SampleRate       float64 `flag:"sentry-sample-rate" env:"SENTRY_SAMPLE_RATE" default:"1" usage:"Share of error events sent to Sentry (0-1]"`
+	TracesSampleRate float64 `flag:"sentry-traces-sample-rate" env:"SENTRY_TRACES_SAMPLE_RATE" default:"-1" usage:"Share of transactions sent to Sentry (negative uses the environment default)"`
+	Release          string  `flag:"sentry-release" env:"SENTRY_RELEASE" usage:"Release reported to Sentry (defaults to the VCS revision of the build)"`
+}
+
+type corsConfig struct {
+	Origins     []string      `flag:"cors-allowed-origins" env:"CORS_ALLOWED_ORIGINS" usage:"Origins allowed to call the service cross-origin (comma separated, * for any, disabled when empty)"`
+	Methods     []string      `flag:"cors-allowed-methods" env:"CORS_ALLOWED_METHODS" default:"GET,POST,PUT,PATCH,DELETE" usage:"Methods allowed for cross-origin requests (comma separated)"`
+	Headers     []string      `flag:"cors-allowed-headers" env:"CORS_ALLOWED_HEADERS" default:"Authorization,Content-Type,Idempotency-Key,X-Request-ID" usage:"Request headers allowed for cross-origin requests (comma separated, * for any)"`
+	Credentials bool          `flag:"cors-allow-credentials" env:"CORS_ALLOW_CREDENTIALS" usage:"Allow cookies in cross-origin requests of the listed origins"`
+	MaxAge      time.Duration `flag:"cors-max-age" env:"CORS_MAX_AGE" default:"10m" usage:"Time browsers cache a preflight response (0 leaves it to the browser)"`
+}
+
+type googleAuthConfig struct {
+	Audiences []string `flag:"google-auth-audiences" env:"GOOGLE_AUTH_AUDIENCES" usage:"Audiences of the Google-signed ID tokens accepted by middleware.GoogleIDToken (comma separated)"`
+	Emails    []string `flag:"google-auth-emails" env:"GOOGLE_AUTH_EMAILS" usage:"Service accounts allowed to call with a Google-signed ID token (comma separated, any when empty)"`
+}
+
+type cloudSQLConfig struct {
+	PublicIP        bool   `flag:"cloudsql-public-ip" env:"CLOUDSQL_PUBLIC_IP" usage:"Connect to Cloud SQL over the public IP"`
+	LazyRefresh     bool   `flag:"cloudsql-lazy-refresh" env:"CLOUDSQL_LAZY_REFRESH" usage:"Refresh the Cloud SQL certificates on connect instead of in the background"`
+	CredentialsFile string `flag:"cloudsql-credentials-file" env:"CLOUDSQL_CREDENTIALS_FILE" usage:"Service account credentials file of the Cloud SQL connector"`
+	DisableIAMAuthN bool   `flag:"cloudsql-disable-iam-authn" env:"CLOUDSQL_DISABLE_IAM_AUTHN" usage:"Authenticate to Cloud SQL with the DSN password instead of IAM"`
+}
+
+type migrationsConfig struct {
+	Table          string        `flag:"migrate-table" env:"MIGRATE_TABLE" default:"schema_migrations" usage:"Table holding the migration version, unique per service sharing the database"`
+	LockTimeout    time.Duration `flag:"migrate-lock-timeout" env:"MIGRATE_LOCK_TIMEOUT" default:"5m" usage:"Time to wait for the migration lock held by another instance"`
+	StepTimeout    time.Duration `flag:"migrate-step-timeout" env:"MIGRATE_STEP_TIMEOUT" usage:"Maximum duration of a single migration (0 is unbounded)"`
+	Timeout        time.Duration `flag:"migrate-timeout" env:"MIGRATE_TIMEOUT" usage:"Maximum duration of a migration run, stops after the running migration (0 is unbounded)"`
+	Directory      string        `flag:"migrate-dir" env:"MIGRATE_DIR" usage:"Directory with migration files replacing the embedded migrations"`
+	ConfirmDownAll bool          `flag:"migrate-confirm-down-all" env:"MIGRATE_CONFIRM_DOWN_ALL" usage:"Allow reverting all migrations with -migrate down all in production-like environments"`
+	OnStart        bool          `flag:"migrate-on-start" env:"MIGRATE_ON_START" usage:"Apply the pending up migrations when the application starts"`
+	MaxPending     int           `flag:"migrate-on-start-max-pending" env:"MIGRATE_ON_START_MAX_PENDING" default:"5" usage:"Maximum number of pending migrations applied on start (0 is unlimited)"`
+	// Set by the -migrate command, which runs the migrations instead of the application.
+	Command bool
 }
 
 type pubsubConfig struct {
-	Emulator string
-	Project  string
+	Emulator        string             `flag:"pubsub-emulator" env:"PUBSUB_EMULATOR" usage:"Pubsub emulator host"`
+	Project         string             `flag:"pubsub-project" env:"PUBSUB_PROJECT" usage:"Pubsub project id"`
+	QueueProjects   map[string]string  `flag:"pubsub-queue-projects" env:"PUBSUB_QUEUE_PROJECTS" usage:"Pubsub project per queue (queue=project,...)"`
+	BacklogInterval time.Duration      `flag:"pubsub-backlog-interval" env:"PUBSUB_BACKLOG_INTERVAL" default:"1m" usage:"Interval to sample the backlog of subscribed queues (0 disables)"`
+	HandlerTimeout  time.Duration      `flag:"messenger-handler-timeout" env:"MESSENGER_HANDLER_TIMEOUT" default:"1m" usage:"Maximum execution time of a message handler (0 disables)"`
+	MaxExtension    time.Duration      `flag:"messenger-max-extension" env:"MESSENGER_MAX_EXTENSION" default:"10m" usage:"Maximum time the ack deadline of a message is extended while it is handled"`
+	ExtensionPeriod time.Duration      `flag:"messenger-max-extension-period" env:"MESSENGER_MAX_EXTENSION_PERIOD" usage:"Maximum duration of a single ack deadline extension (10s-10m, 0 lets the client decide)"`
+	TraceRates      map[string]float64 `flag:"messenger-trace-sample-rates" env:"MESSENGER_TRACE_SAMPLE_RATES" usage:"Share of handled messages traced per queue (queue=rate,...)"`
+	LocalDirectory  string             `flag:"messenger-dir" env:"MESSENGER_DIR" usage:"Directory for the file based messenger (replaces Pub/Sub)"`
+	Codec           string             `flag:"pubsub-codec" env:"PUBSUB_CODEC" default:"json" usage:"Envelope codec of published messages (json, protobuf, msgpack, raw)"`
 }
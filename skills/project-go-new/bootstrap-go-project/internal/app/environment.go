@@ -0,0 +1,77 @@
+package app
+
+import "fmt"
+
+var (
+	Dev     = Environment{name: "dev", debugEndpoints: true, sentryTracesSampleRate: 1}
+	Stage   = Environment{name: "stage", debugEndpoints: true, sentryTracesSampleRate: 1}
+	Acc     = Environment{name: "acc", sentryRequired: true, sentryTracesSampleRate: 0.5}
+	Sandbox = Environment{name: "sandbox", productionLike: true, sentryRequired: true, sentryTracesSampleRate: 0.1}
+	Prod    = Environment{name: "prod", productionLike: true, sentryRequired: true, sentryTracesSampleRate: 0.1}
+)
+
+var environments = []Environment{Dev, Stage, Acc, Sandbox, Prod}
+
+// Environment the application runs in.
+// Use the predicates instead of comparing environments, so all components agree on what an environment allows.
+type Environment struct {
+	name           string
+	productionLike bool
+	debugEndpoints bool
+	sentryRequired bool
+	// Share of transactions sent to Sentry when not configured explicitly.
+	sentryTracesSampleRate float64
+}
+
+// ParseEnvironment returns the environment for the given name.
+func ParseEnvironment(name string) (Environment, error) {
+	for _, e := range environments {
+		if e.name == name {
+			return e, nil
+		}
+	}
+
+	return Environment{}, fmt.Errorf("invalid environment: %s", name)
+}
+
+// String returns the name of the environment, e.g. "prod".
+func (e Environment) String() string {
+	return e.name
+}
+
+// IsProductionLike returns true for environments serving real customers or partners.
+// These environments must not leak debug information.
+func (e Environment) IsProductionLike() bool {
+	return e.productionLike
+}
+
+// AllowsDebugEndpoints returns true if debug and profiling endpoints may be exposed.
+func (e Environment) AllowsDebugEndpoints() bool {
+	return e.debugEndpoints
+}
+
+// SentryRequired returns true if the application must not start without Sentry.
+func (e Environment) SentryRequired() bool {
+	return e.sentryRequired
+}
+
+// SentryTracesSampleRate returns the default share of transactions sent to Sentry.
+func (e Environment) SentryTracesSampleRate() float64 {
+	return e.sentryTracesSampleRate
+}
+
+// MarshalText implements encoding.TextMarshaler.
+func (e Environment) MarshalText() ([]byte, error) {
+	return []byte(e.name), nil
+}
+
+// UnmarshalText implements encoding.TextUnmarshaler, the environment must be a valid environment name.
+func (e *Environment) UnmarshalText(text []byte) error {
+	env, err := ParseEnvironment(string(text))
+	if err != nil {
+		return err
+	}
+
+	*e = env
+	return nil
+}
@@ -0,0 +1,120 @@
+package app
+
+import (
+	"context"
+	"flag"
+	"fmt"
+	"reflect"
+
+	"gitlab.com/btcdirect-api/bootstrap-go-service/internal/http/router"
+	"gitlab.com/btcdirect-api/bootstrap-go-service/internal/registry"
+)
+
+// RouteRegistrar registers routes of the service on the router, after the routes of the bootstrap.
+// Routes on admin require the admin token.
+type RouteRegistrar func(r router.Router, admin router.Router, a *App)
+
+// HandlerRegistrar returns message handlers of the service, resolving their dependencies from the registry.
+type HandlerRegistrar func(r *registry.Registry) (Handlers, error)
+
+// WithRoutes adds routes to the HTTP server, so services do not need to edit the bootstrap routes.
+func WithRoutes(register RouteRegistrar) Option {
+	return func(o *options) {
+		o.routes = append(o.routes, register)
+	}
+}
+
+// WithRouter serves the routes with the router returned by newRouter instead of gorilla/mux, e.g. router.NewServeMux.
+// It is called for each server, the ops server gets its own router.
+func WithRouter(newRouter func() router.Router) Option {
+	return func(o *options) {
+		o.newRouter = newRouter
+	}
+}
+
+// WithHandlers subscribes the application to the returned message handlers, next to the handlers of newHandlers.
+func WithHandlers(register HandlerRegistrar) Option {
+	return func(o *options) {
+		o.handlers = append(o.handlers, register)
+	}
+}
+
+// ReadinessCheck reports whether a dependency of the service is available, e.g. an external API.
+// Return quickly, the readiness handler waits for all checks.
+type ReadinessCheck func(ctx context.Context) error
+
+// WithReadinessCheck adds a named dependency to /ready. A failing check returns 503 Service Unavailable,
+// add only dependencies without which the service is useless.
+func WithReadinessCheck(name string, check ReadinessCheck) Option {
+	return func(o *options) {
+		if o.readinessChecks == nil {
+			o.readinessChecks = make(map[string]ReadinessCheck)
+		}
+		o.readinessChecks[name] = check
+	}
+}
+
+// WithShutdownHook runs the hook when the application shuts down.
+// Hooks run before the components of the bootstrap are stopped, in reverse order of registration.
+func WithShutdownHook(hook func() error) Option {
+	return func(o *options) {
+		o.shutdownHooks = append(o.shutdownHooks, hook)
+	}
+}
+
+// WithConfig registers a configuration section of the service, constructors resolve it from the registry.
+// Bind its flags with BindSection before parsing the flags.
+func WithConfig[T any](section T) Option {
+	return func(o *options) {
+		o.providers = append(o.providers, func(r *registry.Registry) {
+			registry.Value(r, section)
+		})
+	}
+}
+
+// BindSection registers the tagged fields of a configuration section of the service on the flag set,
+// following the tags of Configuration. The section must be a pointer to a struct.
+func BindSection(fs *flag.FlagSet, section interface{}) error {
+	v := reflect.ValueOf(section)
+	if v.Kind() != reflect.Ptr || v.Elem().Kind() != reflect.Struct {
+		return fmt.Errorf("configuration section must be a pointer to a struct, got %T", section)
+	}
+
+	return bindFlags(fs, v.Elem())
+}
+
+// RegisterRoutes registers the routes added with WithRoutes.
+// It is called by the HTTP server after registering the routes of the bootstrap.
+func (a *App) RegisterRoutes(r router.Router, admin router.Router) {
+	for _, register := range a.routes {
+		register(r, admin, a)
+	}
+}
+
+// NewRouter returns a router of WithRouter, nil when the default gorilla/mux router is used.
+func (a *App) NewRouter() router.Router {
+	if a.newRouter == nil {
+		return nil
+	}
+
+	return a.newRouter()
+}
+
+// ReadinessChecks returns the checks added with WithReadinessCheck by name.
+func (a *App) ReadinessChecks() map[string]ReadinessCheck {
+	return a.readinessChecks
+}
+
+// Returns the handlers of newHandlers with the handlers added with WithHandlers.
+func resolveHandlers(r *registry.Registry, registrars []HandlerRegistrar) (Handlers, error) {
+	handlers := registry.MustResolve[Handlers](r)
+	for _, register := range registrars {
+		h, err := register(r)
+		if err != nil {
+			return nil, fmt.Errorf("registering message handlers: %w", err)
+		}
+		handlers = append(handlers, h...)
+	}
+
+	return handlers, nil
+}
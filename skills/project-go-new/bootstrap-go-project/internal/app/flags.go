@@ -0,0 +1,184 @@
+package app
+
+import (
+	"encoding"
+	"flag"
+	"fmt"
+	"os"
+	"reflect"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+)
+
+var durationType = reflect.TypeOf(time.Duration(0))
+
+// BindFlags registers a flag for every tagged field of the configuration on the flag set.
+// The default of a flag is read from its environment variable and falls back to the default tag.
+//
+// An error is returned when a default can not be parsed into its field.
+func (c *Configuration) BindFlags(fs *flag.FlagSet) error {
+	return bindFlags(fs, reflect.ValueOf(c).Elem())
+}
+
+func bindFlags(fs *flag.FlagSet, v reflect.Value) error {
+	t := v.Type()
+
+	for i := 0; i < t.NumField(); i++ {
+		field := t.Field(i)
+		name := field.Tag.Get("flag")
+
+		if name == "" {
+			if field.Type.Kind() == reflect.Struct {
+				if err := bindFlags(fs, v.Field(i)); err != nil {
+					return err
+				}
+			}
+			continue
+		}
+
+		value := &fieldValue{v: v.Field(i)}
+
+		def := field.Tag.Get("default")
+		if env, ok := os.LookupEnv(field.Tag.Get("env")); ok && env != "" {
+			def = env
+		}
+
+		if def != "" {
+			if err := value.Set(def); err != nil {
+				return fmt.Errorf("invalid default for flag %s: %w", name, err)
+			}
+		}
+
+		usage := field.Tag.Get("usage")
+		if env := field.Tag.Get("env"); env != "" {
+			usage += " (env " + env + ")"
+		}
+
+		fs.Var(value, name, usage)
+	}
+
+	return nil
+}
+
+// fieldValue implements flag.Value for a configuration field.
+type fieldValue struct {
+	v reflect.Value
+}
+
+func (f *fieldValue) String() string {
+	// The flag package calls String on a zero value to detect zero defaults.
+	if f == nil || !f.v.IsValid() {
+		return ""
+	}
+
+	if m, ok := f.v.Interface().(encoding.TextMarshaler); ok {
+		b, _ := m.MarshalText()
+		return string(b)
+	}
+
+	switch {
+	case f.v.Type() == durationType:
+		return time.Duration(f.v.Int()).String()
+	case f.v.Kind() == reflect.Slice && f.v.Type().Elem().Kind() == reflect.String:
+		return strings.Join(f.v.Convert(reflect.TypeOf([]string(nil))).Interface().([]string), ",")
+	case f.v.Kind() == reflect.Map:
+		pairs := make([]string, 0, f.v.Len())
+		for _, k := range f.v.MapKeys() {
+			pairs = append(pairs, k.String()+"="+fmt.Sprint(f.v.MapIndex(k).Interface()))
+		}
+		sort.Strings(pairs)
+		return strings.Join(pairs, ",")
+	default:
+		return fmt.Sprint(f.v.Interface())
+	}
+}
+
+func (f *fieldValue) Set(s string) error {
+	if u, ok := f.v.Addr().Interface().(encoding.TextUnmarshaler); ok {
+		return u.UnmarshalText([]byte(s))
+	}
+
+	switch {
+	case f.v.Type() == durationType:
+		d, err := time.ParseDuration(s)
+		if err != nil {
+			return err
+		}
+		f.v.SetInt(int64(d))
+	case f.v.Kind() == reflect.String:
+		f.v.SetString(s)
+	case f.v.Kind() == reflect.Bool:
+		b, err := strconv.ParseBool(s)
+		if err != nil {
+			return err
+		}
+		f.v.SetBool(b)
+	case f.v.Kind() == reflect.Float64:
+		n, err := strconv.ParseFloat(s, 64)
+		if err != nil {
+			return err
+		}
+		f.v.SetFloat(n)
+	case f.v.Kind() == reflect.Int || f.v.Kind() == reflect.Int64:
+		i, err := strconv.ParseInt(s, 10, 64)
+		if err != nil {
+			return err
+		}
+		f.v.SetInt(i)
+	case f.v.Kind() == reflect.Slice && f.v.Type().Elem().Kind() == reflect.String:
+		var values []string
+		for _, value := range strings.Split(s, ",") {
+			if value = strings.TrimSpace(value); value != "" {
+				values = append(values, value)
+			}
+		}
+		f.v.Set(reflect.ValueOf(values))
+	case f.v.Kind() == reflect.Map && f.v.Type().Key().Kind() == reflect.String && f.v.Type().Elem().Kind() == reflect.String:
+		m, err := parseMap(s)
+		if err != nil {
+			return err
+		}
+		f.v.Set(reflect.ValueOf(m))
+	case f.v.Kind() == reflect.Map && f.v.Type().Key().Kind() == reflect.String && f.v.Type().Elem().Kind() == reflect.Float64:
+		m, err := parseMap(s)
+		if err != nil {
+			return err
+		}
+		floats := make(map[string]float64, len(m))
+		for k, v := range m {
+			if floats[k], err = strconv.ParseFloat(v, 64); err != nil {
+				return fmt.Errorf("invalid value for %s: %w", k, err)
+			}
+		}
+		f.v.Set(reflect.ValueOf(floats))
+	default:
+		return fmt.Errorf("unsupported configuration type %s", f.v.Type())
+	}
+
+	return nil
+}
+
+// IsBoolFlag allows boolean flags to be given without a value.
+func (f *fieldValue) IsBoolFlag() bool {
+	return f.v.IsValid() && f.v.Kind() == reflect.Bool
+}
+
+// Parses a comma separated list of key=value pairs.
+func parseMap(input string) (map[string]string, error) {
+	m := map[string]string{}
+	if input == "" {
+		return m, nil
+	}
+
+	for _, pair := range strings.Split(input, ",") {
+		key, value, ok := strings.Cut(strings.TrimSpace(pair), "=")
+		if !ok || key == "" {
+			return nil, fmt.Errorf("invalid key=value pair: %s", pair)
+		}
+		m[key] = value
+	}
+
+	return m, nil
+}
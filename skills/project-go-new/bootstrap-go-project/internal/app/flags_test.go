@@ -0,0 +1,142 @@
+package app
+
+import (
+	"flag"
+	"io"
+	"reflect"
+	"testing"
+	"time"
+)
+
+func TestBindFlagsPrecedence(t *testing.T) {
+	tests := []struct {
+		name string
+		env  map[string]string
+		args []string
+		get  func(c Configuration) interface{}
+		want interface{}
+	}{
+		{
+			name: "default",
+			env:  map[string]string{"HTTP_PORT": ""},
+			get:  func(c Configuration) interface{} { return c.HTTPPort },
+			want: "8080",
+		},
+		{
+			name: "environment over default",
+			env:  map[string]string{"HTTP_PORT": "9090"},
+			get:  func(c Configuration) interface{} { return c.HTTPPort },
+			want: "9090",
+		},
+		{
+			name: "empty environment keeps the default",
+			env:  map[string]string{"HTTP_PORT": ""},
+			get:  func(c Configuration) interface{} { return c.HTTPPort },
+			want: "8080",
+		},
+		{
+			name: "flag over environment",
+			env:  map[string]string{"HTTP_PORT": "9090"},
+			args: []string{"-port", "7070"},
+			get:  func(c Configuration) interface{} { return c.HTTPPort },
+			want: "7070",
+		},
+		{
+			name: "no default",
+			env:  map[string]string{"DATABASE_URL": ""},
+			get:  func(c Configuration) interface{} { return c.DatabaseDSN },
+			want: "",
+		},
+		{
+			name: "duration",
+			env:  map[string]string{"HTTP_REQUEST_TIMEOUT": "1m"},
+			get:  func(c Configuration) interface{} { return c.HTTPRequestTimeout },
+			want: time.Minute,
+		},
+		{
+			name: "bool flag without value",
+			env:  map[string]string{"DEBUG_ENDPOINTS": "false"},
+			args: []string{"-debug-endpoints"},
+			get:  func(c Configuration) interface{} { return c.DebugEndpoints },
+			want: true,
+		},
+		{
+			name: "list",
+			env:  map[string]string{"TRUSTED_PROXIES": "10.0.0.0/8, ,192.168.1.1"},
+			get:  func(c Configuration) interface{} { return c.TrustedProxies },
+			want: []string{"10.0.0.0/8", "192.168.1.1"},
+		},
+		{
+			name: "nested default",
+			env:  map[string]string{"MIGRATE_TABLE": ""},
+			get:  func(c Configuration) interface{} { return c.Migrations.Table },
+			want: "schema_migrations",
+		},
+		{
+			name: "nested flag",
+			args: []string{"-migrate-table", "orders_migrations"},
+			get:  func(c Configuration) interface{} { return c.Migrations.Table },
+			want: "orders_migrations",
+		},
+		{
+			name: "map",
+			args: []string{"-messenger-trace-sample-rates", "orders=0.5,webhook=1"},
+			get:  func(c Configuration) interface{} { return c.Pubsub.TraceRates },
+			want: map[string]float64{"orders": 0.5, "webhook": 1},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			for key, value := range tt.env {
+				t.Setenv(key, value)
+			}
+
+			c := Configuration{}
+			fs := flag.NewFlagSet("test", flag.ContinueOnError)
+			if err := c.BindFlags(fs); err != nil {
+				t.Fatalf("BindFlags() error = %v", err)
+			}
+			if err := fs.Parse(tt.args); err != nil {
+				t.Fatalf("Parse(%v) error = %v", tt.args, err)
+			}
+
+			if got := tt.get(c); !reflect.DeepEqual(got, tt.want) {
+				t.Errorf("got %v, want %v", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestBindFlagsInvalid(t *testing.T) {
+	tests := []struct {
+		name string
+		env  map[string]string
+		args []string
+	}{
+		{name: "invalid environment", env: map[string]string{"HTTP_REQUEST_TIMEOUT": "soon"}},
+		{name: "invalid flag", args: []string{"-http-request-timeout", "soon"}},
+		{name: "invalid map", args: []string{"-pubsub-queue-projects", "orders"}},
+		{name: "invalid map value", args: []string{"-messenger-trace-sample-rates", "orders=half"}},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			for key, value := range tt.env {
+				t.Setenv(key, value)
+			}
+
+			c := Configuration{}
+			fs := flag.NewFlagSet("test", flag.ContinueOnError)
+			fs.SetOutput(io.Discard)
+
+			err := c.BindFlags(fs)
+			if err == nil {
+				err = fs.Parse(tt.args)
+			}
+			if err == nil {
+				t.Error("error = nil, want the invalid value refused")
+			}
+		})
+	}
+}
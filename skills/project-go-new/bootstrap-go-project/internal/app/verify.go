@@ -0,0 +1,140 @@
+package app
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"net/http"
+	"strings"
+	"time"
+
+	"github.com/getsentry/sentry-go"
+	"github.com/jmoiron/sqlx"
+	msg "gitlab.com/btcdirect-api/bootstrap-go-service/internal/messenger"
+	"gitlab.com/btcdirect-api/bootstrap-go-service/internal/sql"
+	"go.uber.org/zap"
+)
+
+// Maximum time a single check may take.
+const verifyTimeout = 10 * time.Second
+
+// Check exercises a credential of the configuration.
+type Check struct {
+	Name string
+	// Run returns ErrSkipped (wrapped with the reason) when the credential is not configured.
+	Run func(ctx context.Context) error
+}
+
+// CheckResult is the outcome of a single check.
+type CheckResult struct {
+	Name     string
+	Err      error
+	Skipped  bool
+	Duration time.Duration
+}
+
+var ErrSkipped = errors.New("skipped")
+
+// Verify exercises each configured credential and returns the result per credential:
+// the database login, the Pub/Sub publish permission on the dead letter topic and the Sentry DSN.
+// Services add checks for their own credentials, e.g. downstream API authentication.
+//
+// Nothing is written: no message is published and no event is sent to Sentry.
+func Verify(c Configuration, log *zap.SugaredLogger, checks ...Check) []CheckResult {
+	checks = append([]Check{
+		{Name: "database", Run: func(ctx context.Context) error { return verifyDatabase(ctx, c) }},
+		{Name: "messenger", Run: func(ctx context.Context) error { return verifyMessenger(ctx, c, log) }},
+		{Name: "sentry", Run: func(ctx context.Context) error { return verifySentry(ctx, c) }},
+	}, checks...)
+
+	results := make([]CheckResult, 0, len(checks))
+	for _, check := range checks {
+		ctx, cancel := context.WithTimeout(context.Background(), verifyTimeout)
+		start := time.Now()
+		err := check.Run(ctx)
+		cancel()
+
+		result := CheckResult{Name: check.Name, Err: err, Duration: time.Since(start)}
+		result.Skipped = errors.Is(err, ErrSkipped)
+		results = append(results, result)
+	}
+
+	return results
+}
+
+func verifyDatabase(ctx context.Context, c Configuration) error {
+	if c.DatabaseDSN == "" {
+		return fmt.Errorf("%w: no database configured", ErrSkipped)
+	}
+
+	dsn := c.DatabaseDSN
+	if sql.IsSecretReference(dsn) {
+		resolved, err := sql.ResolveSecret(ctx, dsn)
+		if err != nil {
+			return err
+		}
+		dsn = resolved
+	}
+
+	d, err := sql.DriverFromDSNWithOptions(dsn, cloudSQLOptions(c))
+	if d.Cleanup != nil {
+		defer d.Cleanup()
+	}
+	if err != nil {
+		return err
+	}
+
+	if d.DSN != "" {
+		dsn = d.DSN
+	}
+
+	db, err := sqlx.Open(d.Name, dsn)
+	if err != nil {
+		return err
+	}
+	defer db.Close()
+
+	return db.PingContext(ctx)
+}
+
+func verifyMessenger(ctx context.Context, c Configuration, log *zap.SugaredLogger) error {
+	config, err := messengerConfig(c, log, nil)
+	if err != nil {
+		return err
+	}
+
+	// The dead letter topic belongs to the service, so it exists in every environment.
+	return msg.Verify(ctx, config, config.PubsubConfig.DeadLetterTopic)
+}
+
+// Sends an empty envelope to Sentry, which authenticates the DSN without creating an event.
+func verifySentry(ctx context.Context, c Configuration) error {
+	if c.SentryDSN == "" {
+		return fmt.Errorf("%w: no Sentry DSN configured", ErrSkipped)
+	}
+
+	dsn, err := sentry.NewDsn(c.SentryDSN)
+	if err != nil {
+		return err
+	}
+
+	body := strings.NewReader(fmt.Sprintf(`{"dsn":%q}`+"\n", c.SentryDSN))
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, dsn.GetAPIURL().String(), body)
+	if err != nil {
+		return err
+	}
+	req.Header.Set("Content-Type", "application/x-sentry-envelope")
+	req.Header.Set("X-Sentry-Auth", "Sentry sentry_version=7, sentry_key="+dsn.GetPublicKey())
+
+	res, err := http.DefaultClient.Do(req)
+	if err != nil {
+		return err
+	}
+	defer res.Body.Close()
+
+	if res.StatusCode >= 300 {
+		return fmt.Errorf("Sentry rejected the DSN: %s", res.Status)
+	}
+
+	return nil
+}
@@ -0,0 +1,191 @@
+package core
+
+import (
+	"os"
+	"os/signal"
+	"runtime"
+	"sync"
+	"syscall"
+	"time"
+
+	"github.com/coreos/go-systemd/daemon"
+	"gitlab.com/btcdirect-api/go-modules/logger"
+	"go.uber.org/zap"
+	"go.uber.org/zap/zapcore"
+)
+
+// App struct, you should embed this in your own application struct
+// to add custom services.
+//
+// Example:
+//
+//	type App struct {
+//		*core.App
+//		MyService *service.MyService
+//	}
+type App struct {
+	Log             *zap.SugaredLogger
+	LogLevel        *zap.AtomicLevel // Changes the level of Log at runtime, nil when set with WithLogger.
+	Shutdown        *GracefulShutdown
+	shutdownTimeout time.Duration
+	stop            chan struct{}
+	stopOnce        *sync.Once
+	shuttingDown    chan struct{}
+}
+
+type opt func(*App)
+
+// Initialize creates an application and applies the given options.
+func Initialize(opts ...opt) App {
+	a := App{
+		Shutdown:     newGracefulShutdown(),
+		stop:         make(chan struct{}),
+		stopOnce:     &sync.Once{},
+		shuttingDown: make(chan struct{}),
+	}
+
+	for _, o := range opts {
+		o(&a)
+	}
+
+	return a
+}
+
+// WithLogger sets the logger for the application.
+func WithLogger(log *zap.SugaredLogger) opt {
+	return func(a *App) {
+		a.Log = log
+	}
+}
+
+// WithLoggerForLevel creates a logger for the given log level and sets it for the application.
+// The level can be changed at runtime with LogLevel.
+func WithLoggerForLevel(logLevel string) opt {
+	return func(a *App) {
+		level := zap.NewAtomicLevel()
+		l, err := zapcore.ParseLevel(logLevel)
+		if err == nil {
+			level.SetLevel(l)
+		}
+
+		// The logger module only takes a fixed level, so its logger logs all levels and the level is filtered here.
+		log := logger.NewLogger(zapcore.DebugLevel.String()).Desugar()
+		a.Log = log.WithOptions(zap.WrapCore(func(c zapcore.Core) zapcore.Core {
+			return levelCore{Core: c, level: level}
+		})).Sugar()
+		a.LogLevel = &level
+
+		if err != nil {
+			a.Log.Warnf("Could not set unknown log level '%s'. Defaulting to 'info'", logLevel)
+			a.Log.Info("Valid log levels are: debug, info, warn, error, fatal, panic and dpanic")
+		}
+	}
+}
+
+// Filters the entries of a core by a level that can be changed at runtime.
+type levelCore struct {
+	zapcore.Core
+	level zap.AtomicLevel
+}
+
+func (c levelCore) Enabled(l zapcore.Level) bool {
+	return c.level.Enabled(l)
+}
+
+func (c levelCore) Check(e zapcore.Entry, ce *zapcore.CheckedEntry) *zapcore.CheckedEntry {
+	if !c.level.Enabled(e.Level) {
+		return ce
+	}
+
+	return c.Core.Check(e, ce)
+}
+
+func (c levelCore) With(fields []zapcore.Field) zapcore.Core {
+	return levelCore{Core: c.Core.With(fields), level: c.level}
+}
+
+// WithShutdownTimeout sets a timeout to wait before shutting down the application.
+// This can be useful for a graceful shutdown in Kubernetes as it cannot use a preStop hook due to
+// the container being distroless.
+func WithShutdownTimeout(timeout time.Duration) opt {
+	return func(a *App) {
+		a.shutdownTimeout = timeout
+	}
+}
+
+// Stop requests a shutdown of the application, as if a shutdown signal was received.
+// This allows stopping the application without signalling the process, e.g. in tests.
+func (a *App) Stop() {
+	a.stopOnce.Do(func() {
+		close(a.stop)
+	})
+}
+
+// ShuttingDown returns true once a shutdown was requested, also during the delay before shutting down.
+// The readiness fails from then on, so no new traffic is routed to the application while it drains.
+func (a *App) ShuttingDown() bool {
+	select {
+	case <-a.shuttingDown:
+		return true
+	default:
+		return false
+	}
+}
+
+// Run the application, this will block until a shutdown signal is received or Stop is called.
+// This will also notify systemd that the application is ready.
+//
+// When a shutdown signal is received, all stop channels will be closed aswell.
+func (a *App) Run() {
+	if runtime.GOOS == "linux" {
+		// Notify systemd that the application is ready.
+		daemon.SdNotify(false, "READY=1")
+	}
+
+	a.waitForShutdown()
+	close(a.shuttingDown)
+	start := time.Now()
+
+	if a.shutdownTimeout > 0 {
+		if a.Log != nil {
+			a.Log.Infof("Waiting %s before shutting down application...", a.shutdownTimeout)
+		}
+		time.Sleep(a.shutdownTimeout)
+	}
+	shutdownPhaseSeconds.Set("delay", a.shutdownTimeout.Seconds())
+
+	err := a.Shutdown.shutdown(30 * time.Second)
+	if err != nil {
+		a.Log.Error(err)
+	}
+
+	// The metrics may not be scraped anymore, so the summary is logged as well.
+	if a.Log != nil {
+		a.Log.Infow("Graceful shutdown finished", "duration", time.Since(start), "delay", a.shutdownTimeout, "timedOut", err != nil)
+	}
+}
+
+func (a *App) waitForShutdown() {
+	c := make(chan os.Signal, 1)
+	signal.Notify(c, syscall.SIGINT, syscall.SIGTERM)
+	defer signal.Stop(c)
+
+	for {
+		// This will block the process until a shutdown signal is received.
+		select {
+		case sig := <-c:
+			switch sig {
+			case syscall.SIGINT, syscall.SIGTERM:
+				if a.Log != nil {
+					a.Log.Info("Shutdown request received.")
+				}
+				return
+			}
+		case <-a.stop:
+			if a.Log != nil {
+				a.Log.Info("Shutdown requested.")
+			}
+			return
+		}
+	}
+}
@@ -0,0 +1,160 @@
+package core
+
+import (
+	"context"
+	"fmt"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+
+	"gitlab.com/btcdirect-api/bootstrap-go-service/internal/metrics"
+)
+
+var (
+	shutdownPhaseSeconds = metrics.NewGauge("shutdown_phase_seconds")
+	shutdownHookSeconds  = metrics.NewGauge("shutdown_hook_seconds")
+	shutdownPending      = metrics.NewGauge("shutdown_pending_contexts")
+	shutdownTimeouts     = metrics.NewCounter("shutdown_timeouts")
+)
+
+// Contexts added to the graceful shutdown will be closed when a shutdown signal is received.
+// In your application you can add listen to the context done (this also adds one to the wait groups)
+// and call Done when the application is finished handling the shutdown.
+//
+// If you need more than one waitgroup, you can implement your own waitgroup in your service.
+//
+// Example for contexts:
+//
+//	func (a *App) Run() {
+//		ctx, _ := a.Shutdown.Add()
+//		go func() {
+//			<-ctx.Done()
+//			a.Shutdown.Done()
+//		}()
+//	}
+//
+// Use AddHook to name the context, the time each hook takes to finish is exposed as metric and
+// the pending hooks are reported when the shutdown times out.
+type GracefulShutdown struct {
+	sync.Mutex
+	cancels   []context.CancelFunc
+	waitGroup sync.WaitGroup
+	pending   int
+	// Number of pending contexts per hook name.
+	hooks map[string]int
+	// Start of the shutdown, zero while running.
+	started time.Time
+}
+
+func newGracefulShutdown() *GracefulShutdown {
+	return &GracefulShutdown{
+		cancels:   []context.CancelFunc{},
+		waitGroup: sync.WaitGroup{},
+		hooks:     make(map[string]int),
+	}
+}
+
+func (gs *GracefulShutdown) shutdown(timeout time.Duration) error {
+	gs.Lock()
+	gs.started = time.Now()
+	cancels := gs.cancels
+	gs.Unlock()
+
+	defer func() {
+		shutdownPhaseSeconds.Set("drain", time.Since(gs.started).Seconds())
+	}()
+
+	ctx, cancelCtx := context.WithTimeout(context.Background(), timeout)
+	defer cancelCtx()
+
+	go func() {
+		for _, cancel := range cancels {
+			cancel()
+		}
+
+		gs.waitGroup.Wait()
+
+		cancelCtx()
+	}()
+
+	<-ctx.Done()
+
+	err := ctx.Err()
+	if err == context.Canceled {
+		return nil
+	}
+
+	shutdownTimeouts.Inc("drain")
+
+	gs.Lock()
+	defer gs.Unlock()
+	return fmt.Errorf("graceful shutdown: %w: %d contexts pending, hooks: %s", err, gs.pending, gs.pendingHooks())
+}
+
+// Add a context to the graceful shutdown.
+// This will also add one to the wait group.
+func (gs *GracefulShutdown) Add() (context.Context, context.CancelFunc) {
+	ctx, cancel := context.WithCancel(context.Background())
+
+	gs.Lock()
+	gs.cancels = append(gs.cancels, cancel)
+	gs.pending++
+	shutdownPending.Set("contexts", float64(gs.pending))
+	gs.Unlock()
+
+	gs.waitGroup.Add(1)
+	return ctx, cancel
+}
+
+// AddHook adds a named context to the graceful shutdown, call done instead of Done when finished.
+// The time from the start of the shutdown until done is called is exposed per name.
+func (gs *GracefulShutdown) AddHook(name string) (ctx context.Context, done func()) {
+	ctx, _ = gs.Add()
+
+	gs.Lock()
+	gs.hooks[name]++
+	gs.Unlock()
+
+	var once sync.Once
+	return ctx, func() {
+		once.Do(func() {
+			gs.Lock()
+			gs.hooks[name]--
+			if gs.hooks[name] == 0 {
+				delete(gs.hooks, name)
+			}
+			if !gs.started.IsZero() {
+				shutdownHookSeconds.Set(name, time.Since(gs.started).Seconds())
+			}
+			gs.Unlock()
+
+			gs.Done()
+		})
+	}
+}
+
+// Done will remove one from the wait group.
+func (gs *GracefulShutdown) Done() {
+	gs.Lock()
+	gs.pending--
+	shutdownPending.Set("contexts", float64(gs.pending))
+	gs.Unlock()
+
+	gs.waitGroup.Done()
+}
+
+// Returns the names of the pending hooks, the caller must hold the lock.
+func (gs *GracefulShutdown) pendingHooks() string {
+	if len(gs.hooks) == 0 {
+		return "none"
+	}
+
+	names := make([]string, 0, len(gs.hooks))
+	for name, n := range gs.hooks {
+		names = append(names, fmt.Sprintf("%s (%d)", name, n))
+	}
+	sort.Strings(names)
+
+	return strings.Join(names, ", ")
+}
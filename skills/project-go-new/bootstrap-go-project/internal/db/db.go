@@ -1,12 +1,13 @@
 package db
 
 import (
+	"context"
 	"embed"
 	"time"
 
 	"github.com/jmoiron/sqlx"
-	"gitlab.com/btcdirect-api/go-modules/sql"
-	"gitlab.com/btcdirect-api/go-modules/sql/migrate"
+	"gitlab.com/btcdirect-api/bootstrap-go-service/internal/sql"
+	"gitlab.com/btcdirect-api/bootstrap-go-service/internal/sql/migrate"
 	"go.uber.org/zap"
 )
 
@@ -14,6 +15,7 @@ type database struct {
 	log           *zap.SugaredLogger
 	conn          *sql.Connection
 	driverCleanup func() error
+	stopRefresh   chan struct{}
 }
 
 //go:embed migrations/*
@@ -24,15 +26,24 @@ var migrations embed.FS
 // The DSN is used to connect to the database, an error is returned if the DSN is invalid.
 //
 // Cloud SQL is supported by using the following DSN format: "myuser:mypass@cloudsql-mysql(project:region:instance)/mydb"
-func New(dsn string, log *zap.SugaredLogger) *database {
+// Postgres is supported for postgres:// DSNs, see sql.DriverFromDSN for Cloud SQL Postgres.
+// The Cloud SQL connector is configured with the options.
+func New(dsn string, log *zap.SugaredLogger, cloudSQL sql.CloudSQLOptions) *database {
 	l := log.With("component", "database")
-	d, _ := sql.DriverFromDSN(dsn)
+	d, err := sql.DriverFromDSNWithOptions(dsn, cloudSQL)
+	if err != nil {
+		l.Errorw("Could not register database driver", "driver", d.Name, "error", err)
+	}
+	if d.DSN != "" {
+		dsn = d.DSN
+	}
 
 	conn := &sql.Connection{
 		Driver:         d.Name,
 		DSN:            dsn,
 		Log:            l,
 		ConnectTimeout: 10 * time.Second,
+		CloudSQL:       cloudSQL,
 	}
 
 	return &database{
@@ -42,6 +53,15 @@ func New(dsn string, log *zap.SugaredLogger) *database {
 	}
 }
 
+// NewWithConnection creates a database instance for the connection, e.g. a connection to a mock driver in tests.
+// The driver of the connection must be registered.
+func NewWithConnection(conn *sql.Connection) *database {
+	return &database{
+		log:  conn.Log,
+		conn: conn,
+	}
+}
+
 // Start opens the Connection to the database.
 // This will block until the Connection is established.
 // This should be called once during application startup.
@@ -51,13 +71,61 @@ func (db *database) Start() *sqlx.DB {
 	return db.conn.DB(true)
 }
 
-// Migrate the database.
+// Migrate the database with the migrations of its dialect, in migrations/mysql or migrations/postgres.
 func (db *database) Migrate(m migrate.Migrate) error {
-	return m.Migrate(migrations, db.conn, db.log)
+	dir := "migrations/mysql"
+	if sql.IsPostgres(db.conn.Driver) {
+		dir = "migrations/postgres"
+	}
+
+	return m.Migrate(migrations, dir, db.conn, db.log)
+}
+
+// RefreshSecret resolves the Secret Manager reference of the DSN every interval,
+// and reconnects when the DSN changed from the last resolved DSN, e.g. after the password rotated.
+// It stops when the database shuts down.
+func (db *database) RefreshSecret(reference, dsn string, interval time.Duration) {
+	db.stopRefresh = make(chan struct{})
+
+	go func() {
+		ticker := time.NewTicker(interval)
+		defer ticker.Stop()
+
+		for {
+			select {
+			case <-db.stopRefresh:
+				return
+			case <-ticker.C:
+			}
+
+			ctx, cancel := context.WithTimeout(context.Background(), interval)
+			resolved, err := sql.ResolveSecret(ctx, reference)
+			cancel()
+			if err != nil {
+				db.log.Errorw("Could not refresh the database secret", "error", err)
+				continue
+			}
+
+			if resolved == dsn {
+				continue
+			}
+
+			if err := db.conn.Reconnect(resolved); err != nil {
+				db.log.Errorw("Could not reconnect with the refreshed database secret", "error", err)
+				continue
+			}
+			dsn = resolved
+		}
+	}()
 }
 
 // Shutdown closes the database Connection and cleans up the driver if needed.
 func (db *database) Shutdown() error {
+	if db.stopRefresh != nil {
+		close(db.stopRefresh)
+		db.stopRefresh = nil
+	}
+
 	if err := db.conn.Shutdown(); err != nil {
 		return err
 	}
@@ -0,0 +1,13 @@
+CREATE TABLE idempotency_keys (
+    idempotency_key VARCHAR(255) NOT NULL,
+    method          VARCHAR(8)   NOT NULL,
+    path            VARCHAR(255) NOT NULL,
+    fingerprint     CHAR(64)     NOT NULL,
+    status          SMALLINT     NULL,
+    header          TEXT         NULL,
+    body            MEDIUMBLOB   NULL,
+    created_at      DATETIME(3)  NOT NULL,
+    expires_at      DATETIME(3)  NOT NULL,
+    PRIMARY KEY (idempotency_key),
+    INDEX idx_idempotency_keys_expires_at (expires_at)
+);
@@ -0,0 +1,8 @@
+CREATE TABLE tasks (
+    id         VARCHAR(36)  NOT NULL,
+    status     VARCHAR(16)  NOT NULL,
+    error      TEXT         NULL,
+    created_at TIMESTAMP(3) NOT NULL,
+    updated_at TIMESTAMP(3) NOT NULL,
+    PRIMARY KEY (id)
+);
@@ -0,0 +1,14 @@
+CREATE TABLE idempotency_keys (
+    idempotency_key VARCHAR(255) NOT NULL,
+    method          VARCHAR(8)   NOT NULL,
+    path            VARCHAR(255) NOT NULL,
+    fingerprint     CHAR(64)     NOT NULL,
+    status          SMALLINT     NULL,
+    header          TEXT         NULL,
+    body            BYTEA        NULL,
+    created_at      TIMESTAMP(3) NOT NULL,
+    expires_at      TIMESTAMP(3) NOT NULL,
+    PRIMARY KEY (idempotency_key)
+);
+
+CREATE INDEX idx_idempotency_keys_expires_at ON idempotency_keys (expires_at);
@@ -0,0 +1,85 @@
+package client
+
+import (
+	"context"
+	"errors"
+	"sync"
+	"time"
+)
+
+const (
+	defaultCheckTimeout = 2 * time.Second
+	defaultCheckCache   = 30 * time.Second
+)
+
+var ErrUnreachable = errors.New("external API is unreachable")
+
+// CheckOptions configures ReadinessCheck.
+type CheckOptions struct {
+	// Maximum time to authenticate against the API, defaults to 2 seconds.
+	Timeout time.Duration
+	// Time the result is reused, so readiness probes do not authenticate on every request. Defaults to 30 seconds.
+	Cache time.Duration
+}
+
+// ReadinessCheck returns a check that authenticates against the API of the client, to add to the readiness
+// of the service with app.WithReadinessCheck. It requests a new token without replacing the token of the client,
+// so the check also fails when the credentials are no longer accepted.
+//
+// Clients other than NewAuthenticatedClient are checked by obtaining their bearer token, within the timeout.
+func ReadinessCheck(c AuthenticatedClient, o CheckOptions) func(ctx context.Context) error {
+	if o.Timeout == 0 {
+		o.Timeout = defaultCheckTimeout
+	}
+	if o.Cache == 0 {
+		o.Cache = defaultCheckCache
+	}
+
+	var (
+		mu        sync.Mutex
+		err       error
+		checkedAt time.Time
+	)
+
+	return func(ctx context.Context) error {
+		// Concurrent probes wait for a single check instead of each authenticating.
+		mu.Lock()
+		defer mu.Unlock()
+
+		if !checkedAt.IsZero() && time.Since(checkedAt) < o.Cache {
+			return err
+		}
+
+		checkCtx, cancel := context.WithTimeout(ctx, o.Timeout)
+		defer cancel()
+
+		result := check(checkCtx, c)
+		// A probe that gave up is not a result of the API.
+		if ctx.Err() != nil {
+			return result
+		}
+		err, checkedAt = result, time.Now()
+
+		return err
+	}
+}
+
+func check(ctx context.Context, c AuthenticatedClient) error {
+	if ac, ok := c.(*authenticatedClient); ok {
+		_, err := ac.requestToken(ctx)
+		return err
+	}
+
+	done := make(chan error, 1)
+	go func() {
+		_, err := c.BearerToken()
+		done <- err
+	}()
+
+	select {
+	case err := <-done:
+		return err
+	case <-ctx.Done():
+		return ErrUnreachable
+	}
+}
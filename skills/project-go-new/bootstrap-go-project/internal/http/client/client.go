@@ -0,0 +1,613 @@
+package client
+
+import (
+	"bytes"
+	"context"
+	"crypto/tls"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"maps"
+	"net"
+	"net/http"
+	"net/url"
+	"sync"
+	"time"
+
+	"go.opentelemetry.io/otel/propagation"
+	"go.uber.org/zap"
+	"golang.org/x/time/rate"
+)
+
+const (
+	DefaultAuthenticateEndpoint = "/token/authenticate"
+	DefaultTokenExpireTime      = time.Hour - 20*time.Second
+	DefaultTimeout              = 30 * time.Second
+	DefaultDialTimeout          = 5 * time.Second
+	DefaultTLSHandshakeTimeout  = 5 * time.Second
+	DefaultMaxIdleConnsPerHost  = 10
+	DefaultIdleConnTimeout      = 90 * time.Second
+	DefaultRenewBefore          = time.Minute
+)
+
+// ErrTimeout is returned when an attempt exceeds the Timeout of the client.
+var ErrTimeout = errors.New("request to external API timed out")
+
+// AuthenticatedClient calls an external API with a bearer token, obtained from its authenticate endpoint
+// or with the OAuth2 client credentials grant.
+type AuthenticatedClient interface {
+	BearerToken() (string, error)
+	AddAuthorizationHeader(r *http.Request) error
+	DoRequest(rc RequestConfig) error
+}
+
+type AuthenticatedClientConfig struct {
+	BaseUrl              string
+	AuthenticateEndpoint string
+	Username             string
+	Password             string
+	// Authenticates with the OAuth2 client credentials grant instead of the username and password when set.
+	OAuth2          *OAuth2Config
+	TokenExpireTime time.Duration
+	// The token is renewed in the background this long before it expires, so requests do not wait for it.
+	// Defaults to 1 minute, and at most half the lifetime of the token.
+	RenewBefore time.Duration
+	// Maximum time of a single attempt, including reading the response body into Data. Defaults to 30 seconds.
+	// For responses streamed into Writer or Each it ends when the response headers are received, so a download is
+	// only limited by the context of the request.
+	Timeout time.Duration
+	// Maximum time to connect and to complete the TLS handshake, both default to 5 seconds.
+	DialTimeout         time.Duration
+	TLSHandshakeTimeout time.Duration
+	// Idle connections kept open to the API, defaults to 10, closed after IdleConnTimeout (default: 90 seconds).
+	MaxIdleConnsPerHost int
+	IdleConnTimeout     time.Duration
+	// Opens a new connection for every request.
+	DisableKeepAlives bool
+	// Proxy of the requests, defaults to the HTTP_PROXY, HTTPS_PROXY and NO_PROXY environment variables.
+	Proxy func(*http.Request) (*url.URL, error)
+	// TLS configuration, e.g. a private root CA, the system roots are used by default.
+	TLSConfig *tls.Config
+	// Sends the requests instead of the pooled transport, e.g. the stub or recorder of httpclienttest.
+	// The transport settings above do not apply to it.
+	Transport http.RoundTripper
+	// Client certificate for APIs requiring mutual TLS.
+	ClientCertificate *ClientCertificateConfig
+	// Headers sent with every request, including the token requests, e.g. Accept-Language or the API key of a partner.
+	Header http.Header
+	// User-Agent of the requests, e.g. my-service/1.4.0, defaults to the Go HTTP client.
+	UserAgent string
+	// Propagates the trace of the request context, defaults to the W3C traceparent and tracestate headers.
+	Propagator propagation.TextMapPropagator
+	// Sends the request ID of the request context in the X-Request-ID header, e.g. to internal services.
+	ForwardRequestID bool
+	// Maximum requests per second to the API, including token requests and retries, with bursts of RateBurst
+	// (default: 1). Requests wait for their turn, not limited when zero.
+	RateLimit float64
+	RateBurst int
+	// Retries of DoRequest after transient failures, none by default.
+	Retry RetryPolicy
+	// Hedging of GET and HEAD requests of DoRequest, none by default.
+	Hedge HedgePolicy
+	// Checks whether the responses of the API are successful, for requests without AcceptStatuses or Success,
+	// e.g. client.AcceptStatuses(200, 202). Any 2xx status is successful by default.
+	Success func(res *http.Response) error
+	Logger  *zap.SugaredLogger
+}
+
+type authenticatedClient struct {
+	AuthenticatedClientConfig
+	// Guards token and refresh, concurrent requests share the token and a single authentication.
+	mu      sync.Mutex
+	token   bearerToken
+	refresh *tokenRefresh
+	// Shared by all requests, so connections are reused.
+	client *http.Client
+}
+
+type tokenRequestKey struct{}
+
+type bearerToken struct {
+	Token     string
+	ExpiresAt time.Time
+	// Time the token is renewed in the background, before it expires.
+	RenewAt time.Time
+}
+
+// Authentication in progress, the requests without a valid token wait for it.
+type tokenRefresh struct {
+	done chan struct{}
+	err  error
+}
+
+type RequestConfig struct {
+	// Context of the request, its deadline and cancellation also stop retries. Defaults to context.Background().
+	Context context.Context
+	// Method of the request, defaults to GET.
+	Method string
+	URL    string
+	// Name of the endpoint in the metrics, e.g. /orders/{id}, defaults to the path of the URL.
+	// Set it when the path contains identifiers, so the metrics do not get a label per identifier.
+	Endpoint string
+	// Query parameters added to the URL.
+	Query url.Values
+	// Encoded as the JSON body of the request, or sent as it is with Reader.
+	Body   any
+	Reader io.Reader
+	// The JSON response is decoded into Data, unless it is nil or the response has no body.
+	// A *[]byte receives the body as it is.
+	Data any
+	// Receives the body as it is streamed, e.g. a report download, instead of Data.
+	Writer io.Writer
+	// Called with every value of a newline delimited JSON (NDJSON) response, e.g. an export, instead of Data.
+	// Decoding stops at the first error it returns.
+	Each func(value json.RawMessage) error
+	// Receives the headers of the response when it is not nil, e.g. for a Link header.
+	ResponseHeader http.Header
+	// Statuses of a successful response, e.g. 200 and 206 for a range request. Any 2xx status by default, or the
+	// Success of the client.
+	AcceptStatuses []int
+	// Checks whether the response is successful instead of AcceptStatuses, the error it returns is returned by
+	// DoRequest. The body is only read when it returns nil.
+	Success func(res *http.Response) error
+	// Deprecated: use AcceptStatuses.
+	ExpectedStatusCode int
+}
+
+func NewAuthenticatedClient(c AuthenticatedClientConfig) AuthenticatedClient {
+	if c.AuthenticateEndpoint == "" {
+		c.AuthenticateEndpoint = DefaultAuthenticateEndpoint
+	}
+	if c.TokenExpireTime == 0 {
+		c.TokenExpireTime = DefaultTokenExpireTime
+	}
+	if c.RenewBefore == 0 {
+		c.RenewBefore = DefaultRenewBefore
+	}
+	if c.Timeout == 0 {
+		c.Timeout = DefaultTimeout
+	}
+	if c.DialTimeout == 0 {
+		c.DialTimeout = DefaultDialTimeout
+	}
+	if c.TLSHandshakeTimeout == 0 {
+		c.TLSHandshakeTimeout = DefaultTLSHandshakeTimeout
+	}
+	if c.MaxIdleConnsPerHost == 0 {
+		c.MaxIdleConnsPerHost = DefaultMaxIdleConnsPerHost
+	}
+	if c.IdleConnTimeout == 0 {
+		c.IdleConnTimeout = DefaultIdleConnTimeout
+	}
+	if c.Proxy == nil {
+		c.Proxy = http.ProxyFromEnvironment
+	}
+	if c.RateBurst == 0 {
+		c.RateBurst = 1
+	}
+	if c.Propagator == nil {
+		c.Propagator = propagation.TraceContext{}
+	}
+	if c.ClientCertificate != nil {
+		config, err := newMutualTLSConfig(c.TLSConfig, *c.ClientCertificate, c.Logger)
+		if err != nil {
+			c.Logger.Fatalf("Invalid client certificate for %s: %s", c.BaseUrl, err)
+		}
+		c.TLSConfig = config
+	}
+
+	return &authenticatedClient{
+		AuthenticatedClientConfig: c,
+		client:                    newHTTPClient(c),
+	}
+}
+
+func (c *authenticatedClient) BearerToken() (string, error) {
+	return c.validToken(context.Background())
+}
+
+// Returns the token, and authenticates when it expired. A token close to its expiry is returned while it is
+// renewed in the background.
+func (c *authenticatedClient) validToken(ctx context.Context) (string, error) {
+	c.mu.Lock()
+	token := c.token
+	if token.Valid() {
+		if time.Now().After(token.RenewAt) {
+			c.startRefresh(ctx)
+		}
+		c.mu.Unlock()
+		return token.Token, nil
+	}
+	refresh := c.startRefresh(ctx)
+	c.mu.Unlock()
+
+	select {
+	case <-refresh.done:
+	case <-ctx.Done():
+		return "", ctx.Err()
+	}
+	if refresh.err != nil {
+		return "", refresh.err
+	}
+
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	return c.token.Token, nil
+}
+
+// Starts to authenticate, unless it is in progress, c.mu must be held. The authentication is not cancelled with
+// the request that started it, as other requests wait for it, it is limited by the Timeout of the client.
+func (c *authenticatedClient) startRefresh(ctx context.Context) *tokenRefresh {
+	if c.refresh != nil {
+		return c.refresh
+	}
+
+	refresh := &tokenRefresh{done: make(chan struct{})}
+	c.refresh = refresh
+
+	go func() {
+		err := c.authenticate(context.WithoutCancel(ctx))
+		if err != nil {
+			c.Logger.Errorw("Failed to obtain an authorization token", "error", err)
+		}
+
+		c.mu.Lock()
+		c.refresh = nil
+		c.mu.Unlock()
+
+		refresh.err = err
+		close(refresh.done)
+	}()
+
+	return refresh
+}
+
+func (c *authenticatedClient) AddAuthorizationHeader(r *http.Request) error {
+	token, err := c.validToken(r.Context())
+	if err != nil {
+		return err
+	}
+
+	r.Header.Set("Authorization", fmt.Sprintf("Bearer %s", token))
+
+	return nil
+}
+
+func (t bearerToken) Valid() bool {
+	if t.Token == "" {
+		return false
+	}
+
+	return t.ExpiresAt.After(time.Now())
+}
+
+func (c *authenticatedClient) authenticate(ctx context.Context) error {
+	c.Logger.Info("Requesting an authorization token")
+
+	token, err := c.requestToken(ctx)
+	if err != nil {
+		return err
+	}
+
+	c.Logger.Info("Successfully obtained an authorization token")
+
+	renewBefore := min(c.RenewBefore, time.Until(token.ExpiresAt)/2)
+	token.RenewAt = token.ExpiresAt.Add(-renewBefore)
+
+	c.mu.Lock()
+	c.token = token
+	c.mu.Unlock()
+
+	return nil
+}
+
+// Requests a token from the authenticate endpoint, without storing it.
+func (c *authenticatedClient) requestToken(ctx context.Context) (bearerToken, error) {
+	if c.OAuth2 != nil {
+		return c.requestOAuth2Token(ctx)
+	}
+
+	body := struct {
+		Username string `json:"username"`
+		Password string `json:"password"`
+	}{c.Username, c.Password}
+
+	js, err := json.Marshal(body)
+	if err != nil {
+		return bearerToken{}, err
+	}
+
+	r, err := http.NewRequestWithContext(withTokenRequest(ctx), http.MethodPost, c.BaseUrl+c.AuthenticateEndpoint, bytes.NewBuffer(js))
+	if err != nil {
+		return bearerToken{}, err
+	}
+	r.Header.Set("Content-Type", "application/json")
+
+	res, err := c.send(r, false)
+	if err != nil {
+		return bearerToken{}, err
+	}
+
+	defer res.Body.Close()
+
+	if res.StatusCode != http.StatusOK {
+		return bearerToken{}, fmt.Errorf("authentication failed: %s", res.Status)
+	}
+
+	token := struct {
+		Token string `json:"token"`
+	}{}
+	if err := json.NewDecoder(res.Body).Decode(&token); err != nil {
+		return bearerToken{}, err
+	}
+
+	return bearerToken{Token: token.Token, ExpiresAt: time.Now().Add(c.TokenExpireTime)}, nil
+}
+
+// IsTokenRequest reports whether the request is sent by the client to obtain its token, e.g. so a test transport
+// does not record the credentials.
+func IsTokenRequest(r *http.Request) bool {
+	return r.Context().Value(tokenRequestKey{}) != nil
+}
+
+func withTokenRequest(ctx context.Context) context.Context {
+	return context.WithValue(ctx, tokenRequestKey{}, true)
+}
+
+func (c *authenticatedClient) DoRequest(rc RequestConfig) error {
+	if rc.ExpectedStatusCode != 0 && len(rc.AcceptStatuses) == 0 {
+		rc.AcceptStatuses = []int{rc.ExpectedStatusCode}
+	}
+
+	if rc.Method == "" {
+		rc.Method = http.MethodGet
+	}
+	if rc.Body != nil && rc.Reader != nil {
+		return errors.New("request has both a Body and a Reader")
+	}
+	if (rc.Data != nil && rc.Writer != nil) || (rc.Data != nil && rc.Each != nil) || (rc.Writer != nil && rc.Each != nil) {
+		return errors.New("request has more than one of Data, Writer and Each")
+	}
+
+	ctx := rc.Context
+	if ctx == nil {
+		ctx = context.Background()
+	}
+
+	target, err := requestURL(rc.URL, rc.Query)
+	if err != nil {
+		return err
+	}
+
+	token, err := c.validToken(ctx)
+	if err != nil {
+		return err
+	}
+
+	if rc.Body != nil {
+		js, err := json.Marshal(rc.Body)
+		if err != nil {
+			return err
+		}
+		rc.Reader = bytes.NewReader(js)
+	}
+
+	// Keep the body, so it can be sent again on a retry.
+	body := rc.Reader
+	if c.Retry.MaxAttempts > 1 && body != nil {
+		b, err := io.ReadAll(body)
+		if err != nil {
+			return err
+		}
+		body = bytes.NewReader(b)
+	}
+
+	r, err := http.NewRequestWithContext(withEndpoint(ctx, rc.Endpoint), rc.Method, target, body)
+	if err != nil {
+		return err
+	}
+	if body != nil {
+		r.Header.Set("Content-Type", "application/json")
+	}
+	r.Header.Set("Accept", accept(rc))
+	r.Header.Set("Authorization", fmt.Sprintf("Bearer %s", token))
+
+	var res *http.Response
+	for attempt := 1; ; attempt++ {
+		if attempt > 1 && r.GetBody != nil {
+			if r.Body, err = r.GetBody(); err != nil {
+				return err
+			}
+		}
+
+		res, err = c.send(r, rc.Writer != nil || rc.Each != nil)
+		if attempt >= c.Retry.MaxAttempts || ctx.Err() != nil || !retryable(rc.Method, res, err) {
+			if err != nil {
+				return err
+			}
+			break
+		}
+
+		wait, ok := c.Retry.wait(attempt, res)
+		if !ok {
+			break
+		}
+
+		clientRetries.Inc(metricsLabel(r))
+		if err != nil {
+			c.Logger.Warnw("Retrying request to external API", "url", rc.URL, "attempt", attempt, "error", err)
+		} else {
+			c.Logger.Warnw("Retrying request to external API", "url", rc.URL, "attempt", attempt, "status", res.StatusCode)
+			discard(res)
+		}
+
+		if err = sleep(ctx, wait); err != nil {
+			return err
+		}
+	}
+
+	defer res.Body.Close()
+
+	if rc.ResponseHeader != nil {
+		maps.Copy(rc.ResponseHeader, res.Header)
+	}
+
+	if err = c.checkSuccess(rc, res); err != nil {
+		return err
+	}
+
+	switch {
+	case rc.Writer != nil:
+		_, err = io.Copy(rc.Writer, res.Body)
+		return err
+	case rc.Each != nil:
+		return decodeEach(res.Body, rc.Each)
+	case rc.Data == nil:
+		return nil
+	}
+
+	if raw, ok := rc.Data.(*[]byte); ok {
+		*raw, err = io.ReadAll(res.Body)
+		return err
+	}
+
+	if err = json.NewDecoder(res.Body).Decode(rc.Data); err != nil && !errors.Is(err, io.EOF) {
+		return err
+	}
+
+	return nil
+}
+
+// Sends an attempt of the request, limited by the Timeout of the client. The timeout includes reading the body,
+// unless the response is streamed, then it ends when the response headers are received.
+func (c *authenticatedClient) send(r *http.Request, stream bool) (*http.Response, error) {
+	ctx, cancel := context.WithCancelCause(r.Context())
+	timer := time.AfterFunc(c.Timeout, func() { cancel(ErrTimeout) })
+	stop := func() {
+		timer.Stop()
+		cancel(nil)
+	}
+
+	var res *http.Response
+	var err error
+	if c.Hedge.applies(r) {
+		res, err = c.doHedged(r.WithContext(ctx))
+	} else {
+		res, err = c.client.Do(r.WithContext(ctx))
+	}
+	if err != nil {
+		if context.Cause(ctx) == ErrTimeout {
+			err = fmt.Errorf("%s %s: %w after %s", r.Method, r.URL.Redacted(), ErrTimeout, c.Timeout)
+		}
+		stop()
+		return nil, err
+	}
+	if stream {
+		timer.Stop()
+	}
+
+	res.Body = attemptBody{ReadCloser: res.Body, ctx: ctx, timeout: c.Timeout, stop: stop}
+
+	return res, nil
+}
+
+// Body of the response of an attempt, it reports when the timeout of the attempt ended the read, and releases the
+// attempt when closed.
+type attemptBody struct {
+	io.ReadCloser
+	ctx     context.Context
+	timeout time.Duration
+	stop    func()
+}
+
+func (b attemptBody) Read(p []byte) (int, error) {
+	n, err := b.ReadCloser.Read(p)
+	if err != nil && err != io.EOF && context.Cause(b.ctx) == ErrTimeout {
+		err = fmt.Errorf("read response: %w after %s", ErrTimeout, b.timeout)
+	}
+
+	return n, err
+}
+
+func (b attemptBody) Close() error {
+	err := b.ReadCloser.Close()
+	b.stop()
+
+	return err
+}
+
+// Returns an error when the response is not successful, see RequestConfig.Success and AcceptStatuses.
+func (c *authenticatedClient) checkSuccess(rc RequestConfig, res *http.Response) error {
+	switch {
+	case rc.Success != nil:
+		return rc.Success(res)
+	case len(rc.AcceptStatuses) > 0:
+		return AcceptStatuses(rc.AcceptStatuses...)(res)
+	case c.Success != nil:
+		return c.Success(res)
+	}
+
+	if res.StatusCode < 200 || res.StatusCode > 299 {
+		return NewAPIError(res)
+	}
+
+	return nil
+}
+
+// Adds the query parameters to the URL.
+func requestURL(raw string, query url.Values) (string, error) {
+	if len(query) == 0 {
+		return raw, nil
+	}
+
+	u, err := url.Parse(raw)
+	if err != nil {
+		return "", err
+	}
+
+	q := u.Query()
+	for key, values := range query {
+		for _, v := range values {
+			q.Add(key, v)
+		}
+	}
+	u.RawQuery = q.Encode()
+
+	return u.String(), nil
+}
+
+// Returns the client of the configuration, with a transport pooling the connections to the API.
+func newHTTPClient(c AuthenticatedClientConfig) *http.Client {
+	var transport http.RoundTripper = &http.Transport{
+		Proxy:                 c.Proxy,
+		DialContext:           (&net.Dialer{Timeout: c.DialTimeout, KeepAlive: 30 * time.Second}).DialContext,
+		TLSClientConfig:       c.TLSConfig,
+		TLSHandshakeTimeout:   c.TLSHandshakeTimeout,
+		DisableKeepAlives:     c.DisableKeepAlives,
+		MaxIdleConns:          100,
+		MaxIdleConnsPerHost:   c.MaxIdleConnsPerHost,
+		IdleConnTimeout:       c.IdleConnTimeout,
+		ExpectContinueTimeout: time.Second,
+		ForceAttemptHTTP2:     true,
+	}
+	if c.Transport != nil {
+		transport = c.Transport
+	}
+
+	var next http.RoundTripper = metricsTransport{next: transport}
+	if c.RateLimit > 0 {
+		next = rateLimitTransport{next: next, limiter: rate.NewLimiter(rate.Limit(c.RateLimit), c.RateBurst)}
+	}
+
+	return &http.Client{
+		Transport: headerTransport{
+			next:             next,
+			header:           c.Header.Clone(),
+			userAgent:        c.UserAgent,
+			propagator:       c.Propagator,
+			forwardRequestID: c.ForwardRequestID,
+		},
+	}
+}
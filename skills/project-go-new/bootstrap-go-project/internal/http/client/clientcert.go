@@ -0,0 +1,132 @@
+package client
+
+import (
+	"crypto/tls"
+	"crypto/x509"
+	"errors"
+	"fmt"
+	"os"
+	"sync"
+	"time"
+
+	"go.uber.org/zap"
+)
+
+// ClientCertificateConfig configures mutual TLS, the client certificate is presented to the API and the API is
+// verified with the CA. Set either the paths of the PEM files or the PEMs themselves.
+type ClientCertificateConfig struct {
+	CertFile string
+	KeyFile  string
+	CertPEM  []byte
+	KeyPEM   []byte
+	// CA that signed the certificate of the API, the system roots are used without it.
+	CAFile string
+	CAPEM  []byte
+	// Interval the files are checked for a renewed certificate, e.g. when a secret is rotated. Not checked when zero.
+	ReloadInterval time.Duration
+}
+
+// Provides the client certificate at every handshake, reloading the files when they changed.
+type clientCertificate struct {
+	ClientCertificateConfig
+	log *zap.SugaredLogger
+
+	mu        sync.Mutex
+	cert      *tls.Certificate
+	err       error
+	modTime   time.Time
+	checkedAt time.Time
+}
+
+// Returns the TLS configuration with the client certificate and CA, based on base when it is set.
+func newMutualTLSConfig(base *tls.Config, c ClientCertificateConfig, log *zap.SugaredLogger) (*tls.Config, error) {
+	config := &tls.Config{MinVersion: tls.VersionTLS12}
+	if base != nil {
+		config = base.Clone()
+	}
+
+	if c.CAFile != "" || len(c.CAPEM) > 0 {
+		pem := c.CAPEM
+		if c.CAFile != "" {
+			b, err := os.ReadFile(c.CAFile)
+			if err != nil {
+				return nil, fmt.Errorf("read CA: %w", err)
+			}
+			pem = b
+		}
+
+		pool := x509.NewCertPool()
+		if !pool.AppendCertsFromPEM(pem) {
+			return nil, errors.New("CA has no PEM encoded certificates")
+		}
+		config.RootCAs = pool
+	}
+
+	cc := &clientCertificate{ClientCertificateConfig: c, log: log}
+	cc.load()
+	if cc.err != nil {
+		return nil, cc.err
+	}
+	config.GetClientCertificate = cc.get
+
+	return config, nil
+}
+
+func (c *clientCertificate) get(*tls.CertificateRequestInfo) (*tls.Certificate, error) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	if c.ReloadInterval > 0 && c.CertFile != "" && time.Since(c.checkedAt) >= c.ReloadInterval {
+		c.reload()
+	}
+
+	return c.cert, c.err
+}
+
+// Loads the certificate when the files changed, a failed reload keeps the previous certificate. c.mu must be held.
+func (c *clientCertificate) reload() {
+	c.checkedAt = time.Now()
+
+	info, err := os.Stat(c.CertFile)
+	if err != nil || !info.ModTime().After(c.modTime) {
+		return
+	}
+
+	previous := c.cert
+	c.load()
+	if c.err != nil && previous != nil {
+		c.log.Errorw("Failed to reload the client certificate, using the previous certificate", "error", c.err)
+		c.cert, c.err = previous, nil
+		return
+	}
+
+	c.log.Infow("Reloaded the client certificate", "file", c.CertFile)
+}
+
+func (c *clientCertificate) load() {
+	c.checkedAt = time.Now()
+
+	if c.CertFile == "" {
+		cert, err := tls.X509KeyPair(c.CertPEM, c.KeyPEM)
+		if err != nil {
+			c.err = fmt.Errorf("load client certificate: %w", err)
+			return
+		}
+		c.cert, c.err = &cert, nil
+		return
+	}
+
+	info, err := os.Stat(c.CertFile)
+	if err != nil {
+		c.err = fmt.Errorf("load client certificate: %w", err)
+		return
+	}
+
+	cert, err := tls.LoadX509KeyPair(c.CertFile, c.KeyFile)
+	if err != nil {
+		c.err = fmt.Errorf("load client certificate: %w", err)
+		return
+	}
+
+	c.cert, c.err, c.modTime = &cert, nil, info.ModTime()
+}
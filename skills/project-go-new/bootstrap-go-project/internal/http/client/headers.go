@@ -0,0 +1,43 @@
+package client
+
+import (
+	"net/http"
+
+	"gitlab.com/btcdirect-api/bootstrap-go-service/internal/http/middleware"
+	"go.opentelemetry.io/otel/propagation"
+)
+
+// Adds the default headers of the client to its requests, including the token requests, and the trace context and
+// optionally the request ID of the request context, so traces and logs of the API can be correlated with the service.
+type headerTransport struct {
+	next             http.RoundTripper
+	header           http.Header
+	userAgent        string
+	propagator       propagation.TextMapPropagator
+	forwardRequestID bool
+}
+
+func (t headerTransport) RoundTrip(r *http.Request) (*http.Response, error) {
+	// A RoundTripper must not modify the request of the caller.
+	r = r.Clone(r.Context())
+
+	// Headers set on the request take precedence over the defaults.
+	for key, values := range t.header {
+		if r.Header.Get(key) != "" {
+			continue
+		}
+		for _, v := range values {
+			r.Header.Add(key, v)
+		}
+	}
+	if t.userAgent != "" && r.Header.Get("User-Agent") == "" {
+		r.Header.Set("User-Agent", t.userAgent)
+	}
+
+	t.propagator.Inject(r.Context(), propagation.HeaderCarrier(r.Header))
+	if t.forwardRequestID {
+		middleware.PropagateRequestID(r.Context(), r)
+	}
+
+	return t.next.RoundTrip(r)
+}
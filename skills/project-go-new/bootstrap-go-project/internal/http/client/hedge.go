@@ -0,0 +1,128 @@
+package client
+
+import (
+	"context"
+	"io"
+	"net/http"
+	"time"
+
+	"gitlab.com/btcdirect-api/bootstrap-go-service/internal/metrics"
+)
+
+// Hedged attempts sent because the earlier attempts did not answer within the delay, per "host method endpoint".
+var clientHedges = metrics.NewCounter("http_client_hedges")
+
+// HedgePolicy configures hedged requests, for latency sensitive reads. The zero value does not hedge.
+//
+// When a GET or HEAD request has not been answered within the delay, another attempt is sent concurrently. The first
+// response is used and the other attempts are cancelled, so a slow connection or instance of the API does not
+// delay the request. Hedging adds load to the API, set the delay to a high percentile of its latency, e.g. the p95.
+type HedgePolicy struct {
+	// Time to wait for a response before sending another attempt.
+	Delay time.Duration
+	// Maximum number of concurrent attempts, including the first one. Defaults to 2.
+	MaxAttempts int
+}
+
+type hedgeResult struct {
+	attempt int
+	res     *http.Response
+	err     error
+}
+
+// Reports whether the request is hedged, only requests without a body and side effects are.
+func (p HedgePolicy) applies(r *http.Request) bool {
+	if p.Delay <= 0 || (r.Method != http.MethodGet && r.Method != http.MethodHead) {
+		return false
+	}
+
+	return r.Body == nil || r.Body == http.NoBody
+}
+
+// Sends the request, and another attempt every delay until one of them answers, see HedgePolicy.
+// A failed attempt does not end the request while other attempts are pending.
+func (c *authenticatedClient) doHedged(r *http.Request) (*http.Response, error) {
+	attempts := c.Hedge.MaxAttempts
+	if attempts <= 0 {
+		attempts = 2
+	}
+
+	results := make(chan hedgeResult, attempts)
+	cancels := make([]context.CancelFunc, 0, attempts)
+	send := func() {
+		ctx, cancel := context.WithCancel(r.Context())
+		attempt := len(cancels)
+		cancels = append(cancels, cancel)
+		req := r.Clone(ctx)
+		go func() {
+			res, err := c.client.Do(req)
+			results <- hedgeResult{attempt: attempt, res: res, err: err}
+		}()
+	}
+
+	send()
+	timer := time.NewTimer(c.Hedge.Delay)
+	defer timer.Stop()
+
+	pending := 1
+	for {
+		select {
+		case <-timer.C:
+			if len(cancels) < attempts {
+				clientHedges.Inc(metricsLabel(r))
+				send()
+				pending++
+				timer.Reset(c.Hedge.Delay)
+			}
+		case result := <-results:
+			pending--
+			if result.err != nil && pending > 0 {
+				continue
+			}
+			if result.err != nil && len(cancels) < attempts && r.Context().Err() == nil {
+				send()
+				pending++
+				continue
+			}
+
+			// Cancel the other attempts, the context of the used attempt is cancelled when its body is closed.
+			for i, cancel := range cancels {
+				if i != result.attempt {
+					cancel()
+				}
+			}
+			go discardHedges(results, pending)
+
+			if result.err != nil {
+				cancels[result.attempt]()
+				return nil, result.err
+			}
+
+			result.res.Body = cancelOnClose{ReadCloser: result.res.Body, cancel: cancels[result.attempt]}
+
+			return result.res, nil
+		}
+	}
+}
+
+// Closes the responses of the cancelled attempts, so their connections are released.
+func discardHedges(results <-chan hedgeResult, pending int) {
+	for ; pending > 0; pending-- {
+		if result := <-results; result.err == nil {
+			result.res.Body.Close()
+		}
+	}
+}
+
+// Cancels the context of the response when its body is closed.
+type cancelOnClose struct {
+	io.ReadCloser
+	cancel context.CancelFunc
+}
+
+func (b cancelOnClose) Close() error {
+	err := b.ReadCloser.Close()
+	b.cancel()
+
+	return err
+}
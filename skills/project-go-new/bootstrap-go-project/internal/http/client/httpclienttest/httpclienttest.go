@@ -0,0 +1,235 @@
+// Package httpclienttest provides a stub and a record/replay transport for unit tests of code calling external APIs
+// with client.AuthenticatedClient.
+//
+// The stub answers the requests matching a method and a URL pattern:
+//
+//	api := httpclienttest.New(t)
+//	api.On("GET", `/orders/\d+$`).Returns(http.StatusOK, Order{ID: 7})
+//
+//	svc := orders.NewService(api)
+//	order, err := svc.Find(ctx, 7)
+//
+//	api.AssertRequested(t, "GET", `/orders/7$`)
+//
+// Requests without a stub fail with 404 Not Found. The token requests of the client are answered with a fake token.
+package httpclienttest
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"regexp"
+	"strconv"
+	"sync"
+	"testing"
+
+	"gitlab.com/btcdirect-api/bootstrap-go-service/internal/http/client"
+	"go.uber.org/zap"
+)
+
+// Token returned for the token requests of the client, in the fields of both the authenticate endpoint and OAuth2.
+const Token = "httpclienttest-token"
+
+var tokenResponse = []byte(`{"token":"` + Token + `","access_token":"` + Token + `","token_type":"Bearer","expires_in":3600}`)
+
+// Request is a request received by the stub, without the token requests.
+type Request struct {
+	Method string
+	URL    string
+	Header http.Header
+	Body   []byte
+}
+
+// Stub is the response to the requests matching its method and URL pattern.
+type Stub struct {
+	method string
+	url    *regexp.Regexp
+	status int
+	header http.Header
+	body   []byte
+	err    error
+}
+
+// Returns sets the response of the stub, the body is encoded as JSON unless it is a []byte or string.
+func (s *Stub) Returns(status int, body any) *Stub {
+	s.status = status
+
+	switch b := body.(type) {
+	case nil:
+		s.body = nil
+	case []byte:
+		s.body = b
+	case string:
+		s.body = []byte(b)
+	default:
+		js, err := json.Marshal(b)
+		if err != nil {
+			panic(fmt.Sprintf("httpclienttest: encode body: %s", err))
+		}
+		s.body = js
+		s.header.Set("Content-Type", "application/json")
+	}
+
+	return s
+}
+
+// WithHeader sets a header of the response, e.g. a Link header.
+func (s *Stub) WithHeader(key, value string) *Stub {
+	s.header.Set(key, value)
+	return s
+}
+
+// Fails makes the requests fail without a response, e.g. with a connection error.
+func (s *Stub) Fails(err error) *Stub {
+	s.err = err
+	return s
+}
+
+// Client is a client.AuthenticatedClient sending its requests to the stubs, create it with New.
+// It is a real client with a stub transport, so the requests are encoded and the responses decoded as in production.
+type Client struct {
+	client.AuthenticatedClient
+	transport *Transport
+}
+
+// New returns a stub client, the configuration sets options like Retry, its Transport is replaced.
+func New(t testing.TB, config ...client.AuthenticatedClientConfig) *Client {
+	t.Helper()
+
+	var c client.AuthenticatedClientConfig
+	if len(config) > 0 {
+		c = config[0]
+	}
+	if c.BaseUrl == "" {
+		c.BaseUrl = "http://httpclienttest"
+	}
+	if c.Logger == nil {
+		c.Logger = zap.NewNop().Sugar()
+	}
+
+	transport := NewTransport()
+	c.Transport = transport
+
+	return &Client{AuthenticatedClient: client.NewAuthenticatedClient(c), transport: transport}
+}
+
+// On adds a stub for the requests with the method and a URL matching the regular expression, it returns 200 OK
+// without a body until Returns is called. The last added matching stub is used, so a test can override a stub.
+func (c *Client) On(method, urlPattern string) *Stub {
+	return c.transport.On(method, urlPattern)
+}
+
+// Requests returns the requests received by the stub.
+func (c *Client) Requests() []Request {
+	return c.transport.Requests()
+}
+
+// AssertRequested fails the test when no request with the method and a URL matching the regular expression was sent.
+func (c *Client) AssertRequested(t testing.TB, method, urlPattern string) {
+	t.Helper()
+	c.transport.AssertRequested(t, method, urlPattern)
+}
+
+// Transport is an http.RoundTripper answering requests with stubs, to use with client.AuthenticatedClientConfig
+// or any http.Client.
+type Transport struct {
+	mu       sync.Mutex
+	stubs    []*Stub
+	requests []Request
+}
+
+// NewTransport returns a stub transport.
+func NewTransport() *Transport {
+	return &Transport{}
+}
+
+// On adds a stub, see Client.On.
+func (tr *Transport) On(method, urlPattern string) *Stub {
+	tr.mu.Lock()
+	defer tr.mu.Unlock()
+
+	s := &Stub{method: method, url: regexp.MustCompile(urlPattern), status: http.StatusOK, header: http.Header{}}
+	tr.stubs = append(tr.stubs, s)
+
+	return s
+}
+
+// Requests returns the requests received by the transport.
+func (tr *Transport) Requests() []Request {
+	tr.mu.Lock()
+	defer tr.mu.Unlock()
+
+	return append([]Request(nil), tr.requests...)
+}
+
+// AssertRequested fails the test when no request with the method and a URL matching the regular expression was sent.
+func (tr *Transport) AssertRequested(t testing.TB, method, urlPattern string) {
+	t.Helper()
+
+	re := regexp.MustCompile(urlPattern)
+	for _, r := range tr.Requests() {
+		if r.Method == method && re.MatchString(r.URL) {
+			return
+		}
+	}
+
+	t.Errorf("httpclienttest: no request %s %s, received %d requests", method, urlPattern, len(tr.Requests()))
+}
+
+func (tr *Transport) RoundTrip(r *http.Request) (*http.Response, error) {
+	if client.IsTokenRequest(r) {
+		return response(r, http.StatusOK, http.Header{"Content-Type": {"application/json"}}, tokenResponse), nil
+	}
+
+	body, err := readBody(r)
+	if err != nil {
+		return nil, err
+	}
+
+	tr.mu.Lock()
+	tr.requests = append(tr.requests, Request{Method: r.Method, URL: r.URL.String(), Header: r.Header.Clone(), Body: body})
+
+	var stub *Stub
+	for i := len(tr.stubs) - 1; i >= 0; i-- {
+		if tr.stubs[i].method == r.Method && tr.stubs[i].url.MatchString(r.URL.String()) {
+			stub = tr.stubs[i]
+			break
+		}
+	}
+	tr.mu.Unlock()
+
+	if stub == nil {
+		return response(r, http.StatusNotFound, http.Header{}, []byte(`{"error":"no stub for `+r.Method+` `+r.URL.String()+`"}`)), nil
+	}
+	if stub.err != nil {
+		return nil, stub.err
+	}
+
+	return response(r, stub.status, stub.header.Clone(), stub.body), nil
+}
+
+// Reads the body of the request, and closes it as a RoundTripper must.
+func readBody(r *http.Request) ([]byte, error) {
+	if r.Body == nil {
+		return nil, nil
+	}
+	defer r.Body.Close()
+
+	return io.ReadAll(r.Body)
+}
+
+func response(r *http.Request, status int, header http.Header, body []byte) *http.Response {
+	return &http.Response{
+		Status:        strconv.Itoa(status) + " " + http.StatusText(status),
+		StatusCode:    status,
+		Proto:         "HTTP/1.1",
+		ProtoMajor:    1,
+		ProtoMinor:    1,
+		Header:        header,
+		Body:          io.NopCloser(bytes.NewReader(body)),
+		ContentLength: int64(len(body)),
+		Request:       r,
+	}
+}
@@ -0,0 +1,188 @@
+package httpclienttest
+
+import (
+	"bytes"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"os"
+	"path/filepath"
+	"sync"
+	"testing"
+
+	"gitlab.com/btcdirect-api/bootstrap-go-service/internal/http/client"
+)
+
+// RecordEnv forces the recorder to record, also when the file exists, e.g. HTTPCLIENTTEST_RECORD=1 go test ./...
+const RecordEnv = "HTTPCLIENTTEST_RECORD"
+
+// Headers that are not recorded, so recordings do not contain credentials.
+var redactedHeaders = []string{"Authorization", "Cookie", "Set-Cookie", "Proxy-Authorization"}
+
+// Interaction is a recorded request with its response.
+type Interaction struct {
+	Method         string      `json:"method"`
+	URL            string      `json:"url"`
+	RequestBody    string      `json:"requestBody,omitempty"`
+	Status         int         `json:"status"`
+	ResponseHeader http.Header `json:"responseHeader,omitempty"`
+	ResponseBody   string      `json:"responseBody,omitempty"`
+}
+
+// Recorder is an http.RoundTripper that records the requests to an API and their responses in a file, and replays
+// them in later runs, so integration responses are captured once and the tests run without the API:
+//
+//	orders := client.NewAuthenticatedClient(client.AuthenticatedClientConfig{
+//		BaseUrl:   "https://orders.example.com",
+//		Transport: httpclienttest.NewRecorder(t, "testdata/orders.json"),
+//		...
+//	})
+//
+// The recorder records when the file does not exist or RecordEnv is set, and writes the file when the test
+// finishes. Token requests are sent but not recorded, and answered with a fake token when replaying. Credentials
+// in the headers are not recorded, credentials in the URLs or bodies must be removed from the file.
+//
+// When replaying, each recorded interaction answers one request with the same method, URL and body, in the
+// recorded order, and requests without an interaction fail.
+type Recorder struct {
+	// Transport of the recorded requests, defaults to http.DefaultTransport.
+	Next http.RoundTripper
+
+	path      string
+	recording bool
+
+	mu           sync.Mutex
+	interactions []Interaction
+	replayed     []bool
+}
+
+// NewRecorder returns a recorder of the file, it fails the test when the file can not be read or written.
+func NewRecorder(t testing.TB, path string) *Recorder {
+	t.Helper()
+
+	rec := &Recorder{path: path}
+
+	b, err := os.ReadFile(path)
+	switch {
+	case os.Getenv(RecordEnv) != "" || errors.Is(err, os.ErrNotExist):
+		rec.recording = true
+	case err != nil:
+		t.Fatalf("httpclienttest: read recording: %s", err)
+	default:
+		if err := json.Unmarshal(b, &rec.interactions); err != nil {
+			t.Fatalf("httpclienttest: decode recording %s: %s", path, err)
+		}
+		rec.replayed = make([]bool, len(rec.interactions))
+	}
+
+	if rec.recording {
+		t.Cleanup(func() {
+			if err := rec.save(); err != nil {
+				t.Errorf("httpclienttest: write recording: %s", err)
+			}
+		})
+	}
+
+	return rec
+}
+
+// Recording reports whether the recorder records, or replays.
+func (rec *Recorder) Recording() bool {
+	return rec.recording
+}
+
+func (rec *Recorder) RoundTrip(r *http.Request) (*http.Response, error) {
+	if !rec.recording && client.IsTokenRequest(r) {
+		return response(r, http.StatusOK, http.Header{"Content-Type": {"application/json"}}, tokenResponse), nil
+	}
+
+	body, err := readBody(r)
+	if err != nil {
+		return nil, err
+	}
+
+	if !rec.recording {
+		return rec.replay(r, body)
+	}
+
+	return rec.record(r, body)
+}
+
+// Sends the request, and records it with its response unless it is a token request.
+func (rec *Recorder) record(r *http.Request, body []byte) (*http.Response, error) {
+	next := rec.Next
+	if next == nil {
+		next = http.DefaultTransport
+	}
+
+	out := r.Clone(r.Context())
+	out.Body = io.NopCloser(bytes.NewReader(body))
+	res, err := next.RoundTrip(out)
+	if err != nil || client.IsTokenRequest(r) {
+		return res, err
+	}
+
+	resBody, err := io.ReadAll(res.Body)
+	res.Body.Close()
+	if err != nil {
+		return nil, err
+	}
+	res.Body = io.NopCloser(bytes.NewReader(resBody))
+
+	header := res.Header.Clone()
+	for _, h := range redactedHeaders {
+		header.Del(h)
+	}
+
+	rec.mu.Lock()
+	rec.interactions = append(rec.interactions, Interaction{
+		Method:         r.Method,
+		URL:            r.URL.String(),
+		RequestBody:    string(body),
+		Status:         res.StatusCode,
+		ResponseHeader: header,
+		ResponseBody:   string(resBody),
+	})
+	rec.mu.Unlock()
+
+	return res, nil
+}
+
+// Answers the request with the first matching interaction that was not replayed.
+func (rec *Recorder) replay(r *http.Request, body []byte) (*http.Response, error) {
+	rec.mu.Lock()
+	defer rec.mu.Unlock()
+
+	for i, in := range rec.interactions {
+		if rec.replayed[i] || in.Method != r.Method || in.URL != r.URL.String() || in.RequestBody != string(body) {
+			continue
+		}
+
+		rec.replayed[i] = true
+		header := in.ResponseHeader.Clone()
+		if header == nil {
+			header = http.Header{}
+		}
+
+		return response(r, in.Status, header, []byte(in.ResponseBody)), nil
+	}
+
+	return nil, fmt.Errorf("httpclienttest: no recorded interaction for %s %s in %s", r.Method, r.URL, rec.path)
+}
+
+func (rec *Recorder) save() error {
+	rec.mu.Lock()
+	defer rec.mu.Unlock()
+
+	b, err := json.MarshalIndent(rec.interactions, "", "  ")
+	if err != nil {
+		return err
+	}
+	if err := os.MkdirAll(filepath.Dir(rec.path), 0o755); err != nil {
+		return err
+	}
+
+	return os.WriteFile(rec.path, append(b, '\n'), 0o644)
+}
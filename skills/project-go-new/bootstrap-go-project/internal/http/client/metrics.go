@@ -0,0 +1,58 @@
+package client
+
+import (
+	"context"
+	"net/http"
+	"strconv"
+	"time"
+
+	"gitlab.com/btcdirect-api/bootstrap-go-service/internal/metrics"
+)
+
+var (
+	// Requests to external APIs, including token requests and retries, per "host method endpoint status".
+	// The status is "error" when no response was received.
+	clientRequests = metrics.NewCounter("http_client_requests")
+	// Duration of the requests until the response headers, per "host method endpoint".
+	clientRequestSeconds = metrics.NewHistogram("http_client_request_seconds", metrics.DefaultBuckets)
+	// Retries of DoRequest, per "host method endpoint".
+	clientRetries = metrics.NewCounter("http_client_retries")
+)
+
+type endpointKey struct{}
+
+// Records the requests of the client in the metrics.
+type metricsTransport struct {
+	next http.RoundTripper
+}
+
+func (t metricsTransport) RoundTrip(r *http.Request) (*http.Response, error) {
+	start := time.Now()
+	res, err := t.next.RoundTrip(r)
+
+	label := metricsLabel(r)
+	clientRequestSeconds.Observe(label, time.Since(start).Seconds())
+
+	status := "error"
+	if err == nil {
+		status = strconv.Itoa(res.StatusCode)
+	}
+	clientRequests.Inc(label + " " + status)
+
+	return res, err
+}
+
+// Returns the label of the request, with the endpoint of RequestConfig or the path of the URL.
+func metricsLabel(r *http.Request) string {
+	endpoint, ok := r.Context().Value(endpointKey{}).(string)
+	if !ok || endpoint == "" {
+		endpoint = r.URL.Path
+	}
+
+	return r.URL.Host + " " + r.Method + " " + endpoint
+}
+
+// Adds the endpoint of the request to the context, to label its metrics.
+func withEndpoint(ctx context.Context, endpoint string) context.Context {
+	return context.WithValue(ctx, endpointKey{}, endpoint)
+}
@@ -0,0 +1,81 @@
+package client
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+	"net/url"
+	"strings"
+	"time"
+)
+
+// Margin before the expiry of an OAuth2 token, so it is not sent just before it expires.
+const oauth2ExpiryMargin = 20 * time.Second
+
+// OAuth2Config authenticates with the OAuth2 client credentials grant, instead of the username and password of the
+// authenticate endpoint.
+type OAuth2Config struct {
+	// Token endpoint of the authorization server, e.g. https://auth.example.com/oauth2/token.
+	TokenURL     string
+	ClientID     string
+	ClientSecret string
+	Scopes       []string
+}
+
+// Requests an access token with the client credentials grant (RFC 6749, section 4.4).
+// The token expires with the expires_in of the response, or after TokenExpireTime without it.
+func (c *authenticatedClient) requestOAuth2Token(ctx context.Context) (bearerToken, error) {
+	form := url.Values{"grant_type": {"client_credentials"}}
+	if len(c.OAuth2.Scopes) > 0 {
+		form.Set("scope", strings.Join(c.OAuth2.Scopes, " "))
+	}
+
+	r, err := http.NewRequestWithContext(withTokenRequest(ctx), http.MethodPost, c.OAuth2.TokenURL, strings.NewReader(form.Encode()))
+	if err != nil {
+		return bearerToken{}, err
+	}
+	r.Header.Set("Content-Type", "application/x-www-form-urlencoded")
+	r.Header.Set("Accept", "application/json")
+	r.SetBasicAuth(url.QueryEscape(c.OAuth2.ClientID), url.QueryEscape(c.OAuth2.ClientSecret))
+
+	res, err := c.send(r, false)
+	if err != nil {
+		return bearerToken{}, err
+	}
+
+	defer res.Body.Close()
+
+	token := struct {
+		AccessToken      string `json:"access_token"`
+		TokenType        string `json:"token_type"`
+		ExpiresIn        int64  `json:"expires_in"`
+		Error            string `json:"error"`
+		ErrorDescription string `json:"error_description"`
+	}{}
+	if err := json.NewDecoder(res.Body).Decode(&token); err != nil && res.StatusCode == http.StatusOK {
+		return bearerToken{}, err
+	}
+
+	if res.StatusCode != http.StatusOK {
+		if token.Error != "" {
+			return bearerToken{}, fmt.Errorf("authentication failed: %s: %s", res.Status, strings.TrimSpace(token.Error+" "+token.ErrorDescription))
+		}
+		return bearerToken{}, fmt.Errorf("authentication failed: %s", res.Status)
+	}
+
+	if token.AccessToken == "" {
+		return bearerToken{}, errors.New("authentication failed: response has no access_token")
+	}
+	if token.TokenType != "" && !strings.EqualFold(token.TokenType, "bearer") {
+		return bearerToken{}, fmt.Errorf("authentication failed: unsupported token type %s", token.TokenType)
+	}
+
+	expiresAt := time.Now().Add(c.TokenExpireTime)
+	if token.ExpiresIn > 0 {
+		expiresAt = time.Now().Add(time.Duration(token.ExpiresIn)*time.Second - oauth2ExpiryMargin)
+	}
+
+	return bearerToken{Token: token.AccessToken, ExpiresAt: expiresAt}, nil
+}
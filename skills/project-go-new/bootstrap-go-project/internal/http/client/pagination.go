@@ -0,0 +1,300 @@
+package client
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"net/url"
+	"strconv"
+	"strings"
+)
+
+// Pager reads the pages of a list endpoint, see PageNumbers, Cursor and LinkHeader.
+type Pager[T any] interface {
+	// First returns the request of the first page.
+	First(rc RequestConfig) RequestConfig
+	// Next returns the items of the page, and the request of the next page, nil after the last page.
+	Next(rc RequestConfig, body []byte, header http.Header) ([]T, *RequestConfig, error)
+}
+
+// Iterator iterates the items of a paginated list endpoint, requesting the pages as they are needed:
+//
+//	it := client.Paginate(ctx, orders, client.RequestConfig{URL: url}, client.PageNumbers[Order]{Limit: 100})
+//	for it.Next() {
+//		sync(it.Item())
+//	}
+//	if err := it.Err(); err != nil {
+//		return err
+//	}
+type Iterator[T any] struct {
+	ctx   context.Context
+	c     AuthenticatedClient
+	pager Pager[T]
+	// Request of the next page, nil after the last page.
+	next  *RequestConfig
+	items []T
+	item  T
+	err   error
+}
+
+// Paginate returns an iterator over the items of the list endpoint of the request, with the pagination of pager.
+func Paginate[T any](ctx context.Context, c AuthenticatedClient, rc RequestConfig, pager Pager[T]) *Iterator[T] {
+	first := pager.First(rc)
+
+	return &Iterator[T]{ctx: ctx, c: c, pager: pager, next: &first}
+}
+
+// Next advances to the next item, requesting the next page when needed.
+// It returns false after the last item or when a request failed, see Err.
+func (it *Iterator[T]) Next() bool {
+	for len(it.items) == 0 {
+		if it.err != nil || it.next == nil {
+			return false
+		}
+		it.fetch()
+	}
+
+	it.item, it.items = it.items[0], it.items[1:]
+
+	return true
+}
+
+// Item returns the current item.
+func (it *Iterator[T]) Item() T {
+	return it.item
+}
+
+// Err returns the error that stopped the iteration, nil when all items were iterated.
+func (it *Iterator[T]) Err() error {
+	return it.err
+}
+
+// Requests the next page.
+func (it *Iterator[T]) fetch() {
+	rc := *it.next
+	rc.Context = it.ctx
+	rc.ResponseHeader = http.Header{}
+
+	var body json.RawMessage
+	rc.Data = &body
+	if it.err = it.c.DoRequest(rc); it.err != nil {
+		return
+	}
+
+	// A page without a body has no items, and is the last page.
+	if len(body) == 0 {
+		it.next = nil
+		return
+	}
+
+	it.items, it.next, it.err = it.pager.Next(rc, body, rc.ResponseHeader)
+}
+
+// PageNumbers requests the pages by number, e.g. ?page=2&limit=100, until a page has fewer items than the limit.
+type PageNumbers[T any] struct {
+	// Query parameters of the page number and size, default to page and limit.
+	PageParam  string
+	LimitParam string
+	// Number of the first page, defaults to 1.
+	FirstPage int
+	// Items per page, defaults to 100.
+	Limit int
+	// Field of the response object with the items, the response is an array of the items when empty.
+	ItemsField string
+}
+
+func (p PageNumbers[T]) First(rc RequestConfig) RequestConfig {
+	first := p.FirstPage
+	if first == 0 {
+		first = 1
+	}
+
+	return p.page(rc, first)
+}
+
+func (p PageNumbers[T]) Next(rc RequestConfig, body []byte, _ http.Header) ([]T, *RequestConfig, error) {
+	items, err := decodeItems[T](body, p.ItemsField)
+	if err != nil || len(items) < p.limit() {
+		return items, nil, err
+	}
+
+	page, err := strconv.Atoi(rc.Query.Get(p.param(p.PageParam, "page")))
+	if err != nil {
+		return nil, nil, err
+	}
+	next := p.page(rc, page+1)
+
+	return items, &next, nil
+}
+
+// Returns the request of the page.
+func (p PageNumbers[T]) page(rc RequestConfig, page int) RequestConfig {
+	rc.Query = withQuery(rc.Query, p.param(p.PageParam, "page"), strconv.Itoa(page))
+	rc.Query.Set(p.param(p.LimitParam, "limit"), strconv.Itoa(p.limit()))
+
+	return rc
+}
+
+func (p PageNumbers[T]) param(name, fallback string) string {
+	if name == "" {
+		return fallback
+	}
+
+	return name
+}
+
+func (p PageNumbers[T]) limit() int {
+	if p.Limit <= 0 {
+		return 100
+	}
+
+	return p.Limit
+}
+
+// Cursor requests the pages with the cursor of the previous page, e.g. {"items": [...], "nextCursor": "abc"}
+// followed by ?cursor=abc, until a page has no cursor.
+type Cursor[T any] struct {
+	// Field of the response object with the items, defaults to items.
+	ItemsField string
+	// Field of the response object with the cursor of the next page, defaults to nextCursor.
+	CursorField string
+	// Query parameter of the cursor, defaults to cursor.
+	CursorParam string
+}
+
+func (p Cursor[T]) First(rc RequestConfig) RequestConfig {
+	return rc
+}
+
+func (p Cursor[T]) Next(rc RequestConfig, body []byte, _ http.Header) ([]T, *RequestConfig, error) {
+	itemsField, cursorField, cursorParam := p.ItemsField, p.CursorField, p.CursorParam
+	if itemsField == "" {
+		itemsField = "items"
+	}
+	if cursorField == "" {
+		cursorField = "nextCursor"
+	}
+	if cursorParam == "" {
+		cursorParam = "cursor"
+	}
+
+	items, err := decodeItems[T](body, itemsField)
+	if err != nil {
+		return nil, nil, err
+	}
+
+	var page map[string]json.RawMessage
+	if err := json.Unmarshal(body, &page); err != nil {
+		return nil, nil, err
+	}
+
+	// A cursor is a string or a number, null or missing after the last page.
+	cursor := strings.Trim(string(page[cursorField]), `"`)
+	if cursor == "" || cursor == "null" {
+		return items, nil, nil
+	}
+
+	rc.Query = withQuery(rc.Query, cursorParam, cursor)
+
+	return items, &rc, nil
+}
+
+// LinkHeader requests the pages with the URL of the next link of the Link header (RFC 8288), e.g. of the GitHub API.
+type LinkHeader[T any] struct {
+	// Field of the response object with the items, the response is an array of the items when empty.
+	ItemsField string
+}
+
+func (p LinkHeader[T]) First(rc RequestConfig) RequestConfig {
+	return rc
+}
+
+func (p LinkHeader[T]) Next(rc RequestConfig, body []byte, header http.Header) ([]T, *RequestConfig, error) {
+	items, err := decodeItems[T](body, p.ItemsField)
+	if err != nil {
+		return nil, nil, err
+	}
+
+	link := nextLink(header)
+	if link == "" {
+		return items, nil, nil
+	}
+
+	// The link can be relative to the URL of the page, and has the query parameters of the next page.
+	base, err := requestURL(rc.URL, rc.Query)
+	if err != nil {
+		return nil, nil, err
+	}
+	u, err := url.Parse(base)
+	if err != nil {
+		return nil, nil, err
+	}
+	next, err := u.Parse(link)
+	if err != nil {
+		return nil, nil, fmt.Errorf("invalid next link: %w", err)
+	}
+
+	rc.URL, rc.Query = next.String(), nil
+
+	return items, &rc, nil
+}
+
+// Returns the target of the link with rel="next", e.g. Link: <https://api.example.com/orders?page=2>; rel="next".
+func nextLink(header http.Header) string {
+	for _, value := range header.Values("Link") {
+		for _, link := range strings.Split(value, ",") {
+			target, params, _ := strings.Cut(strings.TrimSpace(link), ";")
+			for _, param := range strings.Split(params, ";") {
+				key, v, _ := strings.Cut(strings.TrimSpace(param), "=")
+				if strings.EqualFold(key, "rel") && containsField(strings.Trim(v, `"`), "next") {
+					return strings.Trim(strings.TrimSpace(target), "<>")
+				}
+			}
+		}
+	}
+
+	return ""
+}
+
+// Reports whether the space separated list contains the value, rel can have several types, e.g. rel="next last".
+func containsField(list, value string) bool {
+	for _, field := range strings.Fields(list) {
+		if strings.EqualFold(field, value) {
+			return true
+		}
+	}
+
+	return false
+}
+
+// Decodes the items of a page, the array in the field of the response object, or the response array.
+func decodeItems[T any](body []byte, field string) ([]T, error) {
+	var items []T
+	if field == "" {
+		return items, json.Unmarshal(body, &items)
+	}
+
+	var page map[string]json.RawMessage
+	if err := json.Unmarshal(body, &page); err != nil {
+		return nil, err
+	}
+	if raw, ok := page[field]; ok {
+		if err := json.Unmarshal(raw, &items); err != nil {
+			return nil, err
+		}
+	}
+
+	return items, nil
+}
+
+// Returns a copy of the query with the parameter set, the query of the previous page is not modified.
+func withQuery(query url.Values, key, value string) url.Values {
+	q := make(url.Values, len(query)+1)
+	for k, v := range query {
+		q[k] = append([]string(nil), v...)
+	}
+	q.Set(key, value)
+
+	return q
+}
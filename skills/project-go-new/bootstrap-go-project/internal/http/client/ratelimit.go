@@ -0,0 +1,21 @@
+package client
+
+import (
+	"net/http"
+
+	"golang.org/x/time/rate"
+)
+
+// Waits for the rate limit of the API before sending a request, or until the request context is done.
+type rateLimitTransport struct {
+	next    http.RoundTripper
+	limiter *rate.Limiter
+}
+
+func (t rateLimitTransport) RoundTrip(r *http.Request) (*http.Response, error) {
+	if err := t.limiter.Wait(r.Context()); err != nil {
+		return nil, err
+	}
+
+	return t.next.RoundTrip(r)
+}
@@ -0,0 +1,134 @@
+package client
+
+import (
+	"context"
+	"io"
+	"math/rand/v2"
+	"net/http"
+	"strconv"
+	"time"
+)
+
+const (
+	defaultInitialBackoff = 100 * time.Millisecond
+	defaultMaxBackoff     = 2 * time.Second
+	defaultMaxRetryAfter  = 30 * time.Second
+)
+
+// RetryPolicy configures the retries of DoRequest. The zero value does not retry.
+//
+// Only requests with an idempotent method (GET, HEAD, OPTIONS, PUT, DELETE) are retried after a connection error
+// or a 5xx response, so a request the API may have processed is never repeated. Requests of any method are retried
+// after 429 Too Many Requests, which the API rejected without processing them.
+//
+// The Retry-After header of a response is waited for instead of the backoff.
+type RetryPolicy struct {
+	// Maximum number of attempts, including the first one.
+	MaxAttempts int
+	// Backoff before the first retry, doubled after every attempt. Defaults to 100 milliseconds.
+	InitialBackoff time.Duration
+	// Maximum backoff between attempts, defaults to 2 seconds.
+	MaxBackoff time.Duration
+	// Maximum Retry-After that is waited for, a response asking to wait longer is returned. Defaults to 30 seconds.
+	MaxRetryAfter time.Duration
+}
+
+// Returns the wait before the next attempt, the Retry-After of the response or the backoff.
+// False when the response asks to wait longer than MaxRetryAfter.
+func (p RetryPolicy) wait(attempt int, res *http.Response) (time.Duration, bool) {
+	if d, ok := retryAfter(res); ok {
+		limit := p.MaxRetryAfter
+		if limit <= 0 {
+			limit = defaultMaxRetryAfter
+		}
+		return d, d <= limit
+	}
+
+	return p.backoff(attempt), true
+}
+
+// Returns the Retry-After of the response, in seconds or an HTTP date.
+func retryAfter(res *http.Response) (time.Duration, bool) {
+	if res == nil {
+		return 0, false
+	}
+
+	v := res.Header.Get("Retry-After")
+	if v == "" {
+		return 0, false
+	}
+	if seconds, err := strconv.Atoi(v); err == nil && seconds >= 0 {
+		return time.Duration(seconds) * time.Second, true
+	}
+	if t, err := http.ParseTime(v); err == nil {
+		return max(time.Until(t), 0), true
+	}
+
+	return 0, false
+}
+
+// Returns the backoff after the given attempt, a random duration up to the exponential backoff (full jitter),
+// so clients that failed at the same time do not retry at the same time.
+func (p RetryPolicy) backoff(attempt int) time.Duration {
+	initial, max := p.InitialBackoff, p.MaxBackoff
+	if initial <= 0 {
+		initial = defaultInitialBackoff
+	}
+	if max <= 0 {
+		max = defaultMaxBackoff
+	}
+
+	d := max
+	if attempt < 32 && initial<<(attempt-1) < max {
+		d = initial << (attempt - 1)
+	}
+
+	return time.Duration(rand.Int64N(int64(d) + 1))
+}
+
+func idempotent(method string) bool {
+	switch method {
+	case "", http.MethodGet, http.MethodHead, http.MethodOptions, http.MethodTrace, http.MethodPut, http.MethodDelete:
+		return true
+	}
+
+	return false
+}
+
+// Reports whether the result of an attempt is transient, and the request can be sent again.
+func retryable(method string, res *http.Response, err error) bool {
+	if res != nil && res.StatusCode == http.StatusTooManyRequests {
+		return true
+	}
+	if !idempotent(method) {
+		return false
+	}
+	if err != nil {
+		return true
+	}
+
+	return res.StatusCode >= http.StatusInternalServerError
+}
+
+// Discards the response of a failed attempt, so its connection can be reused.
+func discard(res *http.Response) {
+	if res == nil {
+		return
+	}
+
+	_, _ = io.Copy(io.Discard, io.LimitReader(res.Body, 1<<16))
+	res.Body.Close()
+}
+
+// Waits for the backoff, or returns the error of the context when it is done first.
+func sleep(ctx context.Context, d time.Duration) error {
+	t := time.NewTimer(d)
+	defer t.Stop()
+
+	select {
+	case <-t.C:
+		return nil
+	case <-ctx.Done():
+		return ctx.Err()
+	}
+}
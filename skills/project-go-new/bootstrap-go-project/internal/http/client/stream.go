@@ -0,0 +1,40 @@
+package client
+
+import (
+	"encoding/json"
+	"errors"
+	"io"
+)
+
+// Returns the Accept header for the response of the request.
+func accept(rc RequestConfig) string {
+	switch {
+	case rc.Each != nil:
+		return "application/x-ndjson, application/json"
+	case rc.Writer != nil:
+		return "*/*"
+	}
+	if _, ok := rc.Data.(*[]byte); ok {
+		return "*/*"
+	}
+
+	return "application/json"
+}
+
+// Decodes the values of a newline delimited JSON body one by one, without reading the body into memory.
+func decodeEach(body io.Reader, fn func(value json.RawMessage) error) error {
+	dec := json.NewDecoder(body)
+	for {
+		var value json.RawMessage
+		if err := dec.Decode(&value); err != nil {
+			if errors.Is(err, io.EOF) {
+				return nil
+			}
+			return err
+		}
+
+		if err := fn(value); err != nil {
+			return err
+		}
+	}
+}
@@ -0,0 +1,104 @@
+package client
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"slices"
+	"strings"
+)
+
+// Maximum size of an error response read into an APIError.
+const maxErrorBody = 64 << 10
+
+// APIError is returned by DoRequest when the response is not successful, see RequestConfig.AcceptStatuses.
+// The code and message are read from the JSON body, e.g. {"code": "insufficient_funds", "message": "..."}
+// or {"error": "..."}, when the API returns one.
+type APIError struct {
+	StatusCode int
+	Status     string
+	Code       string
+	Message    string
+	// Body of the response, truncated to 64 KiB.
+	Body []byte
+}
+
+func (e *APIError) Error() string {
+	msg := fmt.Sprintf("request failed: %s", e.Status)
+	if e.Code != "" {
+		msg += ": " + e.Code
+	}
+	if e.Message != "" {
+		msg += ": " + e.Message
+	}
+
+	return msg
+}
+
+// NewAPIError reads the error response into an APIError, e.g. in a RequestConfig.Success check.
+func NewAPIError(res *http.Response) *APIError {
+	e := &APIError{StatusCode: res.StatusCode, Status: res.Status}
+	e.Body, _ = io.ReadAll(io.LimitReader(res.Body, maxErrorBody))
+
+	// Fields of another type than expected are skipped, e.g. an error object.
+	var body struct {
+		Code    json.RawMessage `json:"code"`
+		Message string          `json:"message"`
+		Error   string          `json:"error"`
+	}
+	_ = json.Unmarshal(e.Body, &body)
+
+	if code := string(body.Code); code != "null" {
+		e.Code = strings.Trim(code, `"`)
+	}
+	e.Message = body.Message
+	if e.Message == "" {
+		e.Message = body.Error
+	}
+
+	return e
+}
+
+// AcceptStatuses returns a success check that accepts responses with the statuses, and returns an *APIError for
+// other responses, e.g. for the Success of a client.
+func AcceptStatuses(statuses ...int) func(res *http.Response) error {
+	return func(res *http.Response) error {
+		if slices.Contains(statuses, res.StatusCode) {
+			return nil
+		}
+
+		return NewAPIError(res)
+	}
+}
+
+// DoRequestAs sends the request with the client and returns the JSON response decoded into a T, see DoRequest:
+//
+//	order, err := client.DoRequestAs[Order](ctx, orders, client.RequestConfig{URL: url})
+//
+// When the response is not successful, the error is an *APIError, see RequestConfig.AcceptStatuses.
+func DoRequestAs[T any](ctx context.Context, c AuthenticatedClient, rc RequestConfig) (T, error) {
+	var out T
+	rc.Context = ctx
+	rc.Data = &out
+
+	err := c.DoRequest(rc)
+
+	return out, err
+}
+
+// DoRequestEach sends the request with the client and calls fn with every value of the newline delimited JSON
+// (NDJSON) response decoded into a T, see RequestConfig.Each. It stops at the first error fn returns.
+func DoRequestEach[T any](ctx context.Context, c AuthenticatedClient, rc RequestConfig, fn func(T) error) error {
+	rc.Context = ctx
+	rc.Each = func(value json.RawMessage) error {
+		var v T
+		if err := json.Unmarshal(value, &v); err != nil {
+			return err
+		}
+		return fn(v)
+	}
+
+	return c.DoRequest(rc)
+}
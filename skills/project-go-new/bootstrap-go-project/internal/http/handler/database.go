@@ -0,0 +1,28 @@
+package handler
+
+import (
+	"encoding/json"
+	"net/http"
+
+	"gitlab.com/btcdirect-api/bootstrap-go-service/internal/sql"
+)
+
+// DatabaseNodesHandler returns the health of the database primary and its replicas.
+func DatabaseNodesHandler(conn interface {
+	Nodes() []sql.NodeHealth
+}) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		type output struct {
+			Nodes []sql.NodeHealth `json:"nodes"`
+		}
+
+		o := output{
+			Nodes: conn.Nodes(),
+		}
+
+		w.Header().Set("Content-Type", "application/json")
+		w.WriteHeader(http.StatusOK)
+
+		json.NewEncoder(w).Encode(o)
+	}
+}
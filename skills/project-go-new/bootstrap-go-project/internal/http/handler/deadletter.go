@@ -0,0 +1,109 @@
+package handler
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+	"strconv"
+
+	"gitlab.com/btcdirect-api/bootstrap-go-service/internal/http/router"
+	"gitlab.com/btcdirect-api/bootstrap-go-service/internal/messenger"
+	"go.uber.org/zap"
+)
+
+const defaultDeadLetterLimit = 50
+
+type deadLetterProvider interface {
+	DeadLetters() messenger.DeadLetterQueue
+}
+
+// ListDeadLettersHandler returns the messages in the dead letter queue, limited by the max query parameter.
+// The messages stay in the dead letter queue.
+func ListDeadLettersHandler(provider deadLetterProvider, logger *zap.SugaredLogger) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		type output struct {
+			DeadLetters []messenger.DeadLetter `json:"deadLetters"`
+		}
+
+		max := defaultDeadLetterLimit
+		if v := r.URL.Query().Get("max"); v != "" {
+			var err error
+			if max, err = strconv.Atoi(v); err != nil || max < 1 {
+				errorHandler(fmt.Errorf("invalid max: %s", v), http.StatusBadRequest, w, logger)
+				return
+			}
+		}
+
+		letters, err := provider.DeadLetters().List(r.Context(), max)
+		if err != nil {
+			errorHandler(err, deadLetterErrorCode(err), w, logger)
+			return
+		}
+
+		o := output{
+			DeadLetters: letters,
+		}
+
+		w.Header().Set("Content-Type", "application/json")
+		w.WriteHeader(http.StatusOK)
+
+		json.NewEncoder(w).Encode(o)
+	}
+}
+
+// GetDeadLetterHandler returns a single message from the dead letter queue.
+func GetDeadLetterHandler(provider deadLetterProvider, logger *zap.SugaredLogger) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		letter, err := provider.DeadLetters().Get(r.Context(), router.Param(r, "id"))
+		if err != nil {
+			errorHandler(err, deadLetterErrorCode(err), w, logger)
+			return
+		}
+
+		w.Header().Set("Content-Type", "application/json")
+		w.WriteHeader(http.StatusOK)
+
+		json.NewEncoder(w).Encode(letter)
+	}
+}
+
+// RequeueDeadLetterHandler dispatches a message from the dead letter queue to its original queue again.
+func RequeueDeadLetterHandler(provider deadLetterProvider, logger *zap.SugaredLogger) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		id := router.Param(r, "id")
+		if err := provider.DeadLetters().Requeue(r.Context(), id); err != nil {
+			errorHandler(err, deadLetterErrorCode(err), w, logger)
+			return
+		}
+
+		logger.Infow("Dead letter requeued by admin", "id", id)
+
+		w.WriteHeader(http.StatusNoContent)
+	}
+}
+
+// PurgeDeadLettersHandler removes all messages from the dead letter queue.
+func PurgeDeadLettersHandler(provider deadLetterProvider, logger *zap.SugaredLogger) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		if err := provider.DeadLetters().Purge(r.Context()); err != nil {
+			errorHandler(err, deadLetterErrorCode(err), w, logger)
+			return
+		}
+
+		logger.Info("Dead letters purged by admin")
+
+		w.WriteHeader(http.StatusNoContent)
+	}
+}
+
+func deadLetterErrorCode(err error) int {
+	switch {
+	case errors.Is(err, messenger.ErrDeadLetterNotFound):
+		return http.StatusNotFound
+	case errors.Is(err, messenger.ErrDeadLetterUnsupported):
+		return http.StatusNotImplemented
+	default:
+		return http.StatusInternalServerError
+	}
+}
@@ -0,0 +1,106 @@
+package handler
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"net/http"
+	"strconv"
+	"time"
+
+	"github.com/google/uuid"
+	"gitlab.com/btcdirect-api/bootstrap-go-service/internal/messenger"
+	"go.uber.org/zap"
+)
+
+const defaultEnqueueRetryAfter = 30 * time.Second
+
+var ErrQueueFull = errors.New("queue is full, try again later")
+
+// EnqueueConfig configures an accept and enqueue handler.
+type EnqueueConfig struct {
+	// Reject requests with 429 once the sampled backlog of the queue reaches this size, zero disables the check.
+	MaxBacklog int64
+	// Value of the Retry-After header of rejected requests, defaults to 30 seconds.
+	RetryAfter time.Duration
+	// Tracks the status of the request as a task, the response links to its status with a Location header.
+	Tasks taskAcceptor
+}
+
+type taskAcceptor interface {
+	Accept(ctx context.Context, id string) error
+	Fail(ctx context.Context, id string, cause error) error
+}
+
+// DecodeMessage decodes and validates the request into the message to enqueue.
+// The tracking ID is returned to the client and should be part of the message.
+// Returned errors are reported to the client as a bad request.
+type DecodeMessage func(r *http.Request, trackingID string) (messenger.Message, error)
+
+type enqueueMessenger interface {
+	messenger.MessageDispatcher
+	QueueBacklog(queue string) (messenger.QueueBacklog, bool)
+}
+
+// EnqueueHandler accepts a request, dispatches it as a message and responds with 202 and a tracking ID.
+// The request is handled asynchronously by the subscriber of the queue.
+//
+// When a MaxBacklog is configured and the sampled backlog of the queue reached it, the request is
+// rejected with 429 and a Retry-After header. Queues without a sample are never rejected.
+func EnqueueHandler(m enqueueMessenger, decode DecodeMessage, c EnqueueConfig, logger *zap.SugaredLogger) http.HandlerFunc {
+	if c.RetryAfter == 0 {
+		c.RetryAfter = defaultEnqueueRetryAfter
+	}
+
+	return func(w http.ResponseWriter, r *http.Request) {
+		type output struct {
+			TrackingID string `json:"trackingId"`
+		}
+
+		trackingID := uuid.NewString()
+
+		msg, err := decode(r, trackingID)
+		if err != nil {
+			errorHandler(err, http.StatusBadRequest, w, logger)
+			return
+		}
+
+		if c.MaxBacklog > 0 {
+			if b, ok := m.QueueBacklog(msg.Queue()); ok && b.Messages >= c.MaxBacklog {
+				w.Header().Set("Retry-After", strconv.Itoa(int(c.RetryAfter.Seconds())))
+				errorHandler(ErrQueueFull, http.StatusTooManyRequests, w, logger)
+				return
+			}
+		}
+
+		if c.Tasks != nil {
+			if err := c.Tasks.Accept(r.Context(), trackingID); err != nil {
+				errorHandler(err, http.StatusInternalServerError, w, logger)
+				return
+			}
+			w.Header().Set("Location", "/tasks/"+trackingID)
+		}
+
+		if err := m.Dispatch(msg); err != nil {
+			if c.Tasks != nil {
+				c.Tasks.Fail(r.Context(), trackingID, err)
+			}
+			if errors.Is(err, messenger.ErrNotConnected) {
+				w.Header().Set("Retry-After", strconv.Itoa(int(c.RetryAfter.Seconds())))
+				errorHandler(err, http.StatusServiceUnavailable, w, logger)
+				return
+			}
+			errorHandler(err, http.StatusInternalServerError, w, logger)
+			return
+		}
+
+		o := output{
+			TrackingID: trackingID,
+		}
+
+		w.Header().Set("Content-Type", "application/json")
+		w.WriteHeader(http.StatusAccepted)
+
+		json.NewEncoder(w).Encode(o)
+	}
+}
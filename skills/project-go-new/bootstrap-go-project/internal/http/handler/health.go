@@ -1,10 +1,13 @@
 package handler
 
 import (
+	"context"
 	"encoding/json"
 	"net/http"
+	"time"
 
 	"gitlab.com/btcdirect-api/bootstrap-go-service/internal/app"
+	"gitlab.com/btcdirect-api/bootstrap-go-service/internal/sql"
 )
 
 type configProvider interface {
@@ -19,7 +22,7 @@ func HealthHandler(provider configProvider) http.HandlerFunc {
 		}
 
 		o := output{
-			Environment: string(provider.Config().Environment),
+			Environment: provider.Config().Environment.String(),
 		}
 
 		w.Header().Set("Content-Type", "application/json")
@@ -29,24 +32,90 @@ func HealthHandler(provider configProvider) http.HandlerFunc {
 	}
 }
 
-// ReadinessHandler returns a 200 OK status code if the database connection is alive.
-// Otherwise, it returns a 503 Service Unavailable status code.
+// Maximum time the readiness check waits for the database ping and the dependency checks.
+const readinessPingTimeout = 2 * time.Second
+
+// ReadinessHandler returns a 200 OK status code unless the application is shutting down, the database is down
+// or a dependency check fails. Otherwise, it returns a 503 Service Unavailable status code.
+// While shutting down nothing is checked, so the load balancer stops routing traffic before the server drains.
+// Without a database connection (nil), only the messenger and the dependencies are reported.
+//
+// A slow database is reported as degraded, but stays ready, so readiness does not flap on latency spikes.
+// The readiness of the messenger is reported, but does not affect the status code,
+// so the API keeps serving while the messenger is degraded.
 func ReadinessHandler(dbConn interface {
-	IsAlive() bool
+	Health(ctx context.Context) sql.Health
+}, messenger interface {
+	Ready() bool
+}, checks map[string]app.ReadinessCheck, shutdown interface {
+	ShuttingDown() bool
 }) http.HandlerFunc {
 	return func(w http.ResponseWriter, r *http.Request) {
+		type database struct {
+			Status       sql.HealthStatus `json:"status"`
+			LatencyMs    int64            `json:"latencyMs"`
+			P95LatencyMs int64            `json:"p95LatencyMs"`
+			LastError    string           `json:"lastError,omitempty"`
+			LastErrorAt  *time.Time       `json:"lastErrorAt,omitempty"`
+		}
+		type dependency struct {
+			Ready bool   `json:"ready"`
+			Error string `json:"error,omitempty"`
+		}
 		type output struct {
-			DatabaseHealthy bool `json:"databaseHealthy"`
+			DatabaseHealthy *bool                 `json:"databaseHealthy,omitempty"`
+			Database        *database             `json:"database,omitempty"`
+			MessengerReady  bool                  `json:"messengerReady"`
+			Dependencies    map[string]dependency `json:"dependencies,omitempty"`
+			ShuttingDown    bool                  `json:"shuttingDown,omitempty"`
+		}
+
+		if shutdown != nil && shutdown.ShuttingDown() {
+			w.Header().Set("Content-Type", "application/json")
+			w.WriteHeader(http.StatusServiceUnavailable)
+
+			json.NewEncoder(w).Encode(output{ShuttingDown: true})
+			return
 		}
 
 		o := output{
-			DatabaseHealthy: dbConn != nil && dbConn.IsAlive(),
+			MessengerReady: messenger != nil && messenger.Ready(),
+		}
+		if dbConn != nil {
+			ctx, cancel := context.WithTimeout(r.Context(), readinessPingTimeout)
+			h := dbConn.Health(ctx)
+			cancel()
+
+			healthy := h.Status != sql.HealthDown
+			o.DatabaseHealthy = &healthy
+			o.Database = &database{
+				Status:       h.Status,
+				LatencyMs:    h.Latency.Milliseconds(),
+				P95LatencyMs: h.P95Latency.Milliseconds(),
+				LastError:    h.LastError,
+			}
+			if !h.LastErrorAt.IsZero() {
+				o.Database.LastErrorAt = &h.LastErrorAt
+			}
+		}
+
+		ready := true
+		if len(checks) > 0 {
+			o.Dependencies = make(map[string]dependency, len(checks))
+			for name, err := range runChecks(r.Context(), checks) {
+				d := dependency{Ready: err == nil}
+				if err != nil {
+					d.Error = err.Error()
+					ready = false
+				}
+				o.Dependencies[name] = d
+			}
 		}
 
 		w.Header().Set("Content-Type", "application/json")
 		defer json.NewEncoder(w).Encode(o)
 
-		if !o.DatabaseHealthy {
+		if !ready || o.DatabaseHealthy != nil && !*o.DatabaseHealthy {
 			w.WriteHeader(http.StatusServiceUnavailable)
 			return
 		}
@@ -54,3 +123,36 @@ func ReadinessHandler(dbConn interface {
 		w.WriteHeader(http.StatusOK)
 	}
 }
+
+// Runs the checks concurrently within the readiness timeout, a check that does not return in time fails.
+func runChecks(ctx context.Context, checks map[string]app.ReadinessCheck) map[string]error {
+	ctx, cancel := context.WithTimeout(ctx, readinessPingTimeout)
+	defer cancel()
+
+	type result struct {
+		name string
+		err  error
+	}
+	results := make(chan result, len(checks))
+	for name, check := range checks {
+		go func() {
+			results <- result{name, check(ctx)}
+		}()
+	}
+
+	errs := make(map[string]error, len(checks))
+	for len(errs) < len(checks) {
+		select {
+		case res := <-results:
+			errs[res.name] = res.err
+		case <-ctx.Done():
+			for name := range checks {
+				if _, ok := errs[name]; !ok {
+					errs[name] = ctx.Err()
+				}
+			}
+		}
+	}
+
+	return errs
+}
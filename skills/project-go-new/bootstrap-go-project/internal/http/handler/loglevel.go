@@ -0,0 +1,70 @@
+package handler
+
+import (
+	"encoding/json"
+	"fmt"
+	"net/http"
+
+	"gitlab.com/btcdirect-api/bootstrap-go-service/internal/app"
+	"go.uber.org/zap"
+	"go.uber.org/zap/zapcore"
+)
+
+// GetLogLevelHandler returns the current log level.
+func GetLogLevelHandler(level *zap.AtomicLevel) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		type output struct {
+			Level string `json:"level"`
+		}
+
+		o := output{
+			Level: level.String(),
+		}
+
+		w.Header().Set("Content-Type", "application/json")
+		w.WriteHeader(http.StatusOK)
+
+		json.NewEncoder(w).Encode(o)
+	}
+}
+
+// SetLogLevelHandler changes the log level until the application restarts, e.g. to debug an incident.
+// Like the configured level, debug is not allowed in production-like environments.
+func SetLogLevelHandler(level *zap.AtomicLevel, environment app.Environment, logger *zap.SugaredLogger) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		type input struct {
+			Level string `json:"level"`
+		}
+		type output struct {
+			Level string `json:"level"`
+		}
+
+		var in input
+		if err := json.NewDecoder(r.Body).Decode(&in); err != nil {
+			errorHandler(fmt.Errorf("invalid body: %w", err), http.StatusBadRequest, w, logger)
+			return
+		}
+
+		l, err := zapcore.ParseLevel(in.Level)
+		if err != nil {
+			errorHandler(fmt.Errorf("unknown log level: %s", in.Level), http.StatusBadRequest, w, logger)
+			return
+		}
+		if environment.IsProductionLike() && l == zapcore.DebugLevel {
+			errorHandler(fmt.Errorf("log level '%s' is not allowed in %s", l, environment), http.StatusBadRequest, w, logger)
+			return
+		}
+
+		logger.Warnf("Changing log level from '%s' to '%s'", level.Level(), l)
+		level.SetLevel(l)
+
+		o := output{
+			Level: level.String(),
+		}
+
+		w.Header().Set("Content-Type", "application/json")
+		w.WriteHeader(http.StatusOK)
+
+		json.NewEncoder(w).Encode(o)
+	}
+}
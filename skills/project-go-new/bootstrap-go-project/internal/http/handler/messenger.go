@@ -0,0 +1,28 @@
+package handler
+
+import (
+	"encoding/json"
+	"net/http"
+
+	"gitlab.com/btcdirect-api/bootstrap-go-service/internal/messenger"
+)
+
+// BacklogHandler returns the last sampled backlog of all subscribed queues.
+func BacklogHandler(m interface {
+	Backlog() []messenger.QueueBacklog
+}) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		type output struct {
+			Queues []messenger.QueueBacklog `json:"queues"`
+		}
+
+		o := output{
+			Queues: m.Backlog(),
+		}
+
+		w.Header().Set("Content-Type", "application/json")
+		w.WriteHeader(http.StatusOK)
+
+		json.NewEncoder(w).Encode(o)
+	}
+}
@@ -0,0 +1,51 @@
+package handler
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"net/http"
+	"time"
+
+	"gitlab.com/btcdirect-api/bootstrap-go-service/internal/http/router"
+	"gitlab.com/btcdirect-api/bootstrap-go-service/internal/task"
+	"go.uber.org/zap"
+)
+
+// TaskHandler returns the status of an asynchronously handled request.
+func TaskHandler(tasks interface {
+	Get(ctx context.Context, id string) (task.Task, error)
+}, logger *zap.SugaredLogger) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		type output struct {
+			ID        string      `json:"id"`
+			Status    task.Status `json:"status"`
+			Error     string      `json:"error,omitempty"`
+			CreatedAt time.Time   `json:"createdAt"`
+			UpdatedAt time.Time   `json:"updatedAt"`
+		}
+
+		t, err := tasks.Get(r.Context(), router.Param(r, "id"))
+		if errors.Is(err, task.ErrNotFound) {
+			errorHandler(err, http.StatusNotFound, w, logger)
+			return
+		}
+		if err != nil {
+			errorHandler(err, http.StatusInternalServerError, w, logger)
+			return
+		}
+
+		o := output{
+			ID:        t.ID,
+			Status:    t.Status,
+			Error:     t.Error.String,
+			CreatedAt: t.CreatedAt,
+			UpdatedAt: t.UpdatedAt,
+		}
+
+		w.Header().Set("Content-Type", "application/json")
+		w.WriteHeader(http.StatusOK)
+
+		json.NewEncoder(w).Encode(o)
+	}
+}
@@ -0,0 +1,148 @@
+package handler
+
+import (
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/base64"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"io"
+	"net/http"
+	"strings"
+
+	"gitlab.com/btcdirect-api/bootstrap-go-service/internal/http/router"
+	"gitlab.com/btcdirect-api/bootstrap-go-service/internal/messenger"
+	"gitlab.com/btcdirect-api/bootstrap-go-service/internal/messenger/inbound/webhook"
+	"go.uber.org/zap"
+)
+
+// Maximum size of a webhook payload.
+const maxWebhookBody = 1 << 20
+
+var (
+	ErrUnknownProvider  = errors.New("unknown webhook provider")
+	ErrInvalidSignature = errors.New("invalid webhook signature")
+	ErrPayloadTooLarge  = errors.New("webhook payload too large")
+)
+
+// Request headers not passed on with the webhook message.
+var sensitiveWebhookHeaders = map[string]bool{"Authorization": true, "Cookie": true}
+
+// SignatureScheme verifies the signature of a webhook request over its raw payload with the secret.
+type SignatureScheme func(r *http.Request, payload, secret []byte) error
+
+// WebhookProvider is a webhook sender with its secret and signature scheme.
+type WebhookProvider struct {
+	Secret string
+	Scheme SignatureScheme
+}
+
+// HMACSHA256Hex verifies a hex encoded HMAC-SHA256 signature in the header, after the prefix,
+// e.g. HMACSHA256Hex("X-Hub-Signature-256", "sha256=") for GitHub.
+func HMACSHA256Hex(header, prefix string) SignatureScheme {
+	return func(r *http.Request, payload, secret []byte) error {
+		given, ok := strings.CutPrefix(r.Header.Get(header), prefix)
+		if !ok {
+			return ErrInvalidSignature
+		}
+
+		signature, err := hex.DecodeString(given)
+		if err != nil {
+			return ErrInvalidSignature
+		}
+
+		return verifyHMAC(payload, secret, signature)
+	}
+}
+
+// HMACSHA256Base64 verifies a base64 encoded HMAC-SHA256 signature in the header,
+// e.g. HMACSHA256Base64("X-Shopify-Hmac-Sha256").
+func HMACSHA256Base64(header string) SignatureScheme {
+	return func(r *http.Request, payload, secret []byte) error {
+		signature, err := base64.StdEncoding.DecodeString(r.Header.Get(header))
+		if err != nil {
+			return ErrInvalidSignature
+		}
+
+		return verifyHMAC(payload, secret, signature)
+	}
+}
+
+func verifyHMAC(payload, secret, signature []byte) error {
+	mac := hmac.New(sha256.New, secret)
+	mac.Write(payload)
+	if len(signature) == 0 || !hmac.Equal(mac.Sum(nil), signature) {
+		return ErrInvalidSignature
+	}
+
+	return nil
+}
+
+// WebhookHandler receives the webhooks of the providers on a route with a {provider} variable,
+// e.g. /webhooks/{provider}. The signature is verified with the secret and scheme of the provider,
+// then the raw payload and the request headers are dispatched onto the webhook queue and 202 is returned.
+// The processors of the webhook handler handle the message asynchronously, see webhook.NewHandler.
+//
+// Providers without a secret are rejected, so an unconfigured secret never accepts unsigned webhooks.
+func WebhookHandler(m messenger.MessageDispatcher, providers map[string]WebhookProvider, logger *zap.SugaredLogger) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		type output struct {
+			Status string `json:"status"`
+		}
+
+		name := router.Param(r, "provider")
+		provider, ok := providers[name]
+		if !ok || provider.Secret == "" || provider.Scheme == nil {
+			errorHandler(ErrUnknownProvider, http.StatusNotFound, w, logger)
+			return
+		}
+
+		payload, err := io.ReadAll(http.MaxBytesReader(w, r.Body, maxWebhookBody))
+		if err != nil {
+			var maxErr *http.MaxBytesError
+			if errors.As(err, &maxErr) {
+				errorHandler(ErrPayloadTooLarge, http.StatusRequestEntityTooLarge, w, logger)
+				return
+			}
+			errorHandler(err, http.StatusBadRequest, w, logger)
+			return
+		}
+
+		if err := provider.Scheme(r, payload, []byte(provider.Secret)); err != nil {
+			errorHandler(err, http.StatusUnauthorized, w, logger)
+			return
+		}
+
+		headers := map[string]string{"Webhook-Provider": name}
+		for key, values := range r.Header {
+			if !sensitiveWebhookHeaders[key] && len(values) > 0 {
+				headers[key] = values[0]
+			}
+		}
+
+		msg, err := webhook.NewMessage(headers, payload)
+		if err != nil {
+			errorHandler(err, http.StatusBadRequest, w, logger)
+			return
+		}
+
+		if err := m.Dispatch(msg); err != nil {
+			if errors.Is(err, messenger.ErrNotConnected) {
+				errorHandler(err, http.StatusServiceUnavailable, w, logger)
+				return
+			}
+			errorHandler(err, http.StatusInternalServerError, w, logger)
+			return
+		}
+
+		o := output{
+			Status: "accepted",
+		}
+
+		w.Header().Set("Content-Type", "application/json")
+		w.WriteHeader(http.StatusAccepted)
+
+		json.NewEncoder(w).Encode(o)
+	}
+}
@@ -0,0 +1,43 @@
+package middleware
+
+import (
+	"crypto/subtle"
+	"errors"
+	"net/http"
+	"strings"
+
+	"github.com/gorilla/mux"
+	"gitlab.com/btcdirect-api/bootstrap-go-service/internal/http/respond"
+	"go.uber.org/zap"
+)
+
+var (
+	ErrAdminDisabled = errors.New("admin endpoints are disabled")
+	ErrUnauthorized  = errors.New("unauthorized")
+)
+
+// AdminToken only allows requests with the given bearer token in the Authorization header.
+// When no token is configured all requests are rejected, so admin endpoints are never exposed by accident.
+func AdminToken(token string, log *zap.SugaredLogger) mux.MiddlewareFunc {
+	return func(next http.Handler) http.Handler {
+		return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			if token == "" {
+				writeError(w, http.StatusForbidden, ErrAdminDisabled)
+				return
+			}
+
+			given, ok := strings.CutPrefix(r.Header.Get("Authorization"), "Bearer ")
+			if !ok || subtle.ConstantTimeCompare([]byte(given), []byte(token)) != 1 {
+				log.Warnw("Rejected admin request", "path", r.URL.Path, "clientIp", ClientIPFromRequest(r))
+				writeError(w, http.StatusUnauthorized, ErrUnauthorized)
+				return
+			}
+
+			next.ServeHTTP(w, r)
+		})
+	}
+}
+
+func writeError(w http.ResponseWriter, code int, err error) {
+	respond.Error(w, code, err)
+}
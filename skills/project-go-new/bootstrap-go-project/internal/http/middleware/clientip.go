@@ -0,0 +1,154 @@
+package middleware
+
+import (
+	"context"
+	"fmt"
+	"net"
+	"net/http"
+	"net/netip"
+	"strings"
+)
+
+type clientIPKey struct{}
+
+// ParseTrustedProxies parses the addresses of trusted proxies, CIDRs like 10.0.0.0/8 or single IP addresses.
+func ParseTrustedProxies(proxies []string) ([]netip.Prefix, error) {
+	prefixes, err := ParseCIDRs(proxies)
+	if err != nil {
+		return nil, fmt.Errorf("invalid trusted proxy: %w", err)
+	}
+
+	return prefixes, nil
+}
+
+// ParseCIDRs parses address ranges, CIDRs like 10.0.0.0/8 or single IP addresses. Empty values are skipped.
+func ParseCIDRs(cidrs []string) ([]netip.Prefix, error) {
+	prefixes := make([]netip.Prefix, 0, len(cidrs))
+	for _, c := range cidrs {
+		c = strings.TrimSpace(c)
+		if c == "" {
+			continue
+		}
+
+		if !strings.Contains(c, "/") {
+			addr, err := netip.ParseAddr(c)
+			if err != nil {
+				return nil, err
+			}
+			prefixes = append(prefixes, netip.PrefixFrom(addr.Unmap(), addr.Unmap().BitLen()))
+			continue
+		}
+
+		prefix, err := netip.ParsePrefix(c)
+		if err != nil {
+			return nil, err
+		}
+		prefixes = append(prefixes, prefix.Masked())
+	}
+
+	return prefixes, nil
+}
+
+// ClientIP resolves the IP address of the client and stores it in the request context, see ClientIPFromRequest.
+//
+// Forwarding headers are only read when the request comes from a trusted proxy, otherwise any client could spoof
+// its address. The Forwarded header, or X-Forwarded-For without it, is read from right to left: the first
+// address that is not a trusted proxy is the client, as the addresses left of it may be set by the client.
+func ClientIP(trusted []netip.Prefix) func(http.Handler) http.Handler {
+	return func(next http.Handler) http.Handler {
+		return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			ip := resolveClientIP(r, trusted)
+
+			next.ServeHTTP(w, r.WithContext(context.WithValue(r.Context(), clientIPKey{}, ip)))
+		})
+	}
+}
+
+// ClientIPFromRequest returns the IP address of the client resolved by ClientIP,
+// or the address of the peer when the request did not pass ClientIP.
+func ClientIPFromRequest(r *http.Request) string {
+	if ip, ok := r.Context().Value(clientIPKey{}).(string); ok {
+		return ip
+	}
+
+	return remoteHost(r)
+}
+
+func resolveClientIP(r *http.Request, trusted []netip.Prefix) string {
+	peer := remoteHost(r)
+	if !isTrusted(peer, trusted) {
+		return peer
+	}
+
+	hops := forwardedFor(r.Header)
+	for i := len(hops) - 1; i >= 0; i-- {
+		if !isTrusted(hops[i], trusted) {
+			return hops[i]
+		}
+	}
+
+	// All hops are trusted proxies, the first one is the closest to the client.
+	if len(hops) > 0 {
+		return hops[0]
+	}
+
+	return peer
+}
+
+// Returns the addresses of the Forwarded header, or the X-Forwarded-For header without it.
+// Malformed and obfuscated addresses (e.g. for=unknown) are returned as they are, so they are never trusted.
+func forwardedFor(h http.Header) []string {
+	var hops []string
+
+	if values := h.Values("Forwarded"); len(values) > 0 {
+		for _, value := range values {
+			for _, element := range strings.Split(value, ",") {
+				for _, pair := range strings.Split(element, ";") {
+					key, v, ok := strings.Cut(strings.TrimSpace(pair), "=")
+					if ok && strings.EqualFold(key, "for") {
+						hops = append(hops, forwardedNode(v))
+					}
+				}
+			}
+		}
+		return hops
+	}
+
+	for _, value := range h.Values("X-Forwarded-For") {
+		for _, hop := range strings.Split(value, ",") {
+			if hop = strings.TrimSpace(hop); hop != "" {
+				hops = append(hops, hop)
+			}
+		}
+	}
+
+	return hops
+}
+
+// Returns the address of a node of the Forwarded header, e.g. "[2001:db8::1]:4711" or "192.0.2.43:47011".
+func forwardedNode(v string) string {
+	v = strings.Trim(v, `"`)
+	if host, _, err := net.SplitHostPort(v); err == nil {
+		return host
+	}
+
+	return strings.Trim(v, "[]")
+}
+
+func isTrusted(ip string, trusted []netip.Prefix) bool {
+	addr, err := netip.ParseAddr(ip)
+	if err != nil {
+		return false
+	}
+
+	return contains(trusted, addr.Unmap())
+}
+
+func remoteHost(r *http.Request) string {
+	host, _, err := net.SplitHostPort(r.RemoteAddr)
+	if err != nil {
+		return r.RemoteAddr
+	}
+
+	return host
+}
@@ -0,0 +1,134 @@
+package middleware
+
+import (
+	"net/http"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// CORSOptions configures the cross-origin requests allowed by CORS.
+type CORSOptions struct {
+	// Origins allowed to call the service, e.g. https://app.example.com. "*" allows any origin without credentials.
+	Origins []string
+	Methods []string
+	// Request headers allowed in addition to the CORS-safelisted headers, "*" allows any header.
+	Headers     []string
+	Credentials bool
+	// Time browsers cache the result of a preflight request, zero leaves it to the browser.
+	MaxAge time.Duration
+}
+
+// CORS adds the CORS headers for requests of an allowed origin and answers their preflight requests.
+// Requests without an Origin header and requests of other origins are passed on without CORS headers,
+// so the browser blocks the response. CORS is disabled when no origins are configured.
+//
+// Credentials are only allowed for origins that are listed explicitly, never for "*".
+func CORS(o CORSOptions) func(http.Handler) http.Handler {
+	origins := make(map[string]bool, len(o.Origins))
+	for _, origin := range o.Origins {
+		origins[strings.TrimSuffix(origin, "/")] = true
+	}
+
+	methods := make(map[string]bool, len(o.Methods))
+	for _, method := range o.Methods {
+		methods[strings.ToUpper(method)] = true
+	}
+
+	headers := make(map[string]bool, len(o.Headers))
+	for _, header := range o.Headers {
+		headers[strings.ToLower(header)] = true
+	}
+
+	return func(next http.Handler) http.Handler {
+		if len(origins) == 0 {
+			return next
+		}
+
+		return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			origin := r.Header.Get("Origin")
+			if origin == "" {
+				next.ServeHTTP(w, r)
+				return
+			}
+
+			w.Header().Add("Vary", "Origin")
+
+			preflight := r.Method == http.MethodOptions && r.Header.Get("Access-Control-Request-Method") != ""
+			if preflight {
+				w.Header().Add("Vary", "Access-Control-Request-Method")
+				w.Header().Add("Vary", "Access-Control-Request-Headers")
+			}
+
+			explicit := origins[origin]
+			if !explicit && !origins["*"] {
+				if preflight {
+					w.WriteHeader(http.StatusNoContent)
+					return
+				}
+				next.ServeHTTP(w, r)
+				return
+			}
+
+			if explicit {
+				w.Header().Set("Access-Control-Allow-Origin", origin)
+				if o.Credentials {
+					w.Header().Set("Access-Control-Allow-Credentials", "true")
+				}
+			} else {
+				w.Header().Set("Access-Control-Allow-Origin", "*")
+			}
+
+			if !preflight {
+				next.ServeHTTP(w, r)
+				return
+			}
+
+			method := strings.ToUpper(r.Header.Get("Access-Control-Request-Method"))
+			requested := requestedHeaders(r)
+			if !methods[method] || !allowedHeaders(headers, requested) {
+				w.Header().Del("Access-Control-Allow-Origin")
+				w.Header().Del("Access-Control-Allow-Credentials")
+				w.WriteHeader(http.StatusNoContent)
+				return
+			}
+
+			w.Header().Set("Access-Control-Allow-Methods", strings.Join(o.Methods, ", "))
+			if len(requested) > 0 {
+				w.Header().Set("Access-Control-Allow-Headers", strings.Join(requested, ", "))
+			}
+			if o.MaxAge > 0 {
+				w.Header().Set("Access-Control-Max-Age", strconv.Itoa(int(o.MaxAge.Seconds())))
+			}
+			w.WriteHeader(http.StatusNoContent)
+		})
+	}
+}
+
+// Returns the headers of the Access-Control-Request-Headers header of a preflight request.
+func requestedHeaders(r *http.Request) []string {
+	var requested []string
+	for _, value := range r.Header.Values("Access-Control-Request-Headers") {
+		for _, header := range strings.Split(value, ",") {
+			if header = strings.TrimSpace(header); header != "" {
+				requested = append(requested, header)
+			}
+		}
+	}
+
+	return requested
+}
+
+func allowedHeaders(headers map[string]bool, requested []string) bool {
+	if headers["*"] {
+		return true
+	}
+
+	for _, header := range requested {
+		if !headers[strings.ToLower(header)] {
+			return false
+		}
+	}
+
+	return true
+}
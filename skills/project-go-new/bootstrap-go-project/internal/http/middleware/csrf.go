@@ -0,0 +1,152 @@
+package middleware
+
+import (
+	"context"
+	"crypto/rand"
+	"crypto/subtle"
+	"encoding/base64"
+	"errors"
+	"net/http"
+	"strings"
+	"time"
+
+	"github.com/gorilla/mux"
+	"go.uber.org/zap"
+)
+
+const (
+	DefaultCSRFCookie = "csrf_token"
+	DefaultCSRFHeader = "X-CSRF-Token"
+	// Form field read when the request has no CSRF header, e.g. a plain HTML form.
+	CSRFFormField = "csrf_token"
+	// Default lifetime of the CSRF cookie.
+	defaultCSRFMaxAge = 12 * time.Hour
+)
+
+var ErrCSRF = errors.New("invalid CSRF token")
+
+type csrfKey struct{}
+
+// CSRFOptions configures CSRF.
+type CSRFOptions struct {
+	// Name of the cookie with the token, defaults to csrf_token.
+	CookieName string
+	// Header the client sends the token in, defaults to X-CSRF-Token.
+	HeaderName string
+	// Paths that are not checked, e.g. webhooks. A path ending in * matches the paths starting with it.
+	Exempt []string
+	// SameSite of the cookie, defaults to Lax.
+	SameSite http.SameSite
+	// Only send the cookie over HTTPS, enable it outside local development.
+	Secure bool
+	// Domain and path of the cookie, the path defaults to /.
+	Domain string
+	Path   string
+	// Lifetime of the cookie, defaults to 12 hours.
+	MaxAge time.Duration
+}
+
+// CSRF protects browser-facing endpoints with session cookies against cross-site request forgery,
+// with the double-submit cookie pattern.
+//
+// Every response carries a random token in a cookie, also available to templates with CSRFToken. Unsafe requests
+// (POST, PUT, PATCH, DELETE) must send the same token in the header or the csrf_token form field, which another
+// site can not read or set. Requests without a matching token are rejected with 403 Forbidden.
+//
+// The cookie is readable by JavaScript on purpose, so the frontend can copy it into the header.
+func CSRF(o CSRFOptions, log *zap.SugaredLogger) mux.MiddlewareFunc {
+	if o.CookieName == "" {
+		o.CookieName = DefaultCSRFCookie
+	}
+	if o.HeaderName == "" {
+		o.HeaderName = DefaultCSRFHeader
+	}
+	if o.SameSite == 0 {
+		o.SameSite = http.SameSiteLaxMode
+	}
+	if o.Path == "" {
+		o.Path = "/"
+	}
+	if o.MaxAge == 0 {
+		o.MaxAge = defaultCSRFMaxAge
+	}
+
+	return func(next http.Handler) http.Handler {
+		return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			token := ""
+			if c, err := r.Cookie(o.CookieName); err == nil && validCSRFToken(c.Value) {
+				token = c.Value
+			}
+
+			if !safeMethod(r.Method) && !exempt(o.Exempt, r.URL.Path) {
+				given := r.Header.Get(o.HeaderName)
+				if given == "" {
+					given = r.PostFormValue(CSRFFormField)
+				}
+
+				if token == "" || subtle.ConstantTimeCompare([]byte(given), []byte(token)) != 1 {
+					Logger(r.Context(), log).Warnw("Rejected request without a valid CSRF token",
+						"clientIp", ClientIPFromRequest(r), "method", r.Method, "path", r.URL.Path)
+					writeError(w, http.StatusForbidden, ErrCSRF)
+					return
+				}
+			}
+
+			if token == "" {
+				token = newCSRFToken()
+				http.SetCookie(w, &http.Cookie{
+					Name:     o.CookieName,
+					Value:    token,
+					Path:     o.Path,
+					Domain:   o.Domain,
+					MaxAge:   int(o.MaxAge.Seconds()),
+					Secure:   o.Secure,
+					SameSite: o.SameSite,
+				})
+			}
+
+			next.ServeHTTP(w, r.WithContext(context.WithValue(r.Context(), csrfKey{}, token)))
+		})
+	}
+}
+
+// CSRFToken returns the CSRF token of the request, e.g. to render it in a form field named csrf_token.
+func CSRFToken(ctx context.Context) (string, bool) {
+	token, ok := ctx.Value(csrfKey{}).(string)
+	return token, ok
+}
+
+func safeMethod(method string) bool {
+	switch method {
+	case http.MethodGet, http.MethodHead, http.MethodOptions, http.MethodTrace:
+		return true
+	}
+
+	return false
+}
+
+func exempt(paths []string, path string) bool {
+	for _, p := range paths {
+		if prefix, ok := strings.CutSuffix(p, "*"); ok && strings.HasPrefix(path, prefix) {
+			return true
+		}
+		if p == path {
+			return true
+		}
+	}
+
+	return false
+}
+
+func newCSRFToken() string {
+	b := make([]byte, 32)
+	rand.Read(b)
+
+	return base64.RawURLEncoding.EncodeToString(b)
+}
+
+// Tokens are generated by newCSRFToken, other values of the cookie are replaced.
+func validCSRFToken(token string) bool {
+	b, err := base64.RawURLEncoding.DecodeString(token)
+	return err == nil && len(b) == 32
+}
@@ -0,0 +1,147 @@
+package middleware
+
+import (
+	"bytes"
+	"crypto/sha256"
+	"encoding/hex"
+	"io"
+	"net/http"
+	"sync"
+)
+
+const (
+	IdempotencyKeyHeader = "Idempotency-Key"
+	// Header set on responses shared with a concurrent request with the same idempotency key.
+	ReplayedHeader = "Idempotent-Replayed"
+)
+
+// Deduplicate coalesces concurrent requests with the same idempotency key, method, path and body onto a single
+// handler execution. Requests arriving while the first request is in flight wait for it and receive the same
+// response, or 500 when the handler panicked.
+//
+// Only in-flight requests are coalesced, a request arriving after the first one finished is handled again.
+// A request reusing the key with another body is not coalesced, so idempotency.Middleware can reject it.
+// Requests without an Idempotency-Key header are not affected.
+func Deduplicate() func(http.Handler) http.Handler {
+	d := &deduplicator{calls: make(map[string]*inflightCall)}
+
+	return func(next http.Handler) http.Handler {
+		return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			key := r.Header.Get(IdempotencyKeyHeader)
+			if key == "" {
+				next.ServeHTTP(w, r)
+				return
+			}
+
+			body, err := io.ReadAll(r.Body)
+			if err != nil {
+				writeError(w, http.StatusBadRequest, err)
+				return
+			}
+			r.Body = io.NopCloser(bytes.NewReader(body))
+			sum := sha256.Sum256(body)
+
+			d.serve(r.Method+" "+r.URL.Path+" "+key+" "+hex.EncodeToString(sum[:]), next, w, r)
+		})
+	}
+}
+
+type deduplicator struct {
+	calls map[string]*inflightCall
+	sync.Mutex
+}
+
+type inflightCall struct {
+	done     chan struct{}
+	response *recordedResponse
+	// Set when the handler panicked, the response was not recorded completely.
+	failed bool
+}
+
+type recordedResponse struct {
+	header http.Header
+	status int
+	body   bytes.Buffer
+}
+
+func (d *deduplicator) serve(key string, next http.Handler, w http.ResponseWriter, r *http.Request) {
+	d.Lock()
+	if c, ok := d.calls[key]; ok {
+		d.Unlock()
+
+		select {
+		case <-c.done:
+			if c.failed {
+				writeError(w, http.StatusInternalServerError, ErrInternal)
+				return
+			}
+			c.response.replay(w)
+		case <-r.Context().Done():
+		}
+		return
+	}
+
+	c := &inflightCall{
+		done:     make(chan struct{}),
+		response: &recordedResponse{header: http.Header{}, status: http.StatusOK},
+	}
+	d.calls[key] = c
+	d.Unlock()
+
+	completed := false
+	defer func() {
+		c.failed = !completed
+
+		d.Lock()
+		delete(d.calls, key)
+		d.Unlock()
+		close(c.done)
+	}()
+
+	next.ServeHTTP(&recordingResponseWriter{ResponseWriter: w, response: c.response}, r)
+	completed = true
+}
+
+func (rr *recordedResponse) replay(w http.ResponseWriter) {
+	for k, v := range rr.header {
+		w.Header()[k] = v
+	}
+	w.Header().Set(ReplayedHeader, "true")
+	w.WriteHeader(rr.status)
+	w.Write(rr.body.Bytes())
+}
+
+// Writes the response to the client while recording it for concurrent requests.
+type recordingResponseWriter struct {
+	http.ResponseWriter
+	response    *recordedResponse
+	wroteHeader bool
+}
+
+func (w *recordingResponseWriter) WriteHeader(code int) {
+	if w.wroteHeader {
+		return
+	}
+	w.wroteHeader = true
+
+	w.response.status = code
+	for k, v := range w.ResponseWriter.Header() {
+		w.response.header[k] = append([]string(nil), v...)
+	}
+
+	w.ResponseWriter.WriteHeader(code)
+}
+
+func (w *recordingResponseWriter) Write(b []byte) (int, error) {
+	if !w.wroteHeader {
+		w.WriteHeader(http.StatusOK)
+	}
+
+	w.response.body.Write(b)
+	return w.ResponseWriter.Write(b)
+}
+
+// Unwrap allows http.ResponseController to reach the underlying writer.
+func (w *recordingResponseWriter) Unwrap() http.ResponseWriter {
+	return w.ResponseWriter
+}
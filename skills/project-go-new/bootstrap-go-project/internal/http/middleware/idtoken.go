@@ -0,0 +1,347 @@
+package middleware
+
+import (
+	"context"
+	"crypto"
+	"crypto/ecdsa"
+	"crypto/elliptic"
+	"crypto/rsa"
+	"crypto/sha256"
+	"encoding/base64"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"math/big"
+	"net/http"
+	"slices"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"go.uber.org/zap"
+)
+
+const (
+	// Header with the ID token IAP adds to the requests it forwards.
+	IAPAssertionHeader = "X-Goog-IAP-JWT-Assertion"
+
+	googleCertsURL = "https://www.googleapis.com/oauth2/v3/certs"
+	iapCertsURL    = "https://www.gstatic.com/iap/verify/public_key-jwk"
+	iapIssuer      = "https://cloud.google.com/iap"
+
+	// Allowed clock skew between Google and the service.
+	idTokenLeeway = time.Minute
+	// Time the signing keys are cached when the response has no max-age.
+	defaultKeysMaxAge = time.Hour
+	// Minimum time between fetching the keys again for an unknown key id.
+	keysRefetchInterval = time.Minute
+)
+
+var (
+	ErrIDTokenDisabled = errors.New("ID token verification is not configured")
+	ErrInvalidIDToken  = errors.New("invalid ID token")
+)
+
+var googleIssuers = []string{"https://accounts.google.com", "accounts.google.com"}
+
+type idTokenKey struct{}
+
+// GoogleIDTokenOptions configures the Google-signed ID tokens accepted by GoogleIDToken.
+type GoogleIDTokenOptions struct {
+	// Audiences accepted in the aud claim, e.g. the URL of the Cloud Run service or the IAP client id.
+	Audiences []string
+	// Emails of the service accounts allowed to call, any caller with a valid token when empty.
+	Emails []string
+}
+
+// IDToken holds the verified claims of a Google-signed ID token.
+type IDToken struct {
+	Issuer        string `json:"iss"`
+	Subject       string `json:"sub"`
+	Email         string `json:"email"`
+	EmailVerified bool   `json:"email_verified"`
+	ExpiresAt     int64  `json:"exp"`
+	IssuedAt      int64  `json:"iat"`
+	// Audience of the token, Google ID tokens have a single audience.
+	Audience string `json:"-"`
+}
+
+// GoogleIDToken only allows requests with a valid Google-signed ID token for one of the audiences,
+// e.g. of Cloud Run services, Pub/Sub push subscriptions and Cloud Scheduler calling with workload identity.
+// The token is read from the Authorization bearer header, or from the X-Goog-IAP-JWT-Assertion header of IAP.
+//
+// The claims of the token are stored in the request context, see IDTokenFromContext.
+// When no audience is configured all requests are rejected, so the endpoints are never exposed by accident.
+func GoogleIDToken(o GoogleIDTokenOptions, log *zap.SugaredLogger) func(http.Handler) http.Handler {
+	v := &idTokenVerifier{
+		audiences: o.Audiences,
+		emails:    o.Emails,
+		google:    newKeySet(googleCertsURL),
+		iap:       newKeySet(iapCertsURL),
+	}
+
+	return func(next http.Handler) http.Handler {
+		return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			if len(v.audiences) == 0 {
+				writeError(w, http.StatusForbidden, ErrIDTokenDisabled)
+				return
+			}
+
+			token, err := v.verifyRequest(r)
+			if err != nil {
+				Logger(r.Context(), log).Warnw("Rejected ID token", "path", r.URL.Path, "clientIp", ClientIPFromRequest(r), "error", err)
+				writeError(w, http.StatusUnauthorized, ErrUnauthorized)
+				return
+			}
+
+			next.ServeHTTP(w, r.WithContext(context.WithValue(r.Context(), idTokenKey{}, token)))
+		})
+	}
+}
+
+// IDTokenFromContext returns the verified ID token of the request, false if it has none.
+func IDTokenFromContext(ctx context.Context) (IDToken, bool) {
+	token, ok := ctx.Value(idTokenKey{}).(IDToken)
+	return token, ok
+}
+
+type idTokenVerifier struct {
+	audiences []string
+	emails    []string
+	google    *keySet
+	iap       *keySet
+}
+
+func (v *idTokenVerifier) verifyRequest(r *http.Request) (IDToken, error) {
+	if assertion := r.Header.Get(IAPAssertionHeader); assertion != "" {
+		return v.verify(r.Context(), assertion, v.iap, []string{iapIssuer})
+	}
+
+	token, ok := strings.CutPrefix(r.Header.Get("Authorization"), "Bearer ")
+	if !ok || token == "" {
+		return IDToken{}, errors.New("missing bearer token")
+	}
+
+	return v.verify(r.Context(), token, v.google, googleIssuers)
+}
+
+// Verifies the signature of the token with the keys and the issuer, audience, expiry and email of its claims.
+func (v *idTokenVerifier) verify(ctx context.Context, token string, keys *keySet, issuers []string) (IDToken, error) {
+	parts := strings.Split(token, ".")
+	if len(parts) != 3 {
+		return IDToken{}, fmt.Errorf("%w: malformed token", ErrInvalidIDToken)
+	}
+
+	var header struct {
+		Alg string `json:"alg"`
+		Kid string `json:"kid"`
+	}
+	if err := decodeSegment(parts[0], &header); err != nil {
+		return IDToken{}, err
+	}
+
+	signature, err := base64.RawURLEncoding.DecodeString(parts[2])
+	if err != nil {
+		return IDToken{}, fmt.Errorf("%w: malformed signature", ErrInvalidIDToken)
+	}
+
+	key, err := keys.get(ctx, header.Kid)
+	if err != nil {
+		return IDToken{}, err
+	}
+
+	if err := verifySignature(header.Alg, key, parts[0]+"."+parts[1], signature); err != nil {
+		return IDToken{}, err
+	}
+
+	var claims struct {
+		IDToken
+		Audience json.RawMessage `json:"aud"`
+	}
+	if err := decodeSegment(parts[1], &claims); err != nil {
+		return IDToken{}, err
+	}
+	t := claims.IDToken
+
+	// The aud claim is a string for Google, JWTs also allow a list.
+	var audiences []string
+	var audience string
+	if err := json.Unmarshal(claims.Audience, &audience); err == nil {
+		audiences = []string{audience}
+	} else if err := json.Unmarshal(claims.Audience, &audiences); err != nil {
+		return IDToken{}, fmt.Errorf("%w: malformed audience", ErrInvalidIDToken)
+	}
+
+	now := time.Now()
+	switch {
+	case !slices.Contains(issuers, t.Issuer):
+		return IDToken{}, fmt.Errorf("%w: issuer %q", ErrInvalidIDToken, t.Issuer)
+	case now.After(time.Unix(t.ExpiresAt, 0).Add(idTokenLeeway)):
+		return IDToken{}, fmt.Errorf("%w: expired", ErrInvalidIDToken)
+	case now.Add(idTokenLeeway).Before(time.Unix(t.IssuedAt, 0)):
+		return IDToken{}, fmt.Errorf("%w: issued in the future", ErrInvalidIDToken)
+	}
+
+	for _, a := range audiences {
+		if slices.Contains(v.audiences, a) {
+			t.Audience = a
+		}
+	}
+	if t.Audience == "" {
+		return IDToken{}, fmt.Errorf("%w: audience %v", ErrInvalidIDToken, audiences)
+	}
+
+	// IAP only asserts emails of identities it verified, without the email_verified claim.
+	verified := t.EmailVerified || t.Issuer == iapIssuer
+	if len(v.emails) > 0 && (!verified || !slices.Contains(v.emails, t.Email)) {
+		return IDToken{}, fmt.Errorf("%w: email %q is not allowed", ErrInvalidIDToken, t.Email)
+	}
+
+	return t, nil
+}
+
+func decodeSegment(segment string, v interface{}) error {
+	b, err := base64.RawURLEncoding.DecodeString(segment)
+	if err != nil {
+		return fmt.Errorf("%w: malformed segment", ErrInvalidIDToken)
+	}
+
+	if err := json.Unmarshal(b, v); err != nil {
+		return fmt.Errorf("%w: malformed segment", ErrInvalidIDToken)
+	}
+
+	return nil
+}
+
+// Verifies the RS256 or ES256 signature, the algorithm must match the type of the key.
+func verifySignature(alg string, key crypto.PublicKey, signed string, signature []byte) error {
+	digest := sha256.Sum256([]byte(signed))
+
+	switch k := key.(type) {
+	case *rsa.PublicKey:
+		if alg == "RS256" && rsa.VerifyPKCS1v15(k, crypto.SHA256, digest[:], signature) == nil {
+			return nil
+		}
+	case *ecdsa.PublicKey:
+		if alg == "ES256" && len(signature) == 64 {
+			r, s := new(big.Int).SetBytes(signature[:32]), new(big.Int).SetBytes(signature[32:])
+			if ecdsa.Verify(k, digest[:], r, s) {
+				return nil
+			}
+		}
+	}
+
+	return fmt.Errorf("%w: signature", ErrInvalidIDToken)
+}
+
+// Signing keys of a JWK set, cached for the max-age of the response.
+type keySet struct {
+	url     string
+	client  *http.Client
+	mu      sync.Mutex
+	keys    map[string]crypto.PublicKey
+	expires time.Time
+	fetched time.Time
+}
+
+func newKeySet(url string) *keySet {
+	return &keySet{url: url, client: &http.Client{Timeout: 10 * time.Second}}
+}
+
+// Returns the key with the id, the keys are fetched again when they expired or the id is unknown.
+func (s *keySet) get(ctx context.Context, kid string) (crypto.PublicKey, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	now := time.Now()
+	if key, ok := s.keys[kid]; ok && now.Before(s.expires) {
+		return key, nil
+	}
+
+	// Keys are rotated, an unknown id fetches the keys again, but not for every request with a bogus id.
+	if now.Before(s.expires) && now.Sub(s.fetched) < keysRefetchInterval {
+		return nil, fmt.Errorf("%w: unknown key %q", ErrInvalidIDToken, kid)
+	}
+
+	if err := s.fetch(ctx); err != nil {
+		return nil, fmt.Errorf("fetching the signing keys: %w", err)
+	}
+
+	key, ok := s.keys[kid]
+	if !ok {
+		return nil, fmt.Errorf("%w: unknown key %q", ErrInvalidIDToken, kid)
+	}
+
+	return key, nil
+}
+
+func (s *keySet) fetch(ctx context.Context) error {
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, s.url, nil)
+	if err != nil {
+		return err
+	}
+
+	res, err := s.client.Do(req)
+	if err != nil {
+		return err
+	}
+	defer res.Body.Close()
+
+	if res.StatusCode != http.StatusOK {
+		return fmt.Errorf("unexpected status %d", res.StatusCode)
+	}
+
+	var set struct {
+		Keys []struct {
+			Kid string `json:"kid"`
+			Kty string `json:"kty"`
+			Crv string `json:"crv"`
+			N   string `json:"n"`
+			E   string `json:"e"`
+			X   string `json:"x"`
+			Y   string `json:"y"`
+		} `json:"keys"`
+	}
+	if err := json.NewDecoder(res.Body).Decode(&set); err != nil {
+		return err
+	}
+
+	keys := make(map[string]crypto.PublicKey, len(set.Keys))
+	for _, k := range set.Keys {
+		switch {
+		case k.Kty == "RSA":
+			n, errN := base64.RawURLEncoding.DecodeString(k.N)
+			e, errE := base64.RawURLEncoding.DecodeString(k.E)
+			if errN != nil || errE != nil {
+				continue
+			}
+			keys[k.Kid] = &rsa.PublicKey{N: new(big.Int).SetBytes(n), E: int(new(big.Int).SetBytes(e).Int64())}
+		case k.Kty == "EC" && k.Crv == "P-256":
+			x, errX := base64.RawURLEncoding.DecodeString(k.X)
+			y, errY := base64.RawURLEncoding.DecodeString(k.Y)
+			if errX != nil || errY != nil {
+				continue
+			}
+			keys[k.Kid] = &ecdsa.PublicKey{Curve: elliptic.P256(), X: new(big.Int).SetBytes(x), Y: new(big.Int).SetBytes(y)}
+		}
+	}
+
+	now := time.Now()
+	s.keys, s.fetched, s.expires = keys, now, now.Add(maxAge(res.Header.Get("Cache-Control")))
+
+	return nil
+}
+
+// Returns the max-age of the Cache-Control header.
+func maxAge(cacheControl string) time.Duration {
+	for _, directive := range strings.Split(cacheControl, ",") {
+		if v, ok := strings.CutPrefix(strings.TrimSpace(directive), "max-age="); ok {
+			if seconds, err := strconv.Atoi(v); err == nil && seconds > 0 {
+				return time.Duration(seconds) * time.Second
+			}
+		}
+	}
+
+	return defaultKeysMaxAge
+}
@@ -0,0 +1,56 @@
+package middleware
+
+import (
+	"errors"
+	"net/http"
+	"net/netip"
+
+	"github.com/gorilla/mux"
+	"go.uber.org/zap"
+)
+
+var ErrForbidden = errors.New("forbidden")
+
+// IPFilterOptions configures IPFilter, parse the CIDRs with ParseCIDRs.
+type IPFilterOptions struct {
+	// Only clients in these ranges are allowed, all clients when empty.
+	Allow []netip.Prefix
+	// Clients in these ranges are rejected, also when they are in an allowed range.
+	Deny []netip.Prefix
+}
+
+// IPFilter rejects requests of clients outside the allowed or inside the denied ranges with 403 Forbidden,
+// e.g. to only allow admin routes from the office or VPN. Rejected requests are logged for auditing.
+//
+// The client IP is resolved by ClientIP, configure the trusted proxies when the service runs behind a load
+// balancer, otherwise the address of the load balancer is filtered.
+func IPFilter(o IPFilterOptions, log *zap.SugaredLogger) mux.MiddlewareFunc {
+	return func(next http.Handler) http.Handler {
+		return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			ip := ClientIPFromRequest(r)
+			addr, err := netip.ParseAddr(ip)
+			if err == nil {
+				addr = addr.Unmap()
+			}
+
+			if err != nil || contains(o.Deny, addr) || (len(o.Allow) > 0 && !contains(o.Allow, addr)) {
+				Logger(r.Context(), log).Warnw("Rejected request from IP address",
+					"clientIp", ip, "method", r.Method, "path", r.URL.Path, "userAgent", r.UserAgent())
+				writeError(w, http.StatusForbidden, ErrForbidden)
+				return
+			}
+
+			next.ServeHTTP(w, r)
+		})
+	}
+}
+
+func contains(prefixes []netip.Prefix, addr netip.Addr) bool {
+	for _, prefix := range prefixes {
+		if prefix.Contains(addr) {
+			return true
+		}
+	}
+
+	return false
+}
@@ -0,0 +1,78 @@
+package middleware
+
+import (
+	"errors"
+	"net/http"
+	"runtime/debug"
+
+	"github.com/getsentry/sentry-go"
+	"go.uber.org/zap"
+)
+
+var ErrInternal = errors.New("internal server error")
+
+// Recover recovers a panic of the handler, logs it with the stack and captures it in Sentry.
+// The client receives a JSON 500 response, unless the handler already started writing its response.
+//
+// An http.ErrAbortHandler panic is passed on, it aborts the response on purpose.
+func Recover(log *zap.SugaredLogger) func(http.Handler) http.Handler {
+	return func(next http.Handler) http.Handler {
+		return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			rw := &recoverResponseWriter{ResponseWriter: w}
+
+			defer func() {
+				p := recover()
+				if p == nil {
+					return
+				}
+				if p == http.ErrAbortHandler {
+					panic(p)
+				}
+
+				Logger(r.Context(), log).Errorw("Recovered panic in HTTP handler", "panic", p, "method", r.Method,
+					"path", r.URL.Path, "stack", string(debug.Stack()))
+
+				hub := sentry.GetHubFromContext(r.Context())
+				if hub == nil {
+					hub = sentry.CurrentHub()
+				}
+				hub.RecoverWithContext(r.Context(), p)
+
+				if !rw.wroteHeader {
+					writeError(w, http.StatusInternalServerError, ErrInternal)
+				}
+			}()
+
+			next.ServeHTTP(rw, r)
+		})
+	}
+}
+
+// Records whether the handler started writing the response.
+type recoverResponseWriter struct {
+	http.ResponseWriter
+	wroteHeader bool
+}
+
+func (w *recoverResponseWriter) WriteHeader(code int) {
+	w.wroteHeader = true
+	w.ResponseWriter.WriteHeader(code)
+}
+
+func (w *recoverResponseWriter) Write(b []byte) (int, error) {
+	w.wroteHeader = true
+	return w.ResponseWriter.Write(b)
+}
+
+// Flush passes flushes of streaming handlers on to the underlying writer.
+func (w *recoverResponseWriter) Flush() {
+	if f, ok := w.ResponseWriter.(http.Flusher); ok {
+		w.wroteHeader = true
+		f.Flush()
+	}
+}
+
+// Unwrap allows http.ResponseController to reach the underlying writer.
+func (w *recoverResponseWriter) Unwrap() http.ResponseWriter {
+	return w.ResponseWriter
+}
@@ -0,0 +1,86 @@
+package middleware
+
+import (
+	"context"
+	"net/http"
+
+	"github.com/getsentry/sentry-go"
+	"github.com/google/uuid"
+	"go.uber.org/zap"
+)
+
+const RequestIDHeader = "X-Request-ID"
+
+// Maximum length of a request ID accepted from the client, longer IDs are replaced.
+const maxRequestIDLength = 128
+
+type requestIDKey struct{}
+
+type loggerKey struct{}
+
+// RequestID reads the X-Request-ID header of the request, or generates an ID when it is missing or invalid.
+// The ID is returned in the response header and stored in the request context, see RequestIDFromContext.
+//
+// The request logger, see Logger, and the Sentry scope of the request are tagged with the ID,
+// and PropagateRequestID adds it to downstream requests, so they share one correlation ID.
+func RequestID(log *zap.SugaredLogger) func(http.Handler) http.Handler {
+	return func(next http.Handler) http.Handler {
+		return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			id := r.Header.Get(RequestIDHeader)
+			if !validRequestID(id) {
+				id = uuid.NewString()
+			}
+
+			w.Header().Set(RequestIDHeader, id)
+
+			ctx := context.WithValue(r.Context(), requestIDKey{}, id)
+			ctx = context.WithValue(ctx, loggerKey{}, log.With("requestId", id))
+
+			hub := sentry.GetHubFromContext(ctx)
+			if hub == nil {
+				hub = sentry.CurrentHub().Clone()
+			}
+			hub.Scope().SetTag("request_id", id)
+			ctx = sentry.SetHubOnContext(ctx, hub)
+
+			next.ServeHTTP(w, r.WithContext(ctx))
+		})
+	}
+}
+
+// RequestIDFromContext returns the request ID of the context, false if it has none.
+func RequestIDFromContext(ctx context.Context) (string, bool) {
+	id, ok := ctx.Value(requestIDKey{}).(string)
+	return id, ok
+}
+
+// Logger returns the logger of the request tagged with its request ID, or the given logger outside a request.
+func Logger(ctx context.Context, log *zap.SugaredLogger) *zap.SugaredLogger {
+	if l, ok := ctx.Value(loggerKey{}).(*zap.SugaredLogger); ok {
+		return l
+	}
+
+	return log
+}
+
+// PropagateRequestID sets the request ID of the context on the downstream request.
+func PropagateRequestID(ctx context.Context, req *http.Request) {
+	if id, ok := RequestIDFromContext(ctx); ok {
+		req.Header.Set(RequestIDHeader, id)
+	}
+}
+
+// Only printable ASCII is accepted, the ID is written to logs and response headers.
+func validRequestID(id string) bool {
+	if id == "" || len(id) > maxRequestIDLength {
+		return false
+	}
+
+	for i := 0; i < len(id); i++ {
+		if id[i] < 0x21 || id[i] > 0x7e {
+			return false
+		}
+	}
+
+	return true
+}
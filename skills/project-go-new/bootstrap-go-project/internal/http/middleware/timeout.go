@@ -0,0 +1,97 @@
+package middleware
+
+import (
+	"bufio"
+	"context"
+	"errors"
+	"net"
+	"net/http"
+	"time"
+
+	"gitlab.com/btcdirect-api/bootstrap-go-service/internal/http/respond"
+)
+
+var ErrTimeout = errors.New("request timed out")
+
+type timeoutKey struct{}
+
+// Deadline set by Timeout, with the context of the request before it.
+type timeoutState struct {
+	base context.Context
+	// Set when a later Timeout replaced the deadline, the later Timeout answers the request then.
+	replaced bool
+}
+
+// Timeout sets a deadline on the request context, so database and upstream calls of the handler are cancelled
+// when it passes. When the handler returns without a response after the deadline, 504 Gateway Timeout is written.
+//
+// A Timeout of a route or group replaces the deadline of an earlier Timeout, e.g. the default of the server, also
+// when it is longer. A zero duration removes the deadline, e.g. for streaming or profiling endpoints.
+func Timeout(d time.Duration) func(http.Handler) http.Handler {
+	return func(next http.Handler) http.Handler {
+		return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			ctx := r.Context()
+			state := &timeoutState{base: ctx}
+			if earlier, ok := ctx.Value(timeoutKey{}).(*timeoutState); ok {
+				earlier.replaced = true
+				state.base = earlier.base
+
+				// Keep the values of the request, but only the cancellation of the client, not the earlier deadline.
+				detached, cancel := context.WithCancel(context.WithoutCancel(ctx))
+				defer cancel()
+				stop := context.AfterFunc(earlier.base, cancel)
+				defer stop()
+				ctx = detached
+			}
+
+			if d > 0 {
+				var cancel context.CancelFunc
+				ctx, cancel = context.WithTimeout(ctx, d)
+				defer cancel()
+			}
+
+			tw := &timeoutResponseWriter{ResponseWriter: w}
+			next.ServeHTTP(tw, r.WithContext(context.WithValue(ctx, timeoutKey{}, state)))
+
+			if !tw.written && !state.replaced && errors.Is(ctx.Err(), context.DeadlineExceeded) {
+				respond.Error(w, http.StatusGatewayTimeout, ErrTimeout)
+			}
+		})
+	}
+}
+
+// Tracks whether the handler wrote a response.
+type timeoutResponseWriter struct {
+	http.ResponseWriter
+	written bool
+}
+
+func (w *timeoutResponseWriter) WriteHeader(code int) {
+	w.written = true
+	w.ResponseWriter.WriteHeader(code)
+}
+
+func (w *timeoutResponseWriter) Write(b []byte) (int, error) {
+	w.written = true
+	return w.ResponseWriter.Write(b)
+}
+
+// Flush passes flushes of streaming handlers, e.g. server-sent events, on to the underlying writer.
+// A flush sends the response headers, so the request is not answered after the deadline.
+func (w *timeoutResponseWriter) Flush() {
+	if f, ok := w.ResponseWriter.(http.Flusher); ok {
+		w.written = true
+		f.Flush()
+	}
+}
+
+// Hijack hands the connection over, e.g. for a WebSocket, the connection is not answered after the deadline.
+func (w *timeoutResponseWriter) Hijack() (net.Conn, *bufio.ReadWriter, error) {
+	w.written = true
+	return http.NewResponseController(w.ResponseWriter).Hijack()
+}
+
+// Unwrap allows http.ResponseController to reach the underlying writer.
+func (w *timeoutResponseWriter) Unwrap() http.ResponseWriter {
+	return w.ResponseWriter
+}
@@ -0,0 +1,172 @@
+package request
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"reflect"
+	"strconv"
+	"strings"
+
+	"github.com/gorilla/mux"
+	"gitlab.com/btcdirect-api/bootstrap-go-service/internal/http/respond"
+)
+
+// Maximum size of a decoded JSON body.
+const maxBodySize = 1 << 20
+
+var ErrInvalidBody = errors.New("invalid request body")
+
+// Bind decodes and validates the request into dst, see Decode.
+// When it fails the error is written to the client, 422 with the field errors of a ValidationError, 400 otherwise,
+// and false is returned so the handler returns:
+//
+//	var in createOrder
+//	if !request.Bind(w, r, &in) {
+//		return
+//	}
+func Bind(w http.ResponseWriter, r *http.Request, dst interface{}) bool {
+	err := Decode(r, dst)
+	if err == nil {
+		return true
+	}
+
+	var v *ValidationError
+	if errors.As(err, &v) {
+		respond.JSON(w, http.StatusUnprocessableEntity, respond.Envelope{Error: "validation failed", Fields: v.Fields})
+		return false
+	}
+
+	respond.Error(w, http.StatusBadRequest, err)
+
+	return false
+}
+
+// Decode decodes the request into the struct pointed to by dst, and validates it, see Validate.
+//
+// The JSON body is decoded into the json fields, then the fields tagged with `query:"name"` are set from the
+// query parameters and the fields tagged with `path:"name"` from the route variables or path values.
+// Tag them with `json:"-"` so the body can not set them.
+// Query and path fields are strings, numbers, bools or slices of them (query only).
+//
+// A malformed body or parameter returns an error, failed validation rules return a *ValidationError.
+func Decode(r *http.Request, dst interface{}) error {
+	rv := reflect.ValueOf(dst)
+	if rv.Kind() != reflect.Ptr || rv.Elem().Kind() != reflect.Struct {
+		return fmt.Errorf("decode requires a pointer to a struct, got %T", dst)
+	}
+
+	if r.Body != nil && r.Body != http.NoBody {
+		err := json.NewDecoder(http.MaxBytesReader(nil, r.Body, maxBodySize)).Decode(dst)
+		if err != nil && !errors.Is(err, io.EOF) {
+			return fmt.Errorf("%w: %v", ErrInvalidBody, err)
+		}
+	}
+
+	if err := decodeParameters(r, rv.Elem()); err != nil {
+		return err
+	}
+
+	return Validate(dst)
+}
+
+// Sets the query and path fields of the struct.
+func decodeParameters(r *http.Request, v reflect.Value) error {
+	query := r.URL.Query()
+	vars := mux.Vars(r)
+
+	typ := v.Type()
+	for i := 0; i < typ.NumField(); i++ {
+		field := typ.Field(i)
+		if !field.IsExported() {
+			continue
+		}
+
+		if name := field.Tag.Get("query"); name != "" {
+			values, ok := query[name]
+			if !ok {
+				continue
+			}
+			if err := setField(v.Field(i), values); err != nil {
+				return fmt.Errorf("query parameter '%s' %w", name, err)
+			}
+		}
+
+		if name := field.Tag.Get("path"); name != "" {
+			// Route variables of gorilla/mux, or path values of http.ServeMux.
+			value, ok := vars[name]
+			if !ok {
+				value = r.PathValue(name)
+			}
+			if !ok && value == "" {
+				continue
+			}
+			if err := setField(v.Field(i), []string{value}); err != nil {
+				return fmt.Errorf("path parameter '%s' %w", name, err)
+			}
+		}
+	}
+
+	return nil
+}
+
+func setField(f reflect.Value, values []string) error {
+	if f.Kind() == reflect.Slice && f.Type().Elem().Kind() != reflect.Uint8 {
+		s := reflect.MakeSlice(f.Type(), len(values), len(values))
+		for i, value := range values {
+			if err := setValue(s.Index(i), value); err != nil {
+				return err
+			}
+		}
+		f.Set(s)
+		return nil
+	}
+
+	return setValue(f, values[len(values)-1])
+}
+
+func setValue(f reflect.Value, value string) error {
+	if f.Kind() == reflect.Ptr {
+		p := reflect.New(f.Type().Elem())
+		if err := setValue(p.Elem(), value); err != nil {
+			return err
+		}
+		f.Set(p)
+		return nil
+	}
+
+	switch f.Kind() {
+	case reflect.String:
+		f.SetString(value)
+	case reflect.Bool:
+		b, err := strconv.ParseBool(value)
+		if err != nil {
+			return errors.New("is not a boolean")
+		}
+		f.SetBool(b)
+	case reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32, reflect.Int64:
+		i, err := strconv.ParseInt(strings.TrimSpace(value), 10, f.Type().Bits())
+		if err != nil {
+			return errors.New("is not an integer")
+		}
+		f.SetInt(i)
+	case reflect.Uint, reflect.Uint8, reflect.Uint16, reflect.Uint32, reflect.Uint64:
+		u, err := strconv.ParseUint(strings.TrimSpace(value), 10, f.Type().Bits())
+		if err != nil {
+			return errors.New("is not a positive integer")
+		}
+		f.SetUint(u)
+	case reflect.Float32, reflect.Float64:
+		n, err := strconv.ParseFloat(strings.TrimSpace(value), f.Type().Bits())
+		if err != nil {
+			return errors.New("is not a number")
+		}
+		f.SetFloat(n)
+	default:
+		return fmt.Errorf("has unsupported type %s", f.Type())
+	}
+
+	return nil
+}
@@ -0,0 +1,220 @@
+package request
+
+import (
+	"errors"
+	"fmt"
+	"reflect"
+	"strconv"
+	"strings"
+	"sync"
+	"unicode/utf8"
+)
+
+// ValidationError holds the fields that failed their validation rules.
+type ValidationError struct {
+	Fields []FieldError
+}
+
+// FieldError is a failed validation rule of a field, named by its json, query or path tag.
+type FieldError struct {
+	// Name of the field, empty for an error of the struct returned by Validator.
+	Field   string `json:"field,omitempty"`
+	Message string `json:"message"`
+}
+
+func (e *ValidationError) Error() string {
+	messages := make([]string, len(e.Fields))
+	for i, f := range e.Fields {
+		messages[i] = strings.TrimSpace(f.Field + " " + f.Message)
+	}
+
+	return "validation failed: " + strings.Join(messages, ", ")
+}
+
+// Validator is implemented by structs with rules across fields, it runs after the rules of the fields passed.
+// Return a *ValidationError to report field errors, other errors are reported for the struct.
+type Validator interface {
+	Validate() error
+}
+
+// ValidationFunc validates a field value with the parameter of its rule, e.g. "3" for `validate:"divisible=3"`.
+// The returned error message is reported for the field.
+type ValidationFunc func(value interface{}, param string) error
+
+var validators = struct {
+	sync.RWMutex
+	funcs map[string]ValidationFunc
+}{funcs: make(map[string]ValidationFunc)}
+
+// RegisterValidator registers a custom rule, used in the validate tag by its name.
+// It panics when the name is already registered or is a built-in rule.
+func RegisterValidator(name string, fn ValidationFunc) {
+	validators.Lock()
+	defer validators.Unlock()
+
+	switch name {
+	case "required", "min", "max", "enum":
+		panic(fmt.Sprintf("request: validator %s is a built-in rule", name))
+	}
+	if _, ok := validators.funcs[name]; ok {
+		panic(fmt.Sprintf("request: validator %s registered twice", name))
+	}
+
+	validators.funcs[name] = fn
+}
+
+// Validate checks the rules in the validate tags of the struct fields, nested structs are validated as well:
+//
+//   - required: the value is not empty (zero, empty string, nil)
+//   - min=n, max=n: bounds of a number, or of the length of a string, slice or map
+//   - enum=a|b|c: the value is one of the options
+//   - name=param: a rule registered with RegisterValidator
+//
+// The rules other than required are skipped for empty values, e.g. `validate:"min=3"` allows an empty string.
+// A *ValidationError with all failed fields is returned.
+func Validate(v interface{}) error {
+	rv := reflect.ValueOf(v)
+	for rv.Kind() == reflect.Ptr {
+		if rv.IsNil() {
+			return nil
+		}
+		rv = rv.Elem()
+	}
+	if rv.Kind() != reflect.Struct {
+		return nil
+	}
+
+	var fields []FieldError
+	validateStruct(rv, "", &fields)
+	if len(fields) > 0 {
+		return &ValidationError{Fields: fields}
+	}
+
+	if validator, ok := v.(Validator); ok {
+		if err := validator.Validate(); err != nil {
+			var ve *ValidationError
+			if errors.As(err, &ve) {
+				return ve
+			}
+			return &ValidationError{Fields: []FieldError{{Message: err.Error()}}}
+		}
+	}
+
+	return nil
+}
+
+func validateStruct(v reflect.Value, prefix string, fields *[]FieldError) {
+	typ := v.Type()
+	for i := 0; i < typ.NumField(); i++ {
+		field := typ.Field(i)
+		if !field.IsExported() {
+			continue
+		}
+
+		name := prefix + fieldName(field)
+		value := v.Field(i)
+
+		for _, rule := range strings.Split(field.Tag.Get("validate"), ",") {
+			if rule == "" {
+				continue
+			}
+			if err := checkRule(value, rule); err != nil {
+				*fields = append(*fields, FieldError{Field: name, Message: err.Error()})
+				break
+			}
+		}
+
+		nested := value
+		for nested.Kind() == reflect.Ptr && !nested.IsNil() {
+			nested = nested.Elem()
+		}
+		if nested.Kind() == reflect.Struct {
+			validateStruct(nested, name+".", fields)
+		}
+	}
+}
+
+// Returns the name of the field in the request: its query, path or json name.
+func fieldName(field reflect.StructField) string {
+	for _, tag := range []string{"query", "path", "json"} {
+		if name, _, _ := strings.Cut(field.Tag.Get(tag), ","); name != "" && name != "-" {
+			return name
+		}
+	}
+
+	return field.Name
+}
+
+func checkRule(v reflect.Value, rule string) error {
+	name, param, _ := strings.Cut(rule, "=")
+
+	empty := v.IsZero()
+	if name == "required" {
+		if empty {
+			return errors.New("is required")
+		}
+		return nil
+	}
+	if empty {
+		return nil
+	}
+
+	for v.Kind() == reflect.Ptr {
+		v = v.Elem()
+	}
+
+	switch name {
+	case "min", "max":
+		limit, err := strconv.ParseFloat(param, 64)
+		if err != nil {
+			return fmt.Errorf("has an invalid rule %s", rule)
+		}
+		n, unit := size(v)
+		switch {
+		case name == "min" && n < limit && unit != "":
+			return fmt.Errorf("must have at least %s %s", param, unit)
+		case name == "min" && n < limit:
+			return fmt.Errorf("must be at least %s", param)
+		case name == "max" && n > limit && unit != "":
+			return fmt.Errorf("must have at most %s %s", param, unit)
+		case name == "max" && n > limit:
+			return fmt.Errorf("must be at most %s", param)
+		}
+		return nil
+	case "enum":
+		value := fmt.Sprint(v.Interface())
+		for _, option := range strings.Split(param, "|") {
+			if value == option {
+				return nil
+			}
+		}
+		return fmt.Errorf("must be one of %s", strings.ReplaceAll(param, "|", ", "))
+	}
+
+	validators.RLock()
+	fn, ok := validators.funcs[name]
+	validators.RUnlock()
+	if !ok {
+		return fmt.Errorf("has an unknown rule %s", name)
+	}
+
+	return fn(v.Interface(), param)
+}
+
+// Returns the number to compare with the min and max rules, with the unit of a length.
+func size(v reflect.Value) (float64, string) {
+	switch v.Kind() {
+	case reflect.String:
+		return float64(utf8.RuneCountInString(v.String())), "characters"
+	case reflect.Slice, reflect.Map, reflect.Array:
+		return float64(v.Len()), "items"
+	case reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32, reflect.Int64:
+		return float64(v.Int()), ""
+	case reflect.Uint, reflect.Uint8, reflect.Uint16, reflect.Uint32, reflect.Uint64:
+		return float64(v.Uint()), ""
+	case reflect.Float32, reflect.Float64:
+		return v.Float(), ""
+	}
+
+	return 0, ""
+}
@@ -0,0 +1,55 @@
+package respond
+
+import (
+	"encoding/json"
+	"net/http"
+)
+
+// Response header with the request ID, set by middleware.RequestID before the handler runs.
+const requestIDHeader = "X-Request-ID"
+
+// Envelope is the body of every JSON response, with the request ID for correlation with the logs.
+type Envelope struct {
+	Data  interface{} `json:"data,omitempty"`
+	Error string      `json:"error,omitempty"`
+	// Details of the error, e.g. the failed fields of a validation.
+	Fields    interface{} `json:"fields,omitempty"`
+	RequestID string      `json:"requestId,omitempty"`
+}
+
+// OK writes the data with status 200.
+func OK(w http.ResponseWriter, data interface{}) {
+	JSON(w, http.StatusOK, Envelope{Data: data})
+}
+
+// Created writes the created resource with status 201.
+func Created(w http.ResponseWriter, data interface{}) {
+	JSON(w, http.StatusCreated, Envelope{Data: data})
+}
+
+// Accepted writes the data, e.g. a tracking ID, with status 202.
+func Accepted(w http.ResponseWriter, data interface{}) {
+	JSON(w, http.StatusAccepted, Envelope{Data: data})
+}
+
+// NoContent writes status 204 without a body.
+func NoContent(w http.ResponseWriter) {
+	w.WriteHeader(http.StatusNoContent)
+}
+
+// Error writes the error message with the status code.
+func Error(w http.ResponseWriter, code int, err error) {
+	JSON(w, code, Envelope{Error: err.Error()})
+}
+
+// JSON writes the envelope with the status code, the request ID of the response is added to it.
+func JSON(w http.ResponseWriter, code int, e Envelope) {
+	if e.RequestID == "" {
+		e.RequestID = w.Header().Get(requestIDHeader)
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(code)
+
+	json.NewEncoder(w).Encode(e)
+}
@@ -0,0 +1,141 @@
+package router
+
+import (
+	"net/http"
+	"strings"
+
+	"github.com/gorilla/mux"
+)
+
+// Middleware wraps a handler, the signature shared by the middleware of all routers.
+// The middleware of the bootstrap, mux.MiddlewareFunc, are assignable to it.
+type Middleware = func(http.Handler) http.Handler
+
+// Router registers routes independent of the router implementation, so route registration and middleware
+// can be reused when a service switches routers. Patterns use {name} for path parameters, read them with Param.
+// A pattern ending in a slash matches the subtree, e.g. "/files/" matches "/files/a/b".
+//
+// NewMux adapts gorilla/mux, which the bootstrap uses by default, and NewServeMux the router of the standard library.
+type Router interface {
+	http.Handler
+	// Handle registers the handler for the method and pattern, an empty method matches all methods.
+	Handle(method, pattern string, h http.Handler)
+	HandleFunc(method, pattern string, h http.HandlerFunc)
+	// Use applies the middleware to the routes of the router, also those registered before.
+	Use(mw ...Middleware)
+	// Group returns a router for the routes under the path prefix, with the middleware applied to its routes only.
+	Group(prefix string, mw ...Middleware) Router
+}
+
+// Param returns the path parameter of the request, set by either router.
+func Param(r *http.Request, name string) string {
+	if v, ok := mux.Vars(r)[name]; ok {
+		return v
+	}
+
+	return r.PathValue(name)
+}
+
+type muxRouter struct {
+	*mux.Router
+}
+
+// NewMux adapts a gorilla/mux router.
+func NewMux(r *mux.Router) Router {
+	return muxRouter{r}
+}
+
+func (r muxRouter) Handle(method, pattern string, h http.Handler) {
+	var route *mux.Route
+	if strings.HasSuffix(pattern, "/") {
+		route = r.Router.PathPrefix(pattern).Handler(h)
+	} else {
+		route = r.Router.Handle(pattern, h)
+	}
+	if method != "" {
+		route.Methods(method)
+	}
+}
+
+func (r muxRouter) HandleFunc(method, pattern string, h http.HandlerFunc) {
+	r.Handle(method, pattern, h)
+}
+
+func (r muxRouter) Use(mw ...Middleware) {
+	for _, m := range mw {
+		r.Router.Use(m)
+	}
+}
+
+// Group returns a subrouter, groups are matched in the order they are created, before the routes registered later.
+func (r muxRouter) Group(prefix string, mw ...Middleware) Router {
+	g := muxRouter{r.Router.PathPrefix(prefix).Subrouter()}
+	g.Use(mw...)
+
+	return g
+}
+
+type serveMux struct {
+	mux    *http.ServeMux
+	prefix string
+	// Middleware of the router and its parent groups, applied to a route when it is registered.
+	middleware []Middleware
+	// Middleware of the root router, applied to all requests.
+	root  *[]Middleware
+	group bool
+}
+
+// NewServeMux returns a router on http.ServeMux of the standard library, without dependencies.
+//
+// The patterns follow http.ServeMux: use {$} to match only the path itself of a pattern ending in a slash.
+// A GET route also matches HEAD requests, unmatched methods return a plain text 405.
+func NewServeMux() Router {
+	return &serveMux{mux: http.NewServeMux(), root: &[]Middleware{}}
+}
+
+func (r *serveMux) ServeHTTP(w http.ResponseWriter, req *http.Request) {
+	var h http.Handler = r.mux
+	for i := len(*r.root) - 1; i >= 0; i-- {
+		h = (*r.root)[i](h)
+	}
+
+	h.ServeHTTP(w, req)
+}
+
+func (r *serveMux) Handle(method, pattern string, h http.Handler) {
+	for i := len(r.middleware) - 1; i >= 0; i-- {
+		h = r.middleware[i](h)
+	}
+
+	pattern = r.prefix + pattern
+	if method != "" {
+		pattern = method + " " + pattern
+	}
+
+	r.mux.Handle(pattern, h)
+}
+
+func (r *serveMux) HandleFunc(method, pattern string, h http.HandlerFunc) {
+	r.Handle(method, pattern, h)
+}
+
+// Use applies the middleware of the root router to all requests. A group applies the middleware to the routes
+// registered after it, as the routes of the standard library router can not be changed once registered.
+func (r *serveMux) Use(mw ...Middleware) {
+	if !r.group {
+		*r.root = append(*r.root, mw...)
+		return
+	}
+
+	r.middleware = append(r.middleware, mw...)
+}
+
+func (r *serveMux) Group(prefix string, mw ...Middleware) Router {
+	return &serveMux{
+		mux:        r.mux,
+		prefix:     r.prefix + strings.TrimSuffix(prefix, "/"),
+		middleware: append(append([]Middleware(nil), r.middleware...), mw...),
+		root:       r.root,
+		group:      true,
+	}
+}
@@ -0,0 +1,88 @@
+package router_test
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/gorilla/mux"
+	"gitlab.com/btcdirect-api/bootstrap-go-service/internal/http/router"
+)
+
+// Adds the name of the middleware to the X-Middleware header of the response.
+func tag(name string) router.Middleware {
+	return func(next http.Handler) http.Handler {
+		return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			w.Header().Add("X-Middleware", name)
+			next.ServeHTTP(w, r)
+		})
+	}
+}
+
+// Writes the route and its id parameter.
+func echo(route string) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		w.Write([]byte(route + ":" + router.Param(r, "id")))
+	}
+}
+
+func TestRouters(t *testing.T) {
+	routers := map[string]func() router.Router{
+		"mux":      func() router.Router { return router.NewMux(mux.NewRouter()) },
+		"servemux": router.NewServeMux,
+	}
+
+	tests := []struct {
+		method         string
+		path           string
+		wantStatus     int
+		wantBody       string
+		wantMiddleware []string
+	}{
+		{method: "GET", path: "/orders/42", wantStatus: http.StatusOK, wantBody: "order:42", wantMiddleware: []string{"root"}},
+		{method: "POST", path: "/orders", wantStatus: http.StatusOK, wantBody: "create:", wantMiddleware: []string{"root"}},
+		{method: "GET", path: "/admin/orders/7", wantStatus: http.StatusOK, wantBody: "admin:7", wantMiddleware: []string{"root", "admin"}},
+		{method: "GET", path: "/admin/v2/orders/7", wantStatus: http.StatusOK, wantBody: "admin v2:7", wantMiddleware: []string{"root", "admin", "v2"}},
+		{method: "GET", path: "/files/a/b", wantStatus: http.StatusOK, wantBody: "files:", wantMiddleware: []string{"root"}},
+		{method: "GET", path: "/unknown", wantStatus: http.StatusNotFound},
+		{method: "DELETE", path: "/orders/42", wantStatus: http.StatusMethodNotAllowed},
+	}
+
+	for name, newRouter := range routers {
+		r := newRouter()
+		r.Use(tag("root"))
+		r.HandleFunc("GET", "/orders/{id}", echo("order"))
+		r.HandleFunc("POST", "/orders", echo("create"))
+		r.HandleFunc("GET", "/files/", echo("files"))
+
+		admin := r.Group("/admin", tag("admin"))
+		admin.HandleFunc("GET", "/orders/{id}", echo("admin"))
+		admin.Group("/v2", tag("v2")).HandleFunc("GET", "/orders/{id}", echo("admin v2"))
+
+		for _, tt := range tests {
+			t.Run(name+" "+tt.method+" "+tt.path, func(t *testing.T) {
+				w := httptest.NewRecorder()
+				r.ServeHTTP(w, httptest.NewRequest(tt.method, tt.path, nil))
+
+				if w.Code != tt.wantStatus {
+					t.Fatalf("status = %d, want %d", w.Code, tt.wantStatus)
+				}
+				if tt.wantStatus != http.StatusOK {
+					return
+				}
+				if got := w.Body.String(); got != tt.wantBody {
+					t.Errorf("body = %q, want %q", got, tt.wantBody)
+				}
+				got := w.Header().Values("X-Middleware")
+				if len(got) != len(tt.wantMiddleware) {
+					t.Fatalf("middleware = %v, want %v", got, tt.wantMiddleware)
+				}
+				for i := range got {
+					if got[i] != tt.wantMiddleware[i] {
+						t.Errorf("middleware = %v, want %v", got, tt.wantMiddleware)
+					}
+				}
+			})
+		}
+	}
+}
@@ -0,0 +1,99 @@
+// Package rpc serves gRPC services defined in protobuf on the router of the HTTP server, with connect-go.
+//
+// A single service definition, generated with protoc-gen-go and protoc-gen-connect-go, then answers gRPC and
+// gRPC-Web clients and JSON over HTTP (the Connect protocol, e.g. curl with Content-Type: application/json),
+// sharing the middleware, request log and metrics of the HTTP routes.
+package rpc
+
+import (
+	"context"
+	"errors"
+	"net/http"
+	"time"
+
+	"connectrpc.com/connect"
+	"gitlab.com/btcdirect-api/bootstrap-go-service/internal/http/middleware"
+	"gitlab.com/btcdirect-api/bootstrap-go-service/internal/http/router"
+	"gitlab.com/btcdirect-api/bootstrap-go-service/internal/metrics"
+	"go.uber.org/zap"
+)
+
+var (
+	// Calls of the services per "procedure code", the code is "ok" or the Connect error code, e.g. "not_found".
+	serverCalls = metrics.NewCounter("rpc_server_calls")
+	// Duration of the calls per procedure, for streams until the handler returns.
+	serverCallSeconds = metrics.NewHistogram("rpc_server_call_seconds", metrics.DefaultBuckets)
+)
+
+// HandlerOptions returns the options for the handler constructors generated by protoc-gen-connect-go:
+// the calls are recorded in the metrics, and failed with an internal or unknown error are logged.
+// The options are followed by opts, e.g. connect.WithReadMaxBytes.
+func HandlerOptions(log *zap.SugaredLogger, opts ...connect.HandlerOption) []connect.HandlerOption {
+	return append([]connect.HandlerOption{connect.WithInterceptors(interceptor{log: log})}, opts...)
+}
+
+// Mount serves the handler of a service on the router, under the path returned by its generated constructor,
+// e.g. /orders.v1.OrderService/. The service then answers gRPC, gRPC-Web and Connect JSON requests next to the
+// HTTP routes, with the middleware of the router, e.g. the request log and panic recovery.
+//
+// The middleware apply to the calls of the service only, e.g. middleware.GoogleIDToken shared with the HTTP routes.
+// Requests they reject are answered with the HTTP status, which the clients report as the matching code,
+// e.g. 401 as unauthenticated.
+//
+// gRPC requires HTTP/2: behind a load balancer terminating TLS, enable HTTP_H2C.
+func Mount(r router.Router, path string, h http.Handler, mw ...router.Middleware) {
+	for i := len(mw) - 1; i >= 0; i-- {
+		h = mw[i](h)
+	}
+
+	// The protocols answer other methods than POST themselves, Connect also serves GET for idempotent procedures.
+	r.Handle("", path, h)
+}
+
+// Records the calls of the handlers in the metrics, and logs the calls failing with a server error.
+type interceptor struct {
+	log *zap.SugaredLogger
+}
+
+func (i interceptor) WrapUnary(next connect.UnaryFunc) connect.UnaryFunc {
+	return func(ctx context.Context, req connect.AnyRequest) (connect.AnyResponse, error) {
+		start := time.Now()
+		res, err := next(ctx, req)
+		i.record(ctx, req.Spec().Procedure, start, err)
+
+		return res, err
+	}
+}
+
+// Clients of the services are not instrumented.
+func (i interceptor) WrapStreamingClient(next connect.StreamingClientFunc) connect.StreamingClientFunc {
+	return next
+}
+
+func (i interceptor) WrapStreamingHandler(next connect.StreamingHandlerFunc) connect.StreamingHandlerFunc {
+	return func(ctx context.Context, conn connect.StreamingHandlerConn) error {
+		start := time.Now()
+		err := next(ctx, conn)
+		i.record(ctx, conn.Spec().Procedure, start, err)
+
+		return err
+	}
+}
+
+// Records the call in the metrics, a server error is logged with the request ID.
+func (i interceptor) record(ctx context.Context, procedure string, start time.Time, err error) {
+	serverCallSeconds.Observe(procedure, time.Since(start).Seconds())
+
+	if err == nil {
+		serverCalls.Inc(procedure + " ok")
+		return
+	}
+
+	code := connect.CodeOf(err)
+	serverCalls.Inc(procedure + " " + code.String())
+
+	// Other codes are the answers of the service, e.g. not_found, internal and unknown errors are unexpected.
+	if (code == connect.CodeInternal || code == connect.CodeUnknown) && !errors.Is(err, context.Canceled) {
+		middleware.Logger(ctx, i.log).Errorf("Call of %s failed: %s", procedure, err)
+	}
+}
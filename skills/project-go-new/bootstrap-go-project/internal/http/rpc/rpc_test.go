@@ -0,0 +1,117 @@
+package rpc_test
+
+import (
+	"context"
+	"errors"
+	"expvar"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	"connectrpc.com/connect"
+	"github.com/gorilla/mux"
+	"gitlab.com/btcdirect-api/bootstrap-go-service/internal/http/middleware"
+	"gitlab.com/btcdirect-api/bootstrap-go-service/internal/http/router"
+	"gitlab.com/btcdirect-api/bootstrap-go-service/internal/http/rpc"
+	"go.uber.org/zap"
+	"google.golang.org/protobuf/types/known/durationpb"
+)
+
+const procedure = "/clock.v1.ClockService/Double"
+
+// Serves a service doubling durations behind the admin token, like a handler generated by protoc-gen-connect-go.
+func newServer(t *testing.T) *httptest.Server {
+	log := zap.NewNop().Sugar()
+	double := connect.NewUnaryHandler(procedure, func(_ context.Context, req *connect.Request[durationpb.Duration]) (*connect.Response[durationpb.Duration], error) {
+		d := req.Msg.AsDuration()
+		if d < 0 {
+			return nil, connect.NewError(connect.CodeInvalidArgument, errors.New("negative duration"))
+		}
+		return connect.NewResponse(durationpb.New(2 * d)), nil
+	}, rpc.HandlerOptions(log)...)
+
+	r := router.NewMux(mux.NewRouter())
+	rpc.Mount(r, "/clock.v1.ClockService/", double, middleware.AdminToken("secret", log))
+
+	// gRPC requires HTTP/2.
+	s := httptest.NewUnstartedServer(r)
+	s.EnableHTTP2 = true
+	s.StartTLS()
+	t.Cleanup(s.Close)
+
+	return s
+}
+
+func TestMountProtocols(t *testing.T) {
+	s := newServer(t)
+
+	protocols := map[string][]connect.ClientOption{
+		"grpc":     {connect.WithGRPC()},
+		"grpc-web": {connect.WithGRPCWeb()},
+		"connect":  {connect.WithProtoJSON()},
+	}
+
+	tests := []struct {
+		name     string
+		token    string
+		in       time.Duration
+		want     time.Duration
+		wantCode connect.Code
+	}{
+		{name: "call", token: "secret", in: time.Second, want: 2 * time.Second},
+		{name: "error of the service", token: "secret", in: -time.Second, wantCode: connect.CodeInvalidArgument},
+		{name: "rejected by the middleware", token: "guess", in: time.Second, wantCode: connect.CodeUnauthenticated},
+	}
+
+	for protocol, opts := range protocols {
+		client := connect.NewClient[durationpb.Duration, durationpb.Duration](s.Client(), s.URL+procedure, opts...)
+
+		for _, tt := range tests {
+			t.Run(protocol+" "+tt.name, func(t *testing.T) {
+				req := connect.NewRequest(durationpb.New(tt.in))
+				req.Header().Set("Authorization", "Bearer "+tt.token)
+
+				res, err := client.CallUnary(context.Background(), req)
+				if tt.wantCode != 0 {
+					if got := connect.CodeOf(err); got != tt.wantCode {
+						t.Fatalf("code = %s (%v), want %s", got, err, tt.wantCode)
+					}
+					return
+				}
+				if err != nil {
+					t.Fatalf("CallUnary() error = %v", err)
+				}
+				if got := res.Msg.AsDuration(); got != tt.want {
+					t.Errorf("Double(%s) = %s, want %s", tt.in, got, tt.want)
+				}
+			})
+		}
+	}
+}
+
+func TestMountJSON(t *testing.T) {
+	s := newServer(t)
+
+	req, _ := http.NewRequest("POST", s.URL+procedure, strings.NewReader(`"1.5s"`))
+	req.Header.Set("Content-Type", "application/json")
+	req.Header.Set("Authorization", "Bearer secret")
+
+	res, err := s.Client().Do(req)
+	if err != nil {
+		t.Fatalf("POST %s error = %v", procedure, err)
+	}
+	defer res.Body.Close()
+
+	body, _ := io.ReadAll(res.Body)
+	if res.StatusCode != http.StatusOK || string(body) != `"3s"` {
+		t.Errorf("POST %s = %d %s, want 200 \"3s\"", procedure, res.StatusCode, body)
+	}
+
+	calls := expvar.Get("rpc_server_calls").(*expvar.Map).Get(procedure + " ok")
+	if calls == nil || calls.String() == "0" {
+		t.Errorf("rpc_server_calls of %s = %v, want the call counted", procedure, calls)
+	}
+}
@@ -0,0 +1,44 @@
+package server
+
+import (
+	"net"
+	"net/http"
+	"sync"
+
+	"gitlab.com/btcdirect-api/bootstrap-go-service/internal/metrics"
+)
+
+// Number of connections closed because their requests did not finish within the drain timeout, per server.
+var forcedConnections = metrics.NewCounter("http_shutdown_forced_connections")
+
+// Tracks the open connections of a server, to report the connections closed by the drain timeout.
+// Hijacked connections, e.g. WebSockets, are no longer managed by the server and are not counted.
+type connTracker struct {
+	mu    sync.Mutex
+	conns map[net.Conn]struct{}
+}
+
+func newConnTracker() *connTracker {
+	return &connTracker{conns: make(map[net.Conn]struct{})}
+}
+
+// Tracks a connection state change, set as http.Server.ConnState.
+func (t *connTracker) track(c net.Conn, state http.ConnState) {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+
+	switch state {
+	case http.StateNew:
+		t.conns[c] = struct{}{}
+	case http.StateHijacked, http.StateClosed:
+		delete(t.conns, c)
+	}
+}
+
+// Len returns the number of open connections.
+func (t *connTracker) Len() int {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+
+	return len(t.conns)
+}
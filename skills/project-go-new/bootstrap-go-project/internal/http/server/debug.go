@@ -0,0 +1,26 @@
+package server
+
+import (
+	"expvar"
+	"net/http/pprof"
+
+	"gitlab.com/btcdirect-api/bootstrap-go-service/internal/http/middleware"
+	"gitlab.com/btcdirect-api/bootstrap-go-service/internal/http/router"
+)
+
+// Registers the pprof profiles and the expvar runtime variables on /debug.
+// The endpoints are restricted by the middleware like the admin routes, as profiles expose the memory and
+// command line of the process. Profiles take the requested number of seconds, so the default request deadline
+// does not apply.
+func registerDebugRoutes(r router.Router, mw ...router.Middleware) {
+	debug := Group(r, "/debug", append(mw, middleware.Timeout(0))...)
+
+	debug.Handle("GET", "/vars", expvar.Handler())
+	debug.HandleFunc("GET", "/pprof/cmdline", pprof.Cmdline)
+	debug.HandleFunc("GET", "/pprof/profile", pprof.Profile)
+	debug.HandleFunc("GET", "/pprof/symbol", pprof.Symbol)
+	debug.HandleFunc("POST", "/pprof/symbol", pprof.Symbol)
+	debug.HandleFunc("GET", "/pprof/trace", pprof.Trace)
+	// The index serves the named profiles, e.g. /debug/pprof/heap.
+	debug.HandleFunc("GET", "/pprof/", pprof.Index)
+}
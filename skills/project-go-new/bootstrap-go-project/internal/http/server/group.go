@@ -0,0 +1,14 @@
+package server
+
+import (
+	"gitlab.com/btcdirect-api/bootstrap-go-service/internal/http/router"
+)
+
+// Group returns a router for the routes under the path prefix, e.g. "/api/v1", with the middleware applied
+// to its routes only. On gorilla/mux, groups are matched in the order they are created, before the routes
+// registered later.
+//
+// The middleware of the router applies to the group as well, a group of a group combines both middleware sets.
+func Group(r router.Router, prefix string, mw ...router.Middleware) router.Router {
+	return r.Group(prefix, mw...)
+}
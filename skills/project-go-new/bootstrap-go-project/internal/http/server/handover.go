@@ -0,0 +1,101 @@
+package server
+
+import (
+	"errors"
+	"fmt"
+	"net"
+	"os"
+	"os/exec"
+	"os/signal"
+	"strconv"
+)
+
+// Environment variable with the file descriptor of a listener handed over by the previous process.
+const listenerFDEnv = "HTTP_LISTENER_FD"
+
+var ErrHandoverUnsupported = errors.New("listener does not support handover")
+
+// Returns the listener handed over by the previous process, if any.
+func inheritedListener() (net.Listener, error) {
+	value := os.Getenv(listenerFDEnv)
+	if value == "" {
+		return nil, nil
+	}
+
+	// Make sure a next handover does not inherit a stale descriptor.
+	os.Unsetenv(listenerFDEnv)
+
+	fd, err := strconv.Atoi(value)
+	if err != nil {
+		return nil, fmt.Errorf("invalid %s: %w", listenerFDEnv, err)
+	}
+
+	f := os.NewFile(uintptr(fd), "listener")
+	defer f.Close()
+
+	return net.FileListener(f)
+}
+
+// Wait for the handover signal and hand the listener over to a new process.
+// The new process is started with the same binary and arguments, the listener is passed as file descriptor.
+//
+// Once the new process is started this process requests its own shutdown, so it drains its connections
+// while the new process is already accepting connections on the same socket.
+func (s server) awaitHandover(l net.Listener) {
+	c := make(chan os.Signal, 1)
+	signal.Notify(c, handoverSignal)
+
+	for range c {
+		s.log.Info("Handover requested, starting new process")
+
+		if err := s.handOver(l); err != nil {
+			s.log.Errorf("Failed to hand over listener: %s", err)
+			continue
+		}
+
+		signal.Stop(c)
+		requestShutdown()
+		return
+	}
+}
+
+func (s server) handOver(l net.Listener) error {
+	var f *os.File
+	var err error
+
+	switch l := l.(type) {
+	case *net.TCPListener:
+		f, err = l.File()
+	case *net.UnixListener:
+		// The socket is still in use by the new process, so it must not be removed on close.
+		l.SetUnlinkOnClose(false)
+		f, err = l.File()
+	default:
+		err = ErrHandoverUnsupported
+	}
+	if err != nil {
+		return err
+	}
+	defer f.Close()
+
+	executable, err := os.Executable()
+	if err != nil {
+		return err
+	}
+
+	cmd := exec.Command(executable, os.Args[1:]...)
+	cmd.Stdin = os.Stdin
+	cmd.Stdout = os.Stdout
+	cmd.Stderr = os.Stderr
+	// Extra files start at file descriptor 3, after stdin, stdout and stderr.
+	cmd.ExtraFiles = []*os.File{f}
+	cmd.Env = append(os.Environ(), listenerFDEnv+"=3")
+
+	if err := cmd.Start(); err != nil {
+		return err
+	}
+
+	s.log.Infof("Handed over listener to process %d", cmd.Process.Pid)
+
+	return nil
+}
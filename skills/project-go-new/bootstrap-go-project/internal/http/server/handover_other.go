@@ -0,0 +1,18 @@
+//go:build !unix
+
+package server
+
+import (
+	"errors"
+	"os"
+	"syscall"
+)
+
+// Handover is not supported on this platform, the signal is never sent.
+var handoverSignal os.Signal = syscall.Signal(-1)
+
+func requestShutdown() {}
+
+func reusePort(network, address string, c syscall.RawConn) error {
+	return errors.New("SO_REUSEPORT is not supported on this platform")
+}
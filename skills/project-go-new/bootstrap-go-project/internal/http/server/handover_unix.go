@@ -0,0 +1,30 @@
+//go:build unix
+
+package server
+
+import (
+	"os"
+	"syscall"
+
+	"golang.org/x/sys/unix"
+)
+
+// Signal to hand over the listener to a new process.
+var handoverSignal = syscall.SIGUSR2
+
+// Request a graceful shutdown of this process.
+func requestShutdown() {
+	syscall.Kill(os.Getpid(), syscall.SIGTERM)
+}
+
+// Enable SO_REUSEPORT on the socket, so multiple processes can listen on the same port.
+func reusePort(network, address string, c syscall.RawConn) error {
+	var err error
+	if cerr := c.Control(func(fd uintptr) {
+		err = unix.SetsockoptInt(int(fd), unix.SOL_SOCKET, unix.SO_REUSEPORT, 1)
+	}); cerr != nil {
+		return cerr
+	}
+
+	return err
+}
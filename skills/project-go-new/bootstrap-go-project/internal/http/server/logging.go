@@ -1,10 +1,9 @@
-package http
+package server
 
 import (
-	"net"
 	"net/http"
 
-	"github.com/gorilla/mux"
+	"gitlab.com/btcdirect-api/bootstrap-go-service/internal/http/middleware"
 	"go.uber.org/zap"
 )
 
@@ -13,17 +12,24 @@ type loggingResponseWriter struct {
 	statusCode int
 }
 
-// Returns a new router with logging middleware.
-func createLoggingRouter(r *mux.Router, log *zap.SugaredLogger) http.Handler {
-	return loggingRouter(r, log)
-}
-
 // Override ResponseWriter to inject HTTP status code.
 func (lrw *loggingResponseWriter) WriteHeader(code int) {
 	lrw.statusCode = code
 	lrw.ResponseWriter.WriteHeader(code)
 }
 
+// Flush passes flushes of streaming handlers on to the underlying writer.
+func (lrw *loggingResponseWriter) Flush() {
+	if f, ok := lrw.ResponseWriter.(http.Flusher); ok {
+		f.Flush()
+	}
+}
+
+// Unwrap allows http.ResponseController to reach the underlying writer, e.g. to hijack a WebSocket connection.
+func (lrw *loggingResponseWriter) Unwrap() http.ResponseWriter {
+	return lrw.ResponseWriter
+}
+
 // Logging middleware for HTTP requests.
 // This middleware logs the HTTP request and its response status code.
 //
@@ -40,13 +46,10 @@ func loggingRouter(handler http.Handler, log *zap.SugaredLogger) http.Handler {
 		handler.ServeHTTP(lrw, r)
 
 		statusCode := lrw.statusCode
-		host, _, err := net.SplitHostPort(r.RemoteAddr)
-
-		if err != nil {
-			host = r.RemoteAddr
-		}
+		// The address of the client behind the trusted proxies, see middleware.ClientIP.
+		host := middleware.ClientIPFromRequest(r)
 
-		// Log the HTTP request
-		log.Infof("%s - %s %s - %d %s", host, r.Method, r.URL.Path, statusCode, r.Proto)
+		// Log the HTTP request, the request logger adds the request ID.
+		middleware.Logger(r.Context(), log).Infof("%s - %s %s - %d %s", host, r.Method, r.URL.Path, statusCode, r.Proto)
 	})
 }
@@ -0,0 +1,58 @@
+package server
+
+import (
+	"errors"
+	"net/http"
+	"strings"
+
+	"github.com/gorilla/mux"
+	"gitlab.com/btcdirect-api/bootstrap-go-service/internal/http/respond"
+)
+
+var (
+	ErrNotFound         = errors.New("not found")
+	ErrMethodNotAllowed = errors.New("method not allowed")
+)
+
+// Methods tried to find the allowed methods of a path for the Allow header.
+var allowMethods = []string{
+	http.MethodGet, http.MethodHead, http.MethodPost, http.MethodPut,
+	http.MethodPatch, http.MethodDelete, http.MethodOptions,
+}
+
+// Answers requests without a route with the JSON error envelope, instead of the plain text of the router.
+// A path with routes for other methods returns 405 with the Allow header, otherwise 404 is returned.
+//
+// The router only reports a method mismatch for its own routes, a mismatch on a subrouter is reported as not
+// found. Both are handled alike, so the routes of groups and versions return 405 as well.
+func handleUnmatched(r *mux.Router) {
+	unmatched := http.HandlerFunc(func(w http.ResponseWriter, req *http.Request) {
+		allowed := allowedMethods(r, req)
+		if len(allowed) == 0 {
+			respond.Error(w, http.StatusNotFound, ErrNotFound)
+			return
+		}
+
+		w.Header().Set("Allow", strings.Join(allowed, ", "))
+		respond.Error(w, http.StatusMethodNotAllowed, ErrMethodNotAllowed)
+	})
+
+	r.NotFoundHandler = unmatched
+	r.MethodNotAllowedHandler = unmatched
+}
+
+// Returns the methods of the routes matching the path of the request.
+func allowedMethods(r *mux.Router, req *http.Request) []string {
+	var allowed []string
+	for _, method := range allowMethods {
+		probe := req.Clone(req.Context())
+		probe.Method = method
+
+		var match mux.RouteMatch
+		if r.Match(probe, &match) && match.MatchErr == nil {
+			allowed = append(allowed, method)
+		}
+	}
+
+	return allowed
+}
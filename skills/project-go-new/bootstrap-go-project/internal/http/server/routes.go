@@ -1,15 +1,85 @@
 package server
 
 import (
-	"github.com/gorilla/mux"
 	"gitlab.com/btcdirect-api/bootstrap-go-service/internal/app"
 	"gitlab.com/btcdirect-api/bootstrap-go-service/internal/http/handler"
+	"gitlab.com/btcdirect-api/bootstrap-go-service/internal/http/middleware"
+	"gitlab.com/btcdirect-api/bootstrap-go-service/internal/http/router"
+	"gitlab.com/btcdirect-api/bootstrap-go-service/internal/metrics"
 )
 
 // Registers all routes for the application.
-func registerRoutes(r *mux.Router, app *app.App) {
-	r.HandleFunc("/health", handler.HealthHandler(app)).Methods("GET")
-	r.HandleFunc("/ready", handler.ReadinessHandler(app.DatabaseConnection())).Methods("GET")
+// The operational routes are served on the ops listener instead when it is configured.
+func registerRoutes(r router.Router, app *app.App) {
+	r.Use(middleware.Deduplicate())
+
+	if app.Config().OpsPort == "" {
+		registerOpsRoutes(r, app)
+	}
+
+	// The database is only used when it is configured.
+	if app.HasDatabase() {
+		r.HandleFunc("GET", "/tasks/{id}", handler.TaskHandler(app.Tasks(), app.Logger()))
+	}
+
+	// Admin routes require the admin token, and an allowed IP address when configured.
+	admin := Group(r, "/admin", adminMiddleware(app)...)
+	admin.HandleFunc("GET", "/messenger/backlog", handler.BacklogHandler(app.Messenger()))
+	admin.HandleFunc("GET", "/messenger/dead-letters", handler.ListDeadLettersHandler(app.Messenger(), app.Logger()))
+	admin.HandleFunc("DELETE", "/messenger/dead-letters", handler.PurgeDeadLettersHandler(app.Messenger(), app.Logger()))
+	admin.HandleFunc("GET", "/messenger/dead-letters/{id}", handler.GetDeadLetterHandler(app.Messenger(), app.Logger()))
+	admin.HandleFunc("POST", "/messenger/dead-letters/{id}/requeue", handler.RequeueDeadLetterHandler(app.Messenger(), app.Logger()))
+	if app.HasDatabase() {
+		admin.HandleFunc("GET", "/database/nodes", handler.DatabaseNodesHandler(app.DatabaseConnection()))
+	}
 
 	// TODO: Add your application-specific routes here
+	// Services embedding the bootstrap add their routes with app.WithRoutes instead.
+	app.RegisterRoutes(r, admin)
+}
+
+// Registers the routes of the platform and the operators: probes, metrics, profiling and the log level.
+func registerOpsRoutes(r router.Router, app *app.App) {
+	r.HandleFunc("GET", "/health", handler.HealthHandler(app))
+	r.Handle("GET", "/metrics", metrics.Handler())
+
+	// The database is only checked when it is configured.
+	if app.HasDatabase() {
+		r.HandleFunc("GET", "/ready", handler.ReadinessHandler(app.DatabaseConnection(), app.Messenger(), app.ReadinessChecks(), app))
+	} else {
+		r.HandleFunc("GET", "/ready", handler.ReadinessHandler(nil, app.Messenger(), app.ReadinessChecks(), app))
+	}
+
+	// Changing the log level is restricted like the admin routes.
+	if level := app.LogLevel(); level != nil {
+		logLevel := Group(r, "/log-level", adminMiddleware(app)...)
+		logLevel.Handle("GET", "", handler.GetLogLevelHandler(level))
+		logLevel.Handle("PUT", "", handler.SetLogLevelHandler(level, app.Config().Environment, app.Logger()))
+	}
+
+	// Profiling is optional, it is restricted like the admin routes.
+	if app.Config().DebugEndpoints {
+		registerDebugRoutes(r, adminMiddleware(app)...)
+	}
+}
+
+// Returns the middleware restricting the admin routes: the IP filter of the allowed addresses when configured,
+// before the admin token, so other addresses can not guess the token.
+// Connections on a Unix socket have no client IP, so the filter would reject every request: this is refused.
+func adminMiddleware(app *app.App) []router.Middleware {
+	c := app.Config()
+	mw := []router.Middleware{middleware.AdminToken(c.AdminToken, app.Logger())}
+	if len(c.AdminAllowedIPs) == 0 {
+		return mw
+	}
+	if c.HTTPSocket != "" {
+		app.Logger().Fatal("ADMIN_ALLOWED_IPS can not be used with HTTP_SOCKET, requests on a Unix socket have no client IP")
+	}
+
+	allowed, err := middleware.ParseCIDRs(c.AdminAllowedIPs)
+	if err != nil {
+		app.Logger().Fatalf("Invalid ADMIN_ALLOWED_IPS: %s", err)
+	}
+
+	return append([]router.Middleware{middleware.IPFilter(middleware.IPFilterOptions{Allow: allowed}, app.Logger())}, mw...)
 }
@@ -1,22 +1,307 @@
 package server
 
 import (
-	"gitlab.com/btcdirect-api/go-modules/http"
+	"context"
+	"errors"
+	"net"
+	"net/http"
+	"net/netip"
+	"os"
+	"time"
+
+	"github.com/gorilla/mux"
 	"gitlab.com/btcdirect-api/bootstrap-go-service/internal/app"
+	"gitlab.com/btcdirect-api/bootstrap-go-service/internal/http/middleware"
+	"gitlab.com/btcdirect-api/bootstrap-go-service/internal/http/router"
+	"go.uber.org/zap"
+	"golang.org/x/net/http2"
+	"golang.org/x/net/http2/h2c"
 )
 
 type Server interface {
 	Shutdown()
 }
 
+// server is a wrapper around the http.Server.
+type server struct {
+	Router    router.Router
+	server    *http.Server
+	network   string
+	address   string
+	reusePort bool
+	handover  bool
+	// The ops listener never takes over the listener handed over for the HTTP port.
+	ops bool
+	// Maximum time to wait for open requests on shutdown, the remaining connections are closed after it.
+	drainTimeout time.Duration
+	conns        *connTracker
+	log          *zap.SugaredLogger
+}
+
+// servers shuts down the HTTP server and the ops server together.
+type servers []server
+
 // Start Creates a new HTTP server, registers routes and starts it.
 // Do not forget to call Shutdown() on the server when shutting down.
+//
+// The server listens on the configured TCP port, unless a Unix socket is configured.
+// A listener handed over by a previous process takes precedence over both.
+//
+// With an ops port, the operational routes are served by a second server on that port only,
+// so they can be kept off the public listener, e.g. by exposing only the HTTP port.
 func Start(application *app.App) Server {
-	s := http.CreateServer(application.Config().HTTPPort, application.Logger())
+	c := application.Config()
+	s := createServer(c, application.Logger(), newRouter(application))
+	s.reusePort = c.HTTPReusePort
+	s.handover = c.HTTPHandover
 
 	registerRoutes(s.Router, application)
 
 	s.Start()
 
+	if c.OpsPort == "" {
+		return s
+	}
+
+	ops := createOpsServer(c, application.Logger(), newRouter(application))
+	// A new process started by the handover binds the ops port while this process still listens on it.
+	ops.reusePort = c.HTTPReusePort || c.HTTPHandover
+
+	registerOpsRoutes(ops.Router, application)
+
+	ops.Start()
+
+	return servers{s, ops}
+}
+
+// Handler returns the routes of the application with request IDs, logging, panic recovery and CORS, without starting a server.
+// This allows serving the application from a test server.
+func Handler(application *app.App) http.Handler {
+	r := newRouter(application)
+	registerRoutes(r, application)
+
+	return wrapRouter(r, application.Config(), application.Logger())
+}
+
+// OpsHandler returns the operational routes served on the ops port, without starting a server.
+// Without an ops port, they are part of Handler.
+func OpsHandler(application *app.App) http.Handler {
+	r := newRouter(application)
+	registerOpsRoutes(r, application)
+
+	return wrapOpsRouter(r, application.Config(), application.Logger())
+}
+
+// Returns the router of the routes, gorilla/mux unless the application sets another router with app.WithRouter.
+// Requests without a route are answered with the JSON error envelope on gorilla/mux.
+func newRouter(application *app.App) router.Router {
+	if r := application.NewRouter(); r != nil {
+		return r
+	}
+
+	r := mux.NewRouter()
+	handleUnmatched(r)
+
+	return router.NewMux(r)
+}
+
+// Creates a new HTTP server for the configured port or Unix socket and logger.
+// The logger will be used to log the HTTP requests.
+func createServer(c app.Configuration, log *zap.SugaredLogger, r router.Router) server {
+	conns := newConnTracker()
+
+	s := server{
+		Router:       r,
+		network:      "tcp",
+		address:      ":" + c.HTTPPort,
+		drainTimeout: c.HTTPDrainTimeout,
+		conns:        conns,
+		log:          log,
+		server: &http.Server{
+			Handler:   wrapRouter(r, c, log),
+			ConnState: conns.track,
+		},
+	}
+
+	if c.HTTPSocket != "" {
+		s.network = "unix"
+		s.address = c.HTTPSocket
+	}
+
+	// HTTP/2 without TLS, for gRPC. The connections are served by the HTTP/2 server once upgraded,
+	// so the shutdown sends them a GOAWAY instead of draining them with the HTTP/1.1 connections.
+	if c.HTTPH2C {
+		h2s := &http2.Server{}
+		if err := http2.ConfigureServer(s.server, h2s); err != nil {
+			log.Fatalf("Failed to configure HTTP/2: %s", err)
+		}
+		s.server.Handler = h2c.NewHandler(s.server.Handler, h2s)
+	}
+
 	return s
 }
+
+// Creates the server of the operational routes on the ops port.
+func createOpsServer(c app.Configuration, log *zap.SugaredLogger, r router.Router) server {
+	conns := newConnTracker()
+
+	return server{
+		Router:       r,
+		network:      "tcp",
+		address:      ":" + c.OpsPort,
+		ops:          true,
+		drainTimeout: c.HTTPDrainTimeout,
+		conns:        conns,
+		log:          log,
+		server: &http.Server{
+			Handler:   wrapOpsRouter(r, c, log),
+			ConnState: conns.track,
+		},
+	}
+}
+
+// Wraps the router with the middleware applying to all requests, also those not matching a route.
+// CORS answers the preflight requests before the router, which only matches the methods of the routes.
+// Panics are recovered inside the logging, so the request is logged with the 500 response.
+// The default deadline of the requests is replaced by a middleware.Timeout of a route.
+func wrapRouter(r http.Handler, c app.Configuration, log *zap.SugaredLogger) http.Handler {
+	cors := middleware.CORS(middleware.CORSOptions{
+		Origins:     c.CORS.Origins,
+		Methods:     c.CORS.Methods,
+		Headers:     c.CORS.Headers,
+		Credentials: c.CORS.Credentials,
+		MaxAge:      c.CORS.MaxAge,
+	})
+
+	clientIP := middleware.ClientIP(trustedProxies(c, log))
+	timeout := middleware.Timeout(c.HTTPRequestTimeout)
+
+	return middleware.RequestID(log)(clientIP(loggingRouter(middleware.Recover(log)(timeout(cors(r))), log)))
+}
+
+// Wraps the ops router like wrapRouter, without CORS as browsers do not call the operational routes.
+func wrapOpsRouter(r http.Handler, c app.Configuration, log *zap.SugaredLogger) http.Handler {
+	clientIP := middleware.ClientIP(trustedProxies(c, log))
+	timeout := middleware.Timeout(c.HTTPRequestTimeout)
+
+	return middleware.RequestID(log)(clientIP(loggingRouter(middleware.Recover(log)(timeout(r)), log)))
+}
+
+// Returns the configured trusted proxies, an invalid address stops the application.
+func trustedProxies(c app.Configuration, log *zap.SugaredLogger) []netip.Prefix {
+	trusted, err := middleware.ParseTrustedProxies(c.TrustedProxies)
+	if err != nil {
+		log.Fatalf("Invalid TRUSTED_PROXIES: %s", err)
+	}
+
+	return trusted
+}
+
+// Start the HTTP server.
+// The listener is created before returning, so an address that is already in use is reported on startup.
+func (s server) Start() {
+	s.log.Infof("Starting %s on %s %s", s.name(), s.network, s.address)
+
+	l, err := s.listen()
+	if err != nil {
+		s.log.Fatalf("Failed to start %s: %s", s.name(), err)
+	}
+
+	go s.run(l)
+
+	if s.handover {
+		go s.awaitHandover(l)
+	}
+}
+
+// Run the HTTP server, this will block until the server is shutdown.
+func (s server) run(l net.Listener) {
+	if err := s.server.Serve(l); err != http.ErrServerClosed {
+		s.log.Fatalf("Failed to start %s: %s", s.name(), err)
+	}
+}
+
+// Create the listener for the configured network.
+// A stale Unix socket from a previous run is removed, the socket is removed again when the listener is closed.
+//
+// When the previous process handed over its listener, that listener is used instead.
+func (s server) listen() (net.Listener, error) {
+	if l, err := s.inheritedListener(); l != nil || err != nil {
+		if l != nil {
+			s.log.Infof("Using listener handed over on %s", l.Addr())
+		}
+		return l, err
+	}
+
+	if s.network == "unix" {
+		if err := os.Remove(s.address); err != nil && !errors.Is(err, os.ErrNotExist) {
+			return nil, err
+		}
+	}
+
+	lc := net.ListenConfig{}
+	if s.reusePort && s.network == "tcp" {
+		lc.Control = reusePort
+	}
+
+	return lc.Listen(context.Background(), s.network, s.address)
+}
+
+// Gracefully shutdown the HTTP server.
+// The listener is closed immediately, so no new connections are accepted, and the open requests are drained.
+// The readiness already fails since the shutdown was requested, see core.App.ShuttingDown.
+// If the requests do not finish within the drain timeout, the remaining connections are closed.
+func (s server) Shutdown() {
+	s.log.Infof("Shutting down %s, draining %d connections for up to %s", s.name(), s.conns.Len(), s.drainTimeout)
+
+	ctx, cancel := context.WithTimeout(context.Background(), s.drainTimeout)
+	defer cancel()
+	err := s.server.Shutdown(ctx)
+	if errors.Is(err, context.DeadlineExceeded) {
+		n := s.conns.Len()
+		s.server.Close()
+		forcedConnections.Add(s.label(), int64(n))
+
+		s.log.Warnf("Force-closed %d connections of %s after the drain timeout of %s", n, s.name(), s.drainTimeout)
+		return
+	}
+	if err != nil {
+		s.log.Fatalf("Failed to shutdown %s: %s", s.name(), err)
+	}
+
+	s.log.Infof("%s shutdown", s.name())
+}
+
+// Shutdown gracefully shuts down the HTTP server, then the ops server,
+// so the probes keep answering while the requests drain.
+func (s servers) Shutdown() {
+	for _, srv := range s {
+		srv.Shutdown()
+	}
+}
+
+// Returns the inherited listener for the HTTP port, the ops server listens on its own port.
+func (s server) inheritedListener() (net.Listener, error) {
+	if s.ops {
+		return nil, nil
+	}
+
+	return inheritedListener()
+}
+
+// Label of the server in the metrics.
+func (s server) label() string {
+	if s.ops {
+		return "ops"
+	}
+
+	return "http"
+}
+
+func (s server) name() string {
+	if s.ops {
+		return "ops HTTP server"
+	}
+
+	return "HTTP server"
+}
@@ -0,0 +1,69 @@
+package server
+
+import (
+	"context"
+	"crypto/tls"
+	"net"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/gorilla/mux"
+	"gitlab.com/btcdirect-api/bootstrap-go-service/internal/app"
+	"gitlab.com/btcdirect-api/bootstrap-go-service/internal/http/router"
+	"go.uber.org/zap"
+	"golang.org/x/net/http2"
+)
+
+func TestCreateServerH2C(t *testing.T) {
+	// A client sending HTTP/2 without TLS, like a gRPC client or a load balancer terminating TLS.
+	h2c := &http.Client{Transport: &http2.Transport{
+		AllowHTTP: true,
+		DialTLSContext: func(ctx context.Context, network, addr string, _ *tls.Config) (net.Conn, error) {
+			return (&net.Dialer{}).DialContext(ctx, network, addr)
+		},
+	}}
+
+	tests := []struct {
+		name      string
+		h2c       bool
+		client    *http.Client
+		wantProto string
+		wantErr   bool
+	}{
+		{name: "HTTP/1.1", client: http.DefaultClient, wantProto: "HTTP/1.1"},
+		{name: "HTTP/1.1 with h2c", h2c: true, client: http.DefaultClient, wantProto: "HTTP/1.1"},
+		{name: "HTTP/2 with h2c", h2c: true, client: h2c, wantProto: "HTTP/2.0"},
+		{name: "HTTP/2 without h2c", client: h2c, wantErr: true},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			r := router.NewMux(mux.NewRouter())
+			r.HandleFunc("GET", "/proto", func(w http.ResponseWriter, req *http.Request) {
+				w.Write([]byte(req.Proto))
+			})
+
+			c := app.Configuration{HTTPH2C: tt.h2c}
+			s := httptest.NewServer(createServer(c, zap.NewNop().Sugar(), r).server.Handler)
+			defer s.Close()
+
+			res, err := tt.client.Get(s.URL + "/proto")
+			if tt.wantErr {
+				if err == nil {
+					res.Body.Close()
+					t.Fatal("GET error = nil, want HTTP/2 refused")
+				}
+				return
+			}
+			if err != nil {
+				t.Fatalf("GET error = %v", err)
+			}
+			defer res.Body.Close()
+
+			if res.Proto != tt.wantProto {
+				t.Errorf("proto = %s, want %s", res.Proto, tt.wantProto)
+			}
+		})
+	}
+}
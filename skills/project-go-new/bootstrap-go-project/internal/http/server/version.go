@@ -0,0 +1,162 @@
+package server
+
+import (
+	"net/http"
+	"time"
+
+	"gitlab.com/btcdirect-api/bootstrap-go-service/internal/http/respond"
+	"gitlab.com/btcdirect-api/bootstrap-go-service/internal/http/router"
+)
+
+// Request header selecting the API version of a request without a version in its path.
+const VersionHeader = "API-Version"
+
+// Version of the API, mounted by Versioned.
+type Version struct {
+	// Name of the version, the path prefix and the value of the API-Version header, e.g. "v1".
+	Name string
+	// Serves the requests without a version in the path or header.
+	Default bool
+	// Marks the version as deprecated with a Deprecation header. Setting a Sunset implies it.
+	Deprecated bool
+	// Time the version is removed, sent as the Sunset header.
+	Sunset time.Time
+	// Link to the migration guide, sent with rel="deprecation".
+	Link string
+}
+
+// Versioned mounts the routes of register under each version, so the same handler set serves multiple versions.
+// A version is selected by the path prefix, e.g. /v2/orders, or by the API-Version header on the unversioned path,
+// e.g. /orders with API-Version: v2, which falls back to the Default version without the header.
+// The handlers can branch on the version given to register.
+//
+// Responses of deprecated versions carry the Deprecation and Sunset headers, so clients can detect them.
+// An unversioned path returns 404 when the header selects no version of its routes.
+func Versioned(r router.Router, versions []Version, register func(r router.Router, version string)) {
+	for _, v := range versions {
+		register(Group(r, "/"+v.Name, deprecation(v)), v.Name)
+	}
+
+	// The unversioned routes of all versions are registered once, selecting the version by the header.
+	routes := &versionRoutes{handlers: make(map[versionPattern][]versionHandler)}
+	for _, v := range versions {
+		register(&versionRouter{routes: routes, version: v, middleware: []router.Middleware{deprecation(v)}}, v.Name)
+	}
+	routes.register(r)
+}
+
+// Method and pattern of an unversioned route.
+type versionPattern struct {
+	method  string
+	pattern string
+}
+
+// Handler of a version for an unversioned route.
+type versionHandler struct {
+	version Version
+	handler http.Handler
+	router  *versionRouter
+}
+
+// Collects the unversioned routes of the versions, see Versioned.
+type versionRoutes struct {
+	// The patterns in registration order, so the routes match in the order of the versions.
+	patterns []versionPattern
+	handlers map[versionPattern][]versionHandler
+}
+
+// Registers a route per pattern, serving the handler of the version selected by the header.
+// The middleware of the version groups are applied now, so Use also covers the routes registered before it.
+func (v *versionRoutes) register(r router.Router) {
+	for _, p := range v.patterns {
+		handlers := v.handlers[p]
+		for i, h := range handlers {
+			handlers[i].handler = h.router.wrap(h.handler)
+		}
+
+		r.Handle(p.method, p.pattern, http.HandlerFunc(func(w http.ResponseWriter, req *http.Request) {
+			given := req.Header.Get(VersionHeader)
+			for _, h := range handlers {
+				if given == h.version.Name || (given == "" && h.version.Default) {
+					h.handler.ServeHTTP(w, req)
+					return
+				}
+			}
+
+			respond.Error(w, http.StatusNotFound, ErrNotFound)
+		}))
+	}
+}
+
+// Router given to register for the unversioned routes of a version, the routes are served by the router of Versioned.
+type versionRouter struct {
+	routes     *versionRoutes
+	version    Version
+	parent     *versionRouter
+	prefix     string
+	middleware []router.Middleware
+}
+
+// The routes are only served by the router passed to Versioned.
+func (r *versionRouter) ServeHTTP(w http.ResponseWriter, _ *http.Request) {
+	respond.Error(w, http.StatusNotFound, ErrNotFound)
+}
+
+func (r *versionRouter) Handle(method, pattern string, h http.Handler) {
+	p := versionPattern{method: method, pattern: r.prefix + pattern}
+	if _, ok := r.routes.handlers[p]; !ok {
+		r.routes.patterns = append(r.routes.patterns, p)
+	}
+
+	r.routes.handlers[p] = append(r.routes.handlers[p], versionHandler{version: r.version, handler: h, router: r})
+}
+
+func (r *versionRouter) HandleFunc(method, pattern string, h http.HandlerFunc) {
+	r.Handle(method, pattern, h)
+}
+
+func (r *versionRouter) Use(mw ...router.Middleware) {
+	r.middleware = append(r.middleware, mw...)
+}
+
+func (r *versionRouter) Group(prefix string, mw ...router.Middleware) router.Router {
+	return &versionRouter{
+		routes:     r.routes,
+		version:    r.version,
+		parent:     r,
+		prefix:     r.prefix + prefix,
+		middleware: mw,
+	}
+}
+
+// Wraps the handler with the middleware of the router and its parents.
+func (r *versionRouter) wrap(h http.Handler) http.Handler {
+	for g := r; g != nil; g = g.parent {
+		for i := len(g.middleware) - 1; i >= 0; i-- {
+			h = g.middleware[i](h)
+		}
+	}
+
+	return h
+}
+
+// Adds the deprecation headers of the version to its responses.
+func deprecation(v Version) router.Middleware {
+	return func(next http.Handler) http.Handler {
+		if !v.Deprecated && v.Sunset.IsZero() {
+			return next
+		}
+
+		return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			w.Header().Set("Deprecation", "true")
+			if !v.Sunset.IsZero() {
+				w.Header().Set("Sunset", v.Sunset.UTC().Format(http.TimeFormat))
+			}
+			if v.Link != "" {
+				w.Header().Add("Link", "<"+v.Link+`>; rel="deprecation"`)
+			}
+
+			next.ServeHTTP(w, r)
+		})
+	}
+}
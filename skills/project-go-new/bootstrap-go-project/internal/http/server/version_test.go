@@ -0,0 +1,79 @@
+package server
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/gorilla/mux"
+	"gitlab.com/btcdirect-api/bootstrap-go-service/internal/http/router"
+)
+
+func TestVersioned(t *testing.T) {
+	m := mux.NewRouter()
+	handleUnmatched(m)
+	r := router.NewMux(m)
+
+	Versioned(r, []Version{
+		{Name: "v1", Sunset: time.Date(2027, 1, 1, 0, 0, 0, 0, time.UTC)},
+		{Name: "v2", Default: true},
+	}, func(r router.Router, version string) {
+		r.HandleFunc("GET", "/orders/{id}", func(w http.ResponseWriter, req *http.Request) {
+			w.Write([]byte(version + ":" + router.Param(req, "id")))
+		})
+		if version == "v2" {
+			r.Group("/reports").HandleFunc("GET", "/daily", func(w http.ResponseWriter, _ *http.Request) {
+				w.Write([]byte(version))
+			})
+		}
+	})
+
+	tests := []struct {
+		name           string
+		method         string
+		path           string
+		header         string
+		wantStatus     int
+		wantBody       string
+		wantDeprecated bool
+	}{
+		{name: "path", path: "/v1/orders/42", wantStatus: http.StatusOK, wantBody: "v1:42", wantDeprecated: true},
+		{name: "path default", path: "/v2/orders/42", wantStatus: http.StatusOK, wantBody: "v2:42"},
+		{name: "header", path: "/orders/42", header: "v1", wantStatus: http.StatusOK, wantBody: "v1:42", wantDeprecated: true},
+		{name: "no header", path: "/orders/42", wantStatus: http.StatusOK, wantBody: "v2:42"},
+		{name: "group", path: "/reports/daily", wantStatus: http.StatusOK, wantBody: "v2"},
+		{name: "version without the route", path: "/reports/daily", header: "v1", wantStatus: http.StatusNotFound},
+		{name: "unknown version", path: "/orders/42", header: "v3", wantStatus: http.StatusNotFound},
+		{name: "method", method: "POST", path: "/orders/42", wantStatus: http.StatusMethodNotAllowed},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			method := tt.method
+			if method == "" {
+				method = "GET"
+			}
+			req := httptest.NewRequest(method, tt.path, nil)
+			if tt.header != "" {
+				req.Header.Set(VersionHeader, tt.header)
+			}
+
+			w := httptest.NewRecorder()
+			r.ServeHTTP(w, req)
+
+			if w.Code != tt.wantStatus {
+				t.Fatalf("status = %d, want %d", w.Code, tt.wantStatus)
+			}
+			if tt.wantStatus != http.StatusOK {
+				return
+			}
+			if got := w.Body.String(); got != tt.wantBody {
+				t.Errorf("body = %q, want %q", got, tt.wantBody)
+			}
+			if got := w.Header().Get("Deprecation") == "true"; got != tt.wantDeprecated {
+				t.Errorf("deprecated = %v, want %v", got, tt.wantDeprecated)
+			}
+		})
+	}
+}
@@ -0,0 +1,265 @@
+package websocket
+
+import (
+	"context"
+	"errors"
+	"net/http"
+	"sync"
+	"time"
+
+	"github.com/gorilla/websocket"
+	"gitlab.com/btcdirect-api/bootstrap-go-service/internal/core"
+	"go.uber.org/zap"
+)
+
+const (
+	defaultPingInterval = 30 * time.Second
+	defaultPongTimeout  = 60 * time.Second
+	defaultWriteTimeout = 10 * time.Second
+	defaultSendBuffer   = 16
+)
+
+var (
+	ErrClosed     = errors.New("websocket: connection closed")
+	ErrSlowClient = errors.New("websocket: client is not keeping up")
+)
+
+// HubOptions configures a Hub.
+type HubOptions struct {
+	UpgradeOptions
+	// Interval of the pings keeping the connection alive, defaults to 30 seconds.
+	PingInterval time.Duration
+	// Time without any frame of the client after which it is disconnected, defaults to 60 seconds.
+	PongTimeout time.Duration
+	// Maximum time to write a message, defaults to 10 seconds.
+	WriteTimeout time.Duration
+	// Number of messages queued per client, a client with a full queue is disconnected. Defaults to 16.
+	SendBuffer int
+	// Maximum size of a received message, defaults to 1 MiB.
+	ReadLimit int64
+}
+
+// MessageFunc handles a message received from the client.
+type MessageFunc func(c *Client, typ MessageType, data []byte)
+
+// Hub serves WebSocket clients: it keeps their connections alive with pings, broadcasts messages to all of them,
+// and closes them with 1001 (going away) on the graceful shutdown of the application.
+type Hub struct {
+	o   HubOptions
+	log *zap.SugaredLogger
+	ctx context.Context
+
+	mu      sync.Mutex
+	clients map[*Client]struct{}
+	wg      sync.WaitGroup
+}
+
+// Client is a connection served by the hub.
+type Client struct {
+	conn *Conn
+	hub  *Hub
+	send chan message
+	// Context of the connection, cancelled when it is closed or the application shuts down.
+	ctx    context.Context
+	cancel context.CancelFunc
+}
+
+type message struct {
+	typ  MessageType
+	data []byte
+}
+
+// NewHub creates a hub that closes its clients on the graceful shutdown.
+func NewHub(shutdown *core.GracefulShutdown, log *zap.SugaredLogger, o HubOptions) *Hub {
+	if o.PingInterval == 0 {
+		o.PingInterval = defaultPingInterval
+	}
+	if o.PongTimeout == 0 {
+		o.PongTimeout = defaultPongTimeout
+	}
+	if o.WriteTimeout == 0 {
+		o.WriteTimeout = defaultWriteTimeout
+	}
+	if o.SendBuffer == 0 {
+		o.SendBuffer = defaultSendBuffer
+	}
+
+	ctx, done := shutdown.AddHook("websocket")
+	h := &Hub{o: o, log: log, ctx: ctx, clients: make(map[*Client]struct{})}
+
+	go func() {
+		defer done()
+		<-ctx.Done()
+
+		h.mu.Lock()
+		n := len(h.clients)
+		for c := range h.clients {
+			c.cancel()
+		}
+		h.mu.Unlock()
+
+		log.Infof("Closing %d WebSocket connections", n)
+		h.wg.Wait()
+	}()
+
+	return h
+}
+
+// Handler upgrades the requests and serves the connections, onMessage is called for each received message.
+// It may be nil for clients that only receive, e.g. dashboards with live updates.
+func (h *Hub) Handler(onMessage MessageFunc) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		if h.ctx.Err() != nil {
+			http.Error(w, "shutting down", http.StatusServiceUnavailable)
+			return
+		}
+
+		conn, err := Upgrade(w, r, h.o.UpgradeOptions)
+		if err != nil {
+			h.log.Warnw("WebSocket handshake failed", "path", r.URL.Path, "error", err)
+			return
+		}
+		if h.o.ReadLimit > 0 {
+			conn.SetReadLimit(h.o.ReadLimit)
+		}
+
+		ctx, cancel := context.WithCancel(h.ctx)
+		c := &Client{conn: conn, hub: h, send: make(chan message, h.o.SendBuffer), ctx: ctx, cancel: cancel}
+
+		// The shutdown cancels the registered clients, a client connecting after it started is closed.
+		h.mu.Lock()
+		if h.ctx.Err() != nil {
+			h.mu.Unlock()
+			cancel()
+			closeConn(conn, websocket.CloseGoingAway, h.o.WriteTimeout)
+			return
+		}
+		h.clients[c] = struct{}{}
+		h.wg.Add(1)
+		h.mu.Unlock()
+
+		go c.writeLoop()
+		c.readLoop(onMessage)
+	}
+}
+
+// Broadcast queues the message for all clients, and returns the number of clients it was queued for.
+// Clients that are not keeping up are disconnected instead of blocking the others.
+func (h *Hub) Broadcast(typ MessageType, data []byte) int {
+	h.mu.Lock()
+	clients := make([]*Client, 0, len(h.clients))
+	for c := range h.clients {
+		clients = append(clients, c)
+	}
+	h.mu.Unlock()
+
+	n := 0
+	for _, c := range clients {
+		if c.Send(typ, data) == nil {
+			n++
+		}
+	}
+
+	return n
+}
+
+// Len returns the number of connected clients.
+func (h *Hub) Len() int {
+	h.mu.Lock()
+	defer h.mu.Unlock()
+
+	return len(h.clients)
+}
+
+// Context returns the context of the connection, cancelled when it is closed or the application shuts down.
+func (c *Client) Context() context.Context {
+	return c.ctx
+}
+
+// Send queues the message for the client, a client with a full queue is disconnected with ErrSlowClient.
+func (c *Client) Send(typ MessageType, data []byte) error {
+	if c.ctx.Err() != nil {
+		return ErrClosed
+	}
+
+	select {
+	case c.send <- message{typ: typ, data: data}:
+		return nil
+	default:
+		c.hub.log.Warn("Disconnecting slow WebSocket client")
+		c.cancel()
+		return ErrSlowClient
+	}
+}
+
+// Close disconnects the client.
+func (c *Client) Close() {
+	c.cancel()
+}
+
+// Reads the messages until the connection fails, any frame of the client extends the read deadline.
+func (c *Client) readLoop(onMessage MessageFunc) {
+	defer c.cancel()
+
+	extend := func() {
+		c.conn.SetReadDeadline(time.Now().Add(c.hub.o.PongTimeout))
+	}
+	c.conn.SetPongHandler(func(string) error {
+		extend()
+		return nil
+	})
+
+	for {
+		extend()
+		typ, data, err := c.conn.ReadMessage()
+		if err != nil {
+			return
+		}
+		if onMessage != nil {
+			onMessage(c, MessageType(typ), data)
+		}
+	}
+}
+
+// Writes the queued messages and the pings, and closes the connection when the context is done.
+func (c *Client) writeLoop() {
+	ping := time.NewTicker(c.hub.o.PingInterval)
+	defer ping.Stop()
+
+	defer func() {
+		code := websocket.CloseNormalClosure
+		if c.hub.ctx.Err() != nil {
+			code = websocket.CloseGoingAway
+		}
+		closeConn(c.conn, code, c.hub.o.WriteTimeout)
+
+		c.hub.mu.Lock()
+		delete(c.hub.clients, c)
+		c.hub.mu.Unlock()
+		c.hub.wg.Done()
+	}()
+
+	for {
+		select {
+		case <-c.ctx.Done():
+			return
+		case m := <-c.send:
+			c.conn.SetWriteDeadline(time.Now().Add(c.hub.o.WriteTimeout))
+			if err := c.conn.WriteMessage(int(m.typ), m.data); err != nil {
+				c.cancel()
+				return
+			}
+		case <-ping.C:
+			if err := c.conn.WriteControl(websocket.PingMessage, nil, time.Now().Add(c.hub.o.WriteTimeout)); err != nil {
+				c.cancel()
+				return
+			}
+		}
+	}
+}
+
+// Sends a close message with the code, and closes the connection.
+func closeConn(conn *Conn, code int, timeout time.Duration) {
+	conn.WriteControl(websocket.CloseMessage, websocket.FormatCloseMessage(code, ""), time.Now().Add(timeout))
+	conn.Close()
+}
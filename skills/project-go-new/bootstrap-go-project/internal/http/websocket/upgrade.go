@@ -0,0 +1,84 @@
+package websocket
+
+import (
+	"errors"
+	"net/http"
+	"net/url"
+	"slices"
+	"strings"
+
+	"github.com/gorilla/websocket"
+)
+
+// Default maximum size of a received message.
+const defaultReadLimit = 1 << 20
+
+var (
+	ErrNotWebSocket     = errors.New("websocket: not a websocket handshake")
+	ErrOriginNotAllowed = errors.New("websocket: origin not allowed")
+)
+
+// Conn is a WebSocket connection of gorilla/websocket.
+// Reads must be done by a single goroutine, and writes by a single goroutine, except for WriteControl and Close.
+type Conn = websocket.Conn
+
+// MessageType is the type of a data message.
+type MessageType int
+
+const (
+	TextMessage   MessageType = websocket.TextMessage
+	BinaryMessage MessageType = websocket.BinaryMessage
+)
+
+// UpgradeOptions configures the handshake of Upgrade.
+type UpgradeOptions struct {
+	// Origins allowed to connect besides the host of the request, e.g. https://dashboard.example.com.
+	// Browsers send the Origin header, requests without it (other clients) are allowed.
+	Origins []string
+}
+
+// Upgrade performs the WebSocket handshake of the request and returns the connection, with a read limit of 1 MiB.
+// When the handshake fails an error response is written and the error is returned.
+//
+// The connection is taken over from the HTTP server, it is not closed by the shutdown of the server:
+// close it when the service stops, e.g. by serving it with a Hub.
+func Upgrade(w http.ResponseWriter, r *http.Request, o UpgradeOptions) (*Conn, error) {
+	if r.Method != http.MethodGet || !websocket.IsWebSocketUpgrade(r) {
+		http.Error(w, ErrNotWebSocket.Error(), http.StatusBadRequest)
+		return nil, ErrNotWebSocket
+	}
+
+	if !allowedOrigin(r, o.Origins) {
+		http.Error(w, ErrOriginNotAllowed.Error(), http.StatusForbidden)
+		return nil, ErrOriginNotAllowed
+	}
+
+	// The origin is checked above, the upgrader writes the error response of other handshake failures.
+	upgrader := websocket.Upgrader{CheckOrigin: func(*http.Request) bool { return true }}
+	conn, err := upgrader.Upgrade(w, r, nil)
+	if err != nil {
+		return nil, err
+	}
+	conn.SetReadLimit(defaultReadLimit)
+
+	return conn, nil
+}
+
+// Browsers may only connect from the host of the service or an allowed origin,
+// to prevent cross-site WebSocket hijacking with the cookies of the user.
+func allowedOrigin(r *http.Request, origins []string) bool {
+	origin := r.Header.Get("Origin")
+	if origin == "" {
+		return true
+	}
+
+	u, err := url.Parse(origin)
+	if err != nil {
+		return false
+	}
+	if strings.EqualFold(u.Host, r.Host) {
+		return true
+	}
+
+	return slices.Contains(origins, strings.TrimSuffix(origin, "/"))
+}
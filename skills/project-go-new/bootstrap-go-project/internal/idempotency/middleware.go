@@ -0,0 +1,162 @@
+package idempotency
+
+import (
+	"bytes"
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"errors"
+	"io"
+	"net/http"
+
+	"gitlab.com/btcdirect-api/bootstrap-go-service/internal/http/middleware"
+	"gitlab.com/btcdirect-api/bootstrap-go-service/internal/http/respond"
+)
+
+// Maximum length of an idempotency key, the length of the column.
+const maxKeyLength = 255
+
+var (
+	ErrInvalidKey  = errors.New("invalid idempotency key")
+	ErrInProgress  = errors.New("a request with this idempotency key is in progress")
+	ErrKeyMismatch = errors.New("idempotency key was used for a different request")
+)
+
+// Middleware handles POST and PUT requests with an Idempotency-Key header once: the response is stored with
+// the key and replayed on retries of the request, with the Idempotent-Replayed header, until the key expires.
+// Apply it to unsafe endpoints that must not be executed twice, e.g. creating a payment.
+//
+// A retry while the first request is in progress returns 409 Conflict, and reusing a key for another method,
+// path or body returns 422. Server errors (5xx) are not stored, so the request can be retried.
+// Requests without the header and other methods are not affected.
+func Middleware(s *Store) func(http.Handler) http.Handler {
+	return func(next http.Handler) http.Handler {
+		return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			key := r.Header.Get(middleware.IdempotencyKeyHeader)
+			if key == "" || (r.Method != http.MethodPost && r.Method != http.MethodPut) {
+				next.ServeHTTP(w, r)
+				return
+			}
+			if len(key) > maxKeyLength {
+				respond.Error(w, http.StatusBadRequest, ErrInvalidKey)
+				return
+			}
+
+			body, err := io.ReadAll(r.Body)
+			if err != nil {
+				respond.Error(w, http.StatusBadRequest, err)
+				return
+			}
+			r.Body = io.NopCloser(bytes.NewReader(body))
+
+			log := middleware.Logger(r.Context(), s.log)
+			fingerprint := requestFingerprint(body)
+
+			record, reserved, err := s.Reserve(r.Context(), key, r.Method, r.URL.Path, fingerprint)
+			if err != nil {
+				log.Errorw("Could not reserve idempotency key", "error", err)
+				respond.Error(w, http.StatusInternalServerError, middleware.ErrInternal)
+				return
+			}
+
+			if !reserved {
+				replay(w, record, r, fingerprint)
+				return
+			}
+
+			// The key is stored and released after the client went away, so a retry finds the response.
+			ctx := context.WithoutCancel(r.Context())
+			rec := &recorder{ResponseWriter: w, status: http.StatusOK}
+			completed := false
+			defer func() {
+				if completed {
+					return
+				}
+				if err := s.Release(ctx, key); err != nil {
+					log.Errorw("Could not release idempotency key", "error", err)
+				}
+			}()
+
+			next.ServeHTTP(rec, r)
+
+			if rec.status >= http.StatusInternalServerError {
+				return
+			}
+
+			if err := s.Complete(ctx, key, rec.status, rec.header, rec.body.Bytes()); err != nil {
+				log.Errorw("Could not store idempotent response", "error", err)
+				return
+			}
+			completed = true
+		})
+	}
+}
+
+// Replays the stored response, or rejects the request when it does not match or is still in progress.
+func replay(w http.ResponseWriter, record Record, r *http.Request, fingerprint string) {
+	if record.Method != r.Method || record.Path != r.URL.Path || record.Fingerprint != fingerprint {
+		respond.Error(w, http.StatusUnprocessableEntity, ErrKeyMismatch)
+		return
+	}
+
+	if !record.Completed() {
+		w.Header().Set("Retry-After", "1")
+		respond.Error(w, http.StatusConflict, ErrInProgress)
+		return
+	}
+
+	status, header, body, err := record.Response()
+	if err != nil {
+		respond.Error(w, http.StatusInternalServerError, middleware.ErrInternal)
+		return
+	}
+
+	for k, v := range header {
+		w.Header()[k] = v
+	}
+	w.Header().Set(middleware.ReplayedHeader, "true")
+	w.WriteHeader(status)
+	w.Write(body)
+}
+
+func requestFingerprint(body []byte) string {
+	sum := sha256.Sum256(body)
+	return hex.EncodeToString(sum[:])
+}
+
+// Records the response while writing it to the client.
+type recorder struct {
+	http.ResponseWriter
+	status      int
+	header      http.Header
+	body        bytes.Buffer
+	wroteHeader bool
+}
+
+func (w *recorder) WriteHeader(code int) {
+	if w.wroteHeader {
+		return
+	}
+	w.wroteHeader = true
+
+	w.status = code
+	w.header = w.ResponseWriter.Header().Clone()
+	// The request ID belongs to the request, a replay returns the ID of the retry.
+	w.header.Del(middleware.RequestIDHeader)
+
+	w.ResponseWriter.WriteHeader(code)
+}
+
+func (w *recorder) Write(b []byte) (int, error) {
+	if !w.wroteHeader {
+		w.WriteHeader(http.StatusOK)
+	}
+
+	w.body.Write(b)
+	return w.ResponseWriter.Write(b)
+}
+
+// Unwrap allows http.ResponseController to reach the underlying writer.
+func (w *recorder) Unwrap() http.ResponseWriter {
+	return w.ResponseWriter
+}
@@ -0,0 +1,196 @@
+package idempotency
+
+import (
+	"context"
+	"database/sql"
+	"encoding/json"
+	"errors"
+	"net/http"
+	"time"
+
+	gosql "gitlab.com/btcdirect-api/bootstrap-go-service/internal/sql"
+	"go.uber.org/zap"
+)
+
+const (
+	queryTimeout = 2 * time.Second
+	// Default time a response is replayed for retries of the request.
+	DefaultTTL = 24 * time.Hour
+)
+
+var ErrNotFound = errors.New("idempotency key not found")
+
+// Record is the stored request of an idempotency key, with its response once the request completed.
+type Record struct {
+	Key         string         `db:"idempotency_key"`
+	Method      string         `db:"method"`
+	Path        string         `db:"path"`
+	Fingerprint string         `db:"fingerprint"`
+	Status      sql.NullInt64  `db:"status"`
+	Header      sql.NullString `db:"header"`
+	Body        []byte         `db:"body"`
+	CreatedAt   time.Time      `db:"created_at"`
+	ExpiresAt   time.Time      `db:"expires_at"`
+}
+
+// Completed returns true when the response of the request is stored.
+func (r Record) Completed() bool {
+	return r.Status.Valid
+}
+
+// Response returns the stored status, headers and body.
+func (r Record) Response() (int, http.Header, []byte, error) {
+	header := http.Header{}
+	if r.Header.Valid {
+		if err := json.Unmarshal([]byte(r.Header.String), &header); err != nil {
+			return 0, nil, nil, err
+		}
+	}
+
+	return int(r.Status.Int64), header, r.Body, nil
+}
+
+// Store persists the idempotency keys and their responses in the idempotency_keys table.
+type Store struct {
+	conn gosql.DBConnection
+	ttl  time.Duration
+	log  *zap.SugaredLogger
+}
+
+// Creates a store that keeps the responses for the TTL, DefaultTTL when zero.
+func NewStore(conn gosql.DBConnection, ttl time.Duration, log *zap.SugaredLogger) *Store {
+	if ttl == 0 {
+		ttl = DefaultTTL
+	}
+
+	return &Store{
+		conn: conn,
+		ttl:  ttl,
+		log:  log.With("component", "idempotency"),
+	}
+}
+
+// Reserve stores the key for the request, so the request is handled once.
+// When the key is already stored, false is returned with the stored record. An expired key is reserved again.
+func (s *Store) Reserve(ctx context.Context, key, method, path, fingerprint string) (Record, bool, error) {
+	ctx, cancel := context.WithTimeout(ctx, queryTimeout)
+	defer cancel()
+
+	now := time.Now().UTC()
+	db := s.conn.DB(true)
+
+	query := "INSERT IGNORE INTO idempotency_keys (idempotency_key, method, path, fingerprint, created_at, expires_at) VALUES (?, ?, ?, ?, ?, ?)"
+	if gosql.IsPostgres(db.DriverName()) {
+		query = "INSERT INTO idempotency_keys (idempotency_key, method, path, fingerprint, created_at, expires_at) VALUES (?, ?, ?, ?, ?, ?) ON CONFLICT DO NOTHING"
+	}
+
+	// The second attempt follows the removal of an expired key.
+	for attempt := 0; attempt < 2; attempt++ {
+		res, err := db.ExecContext(ctx, db.Rebind(query), key, method, path, fingerprint, now, now.Add(s.ttl))
+		if err != nil {
+			return Record{}, false, err
+		}
+		if n, err := res.RowsAffected(); err != nil || n > 0 {
+			return Record{}, err == nil, err
+		}
+
+		record, err := s.Get(ctx, key)
+		if errors.Is(err, ErrNotFound) {
+			continue
+		}
+		if err != nil || record.ExpiresAt.After(now) {
+			return record, false, err
+		}
+
+		_, err = db.ExecContext(ctx, db.Rebind("DELETE FROM idempotency_keys WHERE idempotency_key = ? AND expires_at <= ?"), key, now)
+		if err != nil {
+			return Record{}, false, err
+		}
+	}
+
+	record, err := s.Get(ctx, key)
+	return record, false, err
+}
+
+// Complete stores the response of the request of the key.
+func (s *Store) Complete(ctx context.Context, key string, status int, header http.Header, body []byte) error {
+	ctx, cancel := context.WithTimeout(ctx, queryTimeout)
+	defer cancel()
+
+	h, err := json.Marshal(header)
+	if err != nil {
+		return err
+	}
+
+	db := s.conn.DB(true)
+	_, err = db.ExecContext(ctx,
+		db.Rebind("UPDATE idempotency_keys SET status = ?, header = ?, body = ? WHERE idempotency_key = ?"),
+		status, string(h), body, key,
+	)
+
+	return err
+}
+
+// Release removes the key, so a retry of the request is handled again, e.g. after a server error.
+func (s *Store) Release(ctx context.Context, key string) error {
+	ctx, cancel := context.WithTimeout(ctx, queryTimeout)
+	defer cancel()
+
+	db := s.conn.DB(true)
+	_, err := db.ExecContext(ctx, db.Rebind("DELETE FROM idempotency_keys WHERE idempotency_key = ?"), key)
+
+	return err
+}
+
+// Get retrieves the record of the key, ErrNotFound is returned when it does not exist.
+func (s *Store) Get(ctx context.Context, key string) (Record, error) {
+	ctx, cancel := context.WithTimeout(ctx, queryTimeout)
+	defer cancel()
+
+	var record Record
+	db := s.conn.DB(true)
+	err := db.GetContext(ctx, &record, db.Rebind(
+		"SELECT idempotency_key, method, path, fingerprint, status, header, body, created_at, expires_at FROM idempotency_keys WHERE idempotency_key = ?",
+	), key)
+	if errors.Is(err, sql.ErrNoRows) {
+		return Record{}, ErrNotFound
+	}
+
+	return record, err
+}
+
+// Purge removes the expired keys and returns the number of removed keys.
+func (s *Store) Purge(ctx context.Context) (int64, error) {
+	ctx, cancel := context.WithTimeout(ctx, queryTimeout)
+	defer cancel()
+
+	db := s.conn.DB(true)
+	res, err := db.ExecContext(ctx, db.Rebind("DELETE FROM idempotency_keys WHERE expires_at <= ?"), time.Now().UTC())
+	if err != nil {
+		return 0, err
+	}
+
+	return res.RowsAffected()
+}
+
+// PurgeEvery removes the expired keys every interval until the context is done.
+func (s *Store) PurgeEvery(ctx context.Context, interval time.Duration) {
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+			n, err := s.Purge(ctx)
+			if err != nil {
+				s.log.Warnw("Could not purge expired idempotency keys", "error", err)
+				continue
+			}
+			if n > 0 {
+				s.log.Debugw("Purged expired idempotency keys", "count", n)
+			}
+		}
+	}
+}
@@ -0,0 +1,23 @@
+package messenger
+
+import "context"
+
+type handleMessage func(context.Context, adapterMessage) error
+
+type adapterMessage struct {
+	Queue      string
+	Identifier string
+	Body       string
+}
+
+// Adapters that can publish without blocking implement this interface.
+// Other adapters dispatch asynchronous messages from a goroutine.
+type asyncAdapter interface {
+	DispatchAsync(adapterMessage, func(error))
+}
+
+// The adapter interface is used to communicate with the message broker.
+type adapter interface {
+	Dispatch(adapterMessage) error
+	Subscribe(string, handleMessage, lease, context.Context) error
+}
@@ -0,0 +1,146 @@
+package messenger
+
+import (
+	"context"
+	"errors"
+	"sort"
+	"sync"
+	"time"
+
+	"gitlab.com/btcdirect-api/bootstrap-go-service/internal/metrics"
+	"go.uber.org/zap"
+)
+
+var (
+	backlogMessages     = metrics.NewGauge("messenger_backlog_messages")
+	backlogOldestAge    = metrics.NewGauge("messenger_backlog_oldest_message_age_seconds")
+	backlogSampleErrors = metrics.NewCounter("messenger_backlog_sample_errors")
+)
+
+var ErrBacklogUnsupported = errors.New("backlog is not supported by the adapter")
+
+// QueueBacklog is the last sampled backlog of a subscribed or dispatched queue.
+type QueueBacklog struct {
+	Queue            string        `json:"queue"`
+	Messages         int64         `json:"messages"`
+	OldestMessageAge time.Duration `json:"oldestMessageAge"`
+	SampledAt        time.Time     `json:"sampledAt"`
+	Error            string        `json:"error,omitempty"`
+}
+
+type backlog struct {
+	Messages         int64
+	OldestMessageAge time.Duration
+}
+
+// Adapters that can report the backlog of a queue implement this interface.
+type backlogAdapter interface {
+	Backlog(ctx context.Context, queue string) (backlog, error)
+}
+
+// The backlog sampler periodically samples the backlog of all subscribed queues.
+// The samples are published as metrics and kept for reporting.
+type backlogSampler struct {
+	adapter  adapter
+	interval time.Duration
+	log      *zap.SugaredLogger
+	queues   map[string]QueueBacklog
+	sync.Mutex
+}
+
+func newBacklogSampler(a adapter, interval time.Duration, log *zap.SugaredLogger) *backlogSampler {
+	return &backlogSampler{
+		adapter:  a,
+		interval: interval,
+		log:      log,
+		queues:   make(map[string]QueueBacklog),
+	}
+}
+
+// Add a queue to be sampled.
+func (b *backlogSampler) Add(queue string) {
+	b.Lock()
+	defer b.Unlock()
+
+	if _, ok := b.queues[queue]; !ok {
+		b.queues[queue] = QueueBacklog{Queue: queue}
+	}
+}
+
+// Backlogs returns the last samples of all queues, ordered by queue name.
+func (b *backlogSampler) Backlogs() []QueueBacklog {
+	b.Lock()
+	defer b.Unlock()
+
+	backlogs := make([]QueueBacklog, 0, len(b.queues))
+	for _, q := range b.queues {
+		backlogs = append(backlogs, q)
+	}
+
+	sort.Slice(backlogs, func(i, j int) bool {
+		return backlogs[i].Queue < backlogs[j].Queue
+	})
+
+	return backlogs
+}
+
+// Backlog returns the last sample of a queue.
+// False is returned when the queue has not been sampled successfully yet.
+func (b *backlogSampler) Backlog(queue string) (QueueBacklog, bool) {
+	b.Lock()
+	defer b.Unlock()
+
+	q, ok := b.queues[queue]
+	if !ok || q.SampledAt.IsZero() || q.Error != "" {
+		return QueueBacklog{}, false
+	}
+
+	return q, true
+}
+
+// Run samples the backlog of all queues every interval.
+// This is a blocking method and will return when the context is cancelled.
+func (b *backlogSampler) Run(ctx context.Context) {
+	ba, ok := b.adapter.(backlogAdapter)
+	if !ok {
+		b.log.Warn(ErrBacklogUnsupported.Error())
+		return
+	}
+
+	ticker := time.NewTicker(b.interval)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+			b.sample(ctx, ba)
+		}
+	}
+}
+
+func (b *backlogSampler) sample(ctx context.Context, ba backlogAdapter) {
+	for _, q := range b.Backlogs() {
+		ctx, cancel := context.WithTimeout(ctx, b.interval)
+		bl, err := ba.Backlog(ctx, q.Queue)
+		cancel()
+
+		q.SampledAt = time.Now()
+		if err != nil {
+			b.log.Warnw("Error sampling queue backlog", "queue", q.Queue, "error", err)
+			backlogSampleErrors.Inc(q.Queue)
+			q.Error = err.Error()
+		} else {
+			backlogMessages.Set(q.Queue, float64(bl.Messages))
+			backlogOldestAge.Set(q.Queue, bl.OldestMessageAge.Seconds())
+			q.Messages = bl.Messages
+			q.OldestMessageAge = bl.OldestMessageAge
+			q.Error = ""
+		}
+
+		b.Lock()
+		b.queues[q.Queue] = q
+		b.Unlock()
+	}
+}
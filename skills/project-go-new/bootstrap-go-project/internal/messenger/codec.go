@@ -0,0 +1,314 @@
+package messenger
+
+import (
+	"encoding/binary"
+	"encoding/json"
+	"errors"
+	"fmt"
+
+	"google.golang.org/protobuf/encoding/protowire"
+)
+
+// Pub/Sub attributes set on every published message, so consumers can decode any codec.
+const (
+	codecAttribute = "codec"
+	typeAttribute  = "type"
+)
+
+var ErrUnknownCodec = errors.New("unknown codec")
+
+// Envelope is the identifier and body of a message on the wire.
+type Envelope struct {
+	Type string
+	Body string
+}
+
+// Codec encodes the envelope of Pub/Sub messages.
+//
+// Published messages carry the name of their codec as attribute and consumers decode every known codec,
+// messages without the attribute are decoded as JSON. This allows migrating the wire format gradually:
+// first deploy the consumers, then switch the codec of the producers.
+type Codec interface {
+	// Name of the codec in the codec attribute.
+	Name() string
+	Encode(Envelope) ([]byte, error)
+	// Decode the envelope, the attributes of the message are given for codecs that store headers in them.
+	Decode(data []byte, attributes map[string]string) (Envelope, error)
+}
+
+var (
+	// JSONCodec encodes the envelope as {"headers":{"type":"..."},"body":"..."}, the default.
+	JSONCodec Codec = jsonCodec{}
+	// ProtobufCodec encodes the envelope as protobuf message { string type = 1; string body = 2; }.
+	ProtobufCodec Codec = protobufCodec{}
+	// MsgpackCodec encodes the envelope as MessagePack map {"type": "...", "body": "..."}.
+	MsgpackCodec Codec = msgpackCodec{}
+	// RawCodec sends the body as data and the type as attribute, for consumers that do not unwrap envelopes.
+	RawCodec Codec = rawCodec{}
+)
+
+var codecs = map[string]Codec{
+	JSONCodec.Name():     JSONCodec,
+	ProtobufCodec.Name(): ProtobufCodec,
+	MsgpackCodec.Name():  MsgpackCodec,
+	RawCodec.Name():      RawCodec,
+}
+
+// CodecByName returns the built-in codec with the name: json, protobuf, msgpack or raw.
+func CodecByName(name string) (Codec, error) {
+	c, ok := codecs[name]
+	if !ok {
+		return nil, fmt.Errorf("%w: %s", ErrUnknownCodec, name)
+	}
+
+	return c, nil
+}
+
+// Returns the codec the message was encoded with, custom is the configured codec.
+func codecFor(attributes map[string]string, custom Codec) (Codec, error) {
+	name, ok := attributes[codecAttribute]
+	if !ok {
+		// Messages of producers before the codec attribute was introduced.
+		return JSONCodec, nil
+	}
+
+	if custom != nil && custom.Name() == name {
+		return custom, nil
+	}
+
+	if c, ok := codecs[name]; ok {
+		return c, nil
+	}
+
+	return nil, fmt.Errorf("%w: %s", ErrUnknownCodec, name)
+}
+
+type jsonCodec struct{}
+
+func (jsonCodec) Name() string {
+	return "json"
+}
+
+func (jsonCodec) Encode(e Envelope) ([]byte, error) {
+	return json.Marshal(pubsubMessage{
+		Headers: pubsubHeaders{
+			Type: e.Type,
+		},
+		Body: e.Body,
+	})
+}
+
+func (jsonCodec) Decode(data []byte, _ map[string]string) (Envelope, error) {
+	var m pubsubMessage
+	if err := json.Unmarshal(data, &m); err != nil {
+		return Envelope{}, err
+	}
+
+	return Envelope{Type: m.Headers.Type, Body: m.Body}, nil
+}
+
+type protobufCodec struct{}
+
+func (protobufCodec) Name() string {
+	return "protobuf"
+}
+
+func (protobufCodec) Encode(e Envelope) ([]byte, error) {
+	var b []byte
+	b = protowire.AppendTag(b, 1, protowire.BytesType)
+	b = protowire.AppendString(b, e.Type)
+	b = protowire.AppendTag(b, 2, protowire.BytesType)
+	b = protowire.AppendString(b, e.Body)
+
+	return b, nil
+}
+
+func (protobufCodec) Decode(data []byte, _ map[string]string) (Envelope, error) {
+	var e Envelope
+	for len(data) > 0 {
+		num, typ, n := protowire.ConsumeTag(data)
+		if n < 0 {
+			return Envelope{}, protowire.ParseError(n)
+		}
+		data = data[n:]
+
+		if typ == protowire.BytesType && (num == 1 || num == 2) {
+			v, n := protowire.ConsumeString(data)
+			if n < 0 {
+				return Envelope{}, protowire.ParseError(n)
+			}
+			data = data[n:]
+
+			if num == 1 {
+				e.Type = v
+			} else {
+				e.Body = v
+			}
+			continue
+		}
+
+		// Skip fields added by newer producers.
+		n = protowire.ConsumeFieldValue(num, typ, data)
+		if n < 0 {
+			return Envelope{}, protowire.ParseError(n)
+		}
+		data = data[n:]
+	}
+
+	return e, nil
+}
+
+type msgpackCodec struct{}
+
+func (msgpackCodec) Name() string {
+	return "msgpack"
+}
+
+func (msgpackCodec) Encode(e Envelope) ([]byte, error) {
+	// Map with two entries.
+	b := []byte{0x82}
+	b = appendMsgpackString(b, "type")
+	b = appendMsgpackString(b, e.Type)
+	b = appendMsgpackString(b, "body")
+	b = appendMsgpackString(b, e.Body)
+
+	return b, nil
+}
+
+// Decodes a map with string keys and string or binary values, other values are not used by the envelope.
+func (msgpackCodec) Decode(data []byte, _ map[string]string) (Envelope, error) {
+	r := msgpackReader{data: data}
+
+	entries, err := r.mapHeader()
+	if err != nil {
+		return Envelope{}, err
+	}
+
+	var e Envelope
+	for i := 0; i < entries; i++ {
+		key, err := r.str()
+		if err != nil {
+			return Envelope{}, err
+		}
+		value, err := r.str()
+		if err != nil {
+			return Envelope{}, err
+		}
+
+		switch key {
+		case "type":
+			e.Type = value
+		case "body":
+			e.Body = value
+		}
+	}
+
+	return e, nil
+}
+
+func appendMsgpackString(b []byte, s string) []byte {
+	switch n := len(s); {
+	case n < 32:
+		b = append(b, 0xa0|byte(n))
+	case n <= 0xff:
+		b = append(b, 0xd9, byte(n))
+	case n <= 0xffff:
+		b = binary.BigEndian.AppendUint16(append(b, 0xda), uint16(n))
+	default:
+		b = binary.BigEndian.AppendUint32(append(b, 0xdb), uint32(n))
+	}
+
+	return append(b, s...)
+}
+
+var errMsgpack = errors.New("invalid msgpack envelope")
+
+type msgpackReader struct {
+	data []byte
+}
+
+func (r *msgpackReader) next(n int) ([]byte, error) {
+	if len(r.data) < n {
+		return nil, errMsgpack
+	}
+
+	b := r.data[:n]
+	r.data = r.data[n:]
+	return b, nil
+}
+
+func (r *msgpackReader) length(size int) (int, error) {
+	b, err := r.next(size)
+	if err != nil {
+		return 0, err
+	}
+
+	switch size {
+	case 1:
+		return int(b[0]), nil
+	case 2:
+		return int(binary.BigEndian.Uint16(b)), nil
+	default:
+		return int(binary.BigEndian.Uint32(b)), nil
+	}
+}
+
+func (r *msgpackReader) mapHeader() (int, error) {
+	b, err := r.next(1)
+	if err != nil {
+		return 0, err
+	}
+
+	switch {
+	case b[0]&0xf0 == 0x80:
+		return int(b[0] & 0x0f), nil
+	case b[0] == 0xde:
+		return r.length(2)
+	case b[0] == 0xdf:
+		return r.length(4)
+	}
+
+	return 0, errMsgpack
+}
+
+// Reads a string or binary value.
+func (r *msgpackReader) str() (string, error) {
+	b, err := r.next(1)
+	if err != nil {
+		return "", err
+	}
+
+	var n int
+	switch {
+	case b[0]&0xe0 == 0xa0:
+		n = int(b[0] & 0x1f)
+	case b[0] == 0xd9 || b[0] == 0xc4:
+		n, err = r.length(1)
+	case b[0] == 0xda || b[0] == 0xc5:
+		n, err = r.length(2)
+	case b[0] == 0xdb || b[0] == 0xc6:
+		n, err = r.length(4)
+	default:
+		return "", errMsgpack
+	}
+	if err != nil {
+		return "", err
+	}
+
+	s, err := r.next(n)
+	return string(s), err
+}
+
+type rawCodec struct{}
+
+func (rawCodec) Name() string {
+	return "raw"
+}
+
+func (rawCodec) Encode(e Envelope) ([]byte, error) {
+	return []byte(e.Body), nil
+}
+
+func (rawCodec) Decode(data []byte, attributes map[string]string) (Envelope, error) {
+	return Envelope{Type: attributes[typeAttribute], Body: string(data)}, nil
+}
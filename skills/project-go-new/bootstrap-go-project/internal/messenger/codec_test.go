@@ -0,0 +1,117 @@
+package messenger
+
+import (
+	"errors"
+	"strings"
+	"testing"
+
+	"google.golang.org/protobuf/encoding/protowire"
+)
+
+func TestCodecRoundTrip(t *testing.T) {
+	envelopes := []struct {
+		name string
+		e    Envelope
+	}{
+		{name: "empty", e: Envelope{}},
+		{name: "json body", e: Envelope{Type: "order.created", Body: `{"id":"42","amount":"10.50"}`}},
+		{name: "unicode", e: Envelope{Type: "bestelling.geplaatst", Body: `{"naam":"Zoë ✓"}`}},
+		{name: "31 bytes", e: Envelope{Type: "t", Body: strings.Repeat("a", 31)}},
+		{name: "32 bytes", e: Envelope{Type: "t", Body: strings.Repeat("a", 32)}},
+		{name: "256 bytes", e: Envelope{Type: "t", Body: strings.Repeat("a", 256)}},
+		{name: "65536 bytes", e: Envelope{Type: "t", Body: strings.Repeat("a", 65536)}},
+	}
+
+	for _, name := range []string{"json", "protobuf", "msgpack", "raw"} {
+		codec, err := CodecByName(name)
+		if err != nil {
+			t.Fatalf("CodecByName(%q) error = %v", name, err)
+		}
+
+		for _, tt := range envelopes {
+			t.Run(name+"/"+tt.name, func(t *testing.T) {
+				data, err := codec.Encode(tt.e)
+				if err != nil {
+					t.Fatalf("Encode() error = %v", err)
+				}
+
+				// The raw codec sends the type as attribute, like the Pub/Sub adapter.
+				attributes := map[string]string{codecAttribute: name, typeAttribute: tt.e.Type}
+				got, err := codec.Decode(data, attributes)
+				if err != nil {
+					t.Fatalf("Decode() error = %v", err)
+				}
+				if got != tt.e {
+					t.Errorf("Decode(Encode(%.40v)) = %.40v", tt.e, got)
+				}
+			})
+		}
+	}
+}
+
+func TestCodecDecodeInvalid(t *testing.T) {
+	// A newer producer adding field 3 to the protobuf envelope.
+	extended := protowire.AppendTag(nil, 3, protowire.VarintType)
+	extended = protowire.AppendVarint(extended, 7)
+	extended = protowire.AppendTag(extended, 1, protowire.BytesType)
+	extended = protowire.AppendString(extended, "order.created")
+
+	tests := []struct {
+		name    string
+		codec   Codec
+		data    []byte
+		want    Envelope
+		wantErr bool
+	}{
+		{name: "json", codec: JSONCodec, data: []byte(`{"headers":`), wantErr: true},
+		{name: "protobuf truncated", codec: ProtobufCodec, data: []byte{0x0a, 0x05, 'a'}, wantErr: true},
+		{name: "protobuf unknown field", codec: ProtobufCodec, data: extended, want: Envelope{Type: "order.created"}},
+		{name: "msgpack not a map", codec: MsgpackCodec, data: []byte{0xa1, 'a'}, wantErr: true},
+		{name: "msgpack truncated", codec: MsgpackCodec, data: []byte{0x81, 0xa4, 't', 'y'}, wantErr: true},
+		{name: "msgpack binary values", codec: MsgpackCodec, data: []byte{0x81, 0xa4, 'b', 'o', 'd', 'y', 0xc4, 0x02, 'h', 'i'}, want: Envelope{Body: "hi"}},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, err := tt.codec.Decode(tt.data, nil)
+			if (err != nil) != tt.wantErr {
+				t.Fatalf("Decode() error = %v, want error %v", err, tt.wantErr)
+			}
+			if got != tt.want {
+				t.Errorf("Decode() = %v, want %v", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestCodecFor(t *testing.T) {
+	custom := rawCodec{}
+
+	tests := []struct {
+		name       string
+		attributes map[string]string
+		want       string
+		wantErr    error
+	}{
+		{name: "no attribute is json", attributes: map[string]string{}, want: "json"},
+		{name: "built-in", attributes: map[string]string{codecAttribute: "msgpack"}, want: "msgpack"},
+		{name: "configured", attributes: map[string]string{codecAttribute: "raw"}, want: "raw"},
+		{name: "unknown", attributes: map[string]string{codecAttribute: "avro"}, wantErr: ErrUnknownCodec},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			c, err := codecFor(tt.attributes, custom)
+			if !errors.Is(err, tt.wantErr) {
+				t.Fatalf("codecFor() error = %v, want %v", err, tt.wantErr)
+			}
+			if err == nil && c.Name() != tt.want {
+				t.Errorf("codecFor() = %s, want %s", c.Name(), tt.want)
+			}
+		})
+	}
+
+	if _, err := CodecByName("avro"); !errors.Is(err, ErrUnknownCodec) {
+		t.Errorf("CodecByName(avro) error = %v, want ErrUnknownCodec", err)
+	}
+}
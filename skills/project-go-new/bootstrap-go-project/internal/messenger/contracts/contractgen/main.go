@@ -0,0 +1,80 @@
+// Contractgen generates the contract test for the packages that register message examples and consumers.
+//
+// Usage:
+//
+//	//go:generate go run gitlab.com/btcdirect-api/bootstrap-go-service/internal/messenger/contracts/contractgen -o contracts_test.go [-examples dir] <package>...
+//
+// The generated test imports the packages for their registrations and asserts all consumers accept the examples.
+// Examples written by other repositories with contracts.WriteExamples are loaded from the examples directory.
+package main
+
+import (
+	"bytes"
+	"flag"
+	"fmt"
+	"go/format"
+	"log"
+	"os"
+	"path/filepath"
+	"sort"
+	"text/template"
+)
+
+var tmpl = template.Must(template.New("test").Parse(`// Code generated by contractgen. DO NOT EDIT.
+
+package {{ .Package }}
+
+import (
+	"testing"
+
+	"gitlab.com/btcdirect-api/bootstrap-go-service/internal/messenger/contracts"
+{{ range .Imports }}
+	_ "{{ . }}"
+{{- end }}
+)
+
+func TestMessageContracts(t *testing.T) {
+{{- if .Examples }}
+	if err := contracts.LoadExamples({{ printf "%q" .Examples }}); err != nil {
+		t.Fatal(err)
+	}
+{{ end }}
+	contracts.AssertAll(t)
+}
+`))
+
+func main() {
+	output := flag.String("o", "contracts_test.go", "Output file")
+	pkg := flag.String("pkg", "", "Package of the generated test (default: external test package of $GOPACKAGE)")
+	examples := flag.String("examples", "", "Directory with examples of other repositories")
+	flag.Parse()
+
+	if *pkg == "" && os.Getenv("GOPACKAGE") != "" {
+		*pkg = os.Getenv("GOPACKAGE") + "_test"
+	}
+	if *pkg == "" {
+		log.Fatal("contractgen: missing package, run with go generate or set -pkg")
+	}
+
+	imports := flag.Args()
+	sort.Strings(imports)
+
+	var buf bytes.Buffer
+	err := tmpl.Execute(&buf, struct {
+		Package  string
+		Imports  []string
+		Examples string
+	}{*pkg, imports, filepath.ToSlash(*examples)})
+	if err != nil {
+		log.Fatal(err)
+	}
+
+	src, err := format.Source(buf.Bytes())
+	if err != nil {
+		log.Fatal(fmt.Errorf("contractgen: format: %w", err))
+	}
+
+	if err := os.WriteFile(*output, src, 0o644); err != nil {
+		log.Fatal(err)
+	}
+}
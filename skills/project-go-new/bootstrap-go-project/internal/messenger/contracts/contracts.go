@@ -0,0 +1,211 @@
+// Package contracts verifies that the messages dispatched by producers can be handled by their consumers.
+//
+// Producers register an example of every message they dispatch, consumers register their handlers.
+// The generated contract test asserts every consumer can unmarshal the examples for its identifiers,
+// so a producer schema change that breaks a consumer fails CI.
+package contracts
+
+import (
+	"encoding/json"
+	"fmt"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+	"sync"
+	"testing"
+
+	"gitlab.com/btcdirect-api/bootstrap-go-service/internal/messenger"
+)
+
+// Example is the payload of a message as dispatched by its producer.
+type Example struct {
+	Identifier string          `json:"identifier"`
+	Queue      string          `json:"queue"`
+	Payload    json.RawMessage `json:"payload"`
+}
+
+type registry struct {
+	examples  map[string][]Example
+	consumers []messenger.MessageHandler
+	sync.Mutex
+}
+
+var contracts = &registry{examples: make(map[string][]Example)}
+
+// RegisterExample registers an example of a message dispatched by a producer.
+// Register multiple examples for an identifier to cover optional fields.
+func RegisterExample(msg messenger.Message) {
+	payload, err := json.Marshal(msg)
+	if err != nil {
+		panic(fmt.Sprintf("contracts: marshal example %s: %s", msg.Identifier(), err))
+	}
+
+	addExample(Example{
+		Identifier: msg.Identifier(),
+		Queue:      msg.Queue(),
+		Payload:    payload,
+	})
+}
+
+// RegisterConsumer registers a message handler, its message must accept the examples with the same identifier.
+func RegisterConsumer(h messenger.MessageHandler) {
+	contracts.Lock()
+	defer contracts.Unlock()
+
+	contracts.consumers = append(contracts.consumers, h)
+}
+
+// Examples returns the registered examples for an identifier.
+func Examples(identifier string) []Example {
+	contracts.Lock()
+	defer contracts.Unlock()
+
+	return append([]Example(nil), contracts.examples[identifier]...)
+}
+
+// WriteExamples writes the registered examples to a directory, one file per identifier.
+// This allows sharing the examples with consumers in other repositories.
+func WriteExamples(dir string) error {
+	contracts.Lock()
+	defer contracts.Unlock()
+
+	if err := os.MkdirAll(dir, 0o755); err != nil {
+		return err
+	}
+
+	for identifier, examples := range contracts.examples {
+		b, err := json.MarshalIndent(examples, "", "  ")
+		if err != nil {
+			return err
+		}
+
+		if err := os.WriteFile(filepath.Join(dir, identifier+".json"), b, 0o644); err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
+
+// LoadExamples registers the examples written by WriteExamples.
+func LoadExamples(dir string) error {
+	files, err := filepath.Glob(filepath.Join(dir, "*.json"))
+	if err != nil {
+		return err
+	}
+
+	for _, file := range files {
+		b, err := os.ReadFile(file)
+		if err != nil {
+			return err
+		}
+
+		var examples []Example
+		if err := json.Unmarshal(b, &examples); err != nil {
+			return fmt.Errorf("%s: %w", file, err)
+		}
+
+		for _, e := range examples {
+			addExample(e)
+		}
+	}
+
+	return nil
+}
+
+func addExample(e Example) {
+	contracts.Lock()
+	defer contracts.Unlock()
+
+	contracts.examples[e.Identifier] = append(contracts.examples[e.Identifier], e)
+}
+
+// AssertAll asserts all registered consumers accept the examples of their identifiers.
+// Consumers without examples fail, as their producer has no contract.
+func AssertAll(t testing.TB) {
+	t.Helper()
+
+	contracts.Lock()
+	consumers := append([]messenger.MessageHandler(nil), contracts.consumers...)
+	contracts.Unlock()
+
+	if len(consumers) == 0 {
+		t.Skip("contracts: no consumers registered")
+	}
+
+	for _, h := range consumers {
+		AssertConsumes(t, h)
+	}
+}
+
+// AssertConsumes asserts the handler accepts all examples registered for the identifier of its message.
+func AssertConsumes(t testing.TB, h messenger.MessageHandler) {
+	t.Helper()
+
+	identifier := h.Message().Identifier()
+	examples := Examples(identifier)
+	if len(examples) == 0 {
+		t.Errorf("contracts: no examples registered for %s", identifier)
+		return
+	}
+
+	for i, e := range examples {
+		if err := Check(e, h.Message()); err != nil {
+			t.Errorf("contracts: example %d of %s: %s", i, identifier, err)
+		}
+	}
+}
+
+// Check verifies an example can be unmarshalled into the consumer message.
+//
+// The example must be dispatched to the queue of the consumer, and unless the message has a custom
+// JSON unmarshaller, every field the consumer reads must be present in the example.
+func Check(e Example, msg messenger.Message) error {
+	if e.Queue != msg.Queue() {
+		return fmt.Errorf("dispatched to queue %s, consumed from %s", e.Queue, msg.Queue())
+	}
+
+	_, custom := msg.(json.Unmarshaler)
+	empty, err := json.Marshal(msg)
+	if err != nil {
+		return err
+	}
+
+	if err := json.Unmarshal(e.Payload, msg); err != nil {
+		return fmt.Errorf("unmarshal: %w", err)
+	}
+
+	if custom {
+		return nil
+	}
+
+	return checkFields(e.Payload, empty)
+}
+
+// Every field of the empty consumer message must be present in the payload.
+// Fields with omitempty are left out when marshalling the empty consumer message, so they are optional.
+func checkFields(payload, empty []byte) error {
+	var expected, actual map[string]json.RawMessage
+	if err := json.Unmarshal(empty, &expected); err != nil {
+		// Not a JSON object, the unmarshal check is all we can do.
+		return nil
+	}
+	if err := json.Unmarshal(payload, &actual); err != nil {
+		return err
+	}
+
+	var missing []string
+	for field := range expected {
+		if _, ok := actual[field]; !ok {
+			missing = append(missing, field)
+		}
+	}
+
+	if len(missing) > 0 {
+		sort.Strings(missing)
+		return fmt.Errorf("missing fields: %s", strings.Join(missing, ", "))
+	}
+
+	return nil
+}
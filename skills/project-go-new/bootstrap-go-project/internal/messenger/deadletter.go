@@ -0,0 +1,104 @@
+package messenger
+
+import (
+	"context"
+	"errors"
+	"time"
+)
+
+var (
+	ErrDeadLetterNotFound    = errors.New("dead letter not found")
+	ErrDeadLetterUnsupported = errors.New("dead letters are not supported by the adapter")
+)
+
+// DeadLetter is a message that could not be handled and was moved to the dead letter queue.
+type DeadLetter struct {
+	ID               string    `json:"id"`
+	Queue            string    `json:"queue"`
+	Identifier       string    `json:"identifier"`
+	Body             string    `json:"body"`
+	PublishedAt      time.Time `json:"publishedAt,omitempty"`
+	DeliveryAttempts int       `json:"deliveryAttempts,omitempty"`
+}
+
+// DeadLetterQueue allows browsing and handling the messages in the dead letter queue.
+type DeadLetterQueue interface {
+	// List returns up to max messages from the dead letter queue, the messages stay in the queue.
+	List(ctx context.Context, max int) ([]DeadLetter, error)
+	// Get returns a single message from the dead letter queue.
+	Get(ctx context.Context, id string) (DeadLetter, error)
+	// Requeue dispatches the message to its original queue again and removes it from the dead letter queue.
+	Requeue(ctx context.Context, id string) error
+	// Purge removes all messages from the dead letter queue.
+	Purge(ctx context.Context) error
+}
+
+// Adapters that support browsing the dead letter queue implement this interface.
+type deadLetterAdapter interface {
+	DeadLetters(ctx context.Context, queue string, max int) ([]DeadLetter, error)
+	RequeueDeadLetter(ctx context.Context, queue, id string) error
+	PurgeDeadLetters(ctx context.Context, queue string) error
+}
+
+// The maximum number of messages searched for a single dead letter.
+const maxDeadLetterSearch = 1000
+
+type deadLetterQueue struct {
+	adapter adapter
+	queue   string
+}
+
+func (d deadLetterQueue) List(ctx context.Context, max int) ([]DeadLetter, error) {
+	a, err := d.deadLetterAdapter()
+	if err != nil {
+		return nil, err
+	}
+
+	return a.DeadLetters(ctx, d.queue, max)
+}
+
+func (d deadLetterQueue) Get(ctx context.Context, id string) (DeadLetter, error) {
+	letters, err := d.List(ctx, maxDeadLetterSearch)
+	if err != nil {
+		return DeadLetter{}, err
+	}
+
+	for _, l := range letters {
+		if l.ID == id {
+			return l, nil
+		}
+	}
+
+	return DeadLetter{}, ErrDeadLetterNotFound
+}
+
+func (d deadLetterQueue) Requeue(ctx context.Context, id string) error {
+	a, err := d.deadLetterAdapter()
+	if err != nil {
+		return err
+	}
+
+	return a.RequeueDeadLetter(ctx, d.queue, id)
+}
+
+func (d deadLetterQueue) Purge(ctx context.Context) error {
+	a, err := d.deadLetterAdapter()
+	if err != nil {
+		return err
+	}
+
+	return a.PurgeDeadLetters(ctx, d.queue)
+}
+
+func (d deadLetterQueue) deadLetterAdapter() (deadLetterAdapter, error) {
+	if d.queue == "" {
+		return nil, ErrDeadLetterUnsupported
+	}
+
+	a, ok := d.adapter.(deadLetterAdapter)
+	if !ok {
+		return nil, ErrDeadLetterUnsupported
+	}
+
+	return a, nil
+}
@@ -0,0 +1,160 @@
+package messenger
+
+import (
+	"context"
+	"errors"
+	"sync"
+	"time"
+
+	"go.uber.org/zap"
+)
+
+var ErrNotConnected = errors.New("messenger is not connected to the message broker")
+
+// The lazy adapter is used when the message broker is unavailable at startup.
+// It keeps creating the adapter in the background and delegates to it once connected,
+// until then dispatching fails with ErrNotConnected and subscriptions wait for the connection.
+type lazyAdapter struct {
+	sync.RWMutex
+	adapter   adapter
+	connected chan struct{}
+}
+
+func newLazyAdapter() *lazyAdapter {
+	return &lazyAdapter{connected: make(chan struct{})}
+}
+
+// Create the adapter every interval until it succeeds.
+// This is a blocking method and will return when connected or when the context is cancelled.
+func (l *lazyAdapter) connect(ctx context.Context, create func() (adapter, error), interval time.Duration, log *zap.SugaredLogger) {
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+		}
+
+		a, err := create()
+		if err != nil {
+			log.Warnw("Message broker still unavailable", "error", err)
+			continue
+		}
+
+		l.Lock()
+		l.adapter = a
+		l.Unlock()
+		close(l.connected)
+
+		log.Info("Messenger connected to the message broker")
+		return
+	}
+}
+
+// Returns true once the adapter is connected.
+func (l *lazyAdapter) Ready() bool {
+	_, ok := l.current()
+	return ok
+}
+
+func (l *lazyAdapter) current() (adapter, bool) {
+	l.RLock()
+	defer l.RUnlock()
+
+	return l.adapter, l.adapter != nil
+}
+
+func (l *lazyAdapter) Dispatch(msg adapterMessage) error {
+	a, ok := l.current()
+	if !ok {
+		return ErrNotConnected
+	}
+
+	return a.Dispatch(msg)
+}
+
+func (l *lazyAdapter) DispatchAsync(msg adapterMessage, callback func(error)) {
+	a, ok := l.current()
+	if !ok {
+		callback(ErrNotConnected)
+		return
+	}
+
+	if async, ok := a.(asyncAdapter); ok {
+		async.DispatchAsync(msg, callback)
+		return
+	}
+
+	go func() {
+		callback(a.Dispatch(msg))
+	}()
+}
+
+// Subscribe waits for the connection before subscribing to the queue.
+func (l *lazyAdapter) Subscribe(queue string, h handleMessage, ls lease, ctx context.Context) error {
+	select {
+	case <-ctx.Done():
+		return ctx.Err()
+	case <-l.connected:
+	}
+
+	a, _ := l.current()
+	return a.Subscribe(queue, h, ls, ctx)
+}
+
+func (l *lazyAdapter) Backlog(ctx context.Context, queue string) (backlog, error) {
+	a, ok := l.current()
+	if !ok {
+		return backlog{}, ErrNotConnected
+	}
+
+	ba, ok := a.(backlogAdapter)
+	if !ok {
+		return backlog{}, ErrBacklogUnsupported
+	}
+
+	return ba.Backlog(ctx, queue)
+}
+
+func (l *lazyAdapter) DeadLetters(ctx context.Context, queue string, max int) ([]DeadLetter, error) {
+	a, err := l.deadLetterAdapter()
+	if err != nil {
+		return nil, err
+	}
+
+	return a.DeadLetters(ctx, queue, max)
+}
+
+func (l *lazyAdapter) RequeueDeadLetter(ctx context.Context, queue, id string) error {
+	a, err := l.deadLetterAdapter()
+	if err != nil {
+		return err
+	}
+
+	return a.RequeueDeadLetter(ctx, queue, id)
+}
+
+func (l *lazyAdapter) PurgeDeadLetters(ctx context.Context, queue string) error {
+	a, err := l.deadLetterAdapter()
+	if err != nil {
+		return err
+	}
+
+	return a.PurgeDeadLetters(ctx, queue)
+}
+
+func (l *lazyAdapter) deadLetterAdapter() (deadLetterAdapter, error) {
+	a, ok := l.current()
+	if !ok {
+		return nil, ErrNotConnected
+	}
+
+	da, ok := a.(deadLetterAdapter)
+	if !ok {
+		return nil, ErrDeadLetterUnsupported
+	}
+
+	return da, nil
+}
@@ -0,0 +1,413 @@
+package messenger
+
+import (
+	"bufio"
+	"bytes"
+	"context"
+	"encoding/json"
+	"errors"
+	"io"
+	"os"
+	"path/filepath"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/google/uuid"
+	"go.uber.org/zap"
+)
+
+const (
+	defaultFilePollInterval = 500 * time.Millisecond
+	fileMaxDeliveryAttempts = 5
+	fileRedeliveryBackoff   = time.Second
+	fileQueueExtension      = ".jsonl"
+	fileOffsetExtension     = ".offset"
+)
+
+// FileConfig configures the file based adapter.
+// When a directory is set, the messenger will use the file adapter instead of Pub/Sub.
+type FileConfig struct {
+	Directory    string
+	PollInterval time.Duration
+}
+
+type fileAdapter struct {
+	config          FileConfig
+	deadLetterQueue string
+	log             *zap.SugaredLogger
+	sync.Mutex
+}
+
+var ErrMissingDirectory = errors.New("missing directory")
+
+// The file adapter writes dispatched messages to a file per queue and tails these files for subscriptions.
+// This allows running the full message flow locally without the Pub/Sub emulator or network access.
+//
+// Every line in a queue file contains one message in the same envelope as used for Pub/Sub.
+func newFileAdapter(c FileConfig, deadLetterQueue string, log *zap.SugaredLogger) (*fileAdapter, error) {
+	if c.Directory == "" {
+		return nil, ErrMissingDirectory
+	}
+
+	if c.PollInterval == 0 {
+		c.PollInterval = defaultFilePollInterval
+	}
+
+	if err := os.MkdirAll(c.Directory, 0o755); err != nil {
+		return nil, err
+	}
+
+	return &fileAdapter{
+		config:          c,
+		deadLetterQueue: deadLetterQueue,
+		log:             log,
+	}, nil
+}
+
+// Dispatch appends the message to the file of the queue.
+func (f *fileAdapter) Dispatch(msg adapterMessage) error {
+	line, err := json.Marshal(pubsubMessage{
+		Headers: pubsubHeaders{
+			Type: msg.Identifier,
+		},
+		Body: msg.Body,
+	})
+	if err != nil {
+		return err
+	}
+
+	return f.append(msg.Queue, line)
+}
+
+// Subscribe tails the file of the queue and calls the provided handler for every new message.
+// This is a blocking method and will return when the context is cancelled.
+//
+// The position of the subscription is stored next to the queue file, so a restart continues
+// where the previous subscription stopped. A message that fails to be handled is retried and
+// moved to the dead letter queue after the maximum number of delivery attempts.
+//
+// Messages of the file adapter have no ack deadline, the lease is ignored.
+func (f *fileAdapter) Subscribe(queue string, h handleMessage, _ lease, ctx context.Context) error {
+	file, err := os.OpenFile(f.queuePath(queue), os.O_RDONLY|os.O_CREATE, 0o644)
+	if err != nil {
+		return err
+	}
+	defer file.Close()
+
+	offset, err := f.offset(queue)
+	if err != nil {
+		return err
+	}
+
+	if _, err := file.Seek(offset, io.SeekStart); err != nil {
+		return err
+	}
+
+	f.log.Infof("Tailing queue file %s", file.Name())
+
+	reader := bufio.NewReader(file)
+	for {
+		line, err := reader.ReadBytes('\n')
+		if errors.Is(err, io.EOF) {
+			// Keep partially written lines for the next read.
+			if len(line) > 0 {
+				if _, err := file.Seek(-int64(len(line)), io.SeekCurrent); err != nil {
+					return err
+				}
+				reader.Reset(file)
+			}
+
+			select {
+			case <-ctx.Done():
+				return ctx.Err()
+			case <-time.After(f.config.PollInterval):
+			}
+			continue
+		}
+		if err != nil {
+			return err
+		}
+
+		if err := f.receive(queue, line, h, ctx); err != nil {
+			return err
+		}
+
+		offset += int64(len(line))
+		if err := f.storeOffset(queue, offset); err != nil {
+			return err
+		}
+	}
+}
+
+// Handle a single line of the queue file.
+// An error is only returned when the context is cancelled before the message was handled,
+// so the message is received again by the next subscription.
+func (f *fileAdapter) receive(queue string, line []byte, h handleMessage, ctx context.Context) error {
+	f.log.Infow("Received file message", "queue", queue, "data", strings.TrimSpace(string(line)))
+
+	var m pubsubMessage
+	if err := json.Unmarshal(line, &m); err != nil {
+		f.log.Errorw("Skipping malformed file message", "queue", queue, "error", err)
+		return nil
+	}
+
+	for attempt := 1; attempt <= fileMaxDeliveryAttempts; attempt++ {
+		err := h(ctx, adapterMessage{
+			Queue:      queue,
+			Identifier: m.Headers.Type,
+			Body:       m.Body,
+		})
+		if err == nil {
+			return nil
+		}
+
+		select {
+		case <-ctx.Done():
+			return ctx.Err()
+		case <-time.After(fileRedeliveryBackoff):
+		}
+	}
+
+	if f.deadLetterQueue == "" {
+		return nil
+	}
+
+	f.log.Infow("Moving file message to dead letter queue", "queue", queue, "deadLetterQueue", f.deadLetterQueue)
+	m.Headers.Source = queue
+	m.Headers.ID = uuid.NewString()
+	deadLetter, err := json.Marshal(m)
+	if err == nil {
+		err = f.append(f.deadLetterQueue, deadLetter)
+	}
+	if err != nil {
+		f.log.Errorw("Error moving file message to dead letter queue", "queue", queue, "error", err)
+	}
+
+	return nil
+}
+
+// Append a single line to the file of the queue.
+//
+// This method is thread-safe.
+func (f *fileAdapter) append(queue string, line []byte) error {
+	f.Lock()
+	defer f.Unlock()
+
+	return f.appendLine(queue, line)
+}
+
+// Append a single line to the file of the queue, the lock must be held.
+func (f *fileAdapter) appendLine(queue string, line []byte) error {
+	file, err := os.OpenFile(f.queuePath(queue), os.O_WRONLY|os.O_APPEND|os.O_CREATE, 0o644)
+	if err != nil {
+		return err
+	}
+	defer file.Close()
+
+	if len(line) == 0 || line[len(line)-1] != '\n' {
+		line = append(line, '\n')
+	}
+
+	_, err = file.Write(line)
+	return err
+}
+
+// Backlog counts the messages in the queue file after the position of the subscription.
+// The file adapter does not keep track of the age of messages.
+func (f *fileAdapter) Backlog(ctx context.Context, queue string) (backlog, error) {
+	offset, err := f.offset(queue)
+	if err != nil {
+		return backlog{}, err
+	}
+
+	file, err := os.Open(f.queuePath(queue))
+	if errors.Is(err, os.ErrNotExist) {
+		return backlog{}, nil
+	}
+	if err != nil {
+		return backlog{}, err
+	}
+	defer file.Close()
+
+	if _, err := file.Seek(offset, io.SeekStart); err != nil {
+		return backlog{}, err
+	}
+
+	var b backlog
+	reader := bufio.NewReader(file)
+	for {
+		if _, err := reader.ReadBytes('\n'); err != nil {
+			if errors.Is(err, io.EOF) {
+				return b, nil
+			}
+			return backlog{}, err
+		}
+		b.Messages++
+	}
+}
+
+func (f *fileAdapter) offset(queue string) (int64, error) {
+	b, err := os.ReadFile(f.offsetPath(queue))
+	if errors.Is(err, os.ErrNotExist) {
+		return 0, nil
+	}
+	if err != nil {
+		return 0, err
+	}
+
+	return strconv.ParseInt(strings.TrimSpace(string(b)), 10, 64)
+}
+
+func (f *fileAdapter) storeOffset(queue string, offset int64) error {
+	return os.WriteFile(f.offsetPath(queue), []byte(strconv.FormatInt(offset, 10)), 0o644)
+}
+
+func (f *fileAdapter) queuePath(queue string) string {
+	return filepath.Join(f.config.Directory, queue+fileQueueExtension)
+}
+
+func (f *fileAdapter) offsetPath(queue string) string {
+	return filepath.Join(f.config.Directory, queue+fileOffsetExtension)
+}
+
+// DeadLetters reads up to max messages from the dead letter queue file.
+// The ID of a dead letter is stored in its line, dead letters written without one use their position in the file.
+func (f *fileAdapter) DeadLetters(ctx context.Context, queue string, max int) ([]DeadLetter, error) {
+	f.Lock()
+	lines, err := f.readLines(queue)
+	f.Unlock()
+	if err != nil {
+		return nil, err
+	}
+
+	var letters []DeadLetter
+	for _, l := range lines {
+		if len(letters) >= max {
+			break
+		}
+
+		var m pubsubMessage
+		if err := json.Unmarshal(l.data, &m); err != nil {
+			continue
+		}
+
+		letters = append(letters, DeadLetter{
+			ID:         l.id(m),
+			Queue:      m.Headers.Source,
+			Identifier: m.Headers.Type,
+			Body:       m.Body,
+		})
+	}
+
+	return letters, nil
+}
+
+// RequeueDeadLetter appends the dead letter to its source queue and removes it from the dead letter queue file.
+// The lock is held while the file is rewritten, so dead letters appended meanwhile are not lost.
+func (f *fileAdapter) RequeueDeadLetter(ctx context.Context, queue, id string) error {
+	f.Lock()
+	defer f.Unlock()
+
+	lines, err := f.readLines(queue)
+	if err != nil {
+		return err
+	}
+
+	var remaining []byte
+	var requeued *pubsubMessage
+	for _, l := range lines {
+		var m pubsubMessage
+		if requeued != nil || json.Unmarshal(l.data, &m) != nil || l.id(m) != id {
+			remaining = append(remaining, l.data...)
+			continue
+		}
+
+		requeued = &m
+	}
+
+	if requeued == nil {
+		return ErrDeadLetterNotFound
+	}
+
+	source := requeued.Headers.Source
+	requeued.Headers.Source, requeued.Headers.ID = "", ""
+	line, err := json.Marshal(requeued)
+	if err != nil {
+		return err
+	}
+
+	if err := f.appendLine(source, line); err != nil {
+		return err
+	}
+
+	f.log.Infow("Requeued dead letter", "id", id, "queue", source)
+
+	return f.replace(queue, remaining)
+}
+
+// PurgeDeadLetters empties the dead letter queue file.
+func (f *fileAdapter) PurgeDeadLetters(ctx context.Context, queue string) error {
+	f.log.Infof("Purging dead letter queue file %s", f.queuePath(queue))
+	return f.rewrite(queue, nil)
+}
+
+type fileLine struct {
+	offset int64
+	data   []byte
+}
+
+// Returns the ID of the dead letter of the line.
+func (l fileLine) id(m pubsubMessage) string {
+	if m.Headers.ID != "" {
+		return m.Headers.ID
+	}
+
+	return strconv.FormatInt(l.offset, 10)
+}
+
+// Read all lines of the queue file with their offset, the lock must be held.
+func (f *fileAdapter) readLines(queue string) ([]fileLine, error) {
+	b, err := os.ReadFile(f.queuePath(queue))
+	if errors.Is(err, os.ErrNotExist) {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	var lines []fileLine
+	var offset int64
+	for len(b) > 0 {
+		i := bytes.IndexByte(b, '\n')
+		if i < 0 {
+			break
+		}
+
+		lines = append(lines, fileLine{offset: offset, data: b[:i+1]})
+		offset += int64(i + 1)
+		b = b[i+1:]
+	}
+
+	return lines, nil
+}
+
+// Replace the contents of the queue file and reset the position of its subscription.
+//
+// This method is thread-safe.
+func (f *fileAdapter) rewrite(queue string, data []byte) error {
+	f.Lock()
+	defer f.Unlock()
+
+	return f.replace(queue, data)
+}
+
+// Replace the contents of the queue file and reset the position of its subscription, the lock must be held.
+func (f *fileAdapter) replace(queue string, data []byte) error {
+	if err := os.WriteFile(f.queuePath(queue), data, 0o644); err != nil {
+		return err
+	}
+
+	return f.storeOffset(queue, 0)
+}
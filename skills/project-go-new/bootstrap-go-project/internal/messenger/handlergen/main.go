@@ -0,0 +1,301 @@
+// Handlergen generates the message handler boilerplate for annotated message structs.
+//
+// Annotate a message struct with its queue and identifier:
+//
+//	//messenger:message queue=bootstrap-go-service.orders identifier=order.created
+//	type OrderCreated struct {
+//		OrderID string
+//	}
+//
+// And add to the file:
+//
+//	//go:generate go run gitlab.com/btcdirect-api/bootstrap-go-service/internal/messenger/handlergen
+//
+// For every annotated struct, the generated <file>_messenger.go contains the Queue and Identifier methods
+// and a handler that passes the typed message to a function. Fields without a JSON tag get a camel case tag
+// in the source file, and a test skeleton is written to <file>_messenger_test.go unless it already exists.
+package main
+
+import (
+	"bytes"
+	"flag"
+	"fmt"
+	"go/ast"
+	"go/format"
+	"go/parser"
+	"go/token"
+	"log"
+	"os"
+	"strings"
+	"text/template"
+	"unicode"
+)
+
+const annotation = "//messenger:message"
+
+type message struct {
+	Name       string
+	Queue      string
+	Identifier string
+}
+
+var funcs = template.FuncMap{
+	"lower": camelCase,
+}
+
+var code = template.Must(template.New("code").Funcs(funcs).Parse(`// Code generated by handlergen. DO NOT EDIT.
+
+package {{ .Package }}
+
+import (
+	"context"
+
+	"gitlab.com/btcdirect-api/bootstrap-go-service/internal/messenger"
+)
+{{ range .Messages }}
+// Queue implements messenger.Message
+func (m *{{ .Name }}) Queue() string {
+	return {{ printf "%q" .Queue }}
+}
+
+// Identifier implements messenger.Message
+func (m *{{ .Name }}) Identifier() string {
+	return {{ printf "%q" .Identifier }}
+}
+
+type {{ .Name }}HandlerFunc func(context.Context, *{{ .Name }}) error
+
+type {{ .Name | lower }}Handler struct {
+	handle {{ .Name }}HandlerFunc
+}
+
+// New{{ .Name }}Handler creates a message handler that passes {{ .Name }} messages to the function.
+func New{{ .Name }}Handler(handle {{ .Name }}HandlerFunc) messenger.MessageHandler {
+	return &{{ .Name | lower }}Handler{handle: handle}
+}
+
+// Message implements messenger.MessageHandler
+func (h *{{ .Name | lower }}Handler) Message() messenger.Message {
+	return &{{ .Name }}{}
+}
+
+// Handle implements messenger.MessageHandler
+func (h *{{ .Name | lower }}Handler) Handle(m messenger.Message) error {
+	return h.HandleContext(context.Background(), m)
+}
+
+// HandleContext implements messenger.ContextMessageHandler
+func (h *{{ .Name | lower }}Handler) HandleContext(ctx context.Context, m messenger.Message) error {
+	return h.handle(ctx, m.(*{{ .Name }}))
+}
+{{ end }}`))
+
+var test = template.Must(template.New("test").Parse(`package {{ .Package }}
+
+import (
+	"context"
+	"encoding/json"
+	"testing"
+)
+{{ range .Messages }}
+func Test{{ .Name }}Handler(t *testing.T) {
+	// TODO: Fill the message with a representative payload.
+	want := &{{ .Name }}{}
+
+	body, err := json.Marshal(want)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	h := New{{ .Name }}Handler(func(ctx context.Context, got *{{ .Name }}) error {
+		// TODO: Assert the message is handled.
+		return nil
+	})
+
+	msg := h.Message()
+	if err := json.Unmarshal(body, msg); err != nil {
+		t.Fatal(err)
+	}
+
+	if err := h.Handle(msg); err != nil {
+		t.Fatal(err)
+	}
+}
+{{ end }}`))
+
+func main() {
+	file := flag.String("file", os.Getenv("GOFILE"), "Source file with the annotated message structs")
+	tags := flag.Bool("json", true, "Add JSON tags to fields without one")
+	skeleton := flag.Bool("test", true, "Write a test skeleton when it does not exist")
+	flag.Parse()
+
+	if *file == "" {
+		log.Fatal("handlergen: missing file, run with go generate or set -file")
+	}
+
+	fset := token.NewFileSet()
+	f, err := parser.ParseFile(fset, *file, nil, parser.ParseComments)
+	if err != nil {
+		log.Fatal(err)
+	}
+
+	messages, tagged, err := messages(f)
+	if err != nil {
+		log.Fatalf("handlergen: %s", err)
+	}
+	if len(messages) == 0 {
+		log.Fatalf("handlergen: no %s annotations in %s", annotation, *file)
+	}
+
+	if *tags && tagged {
+		var buf bytes.Buffer
+		if err := format.Node(&buf, fset, f); err != nil {
+			log.Fatal(err)
+		}
+		write(*file, buf.Bytes())
+	}
+
+	base := strings.TrimSuffix(*file, ".go")
+	data := struct {
+		Package  string
+		Messages []message
+	}{f.Name.Name, messages}
+
+	write(base+"_messenger.go", render(code, data))
+
+	if _, err := os.Stat(base + "_messenger_test.go"); *skeleton && os.IsNotExist(err) {
+		write(base+"_messenger_test.go", render(test, data))
+	}
+}
+
+// Collect the annotated structs and add missing JSON tags to their fields.
+// Returns whether a tag was added.
+func messages(f *ast.File) ([]message, bool, error) {
+	var messages []message
+	var tagged bool
+
+	for _, decl := range f.Decls {
+		gen, ok := decl.(*ast.GenDecl)
+		if !ok || gen.Tok != token.TYPE || gen.Doc == nil {
+			continue
+		}
+
+		for _, c := range gen.Doc.List {
+			if !strings.HasPrefix(c.Text, annotation) {
+				continue
+			}
+
+			spec := gen.Specs[0].(*ast.TypeSpec)
+			st, ok := spec.Type.(*ast.StructType)
+			if !ok {
+				return nil, false, fmt.Errorf("%s is not a struct", spec.Name.Name)
+			}
+
+			m, err := parseAnnotation(spec.Name.Name, strings.TrimPrefix(c.Text, annotation))
+			if err != nil {
+				return nil, false, err
+			}
+			messages = append(messages, m)
+
+			for _, field := range st.Fields.List {
+				if addTag(field) {
+					tagged = true
+				}
+			}
+		}
+	}
+
+	return messages, tagged, nil
+}
+
+func parseAnnotation(name, args string) (message, error) {
+	m := message{Name: name}
+	for _, arg := range strings.Fields(args) {
+		key, value, _ := strings.Cut(arg, "=")
+		switch key {
+		case "queue":
+			m.Queue = value
+		case "identifier":
+			m.Identifier = value
+		default:
+			return m, fmt.Errorf("%s: unknown annotation argument %s", name, key)
+		}
+	}
+
+	if m.Queue == "" || m.Identifier == "" {
+		return m, fmt.Errorf("%s: queue and identifier are required", name)
+	}
+
+	return m, nil
+}
+
+// Add a camel case JSON tag to an exported field without one.
+func addTag(field *ast.Field) bool {
+	if len(field.Names) != 1 || !field.Names[0].IsExported() {
+		return false
+	}
+
+	tag := ""
+	if field.Tag != nil {
+		tag = strings.Trim(field.Tag.Value, "`")
+		if strings.Contains(tag, `json:"`) {
+			return false
+		}
+		tag += " "
+	}
+
+	field.Tag = &ast.BasicLit{
+		Kind:  token.STRING,
+		Value: "`" + tag + `json:"` + camelCase(field.Names[0].Name) + `"` + "`",
+	}
+
+	return true
+}
+
+// Converts a Go name to lower camel case, acronyms are treated as words: OrderID becomes orderId.
+func camelCase(name string) string {
+	runes := []rune(name)
+
+	var words []string
+	start := 0
+	for i := 1; i < len(runes); i++ {
+		upper := unicode.IsUpper(runes[i])
+		// A word starts at an upper case letter after a lower case letter,
+		// or at the last upper case letter of an acronym followed by a lower case letter.
+		if upper && (unicode.IsLower(runes[i-1]) || i+1 < len(runes) && unicode.IsLower(runes[i+1]) && unicode.IsUpper(runes[i-1])) {
+			words = append(words, string(runes[start:i]))
+			start = i
+		}
+	}
+	words = append(words, string(runes[start:]))
+
+	for i, w := range words {
+		w = strings.ToLower(w)
+		if i > 0 {
+			w = strings.ToUpper(w[:1]) + w[1:]
+		}
+		words[i] = w
+	}
+
+	return strings.Join(words, "")
+}
+
+func render(t *template.Template, data interface{}) []byte {
+	var buf bytes.Buffer
+	if err := t.Execute(&buf, data); err != nil {
+		log.Fatal(err)
+	}
+
+	src, err := format.Source(buf.Bytes())
+	if err != nil {
+		log.Fatalf("handlergen: format: %s", err)
+	}
+
+	return src
+}
+
+func write(file string, src []byte) {
+	if err := os.WriteFile(file, src, 0o644); err != nil {
+		log.Fatal(err)
+	}
+}
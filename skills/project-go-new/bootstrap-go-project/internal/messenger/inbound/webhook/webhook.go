@@ -4,7 +4,7 @@ import (
 	"context"
 	"encoding/json"
 
-	"gitlab.com/btcdirect-api/go-modules/messenger"
+	"gitlab.com/btcdirect-api/bootstrap-go-service/internal/messenger"
 	"go.uber.org/zap"
 )
 
@@ -31,8 +31,12 @@ func (h *handler) Message() messenger.Message {
 
 // Handle implements messenger.MessageHandler
 func (h *handler) Handle(m messenger.Message) error {
+	return h.HandleContext(context.Background(), m)
+}
+
+// HandleContext implements messenger.ContextMessageHandler
+func (h *handler) HandleContext(ctx context.Context, m messenger.Message) error {
 	msg := m.(*message)
-	ctx := context.Background()
 
 	// Dispatch to appropriate processor
 	for _, processor := range h.processors {
@@ -52,6 +56,17 @@ type WebhookPayload struct {
 	Data map[string]interface{} `json:"data"`
 }
 
+// NewMessage creates the webhook message of a received payload, e.g. by handler.WebhookHandler.
+// The raw payload is preserved, so processors can verify signatures over the exact bytes.
+func NewMessage(headers map[string]string, payload []byte) (messenger.Message, error) {
+	m := &message{Headers: headers, RawPayload: string(payload)}
+	if err := json.Unmarshal(payload, &m.Payload); err != nil {
+		return nil, err
+	}
+
+	return m, nil
+}
+
 type message struct {
 	Headers    map[string]string `json:"-"`
 	Payload    WebhookPayload    `json:"payload"`
@@ -66,6 +81,14 @@ func (m *message) Identifier() string {
 	return "webhook"
 }
 
+// MarshalJSON writes the headers and the raw payload, the format read by UnmarshalJSON.
+func (m *message) MarshalJSON() ([]byte, error) {
+	return json.Marshal(struct {
+		Headers map[string]string `json:"headers"`
+		Payload string            `json:"payload"`
+	}{m.Headers, m.RawPayload})
+}
+
 func (m *message) UnmarshalJSON(data []byte) error {
 	var body struct {
 		Headers map[string]string `json:"headers"`
@@ -0,0 +1,88 @@
+package messenger
+
+import (
+	"cmp"
+	"time"
+
+	"gitlab.com/btcdirect-api/bootstrap-go-service/internal/metrics"
+)
+
+var (
+	leaseMaxExtension = metrics.NewGauge("messenger_lease_max_extension_seconds")
+	leaseMinPeriod    = metrics.NewGauge("messenger_lease_min_extension_period_seconds")
+	leaseExpired      = metrics.NewCounter("messenger_lease_expired")
+	handlingSeconds   = metrics.NewGauge("messenger_handling_seconds")
+)
+
+// Bounds of a single ack deadline extension supported by Pub/Sub.
+const (
+	minExtensionPeriod = 10 * time.Second
+	maxExtensionPeriod = 10 * time.Minute
+)
+
+// Maximum time the ack deadline is extended by the Pub/Sub client when MaxExtension is zero.
+const defaultMaxExtension = 60 * time.Minute
+
+// Handlers of long running jobs implement this interface to signal how long handling a message takes.
+// The ack deadline is extended in periods of at least the expected duration (at most 10 minutes),
+// so the message is not redelivered while it is still being handled.
+type DurationHandler interface {
+	ExpectedDuration() time.Duration
+}
+
+// Lease of received messages. While a message is handled, its ack deadline is extended automatically
+// until it is acked or nacked, or the MaxExtension is reached and the message is redelivered.
+type lease struct {
+	// Maximum time the ack deadline is extended, zero uses the Pub/Sub client default (60 minutes).
+	MaxExtension time.Duration
+	// Bounds of a single extension, zero lets the Pub/Sub client decide based on the observed handling time.
+	MinExtensionPeriod time.Duration
+	MaxExtensionPeriod time.Duration
+}
+
+// Returns the lease of the subscription of the handlers.
+// The max extension is raised to the longest timeout of the handlers, as a message must not be redelivered
+// before its handler times out, and the min extension period to the longest expected duration.
+func (m messenger) lease(queue string, h []MessageHandler) lease {
+	l := lease{
+		MaxExtension:       m.MaxExtension,
+		MaxExtensionPeriod: m.MaxExtensionPeriod,
+	}
+
+	for _, handler := range h {
+		timeout := m.HandlerTimeout
+		if t, ok := handler.(TimeoutHandler); ok {
+			timeout = t.Timeout()
+		}
+		if timeout > cmp.Or(l.MaxExtension, defaultMaxExtension) {
+			l.MaxExtension = timeout
+		}
+
+		if d, ok := handler.(DurationHandler); ok && d.ExpectedDuration() > l.MinExtensionPeriod {
+			l.MinExtensionPeriod = d.ExpectedDuration()
+		}
+	}
+
+	if l.MinExtensionPeriod > maxExtensionPeriod {
+		l.MinExtensionPeriod = maxExtensionPeriod
+	} else if l.MinExtensionPeriod > 0 && l.MinExtensionPeriod < minExtensionPeriod {
+		l.MinExtensionPeriod = minExtensionPeriod
+	}
+	if l.MaxExtensionPeriod > 0 && l.MinExtensionPeriod > l.MaxExtensionPeriod {
+		l.MaxExtensionPeriod = l.MinExtensionPeriod
+	}
+
+	leaseMaxExtension.Set(queue, l.MaxExtension.Seconds())
+	leaseMinPeriod.Set(queue, l.MinExtensionPeriod.Seconds())
+
+	return l
+}
+
+// Record the handling time of a message, a message handled longer than the max extension has been redelivered.
+func (l lease) record(a adapterMessage, d time.Duration) {
+	handlingSeconds.Set(a.Queue+"."+a.Identifier, d.Seconds())
+
+	if l.MaxExtension > 0 && d > l.MaxExtension {
+		leaseExpired.Inc(a.Queue + "." + a.Identifier)
+	}
+}
@@ -0,0 +1,395 @@
+package messenger
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"sync"
+	"time"
+
+	"gitlab.com/btcdirect-api/bootstrap-go-service/internal/core"
+	"go.uber.org/zap"
+)
+
+type Config struct {
+	Log         *zap.SugaredLogger
+	Shutdown    *core.GracefulShutdown
+	Environment string
+	// Names the queues, defaults to prefixing the queues with the environment name.
+	QueueNamer     QueueNamer
+	RestartTimeout time.Duration
+	// Interval to sample the backlog of subscribed queues, zero disables sampling.
+	BacklogInterval time.Duration
+	// Maximum execution time of a handler, zero disables the timeout.
+	// Handlers can override it by implementing TimeoutHandler.
+	HandlerTimeout time.Duration
+	// Maximum time the ack deadline of a received message is extended while it is handled,
+	// zero uses the Pub/Sub default. It is raised to the timeout of the handlers.
+	MaxExtension time.Duration
+	// Maximum duration of a single ack deadline extension, zero lets the Pub/Sub client decide.
+	// Handlers can signal their expected duration by implementing DurationHandler.
+	MaxExtensionPeriod time.Duration
+	// Share of handled messages traced per queue, queues without a rate use the traces sample rate of Sentry.
+	// The queues are given without the environment prefix, they are named by the QueueNamer.
+	TraceSampleRates map[string]float64
+	PubsubConfig
+	FileConfig
+}
+
+type Messenger interface {
+	Dispatch(Message) error
+	DispatchAsync(Message, func(error))
+	Subscribe(...MessageHandler) error
+	Backlog() []QueueBacklog
+	QueueBacklog(queue string) (QueueBacklog, bool)
+	DeadLetters() DeadLetterQueue
+	Ready() bool
+}
+
+type MessageDispatcher interface {
+	Dispatch(Message) error
+}
+
+type Message interface {
+	Identifier() string
+	Queue() string
+}
+
+// Make sure to return the message by reference in order to be able to unmarshal it.
+type MessageHandler interface {
+	Message() Message
+	Handle(Message) error
+}
+
+type messenger struct {
+	Config
+	adapter adapter
+	backlog *backlogSampler
+	// Asynchronous dispatches that have not settled yet.
+	pending *pendingDispatches
+}
+
+var ErrDifferentQueues = errors.New("all handlers must subscribe to the same queue")
+
+var ErrShuttingDown = errors.New("messenger is shutting down")
+
+// Counts the asynchronous dispatches that have not settled yet.
+// Once shutdown started no dispatches are added, so the count is not raised while shutdown waits for it.
+type pendingDispatches struct {
+	sync.Mutex
+	closing bool
+	wg      sync.WaitGroup
+}
+
+// Adds a dispatch, returns false once shutdown started.
+func (p *pendingDispatches) add() bool {
+	p.Lock()
+	defer p.Unlock()
+
+	if p.closing {
+		return false
+	}
+	p.wg.Add(1)
+
+	return true
+}
+
+// Marks an added dispatch as settled.
+func (p *pendingDispatches) done() {
+	p.wg.Done()
+}
+
+// Refuses new dispatches and waits for the added dispatches to settle.
+func (p *pendingDispatches) close() {
+	p.Lock()
+	p.closing = true
+	p.Unlock()
+
+	p.wg.Wait()
+}
+
+// Creates a messenger instance using the Pub/Sub adapter.
+// This also opens a connection to the message broker.
+//
+// Queues in the QueueProjects overrides, TraceSampleRates and the DeadLetterTopic are given without the environment prefix,
+// they are named by the QueueNamer like all other queues.
+//
+// When a FileConfig directory is configured, the file adapter is used instead.
+// This is meant for local development without the Pub/Sub emulator.
+//
+// When the message broker is unavailable, the messenger starts degraded: Ready returns false,
+// dispatching fails with ErrNotConnected and subscriptions wait until the connection is retried successfully
+// every RestartTimeout in the background.
+func New(c Config) Messenger {
+	c.Log.Info("Starting messenger")
+	c = c.named()
+
+	create := func() (adapter, error) {
+		if c.FileConfig.Directory != "" {
+			c.Log.Infof("Using file adapter in %s", c.FileConfig.Directory)
+			return newFileAdapter(c.FileConfig, c.PubsubConfig.DeadLetterTopic, c.Log)
+		}
+		return newPubsubAdapter(c.PubsubConfig, c.Log)
+	}
+
+	a, err := create()
+	if err != nil {
+		c.Log.Errorw("Message broker unavailable, starting messenger degraded", "error", err)
+
+		interval := c.RestartTimeout
+		if interval == 0 {
+			interval = 10 * time.Second
+		}
+
+		lazy := newLazyAdapter()
+		ctx, done := c.Shutdown.AddHook("messenger.connect")
+		go func() {
+			defer done()
+			lazy.connect(ctx, create, interval, c.Log)
+		}()
+		a = lazy
+	}
+
+	m := &messenger{
+		Config:  c,
+		adapter: a,
+		backlog: newBacklogSampler(a, c.BacklogInterval, c.Log),
+		pending: &pendingDispatches{},
+	}
+
+	// Wait for asynchronous dispatches to settle on shutdown.
+	ctx, done := c.Shutdown.AddHook("messenger.dispatch")
+	go func() {
+		<-ctx.Done()
+		m.pending.close()
+		done()
+	}()
+
+	if c.BacklogInterval > 0 {
+		ctx, done := c.Shutdown.AddHook("messenger.backlog")
+		go func() {
+			defer done()
+			m.backlog.Run(ctx)
+		}()
+	}
+
+	return m
+}
+
+// Returns the configuration with the queues named by the QueueNamer.
+func (c Config) named() Config {
+	if c.QueueNamer == nil {
+		c.QueueNamer = PrefixNamer(c.Environment + ".")
+	}
+
+	if c.PubsubConfig.DeadLetterTopic != "" {
+		c.PubsubConfig.DeadLetterTopic = c.QueueNamer.QueueName(c.PubsubConfig.DeadLetterTopic)
+	}
+
+	queueProjects := make(map[string]string, len(c.PubsubConfig.QueueProjects))
+	for queue, project := range c.PubsubConfig.QueueProjects {
+		queueProjects[c.QueueNamer.QueueName(queue)] = project
+	}
+	c.PubsubConfig.QueueProjects = queueProjects
+
+	traceSampleRates := make(map[string]float64, len(c.TraceSampleRates))
+	for queue, rate := range c.TraceSampleRates {
+		traceSampleRates[c.QueueNamer.QueueName(queue)] = rate
+	}
+	c.TraceSampleRates = traceSampleRates
+
+	return c
+}
+
+// Will send a message to the queue, this will be in JSON format.
+// The message needs to support JSON marshalling.
+//
+// The queue is named by the QueueNamer, by default prefixed with the environment name.
+// The queue is added to the sampled backlogs, so producers can apply backpressure.
+func (m messenger) Dispatch(msg Message) error {
+	m.Log.Infow("Dispatching message", "message", msg)
+
+	json, err := json.Marshal(msg)
+	if err != nil {
+		return err
+	}
+
+	queue := m.queueName(msg.Queue())
+	m.backlog.Add(queue)
+
+	err = m.adapter.Dispatch(adapterMessage{
+		Queue:      queue,
+		Identifier: msg.Identifier(),
+		Body:       string(json),
+	})
+	if err != nil {
+		m.Log.Errorw("Error dispatching message", "message", msg, "error", err)
+	} else {
+		m.Log.Infow("Message dispatched", "message", msg)
+	}
+
+	return err
+}
+
+// Will send a message to the queue without waiting for the result.
+// The callback is called with the result when the publish settles, it may be nil for fire-and-forget messages.
+// Graceful shutdown waits for pending messages to settle, once it started the callback is called with ErrShuttingDown.
+//
+// The queue is named by the QueueNamer, by default prefixed with the environment name.
+func (m messenger) DispatchAsync(msg Message, callback func(error)) {
+	m.Log.Infow("Dispatching message asynchronously", "message", msg)
+
+	if !m.pending.add() {
+		m.Log.Errorw("Error dispatching message", "message", msg, "error", ErrShuttingDown)
+		if callback != nil {
+			callback(ErrShuttingDown)
+		}
+		return
+	}
+
+	settle := func(err error) {
+		defer m.pending.done()
+
+		if err != nil {
+			m.Log.Errorw("Error dispatching message", "message", msg, "error", err)
+		} else {
+			m.Log.Infow("Message dispatched", "message", msg)
+		}
+
+		if callback != nil {
+			callback(err)
+		}
+	}
+
+	json, err := json.Marshal(msg)
+	if err != nil {
+		settle(err)
+		return
+	}
+
+	queue := m.queueName(msg.Queue())
+	m.backlog.Add(queue)
+
+	am := adapterMessage{
+		Queue:      queue,
+		Identifier: msg.Identifier(),
+		Body:       string(json),
+	}
+
+	if a, ok := m.adapter.(asyncAdapter); ok {
+		a.DispatchAsync(am, settle)
+		return
+	}
+
+	go func() {
+		settle(m.adapter.Dispatch(am))
+	}()
+}
+
+// Subscribes to a queue and will handle the messages using the provided handlers.
+// All handlers must subscribe to the same queue.
+//
+// The queue is named by the QueueNamer, by default prefixed with the environment name.
+//
+// This function will block until the shutdown context is cancelled.
+//
+// If the RestartTimeout is set, the function will restart the subscription upon error.
+func (m messenger) Subscribe(h ...MessageHandler) error {
+	var queue string
+	for _, handler := range h {
+		if queue == "" {
+			queue = handler.Message().Queue()
+		} else if queue != handler.Message().Queue() {
+			return ErrDifferentQueues
+		}
+	}
+
+	queue = m.queueName(queue)
+	m.Log.Infof("Subscribing to %s", queue)
+	m.backlog.Add(queue)
+	lease := m.lease(queue, h)
+
+	ctx, done := m.Shutdown.AddHook("messenger.subscribe." + queue)
+	defer done()
+
+	// The handleMessage function will be called for each message received from the queue.
+	// It will find the correct handler based on the identifier for the message.
+	handleMessage := func(ctx context.Context, a adapterMessage) error {
+		for _, handler := range h {
+			if a.Identifier == handler.Message().Identifier() {
+				msg := handler.Message()
+				if err := json.Unmarshal([]byte(a.Body), msg); err != nil {
+					m.Log.Error(err)
+					return err
+				}
+				start := time.Now()
+				span := m.startTransaction(ctx, a)
+				err := m.handle(span.Context(), a, handler, msg)
+				finishTransaction(span, err)
+				lease.record(a, time.Since(start))
+				if err != nil {
+					m.Log.Error(err)
+				} else {
+					m.Log.Infof("Message %s handled", a.Identifier)
+				}
+				return err
+			}
+		}
+
+		err := errors.New("no handler found for message " + a.Identifier)
+		m.Log.Error(err.Error())
+		return err
+	}
+
+	err := m.adapter.Subscribe(queue, handleMessage, lease, ctx)
+
+	if err == nil || err == ctx.Err() {
+		return nil
+	}
+
+	m.Log.Errorw("Error subscribing to queue", "queue", queue, "error", err)
+
+	if m.RestartTimeout == 0 {
+		return err
+	}
+
+	m.Log.Infof("Restarting subscription in %s", m.RestartTimeout)
+	time.Sleep(m.RestartTimeout)
+	return m.Subscribe(h...)
+}
+
+// Returns the last sampled backlog of all subscribed and dispatched queues.
+// The backlog is only sampled when a BacklogInterval is configured.
+func (m messenger) Backlog() []QueueBacklog {
+	return m.backlog.Backlogs()
+}
+
+// Returns the last sampled backlog of a single queue, given by its name before the QueueNamer is applied.
+// False is returned when the queue has not been sampled yet.
+func (m messenger) QueueBacklog(queue string) (QueueBacklog, bool) {
+	return m.backlog.Backlog(m.queueName(queue))
+}
+
+// Returns the dead letter queue of the messenger.
+// Browsing the dead letter queue is not supported when no DeadLetterTopic is configured.
+func (m messenger) DeadLetters() DeadLetterQueue {
+	return deadLetterQueue{
+		adapter: m.adapter,
+		queue:   m.PubsubConfig.DeadLetterTopic,
+	}
+}
+
+// Returns true if the messenger is connected to the message broker.
+// It is false while the messenger is degraded, because the broker was unavailable at startup.
+func (m messenger) Ready() bool {
+	if l, ok := m.adapter.(*lazyAdapter); ok {
+		return l.Ready()
+	}
+
+	return true
+}
+
+// Returns the name of the topic and subscription of the queue.
+func (m messenger) queueName(queue string) string {
+	return m.QueueNamer.QueueName(queue)
+}
@@ -0,0 +1,49 @@
+package messenger
+
+import (
+	"sync"
+	"sync/atomic"
+	"testing"
+	"time"
+)
+
+// Run with -race, dispatches are added while shutdown waits for them.
+func TestPendingDispatchesClose(t *testing.T) {
+	p := &pendingDispatches{}
+
+	var settled, refused atomic.Int64
+	var wg sync.WaitGroup
+	for i := 0; i < 8; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+
+			for j := 0; j < 100; j++ {
+				if !p.add() {
+					refused.Add(1)
+					continue
+				}
+				go func() {
+					time.Sleep(time.Millisecond)
+					settled.Add(1)
+					p.done()
+				}()
+			}
+		}()
+	}
+
+	time.Sleep(5 * time.Millisecond)
+	p.close()
+	closed := settled.Load()
+	wg.Wait()
+
+	if p.add() {
+		t.Error("add() = true after close, want the dispatch refused")
+	}
+	if got := settled.Load(); got != closed {
+		t.Errorf("%d dispatches settled after close returned, want none", got-closed)
+	}
+	if got := settled.Load() + refused.Load(); got != 800 {
+		t.Errorf("settled and refused %d dispatches, want 800", got)
+	}
+}
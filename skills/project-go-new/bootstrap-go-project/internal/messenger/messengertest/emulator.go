@@ -0,0 +1,176 @@
+// Package messengertest provides helpers for integration tests against the Pub/Sub emulator.
+package messengertest
+
+import (
+	"context"
+	"fmt"
+	"net"
+	"os"
+	"os/exec"
+	"testing"
+	"time"
+
+	"cloud.google.com/go/pubsub"
+	"gitlab.com/btcdirect-api/bootstrap-go-service/internal/core"
+	"gitlab.com/btcdirect-api/bootstrap-go-service/internal/messenger"
+	"go.uber.org/zap"
+)
+
+const (
+	// Environment used to prefix the queues of the fixture.
+	Environment = "test"
+	// Project used on the emulator.
+	Project = "messengertest-project"
+
+	emulatorHostEnv      = "PUBSUB_EMULATOR_HOST"
+	emulatorStartTimeout = 30 * time.Second
+)
+
+// EmulatorFixture is a Pub/Sub emulator with topics and subscriptions for a set of messages.
+type EmulatorFixture struct {
+	Host    string
+	Project string
+	Client  *pubsub.Client
+}
+
+// StartEmulatorFixture attaches to the emulator in PUBSUB_EMULATOR_HOST, or boots one using gcloud.
+// A topic and subscription is created for the queue of every given message, these are deleted again
+// when the test finishes. The test is skipped when no emulator is available.
+func StartEmulatorFixture(t testing.TB, messages ...messenger.Message) *EmulatorFixture {
+	t.Helper()
+
+	host := os.Getenv(emulatorHostEnv)
+	if host == "" {
+		host = startEmulator(t)
+	}
+	t.Setenv(emulatorHostEnv, host)
+
+	client, err := pubsub.NewClient(context.Background(), Project)
+	if err != nil {
+		t.Fatalf("creating Pub/Sub client: %v", err)
+	}
+	t.Cleanup(func() { client.Close() })
+
+	f := &EmulatorFixture{
+		Host:    host,
+		Project: Project,
+		Client:  client,
+	}
+
+	for _, m := range messages {
+		f.createQueue(t, Queue(m))
+	}
+
+	return f
+}
+
+// Queue returns the prefixed queue name of the message, as used by the messenger of the fixture.
+func Queue(m messenger.Message) string {
+	return Environment + "." + m.Queue()
+}
+
+// Config returns a messenger configuration for the emulator of the fixture.
+func (f *EmulatorFixture) Config(log *zap.SugaredLogger, shutdown *core.GracefulShutdown) messenger.Config {
+	return messenger.Config{
+		Log:         log,
+		Shutdown:    shutdown,
+		Environment: Environment,
+		PubsubConfig: messenger.PubsubConfig{
+			Emulator: f.Host,
+			Project:  f.Project,
+		},
+	}
+}
+
+// Create the topic and subscription for the queue and delete them when the test finishes.
+func (f *EmulatorFixture) createQueue(t testing.TB, queue string) {
+	t.Helper()
+
+	ctx := context.Background()
+
+	topic := f.Client.Topic(queue)
+	exists, err := topic.Exists(ctx)
+	if err != nil {
+		t.Fatalf("checking topic %s: %v", queue, err)
+	}
+	if !exists {
+		if topic, err = f.Client.CreateTopic(ctx, queue); err != nil {
+			t.Fatalf("creating topic %s: %v", queue, err)
+		}
+	}
+
+	sub := f.Client.Subscription(queue)
+	exists, err = sub.Exists(ctx)
+	if err != nil {
+		t.Fatalf("checking subscription %s: %v", queue, err)
+	}
+	if !exists {
+		if sub, err = f.Client.CreateSubscription(ctx, queue, pubsub.SubscriptionConfig{Topic: topic}); err != nil {
+			t.Fatalf("creating subscription %s: %v", queue, err)
+		}
+	}
+
+	t.Cleanup(func() {
+		if err := sub.Delete(context.Background()); err != nil {
+			t.Logf("deleting subscription %s: %v", queue, err)
+		}
+		if err := topic.Delete(context.Background()); err != nil {
+			t.Logf("deleting topic %s: %v", queue, err)
+		}
+	})
+}
+
+// Boot an emulator on a free port using gcloud and stop it when the test finishes.
+func startEmulator(t testing.TB) string {
+	t.Helper()
+
+	gcloud, err := exec.LookPath("gcloud")
+	if err != nil {
+		t.Skipf("Pub/Sub emulator not available: set %s or install gcloud", emulatorHostEnv)
+	}
+
+	host, err := freeHost()
+	if err != nil {
+		t.Fatalf("finding a free port for the emulator: %v", err)
+	}
+
+	cmd := exec.Command(gcloud, "beta", "emulators", "pubsub", "start", "--host-port="+host, "--project="+Project)
+	if err := cmd.Start(); err != nil {
+		t.Fatalf("starting the emulator: %v", err)
+	}
+
+	t.Cleanup(func() {
+		cmd.Process.Kill()
+		cmd.Wait()
+	})
+
+	if err := waitForHost(host, emulatorStartTimeout); err != nil {
+		t.Fatalf("waiting for the emulator: %v", err)
+	}
+
+	return host
+}
+
+func freeHost() (string, error) {
+	l, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		return "", err
+	}
+	defer l.Close()
+
+	return l.Addr().String(), nil
+}
+
+func waitForHost(host string, timeout time.Duration) error {
+	deadline := time.Now().Add(timeout)
+	for time.Now().Before(deadline) {
+		conn, err := net.DialTimeout("tcp", host, time.Second)
+		if err == nil {
+			conn.Close()
+			return nil
+		}
+		time.Sleep(200 * time.Millisecond)
+	}
+
+	return fmt.Errorf("emulator on %s did not start within %s", host, timeout)
+}
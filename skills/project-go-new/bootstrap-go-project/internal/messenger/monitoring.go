@@ -0,0 +1,119 @@
+package messenger
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"net/url"
+	"strconv"
+	"time"
+
+	"golang.org/x/oauth2/google"
+)
+
+const (
+	monitoringScope    = "https://www.googleapis.com/auth/monitoring.read"
+	monitoringEndpoint = "https://monitoring.googleapis.com/v3/projects/%s/timeSeries"
+	monitoringWindow   = 5 * time.Minute
+)
+
+// Backlog retrieves the number of undelivered messages and the age of the oldest unacknowledged message
+// for the subscription of the queue from Cloud Monitoring.
+//
+// The Pub/Sub emulator does not report metrics, so the backlog is not supported when using the emulator.
+func (p *pubsubAdapter) Backlog(ctx context.Context, queue string) (backlog, error) {
+	if p.config.Emulator != "" {
+		return backlog{}, ErrBacklogUnsupported
+	}
+
+	project := p.projectFor(queue)
+
+	messages, err := p.subscriptionMetric(ctx, project, queue, "num_undelivered_messages")
+	if err != nil {
+		return backlog{}, err
+	}
+
+	age, err := p.subscriptionMetric(ctx, project, queue, "oldest_unacked_message_age")
+	if err != nil {
+		return backlog{}, err
+	}
+
+	return backlog{
+		Messages:         messages,
+		OldestMessageAge: time.Duration(age) * time.Second,
+	}, nil
+}
+
+// Retrieve the latest value of a Pub/Sub subscription metric.
+// When there are no data points within the monitoring window, zero is returned.
+func (p *pubsubAdapter) subscriptionMetric(ctx context.Context, project, subscription, metric string) (int64, error) {
+	client, err := p.monitoringClient()
+	if err != nil {
+		return 0, err
+	}
+
+	now := time.Now().UTC()
+	query := url.Values{}
+	query.Set("filter", fmt.Sprintf(
+		`metric.type="pubsub.googleapis.com/subscription/%s" AND resource.labels.subscription_id="%s"`,
+		metric, subscription,
+	))
+	query.Set("interval.startTime", now.Add(-monitoringWindow).Format(time.RFC3339))
+	query.Set("interval.endTime", now.Format(time.RFC3339))
+
+	r, err := http.NewRequestWithContext(ctx, http.MethodGet, fmt.Sprintf(monitoringEndpoint, project)+"?"+query.Encode(), nil)
+	if err != nil {
+		return 0, err
+	}
+
+	res, err := client.Do(r)
+	if err != nil {
+		return 0, err
+	}
+	defer res.Body.Close()
+
+	if res.StatusCode != http.StatusOK {
+		return 0, fmt.Errorf("monitoring request failed: %s", res.Status)
+	}
+
+	var body struct {
+		TimeSeries []struct {
+			Points []struct {
+				Value struct {
+					Int64Value string `json:"int64Value"`
+				} `json:"value"`
+			} `json:"points"`
+		} `json:"timeSeries"`
+	}
+	if err := json.NewDecoder(res.Body).Decode(&body); err != nil {
+		return 0, err
+	}
+
+	// Points are returned in reverse time order, so the first point is the latest.
+	if len(body.TimeSeries) == 0 || len(body.TimeSeries[0].Points) == 0 {
+		return 0, nil
+	}
+
+	return strconv.ParseInt(body.TimeSeries[0].Points[0].Value.Int64Value, 10, 64)
+}
+
+// Retrieve the HTTP client for Cloud Monitoring using the default credentials.
+//
+// This method is thread-safe.
+func (p *pubsubAdapter) monitoringClient() (*http.Client, error) {
+	p.Lock()
+	defer p.Unlock()
+
+	if p.monitoring != nil {
+		return p.monitoring, nil
+	}
+
+	client, err := google.DefaultClient(context.Background(), monitoringScope)
+	if err != nil {
+		return nil, err
+	}
+	p.monitoring = client
+
+	return client, nil
+}
@@ -0,0 +1,26 @@
+package messenger
+
+// QueueNamer determines the name of the topic and subscription used for a queue.
+// The dead letter topic is named by the namer as well.
+type QueueNamer interface {
+	QueueName(queue string) string
+}
+
+// QueueNamerFunc allows using a function as QueueNamer.
+type QueueNamerFunc func(queue string) string
+
+func (f QueueNamerFunc) QueueName(queue string) string {
+	return f(queue)
+}
+
+// PrefixNamer prefixes the queue names with the given prefix, e.g. "prod." or "tenant-a.".
+// This is to prevent queues from different environments or tenants from interfering with each other
+// when using the same Pub/Sub instance.
+type PrefixNamer string
+
+func (p PrefixNamer) QueueName(queue string) string {
+	return string(p) + queue
+}
+
+// NoPrefixNamer uses the queue names as they are, for dedicated projects per environment.
+var NoPrefixNamer = PrefixNamer("")
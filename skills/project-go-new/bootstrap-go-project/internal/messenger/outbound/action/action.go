@@ -3,7 +3,7 @@ package action
 import (
 	"fmt"
 
-	"gitlab.com/btcdirect-api/go-modules/messenger"
+	"gitlab.com/btcdirect-api/bootstrap-go-service/internal/messenger"
 	"go.uber.org/zap"
 )
 
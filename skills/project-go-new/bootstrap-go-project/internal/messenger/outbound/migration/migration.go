@@ -0,0 +1,65 @@
+package migration
+
+import (
+	"gitlab.com/btcdirect-api/bootstrap-go-service/internal/sql/migrate"
+)
+
+// Queue the migration events are published to, deploy pipelines subscribe to assert on the outcome.
+const Queue = "bootstrap-go-service.migrations"
+
+// Completed is published when a migration run finished, also when it failed.
+type Completed struct {
+	Cmd        string        `json:"cmd"`
+	Success    bool          `json:"success"`
+	Error      string        `json:"error,omitempty"`
+	Version    uint          `json:"version"`
+	Dirty      bool          `json:"dirty"`
+	DurationMs int64         `json:"durationMs"`
+	Applied    []AppliedFile `json:"applied"`
+}
+
+// AppliedFile is a migration file executed during the run.
+type AppliedFile struct {
+	Version      uint   `json:"version"`
+	Identifier   string `json:"identifier"`
+	Direction    string `json:"direction"`
+	DurationMs   int64  `json:"durationMs"`
+	RowsAffected int64  `json:"rowsAffected"`
+}
+
+// NewCompleted creates the event for the result of a migration run.
+func NewCompleted(r migrate.Result) *Completed {
+	c := &Completed{
+		Cmd:        r.Cmd,
+		Success:    r.Err == nil,
+		Version:    r.Version,
+		Dirty:      r.Dirty,
+		DurationMs: r.Duration.Milliseconds(),
+		Applied:    []AppliedFile{},
+	}
+	if r.Err != nil {
+		c.Error = r.Err.Error()
+	}
+
+	for _, m := range r.Applied {
+		c.Applied = append(c.Applied, AppliedFile{
+			Version:      m.Version,
+			Identifier:   m.Identifier,
+			Direction:    m.Direction,
+			DurationMs:   m.Duration.Milliseconds(),
+			RowsAffected: m.RowsAffected,
+		})
+	}
+
+	return c
+}
+
+// Queue implements messenger.Message
+func (c *Completed) Queue() string {
+	return Queue
+}
+
+// Identifier implements messenger.Message
+func (c *Completed) Identifier() string {
+	return "migration.completed"
+}
@@ -0,0 +1,513 @@
+package messenger
+
+import (
+	"context"
+	"errors"
+	"net/http"
+	"os"
+	"strings"
+	"sync"
+	"time"
+
+	"cloud.google.com/go/pubsub"
+	"go.uber.org/zap"
+)
+
+type PubsubConfig struct {
+	Emulator        string
+	Project         string
+	DeadLetterTopic string
+	// QueueProjects overrides the project per queue, queues without an override use the Project.
+	// This allows publishing to a shared project while consuming from the team project.
+	QueueProjects map[string]string
+	// Codec of the published envelopes, defaults to JSONCodec. Received messages are decoded with the codec they were published with.
+	Codec Codec
+}
+
+type pubsubAdapter struct {
+	config  PubsubConfig
+	client  *pubsub.Client
+	clients map[string]*pubsub.Client
+	topics  map[string]*pubsub.Topic
+	log     *zap.SugaredLogger
+	sync.Mutex
+
+	monitoring *http.Client
+}
+
+type pubsubMessage struct {
+	Headers pubsubHeaders `json:"headers"`
+	Body    string        `json:"body"`
+}
+
+type pubsubHeaders struct {
+	Type string `json:"type"`
+	// Source queue and ID of a message in the dead letter queue of the file adapter.
+	Source string `json:"source,omitempty"`
+	ID     string `json:"id,omitempty"`
+}
+
+var ErrMissingProject = errors.New("missing project")
+
+// The creation of the adapter will create a new Pub/Sub client using the provided configuration.
+func newPubsubAdapter(c PubsubConfig, log *zap.SugaredLogger) (*pubsubAdapter, error) {
+	if c.Emulator != "" {
+		os.Setenv("PUBSUB_EMULATOR_HOST", c.Emulator)
+		if c.Project == "" {
+			c.Project = "emulator-project"
+		}
+	}
+
+	if c.Project == "" {
+		return nil, ErrMissingProject
+	}
+
+	if c.Codec == nil {
+		c.Codec = JSONCodec
+	}
+
+	client, err := pubsub.NewClient(context.Background(), c.Project)
+	if err != nil {
+		return nil, err
+	}
+
+	return &pubsubAdapter{
+		config:  c,
+		client:  client,
+		clients: map[string]*pubsub.Client{c.Project: client},
+		topics:  make(map[string]*pubsub.Topic),
+		log:     log,
+	}, nil
+}
+
+// Retrieve the project of the queue.
+func (p *pubsubAdapter) projectFor(queue string) string {
+	if project := p.config.QueueProjects[queue]; project != "" {
+		return project
+	}
+
+	return p.config.Project
+}
+
+// Retrieve the client for the project of the queue.
+// Clients for overridden projects are created on first use.
+//
+// This method is thread-safe.
+func (p *pubsubAdapter) clientFor(queue string) (*pubsub.Client, error) {
+	project := p.projectFor(queue)
+	if project == p.config.Project {
+		return p.client, nil
+	}
+
+	p.Lock()
+	defer p.Unlock()
+
+	if client, ok := p.clients[project]; ok {
+		return client, nil
+	}
+
+	p.log.Infof("Creating Pub/Sub client for project %s", project)
+	client, err := pubsub.NewClient(context.Background(), project)
+	if err != nil {
+		return nil, err
+	}
+	p.clients[project] = client
+
+	return client, nil
+}
+
+// Dispatch will send a message to the queue, this will be in JSON format.
+// The message needs to support JSON marshalling.
+//
+// This method assumes that the topic already exists.
+func (p *pubsubAdapter) Dispatch(msg adapterMessage) error {
+	res, err := p.publishMessage(msg)
+	if err != nil {
+		return err
+	}
+
+	_, err = res.Get(context.Background())
+	return err
+}
+
+// DispatchAsync will send a message to the queue without waiting for the result.
+// The callback is called when the publish settles.
+func (p *pubsubAdapter) DispatchAsync(msg adapterMessage, callback func(error)) {
+	res, err := p.publishMessage(msg)
+	if err != nil {
+		callback(err)
+		return
+	}
+
+	go func() {
+		_, err := res.Get(context.Background())
+		callback(err)
+	}()
+}
+
+// Publish the message in the envelope to the topic of its queue.
+func (p *pubsubAdapter) publishMessage(msg adapterMessage) (*pubsub.PublishResult, error) {
+	data, err := p.config.Codec.Encode(Envelope{
+		Type: msg.Identifier,
+		Body: msg.Body,
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	topic, err := p.topic(msg.Queue, false)
+	if err != nil {
+		return nil, err
+	}
+
+	return topic.Publish(context.Background(), &pubsub.Message{
+		Data: data,
+		Attributes: map[string]string{
+			codecAttribute: p.config.Codec.Name(),
+			typeAttribute:  msg.Identifier,
+		},
+	}), nil
+}
+
+// Subscribe will listen to the queue and call the provided handler when a message is received.
+// This is a blocking method and will return when the context is cancelled.
+//
+// If the subscription and/or topic do not exist, they will be created.
+// If they do exist, they will be updated to make sure they are correctly configured to prevent
+// alterations in the Google console.
+func (p *pubsubAdapter) Subscribe(queue string, h handleMessage, l lease, ctx context.Context) error {
+	sub, _, err := p.subscription(queue, queue, p.config.DeadLetterTopic)
+	if err != nil {
+		return err
+	}
+
+	// The client extends the ack deadline of received messages until they are settled.
+	if l.MaxExtension > 0 {
+		sub.ReceiveSettings.MaxExtension = l.MaxExtension
+	}
+	sub.ReceiveSettings.MinExtensionPeriod = l.MinExtensionPeriod
+	sub.ReceiveSettings.MaxExtensionPeriod = l.MaxExtensionPeriod
+
+	p.log.Infof("Listening to Pub/Sub subscription %s", sub.ID())
+
+	return sub.Receive(ctx, func(ctx context.Context, msg *pubsub.Message) {
+		p.log.Infow("Received Pub/Sub message", "id", msg.ID, "queue", queue, "data", string(msg.Data))
+
+		e, err := p.decode(msg)
+		if err != nil {
+			p.log.Errorw("Could not decode Pub/Sub message", "id", msg.ID, "queue", queue, "error", err)
+			msg.Nack()
+			return
+		}
+
+		if err := h(ctx, adapterMessage{
+			Queue:      queue,
+			Identifier: e.Type,
+			Body:       e.Body,
+		}); err != nil {
+			msg.Nack()
+			return
+		}
+
+		msg.Ack()
+	})
+}
+
+// Decode the envelope with the codec the message was published with.
+func (p *pubsubAdapter) decode(msg *pubsub.Message) (Envelope, error) {
+	codec, err := codecFor(msg.Attributes, p.config.Codec)
+	if err != nil {
+		return Envelope{}, err
+	}
+
+	return codec.Decode(msg.Data, msg.Attributes)
+}
+
+// Retrieve the topic and create it if it does not exist.
+// The topic is retrieved from the project of the queue.
+func (p *pubsubAdapter) topic(queue string, create bool) (*pubsub.Topic, error) {
+	p.Lock()
+	topic, ok := p.topics[queue]
+	p.Unlock()
+	if ok {
+		return topic, nil
+	}
+
+	client, err := p.clientFor(queue)
+	if err != nil {
+		return nil, err
+	}
+
+	topic = client.Topic(queue)
+	if create {
+		err := p.createTopicIfNotExists(client, topic)
+		if err != nil {
+			return nil, err
+		}
+	}
+
+	p.Lock()
+	defer p.Unlock()
+	p.topics[queue] = topic
+
+	return topic, nil
+}
+
+func (p *pubsubAdapter) createTopicIfNotExists(client *pubsub.Client, topic *pubsub.Topic) error {
+	if exists, err := topic.Exists(context.Background()); exists || err != nil {
+		return err
+	}
+
+	p.log.Infof("Creating Pub/Sub topic %s", topic.String())
+	_, err := client.CreateTopic(context.Background(), topic.ID())
+
+	return err
+}
+
+// Retrieve the subscription and create it if it does not exist.
+// The subscription will be updated to make sure it is correctly configured.
+//
+// This method will also make sure the dead letter topic and subscription are correctly configured.
+func (p *pubsubAdapter) subscription(subscription, topic, deadLetterTopic string) (*pubsub.Subscription, *pubsub.Topic, error) {
+	top, err := p.topic(topic, true)
+	if err != nil {
+		return nil, nil, err
+	}
+
+	client, err := p.clientFor(subscription)
+	if err != nil {
+		return nil, nil, err
+	}
+
+	sub := client.Subscription(subscription)
+	p.createSubscriptionIfNotExists(client, sub, top)
+
+	if deadLetterTopic == "" {
+		return sub, top, nil
+	}
+
+	// Make sure the dead letter topic & subscription exists.
+	_, dlTop, err := p.subscription(deadLetterTopic, deadLetterTopic, "")
+	if err != nil {
+		return nil, nil, err
+	}
+
+	p.log.Infof("Updating Pub/Sub subscription %s", subscription)
+	_, err = sub.Update(context.Background(), pubsub.SubscriptionConfigToUpdate{
+		DeadLetterPolicy: &pubsub.DeadLetterPolicy{
+			DeadLetterTopic:     dlTop.String(),
+			MaxDeliveryAttempts: 5,
+		},
+		RetryPolicy: &pubsub.RetryPolicy{
+			MinimumBackoff: 10 * time.Second,
+			MaximumBackoff: 300 * time.Second,
+		},
+	})
+
+	return sub, top, err
+}
+
+func (p *pubsubAdapter) createSubscriptionIfNotExists(client *pubsub.Client, sub *pubsub.Subscription, topic *pubsub.Topic) error {
+	if exists, err := sub.Exists(context.Background()); exists || err != nil {
+		return err
+	}
+
+	p.log.Infof("Creating Pub/Sub subscription %s", sub.String())
+	_, err := client.CreateSubscription(context.Background(), sub.ID(), pubsub.SubscriptionConfig{
+		Topic: topic,
+	})
+
+	return err
+}
+
+const (
+	// Attribute added by Pub/Sub to dead-lettered messages with the subscription it was dead-lettered from.
+	deadLetterSourceAttribute = "CloudPubSubDeadLetterSourceSubscription"
+	deadLetterPullTimeout     = 5 * time.Second
+)
+
+// DeadLetters pulls up to max messages from the dead letter subscription without acknowledging them.
+func (p *pubsubAdapter) DeadLetters(ctx context.Context, queue string, max int) ([]DeadLetter, error) {
+	var letters []DeadLetter
+	err := p.pullDeadLetters(ctx, queue, max, nil, func(msgs []*pubsub.Message) map[string]bool {
+		for _, msg := range msgs {
+			letters = append(letters, p.deadLetterFromPubsub(msg))
+		}
+		return nil
+	})
+
+	return letters, err
+}
+
+// RequeueDeadLetter publishes the dead letter to the topic of its source subscription and acknowledges it.
+func (p *pubsubAdapter) RequeueDeadLetter(ctx context.Context, queue, id string) error {
+	found := false
+	var requeueErr error
+
+	err := p.pullDeadLetters(ctx, queue, maxDeadLetterSearch, func(msg *pubsub.Message) bool {
+		return msg.ID == id
+	}, func(msgs []*pubsub.Message) map[string]bool {
+		for _, msg := range msgs {
+			if msg.ID != id {
+				continue
+			}
+
+			found = true
+			queue := p.deadLetterFromPubsub(msg).Queue
+			if requeueErr = p.publish(ctx, queue, msg.Data, envelopeAttributes(msg.Attributes)); requeueErr != nil {
+				return nil
+			}
+
+			p.log.Infow("Requeued dead letter", "id", id, "queue", queue)
+			return map[string]bool{id: true}
+		}
+		return nil
+	})
+	if err != nil {
+		return err
+	}
+	if requeueErr != nil {
+		return requeueErr
+	}
+	if !found {
+		return ErrDeadLetterNotFound
+	}
+
+	return nil
+}
+
+// PurgeDeadLetters acknowledges all messages in the dead letter subscription by seeking to the current time.
+func (p *pubsubAdapter) PurgeDeadLetters(ctx context.Context, queue string) error {
+	client, err := p.clientFor(queue)
+	if err != nil {
+		return err
+	}
+
+	p.log.Infof("Purging dead letter subscription %s", queue)
+	return client.Subscription(queue).SeekToTime(ctx, time.Now())
+}
+
+// Publish raw data to the topic of the queue and wait for the result.
+func (p *pubsubAdapter) publish(ctx context.Context, queue string, data []byte, attributes map[string]string) error {
+	topic, err := p.topic(queue, false)
+	if err != nil {
+		return err
+	}
+
+	_, err = topic.Publish(ctx, &pubsub.Message{Data: data, Attributes: attributes}).Get(ctx)
+	return err
+}
+
+// Returns the attributes describing the envelope, so a requeued message is decoded with its original codec.
+func envelopeAttributes(attributes map[string]string) map[string]string {
+	envelope := make(map[string]string)
+	for _, key := range []string{codecAttribute, typeAttribute} {
+		if v, ok := attributes[key]; ok {
+			envelope[key] = v
+		}
+	}
+
+	return envelope
+}
+
+// Pull messages from the dead letter subscription until max messages are received, stop returns true
+// or the pull timeout expires. The received messages are held while settle is called, settle returns
+// the IDs of the messages to acknowledge. All other messages are returned to the subscription.
+func (p *pubsubAdapter) pullDeadLetters(
+	ctx context.Context,
+	queue string,
+	max int,
+	stop func(*pubsub.Message) bool,
+	settle func([]*pubsub.Message) map[string]bool,
+) error {
+	client, err := p.clientFor(queue)
+	if err != nil {
+		return err
+	}
+
+	sub := client.Subscription(queue)
+	sub.ReceiveSettings.Synchronous = true
+	sub.ReceiveSettings.MaxOutstandingMessages = max
+
+	pullCtx, cancel := context.WithTimeout(ctx, deadLetterPullTimeout)
+	defer cancel()
+
+	var (
+		mu       sync.Mutex
+		msgs     []*pubsub.Message
+		seen     = map[string]bool{}
+		acks     map[string]bool
+		full     = make(chan struct{})
+		settled  = make(chan struct{})
+		received = make(chan error, 1)
+		once     sync.Once
+	)
+
+	go func() {
+		received <- sub.Receive(pullCtx, func(_ context.Context, msg *pubsub.Message) {
+			mu.Lock()
+			if len(msgs) >= max || seen[msg.ID] {
+				mu.Unlock()
+				msg.Nack()
+				return
+			}
+			seen[msg.ID] = true
+			msgs = append(msgs, msg)
+			if len(msgs) >= max || (stop != nil && stop(msg)) {
+				once.Do(func() { close(full) })
+			}
+			mu.Unlock()
+
+			// Hold the message until the pulled messages are settled.
+			<-settled
+			if acks[msg.ID] {
+				msg.Ack()
+			} else {
+				msg.Nack()
+			}
+		})
+	}()
+
+	select {
+	case <-full:
+	case <-pullCtx.Done():
+	}
+
+	mu.Lock()
+	pulled := append([]*pubsub.Message(nil), msgs...)
+	mu.Unlock()
+
+	acks = settle(pulled)
+	close(settled)
+	cancel()
+
+	if err := <-received; err != nil && !errors.Is(err, context.Canceled) && !errors.Is(err, context.DeadlineExceeded) {
+		return err
+	}
+
+	return nil
+}
+
+func (p *pubsubAdapter) deadLetterFromPubsub(msg *pubsub.Message) DeadLetter {
+	// Dead letters that can not be decoded are listed without identifier and body.
+	e, _ := p.decode(msg)
+
+	// The source subscription may be given as full resource name.
+	source := msg.Attributes[deadLetterSourceAttribute]
+	if i := strings.LastIndex(source, "/"); i >= 0 {
+		source = source[i+1:]
+	}
+
+	l := DeadLetter{
+		ID:          msg.ID,
+		Queue:       source,
+		Identifier:  e.Type,
+		Body:        e.Body,
+		PublishedAt: msg.PublishTime,
+	}
+	if msg.DeliveryAttempt != nil {
+		l.DeliveryAttempts = *msg.DeliveryAttempt
+	}
+
+	return l
+}
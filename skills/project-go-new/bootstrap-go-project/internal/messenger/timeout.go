@@ -0,0 +1,71 @@
+package messenger
+
+import (
+	"context"
+	"errors"
+	"time"
+
+	"gitlab.com/btcdirect-api/bootstrap-go-service/internal/metrics"
+)
+
+var handlerTimeouts = metrics.NewCounter("messenger_handler_timeouts")
+
+var ErrHandlerTimeout = errors.New("handler timed out")
+
+// Handlers that accept a context implement this interface, it is used instead of Handle.
+// The context is cancelled when the handler times out or the subscription is stopped.
+type ContextMessageHandler interface {
+	MessageHandler
+	HandleContext(context.Context, Message) error
+}
+
+// Handlers that need a different timeout than the HandlerTimeout of the messenger implement this interface.
+// A zero timeout disables the timeout for the handler.
+type TimeoutHandler interface {
+	Timeout() time.Duration
+}
+
+// Handle the message with the timeout of the handler.
+//
+// When the handler exceeds its timeout, its context is cancelled and ErrHandlerTimeout is returned,
+// so the message is nacked and redelivered. A handler without a context cannot be stopped and keeps
+// running in the background, make sure long running handlers implement ContextMessageHandler.
+func (m messenger) handle(ctx context.Context, a adapterMessage, h MessageHandler, msg Message) error {
+	timeout := m.HandlerTimeout
+	if t, ok := h.(TimeoutHandler); ok {
+		timeout = t.Timeout()
+	}
+
+	if timeout <= 0 {
+		return call(ctx, h, msg)
+	}
+
+	ctx, cancel := context.WithTimeout(ctx, timeout)
+	defer cancel()
+
+	done := make(chan error, 1)
+	go func() {
+		done <- call(ctx, h, msg)
+	}()
+
+	select {
+	case err := <-done:
+		return err
+	case <-ctx.Done():
+		if !errors.Is(ctx.Err(), context.DeadlineExceeded) {
+			return ctx.Err()
+		}
+
+		handlerTimeouts.Inc(a.Queue + "." + a.Identifier)
+		m.Log.Warnw("Message handler timed out", "queue", a.Queue, "identifier", a.Identifier, "timeout", timeout)
+		return ErrHandlerTimeout
+	}
+}
+
+func call(ctx context.Context, h MessageHandler, msg Message) error {
+	if ch, ok := h.(ContextMessageHandler); ok {
+		return ch.HandleContext(ctx, msg)
+	}
+
+	return h.Handle(msg)
+}
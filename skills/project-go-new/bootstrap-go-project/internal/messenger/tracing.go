@@ -0,0 +1,50 @@
+package messenger
+
+import (
+	"context"
+	"math/rand"
+
+	"github.com/getsentry/sentry-go"
+)
+
+// Starts the Sentry transaction of handling a message, the handler receives it in its context.
+//
+// Queues with a trace sample rate are sampled with that rate, e.g. 1 for payment events and 0.01 for telemetry,
+// so tracing costs stay bounded for high-volume queues. Other queues use the traces sample rate of Sentry.
+func (m messenger) startTransaction(ctx context.Context, a adapterMessage) *sentry.Span {
+	// Every message is handled with its own hub, as messages are handled concurrently.
+	ctx = sentry.SetHubOnContext(ctx, sentry.CurrentHub().Clone())
+
+	opts := []sentry.SpanOption{
+		sentry.WithOpName("queue.process"),
+		sentry.WithTransactionSource(sentry.SourceTask),
+	}
+	if rate, ok := m.TraceSampleRates[a.Queue]; ok {
+		opts = append(opts, sentry.WithSpanSampled(sample(rate)))
+	}
+
+	span := sentry.StartTransaction(ctx, a.Queue+" "+a.Identifier, opts...)
+	span.SetData("messaging.destination.name", a.Queue)
+	span.SetData("messaging.message.type", a.Identifier)
+
+	return span
+}
+
+// Finish the transaction with the result of the handler.
+func finishTransaction(span *sentry.Span, err error) {
+	if err != nil {
+		span.Status = sentry.SpanStatusInternalError
+	} else {
+		span.Status = sentry.SpanStatusOK
+	}
+
+	span.Finish()
+}
+
+func sample(rate float64) sentry.Sampled {
+	if rand.Float64() < rate {
+		return sentry.SampledTrue
+	}
+
+	return sentry.SampledFalse
+}
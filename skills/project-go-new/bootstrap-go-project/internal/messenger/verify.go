@@ -0,0 +1,76 @@
+package messenger
+
+import (
+	"context"
+	"fmt"
+	"os"
+)
+
+// Permission required to dispatch messages to a queue.
+const publishPermission = "pubsub.topics.publish"
+
+// Verify checks the credentials of the messenger without dispatching a message.
+//
+// With Pub/Sub, the topic of the probe queue must exist and the publish permission is tested on it,
+// the probe queue is given without the environment prefix. With the file adapter, the directory must be writable.
+func Verify(ctx context.Context, c Config, probeQueue string) error {
+	c = c.named()
+
+	if c.FileConfig.Directory != "" {
+		return verifyDirectory(c.FileConfig.Directory)
+	}
+
+	a, err := newPubsubAdapter(c.PubsubConfig, c.Log)
+	if err != nil {
+		return err
+	}
+	defer func() {
+		for _, client := range a.clients {
+			client.Close()
+		}
+	}()
+
+	queue := c.QueueNamer.QueueName(probeQueue)
+	client, err := a.clientFor(queue)
+	if err != nil {
+		return err
+	}
+
+	topic := client.Topic(queue)
+	exists, err := topic.Exists(ctx)
+	if err != nil {
+		return err
+	}
+	if !exists {
+		return fmt.Errorf("probe topic %s does not exist in project %s", queue, a.projectFor(queue))
+	}
+
+	// The emulator does not implement IAM.
+	if c.Emulator != "" {
+		return nil
+	}
+
+	permissions, err := topic.IAM().TestPermissions(ctx, []string{publishPermission})
+	if err != nil {
+		return err
+	}
+	if len(permissions) == 0 {
+		return fmt.Errorf("missing %s permission on topic %s", publishPermission, queue)
+	}
+
+	return nil
+}
+
+func verifyDirectory(dir string) error {
+	if err := os.MkdirAll(dir, 0o755); err != nil {
+		return err
+	}
+
+	f, err := os.CreateTemp(dir, ".verify-*")
+	if err != nil {
+		return err
+	}
+	f.Close()
+
+	return os.Remove(f.Name())
+}
@@ -0,0 +1,126 @@
+package metrics
+
+import (
+	"expvar"
+	"fmt"
+	"net/http"
+	"strconv"
+	"strings"
+	"sync"
+)
+
+// Gauge holds the last reported value per label.
+// The values are published as an expvar map under the name of the gauge.
+type Gauge struct {
+	values *expvar.Map
+}
+
+// Counter holds a monotonically increasing value per label.
+// The values are published as an expvar map under the name of the counter.
+type Counter struct {
+	values *expvar.Map
+}
+
+// NewGauge creates and publishes a gauge.
+// The name must be unique, so gauges should be created once as package variables.
+func NewGauge(name string) *Gauge {
+	return &Gauge{values: expvar.NewMap(name)}
+}
+
+// NewCounter creates and publishes a counter.
+// The name must be unique, so counters should be created once as package variables.
+func NewCounter(name string) *Counter {
+	return &Counter{values: expvar.NewMap(name)}
+}
+
+// Set the value of the gauge for the label.
+func (g *Gauge) Set(label string, value float64) {
+	v := new(expvar.Float)
+	v.Set(value)
+	g.values.Set(label, v)
+}
+
+// Add delta to the counter for the label.
+func (c *Counter) Add(label string, delta int64) {
+	c.values.Add(label, delta)
+}
+
+// Inc increments the counter for the label.
+func (c *Counter) Inc(label string) {
+	c.Add(label, 1)
+}
+
+// Handler returns a handler exposing all published metrics as JSON.
+func Handler() http.Handler {
+	return expvar.Handler()
+}
+
+// DefaultBuckets are the upper bounds of histogram buckets for durations in seconds.
+var DefaultBuckets = []float64{.005, .01, .025, .05, .1, .25, .5, 1, 2.5, 5, 10}
+
+// Histogram counts observed values per label in buckets, with the number and sum of the values.
+// The values are published as an expvar map under the name of the histogram, with per label the cumulative
+// count of values at or below each bucket: {"buckets": {"0.1": 3, "+Inf": 4}, "count": 4, "sum": 1.2}.
+type Histogram struct {
+	values  *expvar.Map
+	buckets []float64
+	mu      sync.Mutex
+}
+
+// NewHistogram creates and publishes a histogram with the upper bounds of its buckets, in increasing order.
+// The name must be unique, so histograms should be created once as package variables.
+func NewHistogram(name string, buckets []float64) *Histogram {
+	return &Histogram{values: expvar.NewMap(name), buckets: buckets}
+}
+
+// Observe adds a value to the histogram for the label.
+func (h *Histogram) Observe(label string, value float64) {
+	v, ok := h.values.Get(label).(*histogramValues)
+	if !ok {
+		h.mu.Lock()
+		if v, ok = h.values.Get(label).(*histogramValues); !ok {
+			v = &histogramValues{bounds: h.buckets, counts: make([]int64, len(h.buckets))}
+			h.values.Set(label, v)
+		}
+		h.mu.Unlock()
+	}
+
+	v.observe(value)
+}
+
+// The values of a histogram for a label.
+type histogramValues struct {
+	mu     sync.Mutex
+	bounds []float64
+	counts []int64
+	count  int64
+	sum    float64
+}
+
+func (v *histogramValues) observe(value float64) {
+	v.mu.Lock()
+	defer v.mu.Unlock()
+
+	for i, bound := range v.bounds {
+		if value <= bound {
+			v.counts[i]++
+		}
+	}
+	v.count++
+	v.sum += value
+}
+
+// String returns the values as JSON, implementing expvar.Var.
+func (v *histogramValues) String() string {
+	v.mu.Lock()
+	defer v.mu.Unlock()
+
+	var b strings.Builder
+	b.WriteString(`{"buckets": {`)
+	for i, bound := range v.bounds {
+		fmt.Fprintf(&b, "%q: %d, ", strconv.FormatFloat(bound, 'g', -1, 64), v.counts[i])
+	}
+	fmt.Fprintf(&b, `"+Inf": %d}, "count": %d, "sum": %s}`, v.count, v.count, strconv.FormatFloat(v.sum, 'g', -1, 64))
+
+	return b.String()
+}
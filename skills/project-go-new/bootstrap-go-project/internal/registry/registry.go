@@ -0,0 +1,124 @@
+// Package registry composes the components of the application from registered constructors.
+//
+// A component is identified by its type. Constructors resolve the components they depend on from the registry,
+// so a component can be added or swapped (e.g. a fake messenger in tests) by registering a different constructor,
+// without changing the components that depend on it.
+package registry
+
+import (
+	"errors"
+	"fmt"
+	"reflect"
+)
+
+var ErrNotProvided = errors.New("component not provided")
+
+// Registry holds the constructors and the constructed components.
+//
+// Components are constructed once, on first resolve. The registry is meant to be used during initialization
+// of the application and is not safe for concurrent use.
+type Registry struct {
+	providers map[reflect.Type]func(*Registry) (interface{}, error)
+	instances map[reflect.Type]interface{}
+	resolving map[reflect.Type]bool
+	shutdown  []func() error
+}
+
+// Creates an empty registry.
+func New() *Registry {
+	return &Registry{
+		providers: make(map[reflect.Type]func(*Registry) (interface{}, error)),
+		instances: make(map[reflect.Type]interface{}),
+		resolving: make(map[reflect.Type]bool),
+	}
+}
+
+// Provide registers the constructor of a component, replacing the constructor registered before.
+// Replacing a component that has already been constructed panics, as its dependents would use the old one.
+func Provide[T any](r *Registry, constructor func(*Registry) (T, error)) {
+	t := typeOf[T]()
+	if _, ok := r.instances[t]; ok {
+		panic(fmt.Sprintf("registry: %s is already constructed", t))
+	}
+
+	r.providers[t] = func(r *Registry) (interface{}, error) {
+		return constructor(r)
+	}
+}
+
+// Value registers a constructed component.
+func Value[T any](r *Registry, v T) {
+	Provide(r, func(*Registry) (T, error) {
+		return v, nil
+	})
+}
+
+// Provided returns true if a constructor is registered for the component, e.g. for optional components.
+func Provided[T any](r *Registry) bool {
+	_, ok := r.providers[typeOf[T]()]
+	return ok
+}
+
+// Resolve returns the component of the type, constructing it and its dependencies when needed.
+func Resolve[T any](r *Registry) (T, error) {
+	var zero T
+	t := typeOf[T]()
+
+	if v, ok := r.instances[t]; ok {
+		return v.(T), nil
+	}
+
+	provider, ok := r.providers[t]
+	if !ok {
+		return zero, fmt.Errorf("%w: %s", ErrNotProvided, t)
+	}
+
+	if r.resolving[t] {
+		return zero, fmt.Errorf("registry: dependency cycle on %s", t)
+	}
+	r.resolving[t] = true
+	defer delete(r.resolving, t)
+
+	v, err := provider(r)
+	if err != nil {
+		return zero, fmt.Errorf("registry: construct %s: %w", t, err)
+	}
+
+	r.instances[t] = v
+	return v.(T), nil
+}
+
+// MustResolve returns the component of the type and panics when it can not be constructed.
+func MustResolve[T any](r *Registry) T {
+	v, err := Resolve[T](r)
+	if err != nil {
+		panic(err)
+	}
+
+	return v
+}
+
+// OnShutdown registers a hook to stop a component when the application shuts down.
+// Constructors register the hook of their component, hooks run in reverse order of registration,
+// so components are stopped before the components they depend on.
+func (r *Registry) OnShutdown(hook func() error) {
+	r.shutdown = append(r.shutdown, hook)
+}
+
+// Shutdown runs the shutdown hooks and returns their errors.
+func (r *Registry) Shutdown() error {
+	var errs []error
+	for i := len(r.shutdown) - 1; i >= 0; i-- {
+		if err := r.shutdown[i](); err != nil {
+			errs = append(errs, err)
+		}
+	}
+	r.shutdown = nil
+
+	return errors.Join(errs...)
+}
+
+// Returns the type of T, also for interface types.
+func typeOf[T any]() reflect.Type {
+	return reflect.TypeOf((*T)(nil)).Elem()
+}
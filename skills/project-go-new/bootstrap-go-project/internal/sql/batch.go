@@ -0,0 +1,170 @@
+package sql
+
+import (
+	"context"
+	"fmt"
+	"reflect"
+	"strings"
+)
+
+// DefaultBatchSize is the number of rows ExecuteBatchUpdate updates per statement.
+const DefaultBatchSize = 500
+
+// BatchOption changes the behaviour of ExecuteBatchUpdate.
+type BatchOption func(*batchOptions)
+
+type batchOptions struct {
+	size int
+}
+
+// WithBatchSize sets the number of rows updated per statement.
+func WithBatchSize(n int) BatchOption {
+	return func(o *batchOptions) {
+		o.size = n
+	}
+}
+
+// BatchResult reports the rows updated by ExecuteBatchUpdate.
+type BatchResult struct {
+	// Number of rows in the batch.
+	Rows int
+	// Number of statements executed, one per chunk of the batch size.
+	Statements int
+	// Number of rows affected. MySQL only counts rows of which a value changed.
+	Affected int64
+}
+
+// ExecuteBatchUpdate updates the rows of a slice of structs, in statements of the batch size (see WithBatchSize).
+// Every column is updated with CASE WHEN on the id, the first field, so a chunk takes one statement.
+// Rows only update their set fields, following the rules of ExecuteUpdate.
+//
+// The chunks are not updated within a transaction, when a chunk fails the result reports the chunks before it.
+func ExecuteBatchUpdate(conn DBConnection, table string, rows interface{}, opts ...BatchOption) (BatchResult, error) {
+	return ExecuteBatchUpdateContext(context.Background(), conn, table, rows, opts...)
+}
+
+// ExecuteBatchUpdateContext updates the rows, each statement is limited by the timeout of the connection.
+// Transient errors are retried, see RetryError.
+func ExecuteBatchUpdateContext(ctx context.Context, conn DBConnection, table string, rows interface{}, opts ...BatchOption) (BatchResult, error) {
+	o := batchOptions{size: DefaultBatchSize}
+	for _, opt := range opts {
+		opt(&o)
+	}
+	if o.size <= 0 {
+		o.size = DefaultBatchSize
+	}
+
+	slice := reflect.ValueOf(rows)
+	if slice.Kind() != reflect.Slice {
+		return BatchResult{}, fmt.Errorf("rows is not a slice")
+	}
+
+	result := BatchResult{Rows: slice.Len()}
+	for start := 0; start < slice.Len(); start += o.size {
+		end := min(start+o.size, slice.Len())
+
+		query, args, err := generateBatchUpdateQuery(ctx, table, slice.Slice(start, end))
+		if err != nil {
+			return result, err
+		}
+
+		affected, err := executeBatchUpdate(ctx, conn, query, args)
+		if err != nil {
+			return result, err
+		}
+
+		result.Statements++
+		result.Affected += affected
+	}
+
+	return result, nil
+}
+
+func executeBatchUpdate(ctx context.Context, conn DBConnection, query string, args []interface{}) (int64, error) {
+	db := conn.DB(true)
+
+	ctx, cancelfunc := queryContext(ctx, conn)
+	defer cancelfunc()
+
+	query = db.Rebind(query)
+
+	var affected int64
+	err := retryQuery(ctx, true, func() error {
+		defer observeQuery(ctx, query, nil)()
+
+		res, err := db.ExecContext(ctx, query, args...)
+		if err != nil {
+			return err
+		}
+		affected, err = res.RowsAffected()
+		return err
+	})
+
+	return affected, err
+}
+
+// Generates UPDATE ... SET col = CASE id WHEN ? THEN ? ... ELSE col END ... WHERE id IN (...) for the rows.
+// Rows of a tenant scoped struct are only updated for the tenant in the context.
+func generateBatchUpdateQuery(ctx context.Context, tableName string, rows reflect.Value) (string, []interface{}, error) {
+	if rows.Len() == 0 {
+		return "", nil, fmt.Errorf("no rows to update")
+	}
+
+	values := make([]reflect.Value, rows.Len())
+	for i := range values {
+		values[i] = reflect.Indirect(reflect.ValueOf(setTimestamps(rows.Index(i).Interface(), false)))
+		if values[i].Kind() != reflect.Struct {
+			return "", nil, fmt.Errorf("rows are not structs")
+		}
+	}
+
+	typ := values[0].Type()
+	id := typ.Field(0).Tag.Get("db")
+
+	tenantColumn, tenant, err := tenantScope(ctx, typ)
+	if err != nil {
+		return "", nil, err
+	}
+
+	var sets []string
+	var args []interface{}
+	for i := 1; i < typ.NumField(); i++ {
+		field := typ.Field(i)
+		column := field.Tag.Get("db")
+		if column == "" || field.Tag.Get("sql") == "" || skipOnUpdate(field) || hasSQLOption(field, tenantTag) {
+			continue
+		}
+
+		var cases []string
+		for _, v := range values {
+			if isSetForUpdate(field, v.Field(i)) {
+				cases = append(cases, "WHEN ? THEN ?")
+				args = append(args, v.Field(0).Interface(), v.Field(i).Interface())
+			}
+		}
+		if len(cases) == 0 {
+			continue
+		}
+
+		sets = append(sets, fmt.Sprintf("%s = CASE %s %s ELSE %s END", column, id, strings.Join(cases, " "), column))
+	}
+
+	if len(sets) == 0 {
+		return "", nil, fmt.Errorf("no columns to update")
+	}
+
+	placeholders := make([]string, len(values))
+	for i, v := range values {
+		placeholders[i] = "?"
+		args = append(args, v.Field(0).Interface())
+	}
+
+	query := fmt.Sprintf("UPDATE %s SET %s WHERE %s IN (%s)", tableName, strings.Join(sets, ", "), id, strings.Join(placeholders, ", "))
+	if tenantColumn != "" {
+		query += fmt.Sprintf(" AND %s = ?", tenantColumn)
+		args = append(args, tenant)
+	}
+	query += ";"
+
+	return query, args, nil
+}
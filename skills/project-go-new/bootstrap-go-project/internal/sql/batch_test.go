@@ -0,0 +1,142 @@
+package sql_test
+
+import (
+	"context"
+	"errors"
+	"reflect"
+	"testing"
+
+	"gitlab.com/btcdirect-api/bootstrap-go-service/internal/sql"
+	"gitlab.com/btcdirect-api/bootstrap-go-service/internal/sql/sqltest"
+)
+
+type balance struct {
+	ID     int64   `db:"id" sql:"update"`
+	Amount int64   `db:"amount" sql:"update"`
+	Note   *string `db:"note" sql:"update"`
+}
+
+type tenantBalance struct {
+	ID       int64  `db:"id" sql:"update"`
+	TenantID string `db:"tenant_id" sql:"insert,tenant"`
+	Amount   int64  `db:"amount" sql:"update"`
+}
+
+func TestExecuteBatchUpdate(t *testing.T) {
+	note := "refund"
+	tenantCtx := sql.WithTenant(context.Background(), "tenant-a")
+
+	tests := []struct {
+		name       string
+		opts       []sqltest.Option
+		ctx        context.Context
+		rows       interface{}
+		batchOpts  []sql.BatchOption
+		want       []string
+		wantArgs   [][]interface{}
+		wantResult sql.BatchResult
+		wantErr    error
+	}{
+		{
+			name: "mysql",
+			rows: []balance{{ID: 1, Amount: 10}, {ID: 2, Note: &note}},
+			want: []string{
+				"UPDATE balances SET amount = CASE id WHEN ? THEN ? ELSE amount END, note = CASE id WHEN ? THEN ? ELSE note END WHERE id IN (?, ?);",
+			},
+			wantArgs:   [][]interface{}{{int64(1), int64(10), int64(2), "refund", int64(1), int64(2)}},
+			wantResult: sql.BatchResult{Rows: 2, Statements: 1, Affected: 1},
+		},
+		{
+			name: "postgres",
+			opts: []sqltest.Option{sqltest.WithPostgres()},
+			rows: []*balance{{ID: 1, Amount: 10}, {ID: 2, Note: &note}},
+			want: []string{
+				"UPDATE balances SET amount = CASE id WHEN $1 THEN $2 ELSE amount END, note = CASE id WHEN $3 THEN $4 ELSE note END WHERE id IN ($5, $6);",
+			},
+			wantArgs:   [][]interface{}{{int64(1), int64(10), int64(2), "refund", int64(1), int64(2)}},
+			wantResult: sql.BatchResult{Rows: 2, Statements: 1, Affected: 1},
+		},
+		{
+			name:      "chunks of the batch size",
+			rows:      []balance{{ID: 1, Amount: 10}, {ID: 2, Amount: 20}, {ID: 3, Amount: 30}},
+			batchOpts: []sql.BatchOption{sql.WithBatchSize(2)},
+			want: []string{
+				"UPDATE balances SET amount = CASE id WHEN ? THEN ? WHEN ? THEN ? ELSE amount END WHERE id IN (?, ?);",
+				"UPDATE balances SET amount = CASE id WHEN ? THEN ? ELSE amount END WHERE id IN (?);",
+			},
+			wantArgs: [][]interface{}{
+				{int64(1), int64(10), int64(2), int64(20), int64(1), int64(2)},
+				{int64(3), int64(30), int64(3)},
+			},
+			wantResult: sql.BatchResult{Rows: 3, Statements: 2, Affected: 2},
+		},
+		{
+			name: "tenant",
+			ctx:  tenantCtx,
+			rows: []tenantBalance{{ID: 1, Amount: 10}},
+			want: []string{
+				"UPDATE balances SET amount = CASE id WHEN ? THEN ? ELSE amount END WHERE id IN (?) AND tenant_id = ?;",
+			},
+			wantArgs:   [][]interface{}{{int64(1), int64(10), int64(1), "tenant-a"}},
+			wantResult: sql.BatchResult{Rows: 1, Statements: 1, Affected: 1},
+		},
+		{
+			name:       "tenant missing",
+			rows:       []tenantBalance{{ID: 1, Amount: 10}},
+			wantResult: sql.BatchResult{Rows: 1},
+			wantErr:    sql.ErrNoTenant,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			db := sqltest.New(t, tt.opts...)
+			ctx := tt.ctx
+			if ctx == nil {
+				ctx = context.Background()
+			}
+
+			result, err := sql.ExecuteBatchUpdateContext(ctx, db, "balances", tt.rows, tt.batchOpts...)
+			if !errors.Is(err, tt.wantErr) {
+				t.Fatalf("ExecuteBatchUpdateContext() error = %v, want %v", err, tt.wantErr)
+			}
+			if result != tt.wantResult {
+				t.Errorf("result = %+v, want %+v", result, tt.wantResult)
+			}
+
+			executed := db.Statements()
+			if len(executed) != len(tt.want) {
+				t.Fatalf("executed %d statements %v, want %d", len(executed), executed, len(tt.want))
+			}
+			for i, s := range executed {
+				if s.Query != tt.want[i] {
+					t.Errorf("statement %d =\n%s\nwant\n%s", i, s.Query, tt.want[i])
+				}
+				if !reflect.DeepEqual(s.Args, tt.wantArgs[i]) {
+					t.Errorf("args of statement %d = %v, want %v", i, s.Args, tt.wantArgs[i])
+				}
+			}
+		})
+	}
+}
+
+func TestExecuteBatchUpdateInvalid(t *testing.T) {
+	tests := []struct {
+		name string
+		rows interface{}
+	}{
+		{name: "not a slice", rows: balance{ID: 1, Amount: 10}},
+		{name: "not structs", rows: []int64{1, 2}},
+		{name: "no set columns", rows: []balance{{ID: 1}}},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			db := sqltest.New(t)
+			if _, err := sql.ExecuteBatchUpdate(db, "balances", tt.rows); err == nil {
+				t.Error("ExecuteBatchUpdate() error = nil, want the rows refused")
+			}
+			db.AssertNotExecuted(t, `UPDATE`)
+		})
+	}
+}
@@ -0,0 +1,336 @@
+package sql
+
+import (
+	"context"
+	stdsql "database/sql"
+	"errors"
+	"strings"
+	"sync"
+	"sync/atomic"
+	"time"
+
+	"cloud.google.com/go/cloudsqlconn"
+	"cloud.google.com/go/cloudsqlconn/mysql/mysql"
+	_ "github.com/go-sql-driver/mysql"
+	"github.com/jmoiron/sqlx"
+	"go.uber.org/zap"
+)
+
+type DBConnection interface {
+	DB(autoRetry bool) *sqlx.DB
+	IsAlive() bool
+	Shutdown() error
+}
+
+// Connection is a wrapper around the sqlx.DB.
+// Create the struct with all public fields and use the DB method to get the database connection.
+type Connection struct {
+	sync.Mutex
+	Driver         string
+	DSN            string
+	Log            *zap.SugaredLogger
+	ConnectTimeout time.Duration
+	// Timeout of the helper queries, defaults to DefaultQueryTimeout and negative disables it.
+	// It can be overridden per call with WithQueryTimeout.
+	QueryTimeout time.Duration
+	// Options of the Cloud SQL connector, used to register the driver, see DriverFromDSN.
+	CloudSQL CloudSQLOptions
+	// Ping latency percentile above which Health reports the database as degraded, defaults to DefaultDegradedLatency.
+	DegradedLatency time.Duration
+	// Helper queries taking longer are logged as slow queries, zero disables the log.
+	SlowQueryThreshold time.Duration
+	// Number of prepared statements of the helper queries kept per connection, zero disables the cache.
+	StatementCacheSize int
+	// Read only replicas of the database, read helpers use them through ReadDB.
+	ReplicaDSNs []string
+	// Swapped by Reconnect while queries run, so it is read without holding the lock.
+	db           atomic.Pointer[sqlx.DB]
+	replicas     []*replica
+	replicasOnce sync.Once
+	nextReplica  atomic.Uint64
+	health       healthTracker
+	statements   stmtCache
+}
+
+// CloudSQLOptions configures the Cloud SQL connector of the cloudsql-mysql and cloudsql-postgres drivers.
+// The zero value connects over the private IP with automatic IAM authentication.
+type CloudSQLOptions struct {
+	// Connect over the public IP, e.g. in staging environments without private connectivity.
+	PublicIP bool
+	// Refresh the instance certificates on connect instead of in the background, for environments that throttle
+	// the CPU outside of requests (e.g. Cloud Run).
+	LazyRefresh bool
+	// Credentials file of the service account to connect with, the default credentials are used when empty.
+	CredentialsFile string
+	// Disable the automatic IAM authentication, the DSN then authenticates with a database password.
+	DisableIAMAuthN bool
+}
+
+// Returns the connector options.
+func (o CloudSQLOptions) options() []cloudsqlconn.Option {
+	dial := cloudsqlconn.WithPrivateIP()
+	if o.PublicIP {
+		dial = cloudsqlconn.WithPublicIP()
+	}
+
+	opts := []cloudsqlconn.Option{cloudsqlconn.WithDefaultDialOptions(dial)}
+	if !o.DisableIAMAuthN {
+		opts = append(opts, cloudsqlconn.WithIAMAuthN())
+	}
+	if o.LazyRefresh {
+		opts = append(opts, cloudsqlconn.WithLazyRefresh())
+	}
+	if o.CredentialsFile != "" {
+		opts = append(opts, cloudsqlconn.WithCredentialsFile(o.CredentialsFile))
+	}
+
+	return opts
+}
+
+type driver struct {
+	Name    string
+	Cleanup func() error
+	// DSN to open the driver with, when it differs from the given DSN.
+	DSN string
+}
+
+const cloudSQLPostgresPrefix = "cloudsql-postgres:"
+
+var ErrNoPostgresDriver = errors.New("no postgres driver registered, import github.com/jackc/pgx/v5/stdlib or github.com/lib/pq")
+
+// RegisterCloudSQLPostgres registers the Cloud SQL Postgres driver, the service sets it to enable Cloud SQL Postgres:
+//
+//	import "cloud.google.com/go/cloudsqlconn/postgres/pgxv5"
+//
+//	sql.RegisterCloudSQLPostgres = pgxv5.RegisterDriver
+var RegisterCloudSQLPostgres func(name string, opts ...cloudsqlconn.Option) (func() error, error)
+
+// DriverFromDSN determines the driver based on the DSN.
+//
+// Supported drivers:
+// - mysql (default)
+// - cloudsql-mysql (use the following DSN format: "myuser:mypass@cloudsql-mysql(project:region:instance)/mydb")
+// - postgres (DSN starting with postgres:// or postgresql://, uses the registered pgx or lib/pq driver)
+// - cloudsql-postgres (use the following DSN format: "cloudsql-postgres:host=project:region:instance user=myuser dbname=mydb sslmode=disable")
+//
+// The Cloud SQL drivers connect with the default CloudSQLOptions, see DriverFromDSNWithOptions.
+func DriverFromDSN(dsn string) (d driver, err error) {
+	return DriverFromDSNWithOptions(dsn, CloudSQLOptions{})
+}
+
+// DriverFromDSNWithOptions determines the driver based on the DSN, like DriverFromDSN,
+// and registers the Cloud SQL drivers with the options.
+func DriverFromDSNWithOptions(dsn string, o CloudSQLOptions) (d driver, err error) {
+	d.Name = "mysql"
+
+	// CloudSQL Postgres
+	if strings.HasPrefix(dsn, cloudSQLPostgresPrefix) {
+		d.Name = "cloudsql-postgres"
+		d.DSN = driverDSN(dsn)
+		if RegisterCloudSQLPostgres == nil {
+			return d, errors.New("cloud sql postgres driver not registered, set sql.RegisterCloudSQLPostgres")
+		}
+		sqlx.BindDriver(d.Name, sqlx.DOLLAR)
+		d.Cleanup, err = RegisterCloudSQLPostgres(d.Name, o.options()...)
+		return d, err
+	}
+
+	// Postgres
+	if IsPostgresDSN(dsn) {
+		d.Name, err = postgresDriver()
+		return d, err
+	}
+
+	// CloudSQL MySQL
+	if strings.Contains(dsn, "cloudsql-mysql") {
+		d.Name = "cloudsql-mysql"
+		d.Cleanup, err = mysql.RegisterDriver("cloudsql-mysql", o.options()...)
+	}
+
+	return d, err
+}
+
+// Returns the DSN to open the driver with, see DriverFromDSN.
+func driverDSN(dsn string) string {
+	return strings.TrimPrefix(dsn, cloudSQLPostgresPrefix)
+}
+
+// IsPostgresDSN returns true for Postgres and Cloud SQL Postgres DSNs.
+func IsPostgresDSN(dsn string) bool {
+	return strings.HasPrefix(dsn, "postgres://") || strings.HasPrefix(dsn, "postgresql://") || strings.HasPrefix(dsn, cloudSQLPostgresPrefix)
+}
+
+// IsPostgres returns true if the driver connects to Postgres.
+func IsPostgres(driver string) bool {
+	return sqlx.BindType(driver) == sqlx.DOLLAR
+}
+
+// Returns the name of the registered Postgres driver, pgx is preferred over lib/pq.
+func postgresDriver() (string, error) {
+	drivers := stdsql.Drivers()
+	for _, name := range []string{"pgx", "postgres"} {
+		for _, d := range drivers {
+			if d == name {
+				return name, nil
+			}
+		}
+	}
+
+	return "", ErrNoPostgresDriver
+}
+
+func (c *Connection) queryTimeout() time.Duration {
+	if c.QueryTimeout == 0 {
+		return DefaultQueryTimeout
+	}
+
+	return c.QueryTimeout
+}
+
+// Returns the database connection.
+// If the connection is not yet established, it will try to establish the connection.
+// If autoRetry is true, it will keep trying to establish the connection until it is successful.
+func (c *Connection) DB(autoRetry bool) *sqlx.DB {
+	if db := c.db.Load(); db != nil {
+		return db
+	}
+
+	c.setupDB(autoRetry)
+
+	return c.db.Load()
+}
+
+// Returns true if the database connection is alive.
+// If the connection is not yet established, it will always return false.
+func (c *Connection) IsAlive() bool {
+	db := c.db.Load()
+	return db != nil && db.Ping() == nil
+}
+
+// Set up the database connection.
+// If autoRetry is true, it will keep trying to establish the connection until it is successful.
+//
+// This method is thread-safe.
+func (c *Connection) setupDB(autoRetry bool) {
+	c.Lock()
+
+	if c.db.Load() != nil {
+		c.Unlock()
+		return
+	}
+
+	db, err := sqlx.Open(c.Driver, c.DSN)
+
+	if err == nil {
+		err = db.Ping()
+		if err == nil {
+			c.Log.Info("Successfully connected to database")
+			c.db.Store(db)
+			c.Unlock()
+			return
+		}
+	}
+
+	c.Log.Errorf("Could not create database connection. %s", err.Error())
+
+	if !autoRetry {
+		c.Unlock()
+		return
+	}
+
+	c.Log.Infof("Retrying to create database connection in %s...", c.ConnectTimeout.String())
+	time.Sleep(c.ConnectTimeout)
+
+	c.Unlock()
+	c.setupDB(true)
+}
+
+// Reconnect replaces the database connection with a connection to the DSN, e.g. after the password rotated.
+// The DSN must use the driver of the connection.
+// The new connection is only used when it is alive, the old connection is closed once its queries finished.
+//
+// This method is thread-safe.
+func (c *Connection) Reconnect(dsn string) error {
+	dsn = driverDSN(dsn)
+	db, err := sqlx.Open(c.Driver, dsn)
+	if err != nil {
+		return err
+	}
+
+	if err := db.Ping(); err != nil {
+		db.Close()
+		return err
+	}
+
+	c.Lock()
+	old := c.db.Swap(db)
+	c.DSN = dsn
+	c.Unlock()
+
+	// The statements are prepared on the old database.
+	c.statements.clear()
+
+	if old != nil {
+		// Close waits for the running queries, so it does not block the caller.
+		go old.Close()
+	}
+
+	c.Log.Info("Reconnected to database")
+
+	return nil
+}
+
+// Close the database connection.
+// If the connection is not yet established, it will do nothing.
+//
+// Will return an error if the database could not be closed.
+//
+// This method is thread-safe.
+func (c *Connection) Shutdown() error {
+	c.Lock()
+	defer c.Unlock()
+
+	db := c.db.Load()
+	if db == nil {
+		return nil
+	}
+
+	c.Log.Info("Shutting down the database so we don't keep connections open")
+
+	c.statements.clear()
+
+	if err := c.closeReplicas(); err != nil {
+		c.Log.Infof("Could not close database replicas %v", err.Error())
+	}
+
+	ctx, cancel := context.WithTimeout(context.Background(), c.ConnectTimeout)
+	defer cancel()
+
+	err := db.Close()
+	if err != nil {
+		c.Log.Infof("Could not close database %v", err.Error())
+		return err
+	}
+
+	for {
+		if err = db.Ping(); err.Error() == "sql: database is closed" {
+			// Database is closed successfully.
+			break
+		}
+
+		select {
+		case <-ctx.Done():
+			if err = ctx.Err(); err != nil {
+				c.Log.Infof("Could not close database. %v", err.Error())
+				return err
+			}
+			// Database is closed successfully.
+			break
+		default:
+		}
+	}
+
+	c.Log.Info("Database shut down")
+
+	return nil
+}
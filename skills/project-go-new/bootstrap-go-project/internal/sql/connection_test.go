@@ -0,0 +1,156 @@
+package sql
+
+import (
+	"context"
+	stdsql "database/sql"
+	sqldriver "database/sql/driver"
+	"fmt"
+	"io"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/jmoiron/sqlx"
+	"go.uber.org/zap"
+)
+
+// Driver answering every query with no rows, so connections are opened without a database.
+const testDriver = "sql_test"
+
+func init() {
+	stdsql.Register(testDriver, emptyDriver{})
+}
+
+type emptyDriver struct{}
+
+func (emptyDriver) Open(string) (sqldriver.Conn, error) {
+	return emptyConn{}, nil
+}
+
+type emptyConn struct{}
+
+func (emptyConn) Prepare(string) (sqldriver.Stmt, error) {
+	return emptyStmt{}, nil
+}
+
+func (emptyConn) Close() error {
+	return nil
+}
+
+func (emptyConn) Begin() (sqldriver.Tx, error) {
+	return emptyTx{}, nil
+}
+
+type emptyTx struct{}
+
+func (emptyTx) Commit() error {
+	return nil
+}
+
+func (emptyTx) Rollback() error {
+	return nil
+}
+
+type emptyStmt struct{}
+
+func (emptyStmt) Close() error {
+	return nil
+}
+
+func (emptyStmt) NumInput() int {
+	return -1
+}
+
+func (emptyStmt) Exec([]sqldriver.Value) (sqldriver.Result, error) {
+	return sqldriver.RowsAffected(0), nil
+}
+
+func (emptyStmt) Query([]sqldriver.Value) (sqldriver.Rows, error) {
+	return emptyRows{}, nil
+}
+
+type emptyRows struct{}
+
+func (emptyRows) Columns() []string {
+	return []string{"id"}
+}
+
+func (emptyRows) Close() error {
+	return nil
+}
+
+func (emptyRows) Next([]sqldriver.Value) error {
+	return io.EOF
+}
+
+// Run with -race, Reconnect swaps the database while the queries read it.
+func TestConnectionReconnectWhileQuerying(t *testing.T) {
+	c := &Connection{Driver: testDriver, DSN: "initial", Log: zap.NewNop().Sugar()}
+	if c.DB(false) == nil {
+		t.Fatal("DB() = nil, want a connection")
+	}
+
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+
+	var wg sync.WaitGroup
+	for i := 0; i < 4; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+
+			for ctx.Err() == nil {
+				// A query on a database that was replaced and closed in the meantime fails, it is not retried.
+				var ids []int64
+				_ = c.DB(true).SelectContext(ctx, &ids, "SELECT id FROM users")
+				c.IsAlive()
+				c.Health(ctx)
+			}
+		}()
+	}
+
+	for i := 0; i < 20; i++ {
+		if err := c.Reconnect(fmt.Sprintf("rotated-%d", i)); err != nil {
+			t.Fatalf("Reconnect() error = %v", err)
+		}
+		time.Sleep(time.Millisecond)
+	}
+
+	cancel()
+	wg.Wait()
+
+	if got := c.DSN; got != "rotated-19" {
+		t.Errorf("DSN = %q, want %q", got, "rotated-19")
+	}
+	if err := c.Shutdown(); err != nil {
+		t.Errorf("Shutdown() error = %v", err)
+	}
+	if c.IsAlive() {
+		t.Error("IsAlive() = true after Shutdown")
+	}
+}
+
+type testUser struct {
+	ID   int64  `db:"id" sql:"update"`
+	Name string `db:"name" sql:"insert,update"`
+}
+
+func TestWithTxCachesStatements(t *testing.T) {
+	c := &Connection{Driver: testDriver, DSN: "initial", Log: zap.NewNop().Sugar(), StatementCacheSize: 10}
+	defer c.Shutdown()
+
+	for i := 0; i < 2; i++ {
+		err := WithTx(context.Background(), c, func(ctx context.Context, tx *sqlx.Tx) error {
+			return ExecuteUpdateTx(ctx, tx, "users", &testUser{ID: 1, Name: "Alice"})
+		})
+		if err != nil {
+			t.Fatalf("WithTx() error = %v", err)
+		}
+	}
+
+	c.statements.Lock()
+	defer c.statements.Unlock()
+	if c.statements.cache == nil || c.statements.cache.Len() != 1 {
+		t.Errorf("cached statements = %v, want the update statement", c.statements.cache)
+	}
+}
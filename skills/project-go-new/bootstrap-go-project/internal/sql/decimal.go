@@ -0,0 +1,224 @@
+package sql
+
+import (
+	sqldriver "database/sql/driver"
+	"errors"
+	"fmt"
+	"math/big"
+	"strings"
+)
+
+var ErrInvalidDecimal = errors.New("invalid decimal")
+
+// Decimal is an exact decimal number for DECIMAL and NUMERIC columns, e.g. amounts of money.
+// It is written and read as text, so the value never passes through a float64.
+//
+// The zero value is 0. A decimal keeps its scale, 1.50 is written as "1.50".
+type Decimal struct {
+	unscaled *big.Int
+	scale    int32
+}
+
+// NewDecimal returns the decimal unscaled * 10^-scale, e.g. NewDecimal(1050, 2) is 10.50.
+func NewDecimal(unscaled int64, scale int32) Decimal {
+	return Decimal{unscaled: big.NewInt(unscaled), scale: scale}
+}
+
+// ParseDecimal parses a decimal like "-10.50", exponents, NaN and infinity are not supported.
+func ParseDecimal(s string) (Decimal, error) {
+	digits := s
+	if strings.HasPrefix(digits, "-") || strings.HasPrefix(digits, "+") {
+		digits = digits[1:]
+	}
+
+	whole, fraction, _ := strings.Cut(digits, ".")
+	if whole == "" && fraction == "" {
+		return Decimal{}, fmt.Errorf("%w: %q", ErrInvalidDecimal, s)
+	}
+	for _, c := range whole + fraction {
+		if c < '0' || c > '9' {
+			return Decimal{}, fmt.Errorf("%w: %q", ErrInvalidDecimal, s)
+		}
+	}
+
+	unscaled, _ := new(big.Int).SetString(whole+fraction, 10)
+	if strings.HasPrefix(s, "-") {
+		unscaled.Neg(unscaled)
+	}
+
+	return Decimal{unscaled: unscaled, scale: int32(len(fraction))}, nil
+}
+
+// MustParseDecimal parses the decimal like ParseDecimal, and panics when it is invalid, e.g. for constants.
+func MustParseDecimal(s string) Decimal {
+	d, err := ParseDecimal(s)
+	if err != nil {
+		panic(err)
+	}
+
+	return d
+}
+
+// Returns the unscaled value, the zero value has none.
+func (d Decimal) value() *big.Int {
+	if d.unscaled == nil {
+		return new(big.Int)
+	}
+
+	return d.unscaled
+}
+
+// Returns the unscaled value at the scale, which is not below the scale of the decimal.
+func (d Decimal) rescale(scale int32) *big.Int {
+	exp := new(big.Int).Exp(big.NewInt(10), big.NewInt(int64(scale-d.scale)), nil)
+	return exp.Mul(exp, d.value())
+}
+
+// Scale returns the number of digits after the decimal point.
+func (d Decimal) Scale() int32 {
+	return d.scale
+}
+
+// Sign returns -1, 0 or 1 for a negative, zero or positive decimal.
+func (d Decimal) Sign() int {
+	return d.value().Sign()
+}
+
+// IsZero returns true if the decimal is 0, at any scale.
+func (d Decimal) IsZero() bool {
+	return d.Sign() == 0
+}
+
+// Cmp compares the decimals, returning -1, 0 or 1 when d is less than, equal to or greater than o.
+// Decimals of different scales are equal when they hold the same value, e.g. 1.5 and 1.50.
+func (d Decimal) Cmp(o Decimal) int {
+	scale := max(d.scale, o.scale)
+	return d.rescale(scale).Cmp(o.rescale(scale))
+}
+
+// Equal returns true if the decimals hold the same value.
+func (d Decimal) Equal(o Decimal) bool {
+	return d.Cmp(o) == 0
+}
+
+// Add returns d + o, at the largest scale of both.
+func (d Decimal) Add(o Decimal) Decimal {
+	scale := max(d.scale, o.scale)
+	return Decimal{unscaled: d.rescale(scale).Add(d.rescale(scale), o.rescale(scale)), scale: scale}
+}
+
+// Sub returns d - o, at the largest scale of both.
+func (d Decimal) Sub(o Decimal) Decimal {
+	return d.Add(o.Neg())
+}
+
+// Neg returns -d.
+func (d Decimal) Neg() Decimal {
+	return Decimal{unscaled: new(big.Int).Neg(d.value()), scale: d.scale}
+}
+
+// Mul returns d * o, at the sum of both scales.
+func (d Decimal) Mul(o Decimal) Decimal {
+	return Decimal{unscaled: new(big.Int).Mul(d.value(), o.value()), scale: d.scale + o.scale}
+}
+
+// Round returns the decimal at the scale, rounding half away from zero, e.g. 2.345 rounds to 2.35 at scale 2.
+func (d Decimal) Round(scale int32) Decimal {
+	if scale >= d.scale {
+		return Decimal{unscaled: d.rescale(scale), scale: scale}
+	}
+
+	exp := new(big.Int).Exp(big.NewInt(10), big.NewInt(int64(d.scale-scale)), nil)
+	quotient, remainder := new(big.Int).QuoRem(d.value(), exp, new(big.Int))
+
+	// Round up when twice the remainder is at least the divisor.
+	if remainder.Abs(remainder).Lsh(remainder, 1).Cmp(exp) >= 0 {
+		quotient.Add(quotient, big.NewInt(int64(d.Sign())))
+	}
+
+	return Decimal{unscaled: quotient, scale: scale}
+}
+
+// String returns the decimal at its scale, e.g. "-10.50".
+func (d Decimal) String() string {
+	digits := new(big.Int).Abs(d.value()).String()
+
+	sign := ""
+	if d.Sign() < 0 {
+		sign = "-"
+	}
+
+	if d.scale <= 0 {
+		return sign + digits + strings.Repeat("0", int(-d.scale))
+	}
+
+	if pad := int(d.scale) + 1 - len(digits); pad > 0 {
+		digits = strings.Repeat("0", pad) + digits
+	}
+	point := len(digits) - int(d.scale)
+
+	return sign + digits[:point] + "." + digits[point:]
+}
+
+// Value writes the decimal as text, so the database converts it without loss.
+func (d Decimal) Value() (sqldriver.Value, error) {
+	return d.String(), nil
+}
+
+// Scan reads the decimal from the text of a DECIMAL or NUMERIC column, or an integer.
+// Floats are refused as they may have lost precision, use NullDecimal for nullable columns.
+func (d *Decimal) Scan(src interface{}) error {
+	var err error
+	switch v := src.(type) {
+	case []byte:
+		*d, err = ParseDecimal(string(v))
+	case string:
+		*d, err = ParseDecimal(v)
+	case int64:
+		*d = NewDecimal(v, 0)
+	case nil:
+		err = fmt.Errorf("%w: can not scan NULL, use NullDecimal", ErrInvalidDecimal)
+	default:
+		err = fmt.Errorf("%w: can not scan %T", ErrInvalidDecimal, src)
+	}
+
+	return err
+}
+
+// MarshalJSON writes the decimal as a JSON string, JavaScript numbers can not hold every decimal.
+func (d Decimal) MarshalJSON() ([]byte, error) {
+	return []byte(`"` + d.String() + `"`), nil
+}
+
+// UnmarshalJSON reads the decimal from a JSON string or number, the number is parsed from its text.
+func (d *Decimal) UnmarshalJSON(data []byte) error {
+	var err error
+	*d, err = ParseDecimal(strings.Trim(string(data), `"`))
+	return err
+}
+
+// NullDecimal is a Decimal that may be NULL, like sql.NullString.
+type NullDecimal struct {
+	Decimal Decimal
+	Valid   bool
+}
+
+// Value writes NULL when the decimal is not valid.
+func (n NullDecimal) Value() (sqldriver.Value, error) {
+	if !n.Valid {
+		return nil, nil
+	}
+
+	return n.Decimal.Value()
+}
+
+// Scan reads the decimal, NULL makes it invalid.
+func (n *NullDecimal) Scan(src interface{}) error {
+	if src == nil {
+		n.Decimal, n.Valid = Decimal{}, false
+		return nil
+	}
+
+	n.Valid = true
+	return n.Decimal.Scan(src)
+}
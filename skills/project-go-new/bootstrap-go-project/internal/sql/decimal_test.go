@@ -0,0 +1,194 @@
+package sql_test
+
+import (
+	"encoding/json"
+	"errors"
+	"testing"
+
+	"gitlab.com/btcdirect-api/bootstrap-go-service/internal/sql"
+)
+
+func TestParseDecimal(t *testing.T) {
+	tests := []struct {
+		in        string
+		want      string
+		wantScale int32
+		wantErr   bool
+	}{
+		{in: "10.50", want: "10.50", wantScale: 2},
+		{in: "-10.50", want: "-10.50", wantScale: 2},
+		{in: "+3", want: "3", wantScale: 0},
+		{in: "0.001", want: "0.001", wantScale: 3},
+		{in: "-0.5", want: "-0.5", wantScale: 1},
+		{in: ".5", want: "0.5", wantScale: 1},
+		{in: "1.", want: "1", wantScale: 0},
+		{in: "123456789012345678901234567890.123456789", want: "123456789012345678901234567890.123456789", wantScale: 9},
+		{in: "", wantErr: true},
+		{in: "-", wantErr: true},
+		{in: ".", wantErr: true},
+		{in: "1e3", wantErr: true},
+		{in: "1.2.3", wantErr: true},
+		{in: "NaN", wantErr: true},
+		{in: " 1", wantErr: true},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.in, func(t *testing.T) {
+			d, err := sql.ParseDecimal(tt.in)
+			if tt.wantErr {
+				if !errors.Is(err, sql.ErrInvalidDecimal) {
+					t.Errorf("ParseDecimal(%q) error = %v, want ErrInvalidDecimal", tt.in, err)
+				}
+				return
+			}
+			if err != nil {
+				t.Fatalf("ParseDecimal(%q) error = %v", tt.in, err)
+			}
+			if got := d.String(); got != tt.want {
+				t.Errorf("ParseDecimal(%q) = %s, want %s", tt.in, got, tt.want)
+			}
+			if got := d.Scale(); got != tt.wantScale {
+				t.Errorf("ParseDecimal(%q).Scale() = %d, want %d", tt.in, got, tt.wantScale)
+			}
+		})
+	}
+}
+
+func TestDecimalRound(t *testing.T) {
+	tests := []struct {
+		in    string
+		scale int32
+		want  string
+	}{
+		{in: "2.345", scale: 2, want: "2.35"},
+		{in: "2.344", scale: 2, want: "2.34"},
+		{in: "-2.345", scale: 2, want: "-2.35"},
+		{in: "-2.344", scale: 2, want: "-2.34"},
+		{in: "0.5", scale: 0, want: "1"},
+		{in: "-0.5", scale: 0, want: "-1"},
+		{in: "0.49", scale: 0, want: "0"},
+		{in: "9.995", scale: 2, want: "10.00"},
+		{in: "1.5", scale: 3, want: "1.500"},
+		{in: "1.50", scale: 2, want: "1.50"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.in, func(t *testing.T) {
+			if got := sql.MustParseDecimal(tt.in).Round(tt.scale).String(); got != tt.want {
+				t.Errorf("%s.Round(%d) = %s, want %s", tt.in, tt.scale, got, tt.want)
+			}
+		})
+	}
+}
+
+func TestDecimalArithmetic(t *testing.T) {
+	tests := []struct {
+		name string
+		got  sql.Decimal
+		want string
+	}{
+		{name: "add", got: sql.MustParseDecimal("10.50").Add(sql.MustParseDecimal("0.125")), want: "10.625"},
+		{name: "sub", got: sql.MustParseDecimal("1").Sub(sql.MustParseDecimal("1.01")), want: "-0.01"},
+		{name: "mul", got: sql.MustParseDecimal("1.5").Mul(sql.MustParseDecimal("-0.20")), want: "-0.300"},
+		{name: "new", got: sql.NewDecimal(1050, 2), want: "10.50"},
+		{name: "zero value", got: sql.Decimal{}, want: "0"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := tt.got.String(); got != tt.want {
+				t.Errorf("%s = %s, want %s", tt.name, got, tt.want)
+			}
+		})
+	}
+
+	if !sql.MustParseDecimal("1.5").Equal(sql.MustParseDecimal("1.50")) {
+		t.Error("1.5 is not equal to 1.50")
+	}
+}
+
+func TestDecimalJSON(t *testing.T) {
+	type payment struct {
+		Amount sql.Decimal `json:"amount"`
+	}
+
+	tests := []struct {
+		name     string
+		in       string
+		wantJSON string
+		wantErr  bool
+	}{
+		{name: "string", in: `{"amount":"10.50"}`, wantJSON: `{"amount":"10.50"}`},
+		{name: "number", in: `{"amount":10.50}`, wantJSON: `{"amount":"10.50"}`},
+		{name: "negative", in: `{"amount":"-0.01"}`, wantJSON: `{"amount":"-0.01"}`},
+		{name: "large", in: `{"amount":"99999999999999999999.99"}`, wantJSON: `{"amount":"99999999999999999999.99"}`},
+		{name: "exponent", in: `{"amount":1e3}`, wantErr: true},
+		{name: "invalid", in: `{"amount":"ten"}`, wantErr: true},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			var p payment
+			err := json.Unmarshal([]byte(tt.in), &p)
+			if tt.wantErr {
+				if !errors.Is(err, sql.ErrInvalidDecimal) {
+					t.Errorf("Unmarshal(%s) error = %v, want ErrInvalidDecimal", tt.in, err)
+				}
+				return
+			}
+			if err != nil {
+				t.Fatalf("Unmarshal(%s) error = %v", tt.in, err)
+			}
+
+			out, err := json.Marshal(p)
+			if err != nil {
+				t.Fatalf("Marshal() error = %v", err)
+			}
+			if string(out) != tt.wantJSON {
+				t.Errorf("round trip of %s = %s, want %s", tt.in, out, tt.wantJSON)
+			}
+		})
+	}
+}
+
+func TestDecimalScan(t *testing.T) {
+	tests := []struct {
+		name    string
+		src     interface{}
+		want    string
+		wantErr bool
+	}{
+		{name: "bytes", src: []byte("10.50"), want: "10.50"},
+		{name: "string", src: "-1.5", want: "-1.5"},
+		{name: "integer", src: int64(42), want: "42"},
+		{name: "float", src: 1.5, wantErr: true},
+		{name: "null", src: nil, wantErr: true},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			var d sql.Decimal
+			err := d.Scan(tt.src)
+			if tt.wantErr {
+				if !errors.Is(err, sql.ErrInvalidDecimal) {
+					t.Errorf("Scan(%v) error = %v, want ErrInvalidDecimal", tt.src, err)
+				}
+				return
+			}
+			if err != nil {
+				t.Fatalf("Scan(%v) error = %v", tt.src, err)
+			}
+			if got := d.String(); got != tt.want {
+				t.Errorf("Scan(%v) = %s, want %s", tt.src, got, tt.want)
+			}
+		})
+	}
+
+	var n sql.NullDecimal
+	if err := n.Scan(nil); err != nil || n.Valid {
+		t.Errorf("NullDecimal.Scan(nil) = %v, %v, want invalid", n, err)
+	}
+	if v, err := n.Value(); v != nil || err != nil {
+		t.Errorf("NullDecimal.Value() = %v, %v, want NULL", v, err)
+	}
+}
@@ -0,0 +1,117 @@
+package sql
+
+import (
+	"context"
+	stdsql "database/sql"
+	"errors"
+	"fmt"
+
+	"github.com/jmoiron/sqlx"
+)
+
+// DefaultFetchSize is the number of rows QueryEach fetches at once from Postgres when no fetch size is given.
+const DefaultFetchSize = 1000
+
+// WithFetchSize sets the number of rows QueryEach fetches at once from Postgres.
+func WithFetchSize(n int) QueryOption {
+	return func(o *queryOptions) {
+		o.fetchSize = n
+	}
+}
+
+// QueryEach runs the query and calls fn for each row, without loading the result into memory,
+// e.g. for jobs reconciling large tables. Scan the row with rows.StructScan or rows.Scan,
+// an error returned by fn stops the iteration and is returned.
+//
+// MySQL streams the rows from the connection as they are read. Postgres reads them through a cursor,
+// in batches of the fetch size, see WithFetchSize.
+//
+// The query timeout of the connection does not apply, as the iteration takes as long as fn takes;
+// the query is only limited by the deadline of ctx. The query is read from a replica unless WithPrimary is given.
+// The query is not scoped to the tenant in the context, add the tenant condition to the query.
+func QueryEach(ctx context.Context, conn DBConnection, query string, args []interface{}, fn func(rows *sqlx.Rows) error, opts ...QueryOption) error {
+	o := newQueryOptions(opts)
+	db := readDB(conn, o)
+
+	ctx = withSlowQueryLog(ctx, conn)
+	query = db.Rebind(query)
+
+	if IsPostgres(db.DriverName()) {
+		return queryEachCursor(ctx, db, query, args, o.fetchSize, fn)
+	}
+
+	done := observeQuery(ctx, query, nil)
+	rows, err := db.QueryxContext(ctx, query, args...)
+	done()
+	if err != nil {
+		return err
+	}
+	defer rows.Close()
+
+	for rows.Next() {
+		if err := fn(rows); err != nil {
+			return err
+		}
+	}
+
+	return rows.Err()
+}
+
+// Name of the cursor of QueryEach, cursors are scoped to their transaction.
+const eachCursor = "query_each"
+
+// Reads the rows of the query in batches through a cursor, within a read only transaction.
+func queryEachCursor(ctx context.Context, db *sqlx.DB, query string, args []interface{}, fetchSize int, fn func(rows *sqlx.Rows) error) error {
+	if fetchSize <= 0 {
+		fetchSize = DefaultFetchSize
+	}
+
+	tx, err := db.BeginTxx(ctx, &stdsql.TxOptions{ReadOnly: true})
+	if err != nil {
+		return err
+	}
+	defer tx.Rollback()
+
+	done := observeQuery(ctx, query, nil)
+	_, err = tx.ExecContext(ctx, fmt.Sprintf("DECLARE %s NO SCROLL CURSOR FOR %s", eachCursor, query), args...)
+	done()
+	if err != nil {
+		return err
+	}
+
+	fetch := fmt.Sprintf("FETCH %d FROM %s", fetchSize, eachCursor)
+	for {
+		n, err := fetchEach(ctx, tx, fetch, fn)
+		if err != nil {
+			return err
+		}
+		if n < fetchSize {
+			break
+		}
+	}
+
+	if err := tx.Commit(); err != nil && !errors.Is(err, stdsql.ErrTxDone) {
+		return err
+	}
+
+	return nil
+}
+
+// Fetches a batch from the cursor and returns the number of rows.
+func fetchEach(ctx context.Context, tx *sqlx.Tx, fetch string, fn func(rows *sqlx.Rows) error) (int, error) {
+	rows, err := tx.QueryxContext(ctx, fetch)
+	if err != nil {
+		return 0, err
+	}
+	defer rows.Close()
+
+	n := 0
+	for rows.Next() {
+		n++
+		if err := fn(rows); err != nil {
+			return n, err
+		}
+	}
+
+	return n, rows.Err()
+}
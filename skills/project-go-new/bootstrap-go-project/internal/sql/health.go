@@ -0,0 +1,121 @@
+package sql
+
+import (
+	"context"
+	"sort"
+	"sync"
+	"time"
+)
+
+// DefaultDegradedLatency is the ping latency percentile above which the database is degraded,
+// when the Connection has no DegradedLatency.
+const DefaultDegradedLatency = 250 * time.Millisecond
+
+// Number of recent pings the latency percentile is computed over.
+const healthSamples = 20
+
+// HealthStatus is the state of the database connection.
+type HealthStatus string
+
+const (
+	// HealthUp means the database responds in time.
+	HealthUp HealthStatus = "up"
+	// HealthDegraded means the database responds, but the 95th percentile of the recent pings is slow.
+	HealthDegraded HealthStatus = "degraded"
+	// HealthDown means the database does not respond.
+	HealthDown HealthStatus = "down"
+)
+
+// Health of the database connection.
+type Health struct {
+	Status HealthStatus
+	// Latency of the last ping.
+	Latency time.Duration
+	// 95th percentile of the latency of the recent pings.
+	P95Latency time.Duration
+	// Last ping error, kept after the database recovered.
+	LastError   string
+	LastErrorAt time.Time
+}
+
+// Latencies and errors of the recent pings.
+type healthTracker struct {
+	sync.Mutex
+	latencies   []time.Duration
+	next        int
+	lastError   string
+	lastErrorAt time.Time
+}
+
+func (t *healthTracker) record(latency time.Duration, err error) {
+	t.Lock()
+	defer t.Unlock()
+
+	if err != nil {
+		t.lastError = err.Error()
+		t.lastErrorAt = time.Now()
+		return
+	}
+
+	if len(t.latencies) < healthSamples {
+		t.latencies = append(t.latencies, latency)
+		return
+	}
+	t.latencies[t.next] = latency
+	t.next = (t.next + 1) % healthSamples
+}
+
+func (t *healthTracker) p95() time.Duration {
+	t.Lock()
+	defer t.Unlock()
+
+	if len(t.latencies) == 0 {
+		return 0
+	}
+
+	sorted := append([]time.Duration(nil), t.latencies...)
+	sort.Slice(sorted, func(i, j int) bool { return sorted[i] < sorted[j] })
+
+	return sorted[(len(sorted)*95-1)/100]
+}
+
+// Health pings the database and returns its health.
+//
+// The database is degraded when the 95th percentile of the recent pings exceeds the DegradedLatency,
+// so a single slow ping does not change the status. A database that is not connected yet, or does not respond
+// within the deadline of ctx, is down.
+func (c *Connection) Health(ctx context.Context) Health {
+	db := c.db.Load()
+	if db == nil {
+		return Health{Status: HealthDown, LastError: "not connected"}
+	}
+
+	start := time.Now()
+	err := db.PingContext(ctx)
+	latency := time.Since(start)
+	c.health.record(latency, err)
+
+	h := Health{
+		Status:     HealthUp,
+		Latency:    latency,
+		P95Latency: c.health.p95(),
+	}
+
+	c.health.Lock()
+	h.LastError, h.LastErrorAt = c.health.lastError, c.health.lastErrorAt
+	c.health.Unlock()
+
+	degraded := c.DegradedLatency
+	if degraded == 0 {
+		degraded = DefaultDegradedLatency
+	}
+
+	switch {
+	case err != nil:
+		h.Status = HealthDown
+	case h.P95Latency > degraded:
+		h.Status = HealthDegraded
+	}
+
+	return h
+}
@@ -0,0 +1,447 @@
+package sql
+
+import (
+	"context"
+	stdsql "database/sql"
+	"fmt"
+	"reflect"
+	"sort"
+	"strings"
+
+	"github.com/jmoiron/sqlx"
+)
+
+// TODO Move to pkg and add comments
+// Data with a uuid tagged primary key returns a zero id, use ExecuteInsertUUID to get its id.
+func ExecuteInsert(conn DBConnection, table string, data interface{}) (int64, error) {
+	return ExecuteInsertContext(context.Background(), conn, table, data)
+}
+
+// ExecuteInsertContext inserts the data and returns its id, the query is limited by the timeout of the connection.
+// Deadlocks and lock wait timeouts are retried, see RetryError.
+func ExecuteInsertContext(ctx context.Context, conn DBConnection, table string, data interface{}) (int64, error) {
+
+	db := conn.DB(true)
+
+	ctx, cancelfunc := queryContext(ctx, conn)
+	defer cancelfunc()
+
+	var id int64
+	err := retryQuery(ctx, false, func() (err error) {
+		id, err = executeInsert(ctx, db, table, data)
+		return err
+	})
+
+	return id, err
+}
+
+// ExecuteInsertTx inserts the data within the transaction, see WithTx.
+func ExecuteInsertTx(ctx context.Context, tx *sqlx.Tx, table string, data interface{}) (int64, error) {
+	return executeInsert(ctx, tx, table, data)
+}
+
+func executeInsert(ctx context.Context, e sqlx.ExtContext, table string, data interface{}) (int64, error) {
+	data, err := setTenant(ctx, data)
+	if err != nil {
+		return 0, err
+	}
+
+	data = setTimestamps(data, true)
+
+	data, uuid, err := setUUID(data)
+	if err != nil {
+		return 0, err
+	}
+
+	query, err := generateInsertQuery(table, data)
+	if err != nil {
+		return 0, err
+	}
+
+	// The id of a UUID primary key is generated before the insert, there is no insert id.
+	if uuid != "" {
+		defer observeQuery(ctx, query, namedParameters(query))()
+
+		_, err := namedExec(ctx, e, query, data)
+		return 0, err
+	}
+
+	// Postgres does not support LastInsertId, the id is returned by the insert instead.
+	if IsPostgres(e.DriverName()) {
+		return executePostgresInsert(ctx, e, query, data)
+	}
+
+	done := observeQuery(ctx, query, namedParameters(query))
+	res, err := namedExec(ctx, e, query, data)
+	done()
+
+	if err != nil {
+		return 0, err
+	}
+
+	lastId, err := res.LastInsertId()
+	if err != nil {
+		return 0, err
+	}
+
+	return lastId, nil
+}
+
+func executePostgresInsert(ctx context.Context, e sqlx.ExtContext, query string, data interface{}) (int64, error) {
+	query = strings.TrimSuffix(query, ";") + " RETURNING id;"
+	defer observeQuery(ctx, query, namedParameters(query))()
+
+	rows, err := namedQuery(ctx, e, query, data)
+	if err != nil {
+		return 0, err
+	}
+	defer rows.Close()
+
+	var id int64
+	if rows.Next() {
+		err = rows.Scan(&id)
+	}
+	if err == nil {
+		err = rows.Err()
+	}
+
+	return id, err
+}
+
+func ExecuteUpdate(conn DBConnection, table string, data interface{}) error {
+	return ExecuteUpdateContext(context.Background(), conn, table, data)
+}
+
+// ExecuteUpdateContext updates the non-zero columns of the data, the query is limited by the timeout of the connection.
+// Transient errors are retried, see RetryError.
+func ExecuteUpdateContext(ctx context.Context, conn DBConnection, table string, data interface{}) error {
+
+	db := conn.DB(true)
+
+	ctx, cancelfuc := queryContext(ctx, conn)
+	defer cancelfuc()
+
+	return retryQuery(ctx, true, func() error {
+		return executeUpdate(ctx, db, table, data)
+	})
+}
+
+// ExecuteUpdateTx updates the data within the transaction, see WithTx.
+func ExecuteUpdateTx(ctx context.Context, tx *sqlx.Tx, table string, data interface{}) error {
+	return executeUpdate(ctx, tx, table, data)
+}
+
+func executeUpdate(ctx context.Context, e sqlx.ExtContext, table string, data interface{}) error {
+	data, err := setTenant(ctx, data)
+	if err != nil {
+		return err
+	}
+
+	data = setTimestamps(data, false)
+
+	query, err := generateUpdateQuery(table, data)
+
+	if err != nil {
+		return err
+	}
+
+	defer observeQuery(ctx, query, namedParameters(query))()
+
+	if _, err := namedExec(ctx, e, query, data); err != nil {
+		return err
+	}
+
+	return nil
+}
+
+// ExecuteGet scans the row with the id into data, read from a replica when the connection has replicas.
+// The id is an integer, or a string for tables with a UUID primary key.
+// Soft deleted rows are not found, unless WithDeleted is given.
+// Structs with a tenant column are only found for the tenant in the context, see WithTenant.
+func ExecuteGet(conn DBConnection, table string, id interface{}, data interface{}, opts ...QueryOption) (interface{}, error) {
+	return ExecuteGetContext(context.Background(), conn, table, id, data, opts...)
+}
+
+// ExecuteGetContext scans the row with the id into data, the query is limited by the timeout of the connection.
+// Transient errors are retried, see RetryError.
+func ExecuteGetContext(ctx context.Context, conn DBConnection, table string, id interface{}, data interface{}, opts ...QueryOption) (interface{}, error) {
+
+	o := newQueryOptions(opts)
+	db := readDB(conn, o)
+
+	ctx, cancelfuc := queryContext(ctx, conn)
+	defer cancelfuc()
+
+	column, tenant, err := tenantScope(ctx, reflect.TypeOf(data))
+	if err != nil {
+		return nil, err
+	}
+
+	query := fmt.Sprintf("SELECT * FROM %v WHERE id = :id", table)
+	args := map[string]interface{}{"id": id}
+	if column != "" {
+		query += fmt.Sprintf(" AND %s = :%s", column, tenantParameter)
+		args[tenantParameter] = tenant
+	}
+	if condition := o.deletedCondition(softDeleteColumn(reflect.TypeOf(data))); condition != "" {
+		query += " AND " + condition
+	}
+
+	err = retryQuery(ctx, true, func() error {
+		defer observeQuery(ctx, query, namedParameters(query))()
+
+		row, err := namedQuery(ctx, db, query, args)
+		if err != nil {
+			return err
+		}
+		defer row.Close()
+
+		row.Next()
+
+		return row.StructScan(data)
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	return data, nil
+}
+
+// ExecuteDelete deletes the row with the id, returns ErrNotFound when it does not exist.
+// The id is an integer, or a string for tables with a UUID primary key.
+// Use ExecuteSoftDelete for tables with a soft delete column.
+// Registered tables with a tenant column only delete rows of the tenant in the context, see RegisterTable.
+func ExecuteDelete(conn DBConnection, table string, id interface{}) error {
+	return ExecuteDeleteContext(context.Background(), conn, table, id)
+}
+
+// ExecuteDeleteContext deletes the row with the id, the query is limited by the timeout of the connection.
+// Deadlocks and lock wait timeouts are retried, see RetryError.
+func ExecuteDeleteContext(ctx context.Context, conn DBConnection, table string, id interface{}) error {
+
+	db := conn.DB(true)
+
+	ctx, cancelfunc := queryContext(ctx, conn)
+	defer cancelfunc()
+
+	column, tenant, err := tableTenantScope(ctx, table)
+	if err != nil {
+		return err
+	}
+
+	query := fmt.Sprintf("DELETE FROM %s WHERE id = :id;", table)
+	args := map[string]interface{}{"id": id}
+	if column != "" {
+		query = fmt.Sprintf("DELETE FROM %s WHERE id = :id AND %s = :%s;", table, column, tenantParameter)
+		args[tenantParameter] = tenant
+	}
+
+	// A retry after a broken connection would report an applied delete as ErrNotFound, so it is not idempotent.
+	var res stdsql.Result
+	err = retryQuery(ctx, false, func() (err error) {
+		defer observeQuery(ctx, query, namedParameters(query))()
+
+		res, err = namedExec(ctx, db, query, args)
+		return err
+	})
+	if err != nil {
+		return err
+	}
+
+	n, err := res.RowsAffected()
+	if err != nil {
+		return err
+	}
+	if n == 0 {
+		return ErrNotFound
+	}
+
+	return nil
+}
+
+// ExecuteSelect scans the rows matching all filters into dest, a pointer to a slice of structs, ordered by id.
+// The filter columns must be db tags of the struct, other columns return ErrUnknownColumn.
+// Soft deleted rows are not selected, unless WithDeleted is given.
+// Structs with a tenant column are only selected for the tenant in the context, see WithTenant.
+func ExecuteSelect(conn DBConnection, table string, filters Filters, dest interface{}, opts ...QueryOption) error {
+	return ExecuteSelectContext(context.Background(), conn, table, filters, dest, opts...)
+}
+
+// ExecuteSelectContext selects the rows matching all filters, the query is limited by the timeout of the connection.
+// Transient errors are retried, see RetryError.
+func ExecuteSelectContext(ctx context.Context, conn DBConnection, table string, filters Filters, dest interface{}, opts ...QueryOption) error {
+	typ := reflect.TypeOf(dest)
+	if typ == nil || typ.Kind() != reflect.Ptr || typ.Elem().Kind() != reflect.Slice {
+		return fmt.Errorf("dest is not a pointer to a slice")
+	}
+	typ = typ.Elem().Elem()
+	for typ.Kind() == reflect.Ptr {
+		typ = typ.Elem()
+	}
+	if typ.Kind() != reflect.Struct {
+		return fmt.Errorf("dest is not a slice of structs")
+	}
+
+	columns := make(map[string]bool)
+	for i := 0; i < typ.NumField(); i++ {
+		if tag := typ.Field(i).Tag.Get("db"); tag != "" && tag != "-" {
+			columns[tag] = true
+		}
+	}
+
+	// Sort the columns, so the same filters result in the same query.
+	names := make([]string, 0, len(filters))
+	for column := range filters {
+		if !columns[column] {
+			return fmt.Errorf("%w: %s", ErrUnknownColumn, column)
+		}
+		names = append(names, column)
+	}
+	sort.Strings(names)
+
+	column, tenant, err := tenantScope(ctx, typ)
+	if err != nil {
+		return err
+	}
+
+	o := newQueryOptions(opts)
+	args := make(map[string]interface{}, len(filters)+1)
+	conditions := make([]string, 0, len(names)+2)
+	for _, name := range names {
+		conditions = append(conditions, fmt.Sprintf("%s = :%s", name, name))
+		args[name] = filters[name]
+	}
+	if column != "" {
+		conditions = append(conditions, fmt.Sprintf("%s = :%s", column, tenantParameter))
+		args[tenantParameter] = tenant
+	}
+	if condition := o.deletedCondition(softDeleteColumn(typ)); condition != "" {
+		conditions = append(conditions, condition)
+	}
+
+	query := fmt.Sprintf("SELECT * FROM %s", table)
+	if len(conditions) > 0 {
+		query += " WHERE " + strings.Join(conditions, " AND ")
+	}
+	query += " ORDER BY id"
+
+	db := readDB(conn, o)
+
+	ctx, cancelfunc := queryContext(ctx, conn)
+	defer cancelfunc()
+
+	bound, boundArgs, err := sqlx.Named(query, args)
+	if err != nil {
+		return err
+	}
+
+	slice := reflect.ValueOf(dest).Elem()
+
+	return retryQuery(ctx, true, func() error {
+		defer observeQuery(ctx, query, names)()
+
+		slice.Set(slice.Slice(0, 0))
+		return sqlx.SelectContext(ctx, db, dest, db.Rebind(bound), boundArgs...)
+	})
+}
+
+func generateInsertQuery(tableName string, data interface{}) (string, error) {
+	columns, err := insertColumns(data)
+	if err != nil {
+		return "", err
+	}
+
+	placeholders := make([]string, 0, len(columns))
+	for _, column := range columns {
+		placeholders = append(placeholders, ":"+column)
+	}
+
+	query := fmt.Sprintf("INSERT INTO %s(%s) VALUES(%s);", tableName, strings.Join(columns, ", "), strings.Join(placeholders, ", "))
+
+	return query, nil
+}
+
+// Returns the columns written on insert, following the sql tags.
+func insertColumns(data interface{}) ([]string, error) {
+	value := reflect.ValueOf(data)
+	typ := reflect.TypeOf(data)
+
+	// If the value is a pointer, dereference it to get the actual struct value
+	if value.Kind() == reflect.Ptr {
+		value = value.Elem()
+		typ = typ.Elem()
+	}
+
+	if value.Kind() != reflect.Struct {
+		return nil, fmt.Errorf("data is not a struct")
+	}
+
+	var columns []string
+
+	for i := 0; i < typ.NumField(); i++ {
+		field := typ.Field(i)
+		tag := field.Tag.Get("db")
+		sqlTag := field.Tag.Get("sql")
+
+		if tag == "" || sqlTag == "" {
+			continue // Skip fields without db tag or no sql tag
+		}
+
+		if skipOnInsert(field) {
+			continue // Skip fields with sql update or softdelete tag
+		}
+
+		columns = append(columns, tag)
+	}
+
+	return columns, nil
+}
+
+func generateUpdateQuery(tableName string, data interface{}) (string, error) {
+	value := reflect.ValueOf(data)
+	typ := reflect.TypeOf(data)
+
+	// If the value is a pointer, dereference it to get the actual struct value
+	if value.Kind() == reflect.Ptr {
+		value = value.Elem()
+		typ = typ.Elem()
+	}
+
+	if value.Kind() != reflect.Struct {
+		return "", fmt.Errorf("data is not a struct")
+	}
+
+	var columns []string
+	var conditions []string
+
+	for i := 0; i < typ.NumField(); i++ {
+		field := typ.Field(i)
+		tag := field.Tag.Get("db")
+		sqlTag := field.Tag.Get("sql")
+
+		if tag == "" || sqlTag == "" {
+			continue // Skip fields without db tag
+		}
+
+		if hasSQLOption(field, tenantTag) {
+			conditions = append(conditions, fmt.Sprintf(" AND %s = :%s", tag, tag))
+			continue // The tenant of a row does not change
+		}
+
+		if skipOnUpdate(field) {
+			continue // Skip fields with sql insert or created tag
+		}
+
+		if isSetForUpdate(field, value.Field(i)) {
+			columns = append(columns, fmt.Sprintf("%s=:%s", tag, tag))
+		}
+	}
+
+	if len(columns) == 0 {
+		return "", fmt.Errorf("no columns to update")
+	}
+
+	query := fmt.Sprintf("UPDATE %s SET %s WHERE id = %s%s;", tableName, strings.Join(columns, ", "), ":"+typ.Field(0).Tag.Get("db"), strings.Join(conditions, ""))
+
+	return query, nil
+}
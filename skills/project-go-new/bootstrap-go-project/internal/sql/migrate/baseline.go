@@ -0,0 +1,193 @@
+package migrate
+
+import (
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"regexp"
+	"sort"
+	"strconv"
+	"strings"
+
+	"github.com/golang-migrate/migrate/v4"
+)
+
+// Identifier of the migration holding the schema snapshot of a baseline, e.g. 300_baseline.up.sql.
+const baselineIdentifier = "baseline"
+
+var ErrBehindBaseline = errors.New("database is behind the baseline")
+
+var (
+	autoIncrement = regexp.MustCompile(` AUTO_INCREMENT=\d+`)
+	definer       = regexp.MustCompile(` DEFINER=\S+`)
+)
+
+// Checks the database is not partially migrated up to the baseline.
+// The migrations before the baseline are removed, so a database between the first migration and the baseline
+// can not be migrated. A new database starts at the baseline.
+func (m *migration) checkBaseline() error {
+	if m.BaselineVersion == 0 {
+		return nil
+	}
+
+	v, _, err := m.Migrate.Version()
+	if errors.Is(err, migrate.ErrNilVersion) {
+		return nil
+	}
+	if err != nil {
+		return err
+	}
+
+	if v < m.BaselineVersion {
+		return fmt.Errorf("%w: version %d is before baseline %d, migrate it with a release before the baseline first", ErrBehindBaseline, v, m.BaselineVersion)
+	}
+
+	return nil
+}
+
+// Baseline collapses the applied migrations into a schema snapshot of the database, written as the migration
+// of the current version to the directory, and removes the migration files it replaces.
+//
+// The database must be clean, at the latest migration of the directory, and only contain tables and views.
+// Only MySQL is supported, the snapshot only holds the schema: move data inserted by the migrations into it by hand.
+func (m *migration) Baseline() error {
+	if m.Directory == "" {
+		return errors.New("Baseline requires the migrations directory, run it from the root of the repository")
+	}
+	if m.Postgres {
+		return errors.New("Baseline only supports MySQL, create the snapshot of a Postgres database with pg_dump")
+	}
+
+	v, dirty, err := m.Migrate.Version()
+	if err != nil {
+		return fmt.Errorf("Error retrieving current migration version: %w", err)
+	}
+	if dirty {
+		return fmt.Errorf("Version %d is dirty, fix the database before creating a baseline", v)
+	}
+
+	files, latest, err := migrationFiles(m.Directory)
+	if err != nil {
+		return err
+	}
+	if latest != v {
+		return fmt.Errorf("Database is at version %d and the latest migration is %d, apply all migrations before creating a baseline", v, latest)
+	}
+
+	snapshot, err := m.snapshot()
+	if err != nil {
+		return err
+	}
+
+	// Keep the width of the version of the last migration.
+	var replaced []string
+	digits := versionDigits
+	for _, file := range files {
+		match := migrationFile.FindStringSubmatch(file)
+		version, _ := strconv.ParseUint(match[1], 10, 64)
+		if uint(version) <= v {
+			replaced = append(replaced, file)
+			digits = max(digits, len(match[1]))
+		}
+	}
+
+	for _, file := range replaced {
+		if err := os.Remove(filepath.Join(m.Directory, file)); err != nil {
+			return err
+		}
+	}
+
+	path := filepath.Join(m.Directory, fmt.Sprintf("%0*d_%s.up.sql", digits, v, baselineIdentifier))
+	if err := os.WriteFile(path, []byte(snapshot), 0o644); err != nil {
+		return err
+	}
+
+	m.Log.Infof("Created baseline %s replacing %d migration files, remove the Go migrations up to version %d", path, len(replaced), v)
+
+	return nil
+}
+
+// Returns the migration files in the directory, sorted, and the latest version.
+func migrationFiles(dir string) ([]string, uint, error) {
+	entries, err := os.ReadDir(dir)
+	if err != nil {
+		return nil, 0, err
+	}
+
+	var files []string
+	var latest uint64
+	for _, entry := range entries {
+		match := migrationFile.FindStringSubmatch(entry.Name())
+		if match == nil {
+			continue
+		}
+
+		version, err := strconv.ParseUint(match[1], 10, 64)
+		if err != nil {
+			return nil, 0, fmt.Errorf("Migration '%s' has an invalid version: %w", entry.Name(), err)
+		}
+		files = append(files, entry.Name())
+		latest = max(latest, version)
+	}
+	sort.Strings(files)
+
+	goMigrations.Lock()
+	for version := range goMigrations.versions {
+		latest = max(latest, uint64(version))
+	}
+	goMigrations.Unlock()
+
+	return files, uint(latest), nil
+}
+
+// Returns the statements creating the tables and views of the database, except the migrations table.
+func (m *migration) snapshot() (string, error) {
+	var routines int
+	err := m.DB.Get(&routines, `SELECT
+		(SELECT COUNT(*) FROM information_schema.routines WHERE routine_schema = DATABASE()) +
+		(SELECT COUNT(*) FROM information_schema.triggers WHERE trigger_schema = DATABASE()) +
+		(SELECT COUNT(*) FROM information_schema.events WHERE event_schema = DATABASE())`)
+	if err != nil {
+		return "", err
+	}
+	if routines > 0 {
+		return "", errors.New("Baseline does not support routines, triggers and events, the database has them")
+	}
+
+	var tables []struct {
+		Name string `db:"name"`
+		Type string `db:"type"`
+	}
+	err = m.DB.Select(&tables, `SELECT table_name AS name, table_type AS type FROM information_schema.tables
+		WHERE table_schema = DATABASE() ORDER BY table_type, table_name`)
+	if err != nil {
+		return "", err
+	}
+
+	var b strings.Builder
+	b.WriteString("SET FOREIGN_KEY_CHECKS = 0;\n\n")
+	for _, table := range tables {
+		if table.Name == m.Table {
+			continue
+		}
+
+		// SHOW CREATE returns the name and the statement, followed by the character sets of a view.
+		var name, statement, charset, collation string
+		if table.Type == "VIEW" {
+			err = m.DB.QueryRow(fmt.Sprintf("SHOW CREATE VIEW `%s`", table.Name)).Scan(&name, &statement, &charset, &collation)
+		} else {
+			err = m.DB.QueryRow(fmt.Sprintf("SHOW CREATE TABLE `%s`", table.Name)).Scan(&name, &statement)
+		}
+		if err != nil {
+			return "", err
+		}
+
+		statement = autoIncrement.ReplaceAllString(statement, "")
+		statement = definer.ReplaceAllString(statement, "")
+		b.WriteString(statement + ";\n\n")
+	}
+	b.WriteString("SET FOREIGN_KEY_CHECKS = 1;\n")
+
+	return b.String(), nil
+}
@@ -0,0 +1,66 @@
+package migrate
+
+import (
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"regexp"
+	"strconv"
+	"strings"
+)
+
+// Number of digits of the version in the file name of a new migration, e.g. 002_create_users_table.up.sql.
+const versionDigits = 3
+
+var (
+	migrationFile  = regexp.MustCompile(`^(\d+)_.*\.(up|down)\.sql$`)
+	invalidNameRun = regexp.MustCompile(`[^a-z0-9]+`)
+)
+
+// Create writes the up and down migration files for the name into the directory, numbered after the last migration.
+// The name is converted to snake case, e.g. "add orders table" is written as 002_add_orders_table.up.sql.
+// It returns the paths of the created files.
+func Create(dir, name string) (up, down string, err error) {
+	name = strings.Trim(invalidNameRun.ReplaceAllString(strings.ToLower(name), "_"), "_")
+	if name == "" {
+		return "", "", errors.New("Migration name is required, e.g. -migrate create add_orders_table")
+	}
+
+	entries, err := os.ReadDir(dir)
+	if err != nil {
+		return "", "", err
+	}
+
+	last, digits := uint64(0), versionDigits
+	for _, entry := range entries {
+		match := migrationFile.FindStringSubmatch(entry.Name())
+		if match == nil {
+			continue
+		}
+
+		version, err := strconv.ParseUint(match[1], 10, 64)
+		if err != nil {
+			return "", "", fmt.Errorf("Migration '%s' has an invalid version: %w", entry.Name(), err)
+		}
+		last = max(last, version)
+		digits = max(digits, len(match[1]))
+	}
+
+	prefix := fmt.Sprintf("%0*d_%s", digits, last+1, name)
+	up = filepath.Join(dir, prefix+".up.sql")
+	down = filepath.Join(dir, prefix+".down.sql")
+
+	for _, file := range []string{up, down} {
+		// Do not overwrite existing files, O_EXCL fails when the file exists.
+		f, err := os.OpenFile(file, os.O_WRONLY|os.O_CREATE|os.O_EXCL, 0o644)
+		if err != nil {
+			return "", "", err
+		}
+		if err := f.Close(); err != nil {
+			return "", "", err
+		}
+	}
+
+	return up, down, nil
+}
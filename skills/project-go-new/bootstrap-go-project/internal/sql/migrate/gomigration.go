@@ -0,0 +1,173 @@
+package migrate
+
+import (
+	"context"
+	"fmt"
+	"io"
+	"os"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+
+	"github.com/golang-migrate/migrate/v4/source"
+	"github.com/jmoiron/sqlx"
+)
+
+// Prefix of the body of a Go migration, the runner calls the registered function instead of executing the body.
+const goMigrationPrefix = "-- go migration "
+
+// GoMigrationFunc migrates the database with application logic, e.g. re-encoding a JSON column.
+// The context is cancelled when the run is cancelled.
+type GoMigrationFunc func(ctx context.Context, db *sqlx.DB) error
+
+type goMigration struct {
+	identifier string
+	up, down   GoMigrationFunc
+}
+
+var goMigrations = struct {
+	sync.Mutex
+	versions map[uint]goMigration
+}{versions: make(map[uint]goMigration)}
+
+// RegisterGo registers a migration implemented in Go, versioned in the same sequence as the SQL files.
+// The down function may be nil when the migration can not be reverted.
+// Register the migrations in an init function, a version used by a SQL file fails the run.
+//
+// Panics when the version is already registered, as this is a programming error.
+func RegisterGo(version uint, identifier string, up, down GoMigrationFunc) {
+	goMigrations.Lock()
+	defer goMigrations.Unlock()
+
+	if _, ok := goMigrations.versions[version]; ok {
+		panic(fmt.Sprintf("migrate: go migration %d registered twice", version))
+	}
+
+	goMigrations.versions[version] = goMigration{identifier: identifier, up: up, down: down}
+}
+
+// Returns the registered Go migration of the version.
+func registeredGo(version uint) (goMigration, bool) {
+	goMigrations.Lock()
+	defer goMigrations.Unlock()
+
+	m, ok := goMigrations.versions[version]
+	return m, ok
+}
+
+// Source merging the registered Go migrations into the versions of the SQL files.
+type goSource struct {
+	source.Driver
+	versions []uint
+	// Version of the last baseline, zero when there is none. See Baseline.
+	baseline uint
+}
+
+// Creates the source, an error is returned when a Go migration uses the version of a SQL file.
+// The Go migrations before the baseline are replaced by it and skipped.
+func newGoSource(d source.Driver) (*goSource, error) {
+	s := &goSource{Driver: d}
+
+	seen := make(map[uint]bool)
+	for v, err := d.First(); err == nil; v, err = d.Next(v) {
+		s.versions = append(s.versions, v)
+		seen[v] = true
+
+		if r, identifier, err := d.ReadUp(v); err == nil {
+			r.Close()
+			if identifier == baselineIdentifier {
+				s.baseline = v
+			}
+		}
+	}
+
+	goMigrations.Lock()
+	for v := range goMigrations.versions {
+		if seen[v] {
+			goMigrations.Unlock()
+			return nil, fmt.Errorf("Go migration %d uses the version of a SQL migration", v)
+		}
+		if v > s.baseline {
+			s.versions = append(s.versions, v)
+		}
+	}
+	goMigrations.Unlock()
+
+	sort.Slice(s.versions, func(i, j int) bool { return s.versions[i] < s.versions[j] })
+
+	return s, nil
+}
+
+func (s *goSource) First() (uint, error) {
+	if len(s.versions) == 0 {
+		return 0, os.ErrNotExist
+	}
+
+	return s.versions[0], nil
+}
+
+func (s *goSource) Prev(version uint) (uint, error) {
+	i := sort.Search(len(s.versions), func(i int) bool { return s.versions[i] >= version })
+	if i == 0 {
+		return 0, os.ErrNotExist
+	}
+
+	return s.versions[i-1], nil
+}
+
+func (s *goSource) Next(version uint) (uint, error) {
+	i := sort.Search(len(s.versions), func(i int) bool { return s.versions[i] > version })
+	if i == len(s.versions) {
+		return 0, os.ErrNotExist
+	}
+
+	return s.versions[i], nil
+}
+
+func (s *goSource) ReadUp(version uint) (io.ReadCloser, string, error) {
+	if m, ok := registeredGo(version); ok && version > s.baseline {
+		return goBody(version, "up"), m.identifier, nil
+	}
+
+	return s.Driver.ReadUp(version)
+}
+
+func (s *goSource) ReadDown(version uint) (io.ReadCloser, string, error) {
+	if m, ok := registeredGo(version); ok && version > s.baseline {
+		if m.down == nil {
+			return nil, "", os.ErrNotExist
+		}
+		return goBody(version, "down"), m.identifier, nil
+	}
+
+	return s.Driver.ReadDown(version)
+}
+
+// Returns the body of a Go migration, read by parseGoBody.
+func goBody(version uint, direction string) io.ReadCloser {
+	return io.NopCloser(strings.NewReader(fmt.Sprintf("%s%d %s", goMigrationPrefix, version, direction)))
+}
+
+// Returns the function of a Go migration body, false for a SQL migration.
+func parseGoBody(body []byte) (GoMigrationFunc, bool) {
+	fields := strings.Fields(strings.TrimPrefix(string(body), goMigrationPrefix))
+	if !strings.HasPrefix(string(body), goMigrationPrefix) || len(fields) != 2 {
+		return nil, false
+	}
+
+	version, err := strconv.ParseUint(fields[0], 10, 64)
+	if err != nil {
+		return nil, false
+	}
+
+	m, ok := registeredGo(uint(version))
+	if !ok {
+		return nil, false
+	}
+	if fields[1] == "down" {
+		return m.down, true
+	}
+
+	return m.up, true
+}
@@ -0,0 +1,157 @@
+package migrate
+
+import (
+	"context"
+	stdsql "database/sql"
+	"errors"
+	"fmt"
+	"time"
+
+	dbdriver "github.com/golang-migrate/migrate/v4/database"
+	"go.uber.org/zap"
+)
+
+// DefaultLockTimeout is the time a run waits for the migration lock held by another instance.
+const DefaultLockTimeout = 5 * time.Minute
+
+// Interval between attempts to take the Postgres advisory lock.
+const lockRetryInterval = time.Second
+
+// Time migrate waits for the lock beyond the timeout of the locking driver, so the driver reports the timeout.
+const lockTimeoutMargin = 10 * time.Second
+
+var ErrLockTimeout = errors.New("timed out waiting for the migration lock")
+
+// Serializes the migration runs of all instances with an advisory lock on the database,
+// GET_LOCK on MySQL and pg_try_advisory_lock on Postgres, so only one instance applies the migrations.
+// The lock is bound to the session, so it is held on a dedicated connection until it is released.
+type lockingDriver struct {
+	dbdriver.Driver
+	db       *stdsql.DB
+	postgres bool
+	lockID   string
+	timeout  time.Duration
+	log      *zap.SugaredLogger
+	conn     *stdsql.Conn
+}
+
+// Creates the locking driver, the lock id is generated from the name of the database and the migrations table.
+func newLockingDriver(driver dbdriver.Driver, db *stdsql.DB, postgres bool, table string, timeout time.Duration, log *zap.SugaredLogger) (*lockingDriver, error) {
+	query := "SELECT DATABASE()"
+	if postgres {
+		query = "SELECT CURRENT_DATABASE()"
+	}
+
+	var name string
+	if err := db.QueryRowContext(context.Background(), query).Scan(&name); err != nil {
+		return nil, err
+	}
+
+	lockID, err := dbdriver.GenerateAdvisoryLockId(name, table)
+	if err != nil {
+		return nil, err
+	}
+
+	if timeout <= 0 {
+		timeout = DefaultLockTimeout
+	}
+
+	return &lockingDriver{Driver: driver, db: db, postgres: postgres, lockID: lockID, timeout: timeout, log: log}, nil
+}
+
+// Lock waits for the lock up to the timeout, ErrLockTimeout is returned when another instance keeps holding it.
+func (d *lockingDriver) Lock() error {
+	ctx, cancel := context.WithTimeout(context.Background(), d.timeout)
+	defer cancel()
+
+	conn, err := d.db.Conn(ctx)
+	if err != nil {
+		return err
+	}
+
+	locked, err := d.tryLock(ctx, conn, 0)
+	if err == nil && !locked {
+		d.log.Infof("Waiting up to %s for the migration lock held by another instance", d.timeout)
+		locked, err = d.waitLock(ctx, conn)
+	}
+	if err == nil && !locked {
+		err = fmt.Errorf("%w after %s", ErrLockTimeout, d.timeout)
+	}
+	if err != nil {
+		conn.Close()
+		return err
+	}
+
+	d.conn = conn
+	return nil
+}
+
+// Waits for the lock, MySQL waits within GET_LOCK and Postgres retries until the context is done.
+func (d *lockingDriver) waitLock(ctx context.Context, conn *stdsql.Conn) (bool, error) {
+	if !d.postgres {
+		deadline, _ := ctx.Deadline()
+		return d.tryLock(ctx, conn, time.Until(deadline))
+	}
+
+	ticker := time.NewTicker(lockRetryInterval)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-ctx.Done():
+			return false, nil
+		case <-ticker.C:
+		}
+
+		locked, err := d.tryLock(ctx, conn, 0)
+		if err != nil || locked {
+			return locked, err
+		}
+	}
+}
+
+// Takes the lock when it is free, MySQL waits up to the duration for it.
+func (d *lockingDriver) tryLock(ctx context.Context, conn *stdsql.Conn, wait time.Duration) (locked bool, err error) {
+	if d.postgres {
+		err = conn.QueryRowContext(ctx, "SELECT pg_try_advisory_lock($1)", d.lockID).Scan(&locked)
+	} else {
+		// GET_LOCK returns NULL on an error, e.g. when the session is killed.
+		var result stdsql.NullBool
+		err = conn.QueryRowContext(ctx, "SELECT GET_LOCK(?, ?)", d.lockID, int(wait.Seconds())).Scan(&result)
+		locked = result.Bool
+	}
+
+	// The deadline expired while waiting.
+	if errors.Is(err, context.DeadlineExceeded) {
+		return false, nil
+	}
+
+	return locked, err
+}
+
+// Unlock releases the lock and its connection.
+func (d *lockingDriver) Unlock() error {
+	if d.conn == nil {
+		return dbdriver.ErrNotLocked
+	}
+
+	query := "SELECT RELEASE_LOCK(?)"
+	if d.postgres {
+		query = "SELECT pg_advisory_unlock($1)"
+	}
+
+	_, err := d.conn.ExecContext(context.Background(), query, d.lockID)
+	d.conn.Close()
+	d.conn = nil
+
+	return err
+}
+
+// Close releases a lock that is still held and closes the wrapped driver.
+func (d *lockingDriver) Close() error {
+	if d.conn != nil {
+		d.Unlock()
+	}
+
+	return d.Driver.Close()
+}
@@ -0,0 +1,365 @@
+package migrate
+
+import (
+	"errors"
+	"fmt"
+	"io/fs"
+	"os"
+	"regexp"
+	"strconv"
+	"time"
+
+	"github.com/golang-migrate/migrate/v4"
+	dbdriver "github.com/golang-migrate/migrate/v4/database"
+	"github.com/golang-migrate/migrate/v4/database/mysql"
+	"github.com/golang-migrate/migrate/v4/source/iofs"
+	"github.com/jmoiron/sqlx"
+	"gitlab.com/btcdirect-api/bootstrap-go-service/internal/sql"
+	"go.uber.org/zap"
+)
+
+const maxDatabaseAttempts = 10
+
+// DefaultTable is the name of the migrations table when Migrate.Table is empty.
+const DefaultTable = "schema_migrations"
+
+var tableName = regexp.MustCompile(`^[A-Za-z_][A-Za-z0-9_]*$`)
+
+var ErrDownAllNotAllowed = errors.New("reverting all migrations is not allowed, confirm it with MIGRATE_CONFIRM_DOWN_ALL")
+
+var ErrTooManyPending = errors.New("too many pending migrations")
+
+type Migrate struct {
+	Cmd, Param string
+	// Name of the table holding the migration version, defaults to DefaultTable.
+	// Services sharing a database each need their own table.
+	Table string
+	// Time to wait for the migration lock held by another instance, defaults to DefaultLockTimeout.
+	LockTimeout time.Duration
+	// Maximum duration of a single migration, zero is unbounded. See ErrStepTimeout.
+	StepTimeout time.Duration
+	// Maximum duration of the run, zero is unbounded. See ErrTimeout.
+	Timeout time.Duration
+	// Path of a directory with the migration files replacing the given filesystem,
+	// e.g. to hotfix a migration without rebuilding the image.
+	Directory string
+	// Allows `down all` to revert all migrations, see ErrDownAllNotAllowed.
+	AllowDownAll bool
+	// Maximum number of pending migrations applied by up, zero is unlimited. See ErrTooManyPending.
+	MaxPending int
+	// OnComplete is called with the result when the run finished, also when it failed.
+	OnComplete func(Result)
+}
+
+type migration struct {
+	Cmd, Param string
+	Migrate    *migrate.Migrate
+	Log        *zap.SugaredLogger
+	DB         *sqlx.DB
+	Postgres   bool
+	// Migrations table, excluded from the baseline.
+	Table string
+	// Directory of the migration files, empty when they are embedded.
+	Directory    string
+	AllowDownAll bool
+	MaxPending   int
+	// Version of the last baseline, zero when there is none.
+	BaselineVersion uint
+	// Versions of the migrations in the source.
+	Versions []uint
+}
+
+// Migrate is a function that runs the migrations for the given connection.
+// The migrations are loaded from the directory of the given filesystem, e.g. "migrations" of an embed.FS,
+// or from the Directory path when it is set.
+//
+// Supported commands are:
+//
+//   - up: Perform all up migrations
+//   - down: Revert the last migration, the given number of migrations, or all migrations for "all"
+//   - version: Print the current migration version
+//   - force: Force the migration version to the given version
+//   - target: Migrate to the given version
+//   - steps: Perform the given number of migration steps
+//   - baseline: Collapse the applied migrations of the Directory into a schema snapshot, see Baseline
+//
+// The Param field is used as the version for the force, target and steps commands, and the count for down.
+// Reverting all migrations is refused unless AllowDownAll is set.
+// Up is refused when more than MaxPending migrations are pending, e.g. when connected to the wrong database.
+//
+// Go migrations registered with RegisterGo run in the sequence of the SQL files.
+//
+// Concurrent runs, e.g. of pods starting at the same time, are serialized by an advisory lock on the database.
+// A run waits up to the LockTimeout for the lock, the migrations applied meanwhile are then no longer pending.
+//
+// A migration exceeding the StepTimeout is cancelled and fails the run. On Postgres a SQL migration is rolled back
+// and the version stays clean, otherwise the version is dirty. A run exceeding the Timeout stops after the running
+// migration, leaving the version clean and the remaining migrations pending.
+func (m Migrate) Migrate(fsys fs.FS, dir string, conn *sql.Connection, log *zap.SugaredLogger) error {
+	log.Info("Running database migrations")
+	defer log.Info("Finished running database migrations")
+
+	if m.Directory != "" {
+		log.Warnf("Loading the migrations from %s instead of the embedded migrations", m.Directory)
+		fsys, dir = os.DirFS(m.Directory), "."
+	}
+
+	if m.Table == "" {
+		m.Table = DefaultTable
+	}
+	if !tableName.MatchString(m.Table) {
+		return fmt.Errorf("Migrations table '%s' is not a valid table name", m.Table)
+	}
+
+	start := time.Now()
+	t := &trace{log: log}
+
+	mi, s, err := createMigrateInstance(fsys, dir, conn, m, t, log)
+	if err == nil {
+		migration := &migration{
+			Cmd:             m.Cmd,
+			Param:           m.Param,
+			Migrate:         mi,
+			Log:             log,
+			DB:              conn.DB(false),
+			Postgres:        sql.IsPostgres(conn.Driver),
+			Table:           m.Table,
+			Directory:       m.Directory,
+			AllowDownAll:    m.AllowDownAll,
+			MaxPending:      m.MaxPending,
+			BaselineVersion: s.baseline,
+			Versions:        s.versions,
+		}
+		stop := stopAfter(mi, m.Timeout, log)
+		err = migration.Run()
+		if stopErr := stop(); err == nil {
+			err = stopErr
+		}
+	}
+
+	result := t.result(m.Cmd, mi, time.Since(start), err)
+	log.Info("Migration summary\n" + result.Summary())
+
+	if m.OnComplete != nil {
+		m.OnComplete(result)
+	}
+
+	return err
+}
+
+// Wrapper for running the golang-migrate/migrate/v4 package.
+func (m *migration) Run() (err error) {
+	switch m.Cmd {
+	case "", "up", "target", "steps":
+		err = m.checkBaseline()
+	}
+	if err == nil && (m.Cmd == "" || m.Cmd == "up") {
+		err = m.checkPending()
+	}
+	if err != nil {
+		m.Log.Errorf("Migration failed with error: %v", err.Error())
+		return err
+	}
+
+	switch m.Cmd {
+	case "":
+		fallthrough
+	case "up":
+		err = m.Up()
+	case "down":
+		err = m.Down()
+	case "version":
+		err = m.Version()
+	case "force":
+		err = m.Force()
+	case "target":
+		err = m.Target()
+	case "steps":
+		err = m.Steps()
+	case "baseline":
+		err = m.Baseline()
+	}
+
+	if err == nil {
+		return
+	}
+
+	if err.Error() == "no change" {
+		m.Log.Info("No changes were made. Target version is the same as current version")
+		return nil
+	}
+
+	m.Log.Errorf("Migration failed with error: %v", err.Error())
+	return err
+}
+
+func (m *migration) Up() error {
+	m.Log.Info("Performing all up migrations")
+	return m.Migrate.Up()
+}
+
+// Checks no more than the maximum number of migrations are pending.
+// The migrations applied by another instance waiting for the lock are counted as pending.
+func (m *migration) checkPending() error {
+	if m.MaxPending <= 0 {
+		return nil
+	}
+
+	v, _, err := m.Migrate.Version()
+	if err != nil && !errors.Is(err, migrate.ErrNilVersion) {
+		return err
+	}
+
+	pending := 0
+	for _, version := range m.Versions {
+		if version > v {
+			pending++
+		}
+	}
+
+	if pending > m.MaxPending {
+		return fmt.Errorf("%w: %d migrations after version %d, the maximum is %d", ErrTooManyPending, pending, v, m.MaxPending)
+	}
+
+	return nil
+}
+
+func (m *migration) Down() error {
+	switch m.Param {
+	case "":
+		m.Log.Info("Performing one down migration")
+		return m.Migrate.Steps(-1)
+	case "all":
+		if !m.AllowDownAll {
+			return ErrDownAllNotAllowed
+		}
+		m.Log.Info("Performing all down migrations")
+		return m.Migrate.Down()
+	}
+
+	i, err := m.intParam()
+	if err != nil {
+		return err
+	}
+	if i <= 0 {
+		return fmt.Errorf("Argument '%s' is not a positive number of down migrations", m.Param)
+	}
+	m.Log.Infof("Performing '%d' down migrations", i)
+	return m.Migrate.Steps(-i)
+}
+
+func (m *migration) Version() error {
+	v, d, err := m.Migrate.Version()
+	if err != nil {
+		return fmt.Errorf("Error retrieving current migration version: %s", err.Error())
+	}
+	state := map[bool]string{true: "DIRTY", false: "CLEAN"}[d]
+	m.Log.Infof("Current migration version is '%d' (%s)", v, state)
+	return nil
+}
+
+func (m *migration) Force() error {
+	i, err := m.intParam()
+	if err != nil {
+		return err
+	}
+	m.Log.Infof("Forcing migration version to '%d'", i)
+	return m.Migrate.Force(i)
+}
+
+func (m *migration) Target() error {
+	i, err := m.intParam()
+	if err != nil {
+		return err
+	}
+	m.Log.Infof("Migration version to '%d'", i)
+	return m.Migrate.Migrate(uint(i))
+}
+
+func (m *migration) Steps() error {
+	i, err := m.intParam()
+	if err != nil {
+		return err
+	}
+	m.Log.Infof("Performing '%d' migration steps", i)
+	return m.Migrate.Steps(i)
+}
+
+func (m *migration) intParam() (int, error) {
+	i, err := strconv.Atoi(m.Param)
+	if err != nil {
+		return 0, fmt.Errorf("Argument '%s' is not a valid migration integer", m.Param)
+	}
+	return i, nil
+}
+
+// Retrieves the database from the given connection.
+// It will wait for the database to become available for a maximum of 10 seconds.
+// If the database is not available after 10 seconds, an error is returned.
+func database(conn *sql.Connection, log *zap.SugaredLogger) (*sqlx.DB, error) {
+	for attempt := 1; attempt <= maxDatabaseAttempts; attempt++ {
+		conn.DB(false)
+		if conn.IsAlive() {
+			break
+		}
+
+		log.Info("Waiting for database to become available")
+		time.Sleep(1 * time.Second)
+	}
+
+	if !conn.IsAlive() {
+		err := fmt.Errorf("Giving up after %d attempts to connect to database", maxDatabaseAttempts)
+		log.Info(err.Error())
+		return nil, err
+	}
+
+	return conn.DB(false), nil
+}
+
+// Creates a new migrate instance with the migration files in the directory of the filesystem.
+// The executed migrations are recorded in the trace, the source is returned for its versions and baseline.
+func createMigrateInstance(fsys fs.FS, dir string, conn *sql.Connection, opts Migrate, t *trace, log *zap.SugaredLogger) (m *migrate.Migrate, s *goSource, err error) {
+	db, err := database(conn, log)
+	if err != nil {
+		return
+	}
+
+	var driver dbdriver.Driver
+	postgres := sql.IsPostgres(conn.Driver)
+	if postgres {
+		driver, err = postgresWithInstance(db.DB, opts.Table)
+	} else {
+		// The locking driver takes the lock with the configured timeout.
+		driver, err = mysql.WithInstance(db.DB, &mysql.Config{MigrationsTable: opts.Table, NoLock: true})
+	}
+	if err != nil {
+		return
+	}
+
+	locking, err := newLockingDriver(driver, db.DB, postgres, opts.Table, opts.LockTimeout, log)
+	if err != nil {
+		return
+	}
+
+	d, err := iofs.New(fsys, dir)
+	if err != nil {
+		return
+	}
+
+	s, err = newGoSource(d)
+	if err != nil {
+		return
+	}
+
+	m, err = migrate.NewWithInstance(
+		"iofs", tracingSource{Driver: s, trace: t},
+		conn.Driver, tracingDriver{Driver: locking, db: db, postgres: postgres, stepTimeout: opts.StepTimeout, trace: t})
+	if err != nil {
+		return
+	}
+
+	// The locking driver times out waiting for the lock, migrate must not give up before it.
+	m.LockTimeout = locking.timeout + lockTimeoutMargin
+
+	return
+}
@@ -0,0 +1,137 @@
+package migrate
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"fmt"
+	"io"
+
+	dbdriver "github.com/golang-migrate/migrate/v4/database"
+)
+
+var errPostgresOpen = errors.New("postgres migrate driver only supports existing connections")
+
+// Migrate driver for Postgres on top of database/sql, so it works with any registered Postgres driver.
+// The migrations table has the same layout as the golang-migrate postgres driver.
+// The driver does not lock, the runs are serialized by the locking driver wrapping it.
+type postgresDriver struct {
+	db    *sql.DB
+	conn  *sql.Conn
+	table string
+}
+
+func postgresWithInstance(db *sql.DB, table string) (dbdriver.Driver, error) {
+	ctx := context.Background()
+
+	// The statements of a migration may depend on the session, so all statements use the same connection.
+	conn, err := db.Conn(ctx)
+	if err != nil {
+		return nil, err
+	}
+
+	_, err = conn.ExecContext(ctx, fmt.Sprintf(
+		"CREATE TABLE IF NOT EXISTS %s (version BIGINT NOT NULL PRIMARY KEY, dirty BOOLEAN NOT NULL)",
+		table,
+	))
+	if err != nil {
+		conn.Close()
+		return nil, err
+	}
+
+	return &postgresDriver{db: db, conn: conn, table: table}, nil
+}
+
+func (p *postgresDriver) Open(url string) (dbdriver.Driver, error) {
+	return nil, errPostgresOpen
+}
+
+func (p *postgresDriver) Close() error {
+	return p.conn.Close()
+}
+
+func (p *postgresDriver) Lock() error {
+	return nil
+}
+
+func (p *postgresDriver) Unlock() error {
+	return nil
+}
+
+func (p *postgresDriver) Run(migration io.Reader) error {
+	query, err := io.ReadAll(migration)
+	if err != nil {
+		return err
+	}
+
+	if _, err := p.conn.ExecContext(context.Background(), string(query)); err != nil {
+		return dbdriver.Error{OrigErr: err, Query: query}
+	}
+
+	return nil
+}
+
+func (p *postgresDriver) SetVersion(version int, dirty bool) error {
+	ctx := context.Background()
+
+	tx, err := p.conn.BeginTx(ctx, nil)
+	if err != nil {
+		return err
+	}
+
+	if _, err := tx.ExecContext(ctx, "TRUNCATE "+p.table); err != nil {
+		tx.Rollback()
+		return err
+	}
+
+	// A nil version without dirty state is stored as an empty table.
+	if version >= 0 || (version == dbdriver.NilVersion && dirty) {
+		_, err := tx.ExecContext(ctx, "INSERT INTO "+p.table+" (version, dirty) VALUES ($1, $2)", version, dirty)
+		if err != nil {
+			tx.Rollback()
+			return err
+		}
+	}
+
+	return tx.Commit()
+}
+
+func (p *postgresDriver) Version() (version int, dirty bool, err error) {
+	err = p.conn.QueryRowContext(context.Background(), "SELECT version, dirty FROM "+p.table+" LIMIT 1").Scan(&version, &dirty)
+	if errors.Is(err, sql.ErrNoRows) {
+		return dbdriver.NilVersion, false, nil
+	}
+
+	return version, dirty, err
+}
+
+func (p *postgresDriver) Drop() error {
+	ctx := context.Background()
+
+	rows, err := p.conn.QueryContext(ctx, "SELECT table_name FROM information_schema.tables WHERE table_schema = CURRENT_SCHEMA() AND table_type = 'BASE TABLE'")
+	if err != nil {
+		return err
+	}
+
+	var tables []string
+	for rows.Next() {
+		var t string
+		if err := rows.Scan(&t); err != nil {
+			rows.Close()
+			return err
+		}
+		tables = append(tables, t)
+	}
+	rows.Close()
+	if err := rows.Err(); err != nil {
+		return err
+	}
+
+	for _, t := range tables {
+		if _, err := p.conn.ExecContext(ctx, fmt.Sprintf("DROP TABLE IF EXISTS %q CASCADE", t)); err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
@@ -0,0 +1,92 @@
+package migrate
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"sync/atomic"
+	"time"
+
+	"github.com/golang-migrate/migrate/v4"
+	"go.uber.org/zap"
+)
+
+var (
+	ErrTimeout     = errors.New("migration run timed out")
+	ErrStepTimeout = errors.New("migration timed out")
+)
+
+// Returns the context of a migration step, limited by the step timeout when it is positive.
+func stepContext(timeout time.Duration) (context.Context, context.CancelFunc) {
+	if timeout <= 0 {
+		return context.WithCancel(context.Background())
+	}
+
+	return context.WithTimeout(context.Background(), timeout)
+}
+
+// Restores the version after a migration step timed out.
+//
+// A SQL migration on Postgres runs in a single implicit transaction, which is rolled back when it is cancelled,
+// so the version is reset to the clean version before the step. Other migrations may be partially applied,
+// the version stays dirty and must be forced after the schema is verified.
+func (d tracingDriver) restoreVersion(m AppliedMigration, goMigration bool, err error) error {
+	previous, ok := d.trace.previousVersion()
+	if !d.postgres || goMigration || !ok {
+		return fmt.Errorf("%w after %s, version %d is dirty: verify the schema and use -migrate force: %w", ErrStepTimeout, d.stepTimeout, m.Version, err)
+	}
+
+	if vErr := d.Driver.SetVersion(previous, false); vErr != nil {
+		return errors.Join(fmt.Errorf("%w after %s: %w", ErrStepTimeout, d.stepTimeout, err), vErr)
+	}
+
+	return fmt.Errorf("%w after %s and was rolled back to version %d: %w", ErrStepTimeout, d.stepTimeout, previous, err)
+}
+
+// SetVersion records the version before a migration step, so it can be restored when the step times out.
+func (d tracingDriver) SetVersion(version int, dirty bool) error {
+	if dirty {
+		if v, vDirty, err := d.Driver.Version(); err == nil && !vDirty {
+			d.trace.setPreviousVersion(v)
+		}
+	}
+
+	return d.Driver.SetVersion(version, dirty)
+}
+
+func (t *trace) setPreviousVersion(version int) {
+	t.Lock()
+	defer t.Unlock()
+
+	t.previous, t.hasPrevious = version, true
+}
+
+func (t *trace) previousVersion() (int, bool) {
+	t.Lock()
+	defer t.Unlock()
+
+	return t.previous, t.hasPrevious
+}
+
+// Stops the run after the running migration when the timeout expires, so the version stays clean.
+// The returned function stops the timer and returns ErrTimeout when the run was stopped.
+func stopAfter(mi *migrate.Migrate, timeout time.Duration, log *zap.SugaredLogger) func() error {
+	if timeout <= 0 || mi == nil {
+		return func() error { return nil }
+	}
+
+	var stopped atomic.Bool
+	timer := time.AfterFunc(timeout, func() {
+		stopped.Store(true)
+		log.Warnf("Migration run exceeded %s, stopping after the running migration", timeout)
+		mi.GracefulStop <- true
+	})
+
+	return func() error {
+		timer.Stop()
+		if stopped.Load() {
+			return fmt.Errorf("%w after %s, the remaining migrations are pending", ErrTimeout, timeout)
+		}
+		return nil
+	}
+}
@@ -0,0 +1,228 @@
+package migrate
+
+import (
+	"context"
+	stdsql "database/sql"
+	"database/sql/driver"
+	"errors"
+	"fmt"
+	"io"
+	"strings"
+	"sync"
+	"text/tabwriter"
+	"time"
+
+	"github.com/golang-migrate/migrate/v4"
+	dbdriver "github.com/golang-migrate/migrate/v4/database"
+	"github.com/golang-migrate/migrate/v4/source"
+	"github.com/jmoiron/sqlx"
+	"go.uber.org/zap"
+)
+
+// Result of a migration run, passed to Migrate.OnComplete.
+type Result struct {
+	Cmd      string
+	Applied  []AppliedMigration
+	Duration time.Duration
+	// Version of the database after the run, zero when no migration is applied.
+	Version uint
+	Dirty   bool
+	Err     error
+}
+
+// AppliedMigration is a single migration file executed during a run.
+type AppliedMigration struct {
+	Version      uint
+	Identifier   string
+	Direction    string
+	Duration     time.Duration
+	RowsAffected int64
+}
+
+// Traces the migrations executed by golang-migrate.
+// The source records the files in the order they are read, the database driver executes them in the same order.
+type trace struct {
+	sync.Mutex
+	log     *zap.SugaredLogger
+	read    []AppliedMigration
+	applied []AppliedMigration
+	// Clean version before the running migration step.
+	previous    int
+	hasPrevious bool
+}
+
+type tracingSource struct {
+	source.Driver
+	trace *trace
+}
+
+func (s tracingSource) ReadUp(version uint) (io.ReadCloser, string, error) {
+	r, identifier, err := s.Driver.ReadUp(version)
+	if err == nil {
+		s.trace.readFile(version, identifier, "up")
+	}
+	return r, identifier, err
+}
+
+func (s tracingSource) ReadDown(version uint) (io.ReadCloser, string, error) {
+	r, identifier, err := s.Driver.ReadDown(version)
+	if err == nil {
+		s.trace.readFile(version, identifier, "down")
+	}
+	return r, identifier, err
+}
+
+type tracingDriver struct {
+	dbdriver.Driver
+	db          *sqlx.DB
+	postgres    bool
+	stepTimeout time.Duration
+	trace       *trace
+}
+
+// Run executes the migration on the database instead of the wrapped driver, to retrieve the affected rows.
+// Go migrations are run by calling their function, they report no affected rows.
+// The migration is cancelled when it exceeds the step timeout.
+func (d tracingDriver) Run(migration io.Reader) error {
+	body, err := io.ReadAll(migration)
+	if err != nil {
+		return err
+	}
+
+	m := d.trace.next()
+	d.trace.log.Infof("Running migration %d_%s.%s", m.Version, m.Identifier, m.Direction)
+
+	ctx, cancel := stepContext(d.stepTimeout)
+	defer cancel()
+
+	start := time.Now()
+	fn, goMigration := parseGoBody(body)
+	if goMigration {
+		err = fn(ctx, d.db)
+	} else {
+		m.RowsAffected, err = execMigration(ctx, d.db.DB, string(body))
+	}
+	m.Duration = time.Since(start)
+	if err != nil && errors.Is(ctx.Err(), context.DeadlineExceeded) {
+		return d.restoreVersion(m, goMigration, err)
+	}
+	if err != nil {
+		return dbdriver.Error{OrigErr: err, Err: "migration failed", Query: body}
+	}
+
+	d.trace.log.Infow("Migration applied", "version", m.Version, "migration", m.Identifier, "direction", m.Direction,
+		"duration", m.Duration, "rowsAffected", m.RowsAffected)
+	d.trace.Lock()
+	d.trace.applied = append(d.trace.applied, m)
+	d.trace.Unlock()
+
+	return nil
+}
+
+func (t *trace) readFile(version uint, identifier, direction string) {
+	t.Lock()
+	defer t.Unlock()
+
+	t.read = append(t.read, AppliedMigration{Version: version, Identifier: identifier, Direction: direction})
+}
+
+// Returns the next migration read from the source.
+func (t *trace) next() AppliedMigration {
+	t.Lock()
+	defer t.Unlock()
+
+	if len(t.read) == 0 {
+		return AppliedMigration{Identifier: "unknown"}
+	}
+
+	m := t.read[0]
+	t.read = t.read[1:]
+	return m
+}
+
+// Returns the result of the run, the version is retrieved from the migrate instance when available.
+func (t *trace) result(cmd string, mi *migrate.Migrate, duration time.Duration, err error) Result {
+	t.Lock()
+	defer t.Unlock()
+
+	if cmd == "" {
+		cmd = "up"
+	}
+
+	r := Result{
+		Cmd:      cmd,
+		Applied:  t.applied,
+		Duration: duration,
+		Err:      err,
+	}
+
+	if mi != nil {
+		v, dirty, vErr := mi.Version()
+		if vErr == nil || errors.Is(vErr, migrate.ErrNilVersion) {
+			r.Version, r.Dirty = v, dirty
+		}
+	}
+
+	return r
+}
+
+// Executes the migration on a single connection, summing the affected rows of all statements when the driver reports them.
+func execMigration(ctx context.Context, db *stdsql.DB, query string) (rows int64, err error) {
+	conn, err := db.Conn(ctx)
+	if err != nil {
+		return 0, err
+	}
+	defer conn.Close()
+
+	err = conn.Raw(func(dc interface{}) error {
+		execer, ok := dc.(driver.ExecerContext)
+		if !ok {
+			res, err := conn.ExecContext(ctx, query)
+			if err != nil {
+				return err
+			}
+			rows, _ = res.RowsAffected()
+			return nil
+		}
+
+		res, err := execer.ExecContext(ctx, query, nil)
+		if err != nil {
+			return err
+		}
+
+		// The MySQL driver reports the affected rows per statement of a multi statement migration.
+		if all, ok := res.(interface{ AllRowsAffected() []int64 }); ok {
+			for _, n := range all.AllRowsAffected() {
+				rows += n
+			}
+			return nil
+		}
+
+		rows, _ = res.RowsAffected()
+		return nil
+	})
+
+	return rows, err
+}
+
+// Summary returns the applied migrations as a table, followed by the resulting version.
+func (r Result) Summary() string {
+	var b strings.Builder
+
+	if len(r.Applied) > 0 {
+		w := tabwriter.NewWriter(&b, 0, 0, 2, ' ', 0)
+		fmt.Fprintln(w, "VERSION\tMIGRATION\tDIRECTION\tDURATION\tROWS")
+		for _, m := range r.Applied {
+			fmt.Fprintf(w, "%d\t%s\t%s\t%s\t%d\n", m.Version, m.Identifier, m.Direction, m.Duration.Round(time.Millisecond), m.RowsAffected)
+		}
+		w.Flush()
+	}
+
+	state := map[bool]string{true: "DIRTY", false: "CLEAN"}[r.Dirty]
+	fmt.Fprintf(&b, "%s: %d migrations applied in %s, version %d (%s)", r.Cmd, len(r.Applied), r.Duration.Round(time.Millisecond), r.Version, state)
+	if r.Err != nil {
+		fmt.Fprintf(&b, ", failed: %v", r.Err)
+	}
+
+	return b.String()
+}
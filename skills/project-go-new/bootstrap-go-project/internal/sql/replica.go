@@ -0,0 +1,142 @@
+package sql
+
+import (
+	"context"
+	"fmt"
+	"sync"
+	"time"
+
+	"github.com/jmoiron/sqlx"
+	"gitlab.com/btcdirect-api/bootstrap-go-service/internal/metrics"
+)
+
+// ReplicaCheckInterval is the interval at which the health of a replica is checked again.
+const ReplicaCheckInterval = 10 * time.Second
+
+var replicaHealthy = metrics.NewGauge("sql_replica_healthy")
+
+// replica is a read only node of the connection.
+type replica struct {
+	sync.Mutex
+	name     string
+	db       *sqlx.DB
+	healthy  bool
+	checked  time.Time
+	checking bool
+}
+
+// NodeHealth is the health of a database node.
+type NodeHealth struct {
+	Name    string    `json:"name"`
+	Healthy bool      `json:"healthy"`
+	Checked time.Time `json:"checked"`
+}
+
+// Check the health of the replica and record it.
+func (r *replica) check() {
+	ctx, cancel := context.WithTimeout(context.Background(), time.Second)
+	defer cancel()
+
+	err := r.db.PingContext(ctx)
+
+	r.Lock()
+	r.healthy = err == nil
+	r.checked = time.Now()
+	r.checking = false
+	r.Unlock()
+
+	healthy := 0.0
+	if err == nil {
+		healthy = 1
+	}
+	replicaHealthy.Set(r.name, healthy)
+}
+
+// Returns true if the replica was healthy on its last check.
+// A stale check is repeated in the background, so reads are not delayed by the check.
+func (r *replica) available() bool {
+	r.Lock()
+	defer r.Unlock()
+
+	if !r.checking && time.Since(r.checked) > ReplicaCheckInterval {
+		r.checking = true
+		go r.check()
+	}
+
+	return r.healthy
+}
+
+// ReadDB returns a healthy replica to read from, replicas are used in turn.
+// Falls back to the primary, see DB, when no replicas are configured or none of them is healthy.
+//
+// Replicas lag behind the primary, read from the primary with WithPrimary when a write must be visible.
+func (c *Connection) ReadDB() *sqlx.DB {
+	replicas := c.openReplicas()
+	for i := 0; i < len(replicas); i++ {
+		r := replicas[int(c.nextReplica.Add(1))%len(replicas)]
+		if r.available() {
+			return r.db
+		}
+	}
+
+	return c.DB(true)
+}
+
+// Nodes returns the health of the primary and the replicas.
+func (c *Connection) Nodes() []NodeHealth {
+	nodes := []NodeHealth{{Name: "primary", Healthy: c.IsAlive(), Checked: time.Now()}}
+	for _, r := range c.openReplicas() {
+		r.Lock()
+		nodes = append(nodes, NodeHealth{Name: r.name, Healthy: r.healthy, Checked: r.checked})
+		r.Unlock()
+	}
+
+	return nodes
+}
+
+// Opens the replicas on first use and checks their health.
+// Replicas use the driver of the primary.
+func (c *Connection) openReplicas() []*replica {
+	c.replicasOnce.Do(func() {
+		for i, dsn := range c.ReplicaDSNs {
+			r := &replica{name: fmt.Sprintf("replica-%d", i)}
+
+			db, err := sqlx.Open(c.Driver, driverDSN(dsn))
+			if err != nil {
+				c.Log.Errorf("Could not open database %s. %s", r.name, err.Error())
+				continue
+			}
+
+			r.db = db
+			r.check()
+			if !r.healthy {
+				c.Log.Warnf("Database %s is not healthy, reading from the primary until it recovers", r.name)
+			}
+			c.replicas = append(c.replicas, r)
+		}
+	})
+
+	return c.replicas
+}
+
+// Close the replicas.
+func (c *Connection) closeReplicas() error {
+	var err error
+	for _, r := range c.replicas {
+		if cerr := r.db.Close(); cerr != nil {
+			err = cerr
+		}
+	}
+	c.replicas = nil
+
+	return err
+}
+
+// Returns the database to read from, the primary when WithPrimary is given or the connection has no replicas.
+func readDB(conn DBConnection, o queryOptions) *sqlx.DB {
+	if r, ok := conn.(interface{ ReadDB() *sqlx.DB }); ok && !o.primary {
+		return r.ReadDB()
+	}
+
+	return conn.DB(true)
+}
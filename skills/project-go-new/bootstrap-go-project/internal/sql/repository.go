@@ -0,0 +1,313 @@
+package sql
+
+import (
+	"context"
+	stdsql "database/sql"
+	"errors"
+	"fmt"
+	"reflect"
+	"sort"
+	"strings"
+
+	"github.com/jmoiron/sqlx"
+)
+
+// Number of entities returned by List when the page has no limit.
+const DefaultPageLimit = 100
+
+var (
+	ErrNotFound      = errors.New("entity not found")
+	ErrUnknownColumn = errors.New("unknown column")
+)
+
+// Repository stores entities of type T in a table.
+// The queries are limited by the query timeout of the connection, see WithQueryTimeout.
+// Transient errors are retried, see RetryError.
+//
+// T is a struct with db tags, the columns are written by Insert and Update following the sql tags,
+// like ExecuteInsert and ExecuteUpdate. The first field is the id column.
+//
+// The finders read from a replica when the connection has replicas, see Connection.ReadDB.
+// When T has a soft delete column, Delete sets it and the finders exclude deleted rows unless WithDeleted is given.
+// When T has a tenant column, the queries are scoped to the tenant in the context, see WithTenant.
+type Repository[T any] struct {
+	conn       DBConnection
+	table      string
+	id         string
+	columns    map[string]bool
+	softDelete string
+	tenant     string
+}
+
+// Filters select entities by column value, e.g. Filters{"status": "active"}.
+type Filters map[string]interface{}
+
+// Page of entities returned by List, ordered by id.
+type Page struct {
+	Limit  int
+	Offset int
+}
+
+// Creates a repository for the table.
+// Panics when T is not a struct with db tags, as this is a programming error.
+func NewRepository[T any](conn DBConnection, table string) *Repository[T] {
+	typ := reflect.TypeOf((*T)(nil)).Elem()
+	if typ.Kind() != reflect.Struct {
+		panic(fmt.Sprintf("sql: repository of %s: not a struct", typ))
+	}
+
+	columns := make(map[string]bool)
+	for i := 0; i < typ.NumField(); i++ {
+		if tag := typ.Field(i).Tag.Get("db"); tag != "" && tag != "-" {
+			columns[tag] = true
+		}
+	}
+
+	id := typ.Field(0).Tag.Get("db")
+	if !columns[id] {
+		panic(fmt.Sprintf("sql: repository of %s: first field has no db tag", typ))
+	}
+
+	RegisterTable(table, (*T)(nil))
+
+	return &Repository[T]{
+		conn:       conn,
+		table:      table,
+		id:         id,
+		columns:    columns,
+		softDelete: softDeleteColumn(typ),
+		tenant:     tenantColumn(typ),
+	}
+}
+
+// Returns the condition scoping the queries to the tenant in the context, empty when T has no tenant column.
+func (r *Repository[T]) tenantCondition(ctx context.Context) (string, []interface{}, error) {
+	if r.tenant == "" {
+		return "", nil, nil
+	}
+
+	tenant, ok := TenantFromContext(ctx)
+	if !ok {
+		return "", nil, fmt.Errorf("%w: %s is tenant scoped", ErrNoTenant, r.table)
+	}
+
+	return r.tenant + " = ?", []interface{}{tenant}, nil
+}
+
+// Insert the entity and return its id.
+func (r *Repository[T]) Insert(ctx context.Context, entity *T) (int64, error) {
+	ctx, cancel := queryContext(ctx, r.conn)
+	defer cancel()
+
+	var id int64
+	err := retryQuery(ctx, false, func() (err error) {
+		id, err = executeInsert(ctx, r.conn.DB(true), r.table, entity)
+		return err
+	})
+
+	return id, err
+}
+
+// Update the non-zero columns of the entity.
+func (r *Repository[T]) Update(ctx context.Context, entity *T) error {
+	ctx, cancel := queryContext(ctx, r.conn)
+	defer cancel()
+
+	return retryQuery(ctx, true, func() error {
+		return executeUpdate(ctx, r.conn.DB(true), r.table, entity)
+	})
+}
+
+// Delete the entity with the id, returns ErrNotFound when it does not exist.
+// The id is an integer, or a string for tables with a UUID primary key.
+// Entities with a soft delete column are soft deleted, deleting them again returns ErrNotFound.
+func (r *Repository[T]) Delete(ctx context.Context, id interface{}) error {
+	ctx, cancel := queryContext(ctx, r.conn)
+	defer cancel()
+
+	db := r.conn.DB(true)
+
+	tenant, tenantArgs, err := r.tenantCondition(ctx)
+	if err != nil {
+		return err
+	}
+
+	query := fmt.Sprintf("DELETE FROM %s WHERE %s = ?", r.table, r.id)
+	parameters := []string{r.id}
+	args := []interface{}{id}
+	if r.softDelete != "" {
+		query = fmt.Sprintf("UPDATE %s SET %s = ? WHERE %s = ? AND %s IS NULL", r.table, r.softDelete, r.id, r.softDelete)
+		parameters = []string{r.softDelete, r.id}
+		args = []interface{}{TimestampClock.Now().UTC(), id}
+	}
+	if tenant != "" {
+		query += " AND " + tenant
+		parameters = append(parameters, r.tenant)
+		args = append(args, tenantArgs...)
+	}
+
+	// A retry after a broken connection would report an applied delete as ErrNotFound, so it is not idempotent.
+	var res stdsql.Result
+	err = retryQuery(ctx, false, func() (err error) {
+		defer observeQuery(ctx, query, parameters)()
+
+		res, err = db.ExecContext(ctx, db.Rebind(query), args...)
+		return err
+	})
+	if err != nil {
+		return err
+	}
+
+	n, err := res.RowsAffected()
+	if err != nil {
+		return err
+	}
+	if n == 0 {
+		return ErrNotFound
+	}
+
+	return nil
+}
+
+// FindByID returns the entity with the id, or ErrNotFound.
+// The id is an integer, or a string for tables with a UUID primary key.
+func (r *Repository[T]) FindByID(ctx context.Context, id interface{}, opts ...QueryOption) (*T, error) {
+	ctx, cancel := queryContext(ctx, r.conn)
+	defer cancel()
+
+	o := newQueryOptions(opts)
+	db := readDB(r.conn, o)
+
+	tenant, tenantArgs, err := r.tenantCondition(ctx)
+	if err != nil {
+		return nil, err
+	}
+
+	query := fmt.Sprintf("SELECT * FROM %s WHERE %s = ?", r.table, r.id)
+	args := append([]interface{}{id}, tenantArgs...)
+	if tenant != "" {
+		query += " AND " + tenant
+	}
+	if condition := o.deletedCondition(r.softDelete); condition != "" {
+		query += " AND " + condition
+	}
+
+	var entity T
+	err = retryQuery(ctx, true, func() error {
+		defer observeQuery(ctx, query, []string{r.id})()
+
+		return sqlx.GetContext(ctx, db, &entity, db.Rebind(query), args...)
+	})
+	if errors.Is(err, stdsql.ErrNoRows) {
+		return nil, ErrNotFound
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	return &entity, nil
+}
+
+// FindBy returns the entities matching all filters, ordered by id.
+// The filter columns must be db tags of T, other columns return ErrUnknownColumn.
+func (r *Repository[T]) FindBy(ctx context.Context, filters Filters, opts ...QueryOption) ([]T, error) {
+	ctx, cancel := queryContext(ctx, r.conn)
+	defer cancel()
+
+	o := newQueryOptions(opts)
+	db := readDB(r.conn, o)
+
+	tenant, tenantArgs, err := r.tenantCondition(ctx)
+	if err != nil {
+		return nil, err
+	}
+
+	// Sort the columns, so the same filters result in the same query.
+	columns := make([]string, 0, len(filters))
+	for column := range filters {
+		if !r.columns[column] {
+			return nil, fmt.Errorf("%w: %s", ErrUnknownColumn, column)
+		}
+		columns = append(columns, column)
+	}
+	sort.Strings(columns)
+
+	conditions := make([]string, 0, len(columns))
+	args := make([]interface{}, 0, len(columns))
+	for _, column := range columns {
+		conditions = append(conditions, column+" = ?")
+		args = append(args, filters[column])
+	}
+	if tenant != "" {
+		conditions = append(conditions, tenant)
+		args = append(args, tenantArgs...)
+	}
+	if condition := o.deletedCondition(r.softDelete); condition != "" {
+		conditions = append(conditions, condition)
+	}
+
+	query := fmt.Sprintf("SELECT * FROM %s", r.table)
+	if len(conditions) > 0 {
+		query += " WHERE " + strings.Join(conditions, " AND ")
+	}
+	query += " ORDER BY " + r.id
+
+	entities := []T{}
+	err = retryQuery(ctx, true, func() error {
+		defer observeQuery(ctx, query, columns)()
+
+		entities = entities[:0]
+		return sqlx.SelectContext(ctx, db, &entities, db.Rebind(query), args...)
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	return entities, nil
+}
+
+// List returns a page of entities ordered by id.
+func (r *Repository[T]) List(ctx context.Context, page Page, opts ...QueryOption) ([]T, error) {
+	ctx, cancel := queryContext(ctx, r.conn)
+	defer cancel()
+
+	o := newQueryOptions(opts)
+	db := readDB(r.conn, o)
+
+	if page.Limit <= 0 {
+		page.Limit = DefaultPageLimit
+	}
+
+	tenant, args, err := r.tenantCondition(ctx)
+	if err != nil {
+		return nil, err
+	}
+
+	var conditions []string
+	if tenant != "" {
+		conditions = append(conditions, tenant)
+	}
+	if condition := o.deletedCondition(r.softDelete); condition != "" {
+		conditions = append(conditions, condition)
+	}
+
+	query := fmt.Sprintf("SELECT * FROM %s", r.table)
+	if len(conditions) > 0 {
+		query += " WHERE " + strings.Join(conditions, " AND ")
+	}
+	query += fmt.Sprintf(" ORDER BY %s LIMIT ? OFFSET ?", r.id)
+	args = append(args, page.Limit, page.Offset)
+
+	entities := []T{}
+	err = retryQuery(ctx, true, func() error {
+		defer observeQuery(ctx, query, []string{"limit", "offset"})()
+
+		entities = entities[:0]
+		return sqlx.SelectContext(ctx, db, &entities, db.Rebind(query), args...)
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	return entities, nil
+}
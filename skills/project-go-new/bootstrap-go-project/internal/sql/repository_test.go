@@ -0,0 +1,99 @@
+package sql_test
+
+import (
+	"context"
+	"database/sql/driver"
+	"testing"
+
+	"gitlab.com/btcdirect-api/bootstrap-go-service/internal/sql"
+	"gitlab.com/btcdirect-api/bootstrap-go-service/internal/sql/sqltest"
+)
+
+type account struct {
+	ID   int64  `db:"id" sql:"update"`
+	Name string `db:"name" sql:"insert,update"`
+}
+
+type device struct {
+	ID   string `db:"id" sql:"uuid"`
+	Name string `db:"name" sql:"insert,update"`
+}
+
+func TestRepositoryIDs(t *testing.T) {
+	const uuid = "01890a5d-ac96-774b-bcce-b302099a8057"
+
+	tests := []struct {
+		name      string
+		opts      []sqltest.Option
+		run       func(ctx context.Context, conn sql.DBConnection) error
+		wantQuery string
+		wantID    interface{}
+	}{
+		{
+			name: "find by integer id",
+			run: func(ctx context.Context, conn sql.DBConnection) error {
+				_, err := sql.NewRepository[account](conn, "accounts").FindByID(ctx, int64(7))
+				return err
+			},
+			wantQuery: "SELECT * FROM accounts WHERE id = ?",
+			wantID:    int64(7),
+		},
+		{
+			name: "find by uuid",
+			run: func(ctx context.Context, conn sql.DBConnection) error {
+				_, err := sql.NewRepository[device](conn, "devices").FindByID(ctx, uuid)
+				return err
+			},
+			wantQuery: "SELECT * FROM devices WHERE id = ?",
+			wantID:    uuid,
+		},
+		{
+			name: "find by uuid on postgres",
+			opts: []sqltest.Option{sqltest.WithPostgres()},
+			run: func(ctx context.Context, conn sql.DBConnection) error {
+				_, err := sql.NewRepository[device](conn, "devices").FindByID(ctx, uuid)
+				return err
+			},
+			wantQuery: "SELECT * FROM devices WHERE id = $1",
+			wantID:    uuid,
+		},
+		{
+			name: "delete by integer id",
+			run: func(ctx context.Context, conn sql.DBConnection) error {
+				return sql.NewRepository[account](conn, "accounts").Delete(ctx, int64(7))
+			},
+			wantQuery: "DELETE FROM accounts WHERE id = ?",
+			wantID:    int64(7),
+		},
+		{
+			name: "delete by uuid",
+			run: func(ctx context.Context, conn sql.DBConnection) error {
+				return sql.NewRepository[device](conn, "devices").Delete(ctx, uuid)
+			},
+			wantQuery: "DELETE FROM devices WHERE id = ?",
+			wantID:    uuid,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			db := sqltest.New(t, tt.opts...)
+			db.OnQuery(`^SELECT`).Returns([]string{"id", "name"}, []driver.Value{tt.wantID, "name"})
+
+			if err := tt.run(context.Background(), db); err != nil {
+				t.Fatalf("error = %v", err)
+			}
+
+			executed := db.Statements()
+			if len(executed) != 1 {
+				t.Fatalf("executed %v, want one statement", executed)
+			}
+			if executed[0].Query != tt.wantQuery {
+				t.Errorf("query = %q, want %q", executed[0].Query, tt.wantQuery)
+			}
+			if len(executed[0].Args) != 1 || executed[0].Args[0] != tt.wantID {
+				t.Errorf("args = %v, want [%v]", executed[0].Args, tt.wantID)
+			}
+		})
+	}
+}
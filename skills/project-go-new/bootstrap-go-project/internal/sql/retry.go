@@ -0,0 +1,96 @@
+package sql
+
+import (
+	"context"
+	sqldriver "database/sql/driver"
+	"errors"
+	"fmt"
+	"time"
+
+	"github.com/go-sql-driver/mysql"
+	"gitlab.com/btcdirect-api/bootstrap-go-service/internal/metrics"
+	"golang.org/x/time/rate"
+)
+
+// Number of times a helper query is attempted when it fails with a transient error.
+const maxQueryAttempts = 3
+
+// Backoff before retrying a helper query, multiplied by the attempt.
+const queryRetryBackoff = 50 * time.Millisecond
+
+// Retries of all helper queries are limited to 10 per second with a burst of 20,
+// so an unhealthy database is not overloaded with retries.
+var retryBudget = rate.NewLimiter(10, 20)
+
+var queryRetries = metrics.NewCounter("sql_query_retries")
+
+// RetryError is returned when a helper query kept failing with a transient error,
+// after all attempts or when the retry budget was exhausted.
+type RetryError struct {
+	Attempts int
+	Err      error
+}
+
+func (e *RetryError) Error() string {
+	return fmt.Sprintf("query failed after %d attempts: %v", e.Attempts, e.Err)
+}
+
+func (e *RetryError) Unwrap() error {
+	return e.Err
+}
+
+// IsTransient returns true if the query failed with an error that is expected to pass on retry:
+// a deadlock, a lock wait timeout or a broken connection.
+func IsTransient(err error) bool {
+	return transientReason(err) != ""
+}
+
+// Returns the reason the error is transient, empty when it is not.
+func transientReason(err error) string {
+	var mysqlErr *mysql.MySQLError
+	if errors.As(err, &mysqlErr) {
+		switch mysqlErr.Number {
+		case 1213: // ER_LOCK_DEADLOCK
+			return "deadlock"
+		case 1205: // ER_LOCK_WAIT_TIMEOUT
+			return "lock_wait"
+		}
+		return ""
+	}
+
+	if IsDeadlock(err) {
+		return "deadlock"
+	}
+
+	if errors.Is(err, sqldriver.ErrBadConn) || errors.Is(err, mysql.ErrInvalidConn) {
+		return "connection"
+	}
+
+	return ""
+}
+
+// Runs the query and retries it with backoff while it fails with a transient error.
+//
+// A broken connection may break after the statement was executed, so it is only retried for idempotent queries.
+// Deadlocks and lock wait timeouts roll back the statement, so they are always retried.
+func retryQuery(ctx context.Context, idempotent bool, query func() error) error {
+	for attempt := 1; ; attempt++ {
+		err := query()
+
+		reason := transientReason(err)
+		if reason == "" || (reason == "connection" && !idempotent) {
+			return err
+		}
+		if attempt == maxQueryAttempts || !retryBudget.Allow() {
+			return &RetryError{Attempts: attempt, Err: err}
+		}
+
+		queryRetries.Inc(reason)
+
+		select {
+		case <-ctx.Done():
+			return &RetryError{Attempts: attempt, Err: errors.Join(err, ctx.Err())}
+		case <-time.After(time.Duration(attempt) * queryRetryBackoff):
+		}
+	}
+}
@@ -0,0 +1,126 @@
+package sql
+
+import (
+	"context"
+	sqldriver "database/sql/driver"
+	"errors"
+	"testing"
+
+	"github.com/go-sql-driver/mysql"
+	"golang.org/x/time/rate"
+)
+
+func TestRetryQuery(t *testing.T) {
+	deadlock := &mysql.MySQLError{Number: 1213, Message: "Deadlock found"}
+	duplicate := &mysql.MySQLError{Number: 1062, Message: "Duplicate entry"}
+	cancelled, cancel := context.WithCancel(context.Background())
+	cancel()
+
+	tests := []struct {
+		name         string
+		ctx          context.Context
+		budget       *rate.Limiter
+		idempotent   bool
+		errs         []error
+		wantCalls    int
+		wantAttempts int
+		wantErr      error
+	}{
+		{
+			name:      "success",
+			errs:      []error{nil},
+			wantCalls: 1,
+		},
+		{
+			name:      "not transient",
+			errs:      []error{duplicate},
+			wantCalls: 1,
+			wantErr:   duplicate,
+		},
+		{
+			name:      "deadlock passes on retry",
+			errs:      []error{deadlock, nil},
+			wantCalls: 2,
+		},
+		{
+			name:         "all attempts fail",
+			errs:         []error{deadlock, deadlock, deadlock},
+			wantCalls:    maxQueryAttempts,
+			wantAttempts: maxQueryAttempts,
+			wantErr:      deadlock,
+		},
+		{
+			name:         "budget exhausted",
+			budget:       rate.NewLimiter(0, 0),
+			errs:         []error{deadlock, nil},
+			wantCalls:    1,
+			wantAttempts: 1,
+			wantErr:      deadlock,
+		},
+		{
+			name:         "budget exhausted after the burst",
+			budget:       rate.NewLimiter(0, 1),
+			errs:         []error{deadlock, deadlock, nil},
+			wantCalls:    2,
+			wantAttempts: 2,
+			wantErr:      deadlock,
+		},
+		{
+			name:      "broken connection not idempotent",
+			errs:      []error{sqldriver.ErrBadConn, nil},
+			wantCalls: 1,
+			wantErr:   sqldriver.ErrBadConn,
+		},
+		{
+			name:       "broken connection idempotent",
+			idempotent: true,
+			errs:       []error{sqldriver.ErrBadConn, nil},
+			wantCalls:  2,
+		},
+		{
+			name:         "context cancelled",
+			ctx:          cancelled,
+			errs:         []error{deadlock, nil},
+			wantCalls:    1,
+			wantAttempts: 1,
+			wantErr:      context.Canceled,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			budget := retryBudget
+			t.Cleanup(func() { retryBudget = budget })
+			retryBudget = rate.NewLimiter(rate.Inf, 0)
+			if tt.budget != nil {
+				retryBudget = tt.budget
+			}
+
+			ctx := tt.ctx
+			if ctx == nil {
+				ctx = context.Background()
+			}
+
+			calls := 0
+			err := retryQuery(ctx, tt.idempotent, func() error {
+				calls++
+				return tt.errs[calls-1]
+			})
+
+			if !errors.Is(err, tt.wantErr) {
+				t.Fatalf("retryQuery() error = %v, want %v", err, tt.wantErr)
+			}
+			if calls != tt.wantCalls {
+				t.Errorf("query called %d times, want %d", calls, tt.wantCalls)
+			}
+
+			var retryErr *RetryError
+			if errors.As(err, &retryErr) != (tt.wantAttempts > 0) {
+				t.Fatalf("retryQuery() error = %v, want RetryError %v", err, tt.wantAttempts > 0)
+			}
+			if retryErr != nil && retryErr.Attempts != tt.wantAttempts {
+				t.Errorf("RetryError.Attempts = %d, want %d", retryErr.Attempts, tt.wantAttempts)
+			}
+		})
+	}
+}
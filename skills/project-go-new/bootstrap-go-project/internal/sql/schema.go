@@ -0,0 +1,213 @@
+package sql
+
+import (
+	"context"
+	stdsql "database/sql"
+	"fmt"
+	"reflect"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+)
+
+// Drift is a difference between a registered struct and the schema of its table.
+type Drift struct {
+	Table  string
+	Column string
+	Issue  string
+}
+
+func (d Drift) String() string {
+	if d.Column == "" {
+		return fmt.Sprintf("%s: %s", d.Table, d.Issue)
+	}
+
+	return fmt.Sprintf("%s.%s: %s", d.Table, d.Column, d.Issue)
+}
+
+var schemaTables = struct {
+	sync.Mutex
+	types map[string]reflect.Type
+}{types: make(map[string]reflect.Type)}
+
+// RegisterTable registers the struct stored in the table, so ValidateSchema compares its db tags to the schema.
+// Repositories register their table when they are created.
+func RegisterTable(table string, model interface{}) {
+	typ := reflect.TypeOf(model)
+	for typ.Kind() == reflect.Ptr {
+		typ = typ.Elem()
+	}
+
+	schemaTables.Lock()
+	defer schemaTables.Unlock()
+
+	schemaTables.types[table] = typ
+}
+
+// Column of a table as described by the information schema.
+type schemaColumn struct {
+	Name     string `db:"name"`
+	DataType string `db:"data_type"`
+	Nullable string `db:"nullable"`
+}
+
+// ValidateSchema compares the db tags of the registered structs to the columns of their tables
+// in the information schema, and returns the drift: missing tables and columns, columns with a type that can not be
+// scanned into the field, and nullable columns scanned into fields that can not hold NULL.
+//
+// Fields with a type the validation does not know, e.g. a custom sql.Scanner, are only checked for their column.
+func ValidateSchema(ctx context.Context, conn DBConnection) ([]Drift, error) {
+	db := conn.DB(true)
+
+	ctx, cancelfunc := queryContext(ctx, conn)
+	defer cancelfunc()
+
+	schema := "DATABASE()"
+	if IsPostgres(db.DriverName()) {
+		schema = "current_schema()"
+	}
+	query := db.Rebind(`SELECT column_name AS name, data_type AS data_type, is_nullable AS nullable
+		FROM information_schema.columns WHERE table_schema = ` + schema + ` AND table_name = ?`)
+
+	schemaTables.Lock()
+	tables := make(map[string]reflect.Type, len(schemaTables.types))
+	names := make([]string, 0, len(schemaTables.types))
+	for table, typ := range schemaTables.types {
+		tables[table] = typ
+		names = append(names, table)
+	}
+	schemaTables.Unlock()
+	sort.Strings(names)
+
+	var drift []Drift
+	for _, table := range names {
+		var columns []schemaColumn
+		if err := db.SelectContext(ctx, &columns, query, table); err != nil {
+			return nil, fmt.Errorf("reading columns of %s: %w", table, err)
+		}
+		if len(columns) == 0 {
+			drift = append(drift, Drift{Table: table, Issue: "table does not exist"})
+			continue
+		}
+
+		drift = append(drift, compareColumns(table, tables[table], columns)...)
+	}
+
+	return drift, nil
+}
+
+// Compares the fields of the struct to the columns of its table.
+func compareColumns(table string, typ reflect.Type, columns []schemaColumn) []Drift {
+	byName := make(map[string]schemaColumn, len(columns))
+	for _, column := range columns {
+		byName[strings.ToLower(column.Name)] = column
+	}
+
+	var drift []Drift
+	for i := 0; i < typ.NumField(); i++ {
+		field := typ.Field(i)
+		name := field.Tag.Get("db")
+		if name == "" || name == "-" {
+			continue
+		}
+
+		column, ok := byName[strings.ToLower(name)]
+		if !ok {
+			drift = append(drift, Drift{Table: table, Column: name, Issue: "column does not exist"})
+			continue
+		}
+
+		types, nullable, known := fieldColumnTypes(field.Type)
+		if !known {
+			continue
+		}
+
+		dataType := strings.ToLower(column.DataType)
+		if !types[dataType] {
+			drift = append(drift, Drift{Table: table, Column: name, Issue: fmt.Sprintf("column type %s does not match field type %s", dataType, field.Type)})
+		}
+		if column.Nullable == "YES" && !nullable {
+			drift = append(drift, Drift{Table: table, Column: name, Issue: fmt.Sprintf("column is nullable, field type %s can not hold NULL", field.Type)})
+		}
+	}
+
+	return drift
+}
+
+var (
+	integerColumns = columnTypes("tinyint", "smallint", "mediumint", "int", "integer", "bigint", "year")
+	floatColumns   = columnTypes("float", "double", "decimal", "numeric", "real", "double precision")
+	stringColumns  = columnTypes("char", "varchar", "tinytext", "text", "mediumtext", "longtext", "enum", "set", "json", "jsonb",
+		"decimal", "numeric", "uuid", "character", "character varying", "date", "datetime", "timestamp", "time")
+	boolColumns    = columnTypes("tinyint", "bit", "boolean")
+	timeColumns    = columnTypes("date", "datetime", "timestamp", "time", "timestamp without time zone", "timestamp with time zone")
+	decimalColumns = columnTypes("decimal", "numeric")
+	bytesColumns   = columnTypes("binary", "varbinary", "tinyblob", "blob", "mediumblob", "longblob", "bytea", "json", "jsonb",
+		"char", "varchar", "text", "mediumtext", "longtext", "uuid")
+)
+
+var (
+	timeType     = reflect.TypeOf(time.Time{})
+	decimalType  = reflect.TypeOf(Decimal{})
+	nullableType = map[reflect.Type]reflect.Type{
+		reflect.TypeOf(stdsql.NullString{}):  reflect.TypeOf(""),
+		reflect.TypeOf(stdsql.NullInt64{}):   reflect.TypeOf(int64(0)),
+		reflect.TypeOf(stdsql.NullInt32{}):   reflect.TypeOf(int32(0)),
+		reflect.TypeOf(stdsql.NullInt16{}):   reflect.TypeOf(int16(0)),
+		reflect.TypeOf(stdsql.NullByte{}):    reflect.TypeOf(byte(0)),
+		reflect.TypeOf(stdsql.NullFloat64{}): reflect.TypeOf(float64(0)),
+		reflect.TypeOf(stdsql.NullBool{}):    reflect.TypeOf(false),
+		reflect.TypeOf(stdsql.NullTime{}):    timeType,
+		reflect.TypeOf(NullDecimal{}):        decimalType,
+	}
+)
+
+func columnTypes(names ...string) map[string]bool {
+	types := make(map[string]bool, len(names))
+	for _, name := range names {
+		types[name] = true
+	}
+
+	return types
+}
+
+// Returns the column types that can be scanned into the field type and whether the field can hold NULL.
+// Known is false for types the validation does not know.
+func fieldColumnTypes(typ reflect.Type) (types map[string]bool, nullable bool, known bool) {
+	if typ.Kind() == reflect.Ptr {
+		typ = typ.Elem()
+		nullable = true
+	}
+	if t, ok := nullableType[typ]; ok {
+		typ = t
+		nullable = true
+	}
+
+	switch {
+	case typ == timeType:
+		return timeColumns, nullable, true
+	case typ == decimalType:
+		return decimalColumns, nullable, true
+	case typ.Kind() == reflect.Slice && typ.Elem().Kind() == reflect.Uint8:
+		// A nil byte slice holds NULL.
+		return bytesColumns, true, true
+	case typ.PkgPath() != "" && typ.Kind() == reflect.Struct:
+		// Custom types, e.g. implementing sql.Scanner, decide for themselves.
+		return nil, false, false
+	}
+
+	switch typ.Kind() {
+	case reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32, reflect.Int64,
+		reflect.Uint, reflect.Uint8, reflect.Uint16, reflect.Uint32, reflect.Uint64:
+		return integerColumns, nullable, true
+	case reflect.Float32, reflect.Float64:
+		return floatColumns, nullable, true
+	case reflect.String:
+		return stringColumns, nullable, true
+	case reflect.Bool:
+		return boolColumns, nullable, true
+	}
+
+	return nil, false, false
+}
@@ -0,0 +1,83 @@
+package sql
+
+import (
+	"context"
+	"encoding/base64"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"strings"
+
+	"golang.org/x/oauth2/google"
+)
+
+const (
+	secretPrefix   = "sm://"
+	secretScope    = "https://www.googleapis.com/auth/cloud-platform"
+	secretEndpoint = "https://secretmanager.googleapis.com/v1/%s:access"
+)
+
+// IsSecretReference returns true if the DSN references a Secret Manager secret,
+// e.g. sm://projects/my-project/secrets/db-dsn.
+func IsSecretReference(dsn string) bool {
+	return strings.HasPrefix(dsn, secretPrefix)
+}
+
+// ResolveSecret returns the DSN stored in the Secret Manager secret of the reference, using the default credentials.
+// The latest version is read unless the reference names one (sm://projects/x/secrets/db-dsn/versions/3).
+//
+// Query parameters of the reference are added to the resolved DSN, e.g. sm://projects/x/secrets/db-dsn?multiStatements=true.
+func ResolveSecret(ctx context.Context, reference string) (string, error) {
+	name, params, _ := strings.Cut(strings.TrimPrefix(reference, secretPrefix), "?")
+	if !strings.HasPrefix(name, "projects/") || !strings.Contains(name, "/secrets/") {
+		return "", fmt.Errorf("invalid secret reference %s, expected %sprojects/<project>/secrets/<secret>", reference, secretPrefix)
+	}
+	if !strings.Contains(name, "/versions/") {
+		name += "/versions/latest"
+	}
+
+	client, err := google.DefaultClient(ctx, secretScope)
+	if err != nil {
+		return "", err
+	}
+
+	r, err := http.NewRequestWithContext(ctx, http.MethodGet, fmt.Sprintf(secretEndpoint, name), nil)
+	if err != nil {
+		return "", err
+	}
+
+	res, err := client.Do(r)
+	if err != nil {
+		return "", err
+	}
+	defer res.Body.Close()
+
+	if res.StatusCode != http.StatusOK {
+		return "", fmt.Errorf("accessing secret %s failed: %s", name, res.Status)
+	}
+
+	var body struct {
+		Payload struct {
+			Data string `json:"data"`
+		} `json:"payload"`
+	}
+	if err := json.NewDecoder(res.Body).Decode(&body); err != nil {
+		return "", err
+	}
+
+	data, err := base64.StdEncoding.DecodeString(body.Payload.Data)
+	if err != nil {
+		return "", err
+	}
+
+	dsn := strings.TrimSpace(string(data))
+	if params != "" {
+		separator := "?"
+		if strings.Contains(dsn, "?") {
+			separator = "&"
+		}
+		dsn += separator + params
+	}
+
+	return dsn, nil
+}
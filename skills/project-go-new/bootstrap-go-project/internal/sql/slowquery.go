@@ -0,0 +1,58 @@
+package sql
+
+import (
+	"context"
+	"regexp"
+	"time"
+
+	"go.uber.org/zap"
+)
+
+type slowQueryKey struct{}
+
+// slowQueryLog logs the queries taking longer than the threshold.
+type slowQueryLog struct {
+	threshold time.Duration
+	log       *zap.SugaredLogger
+}
+
+// Matches the named parameters of a query, skipping Postgres casts (::type).
+var namedParameter = regexp.MustCompile(`(?:^|[^:]):(\w+)`)
+
+// Adds the slow query log of the connection to the context, when the connection has a slow query threshold.
+func withSlowQueryLog(ctx context.Context, conn DBConnection) context.Context {
+	c, ok := conn.(*Connection)
+	if !ok || c.SlowQueryThreshold <= 0 || c.Log == nil {
+		return ctx
+	}
+
+	return context.WithValue(ctx, slowQueryKey{}, slowQueryLog{threshold: c.SlowQueryThreshold, log: c.Log})
+}
+
+// Starts timing the query, the returned func logs it when it exceeded the slow query threshold.
+// Only the names of the parameters are logged, their values may contain personal data.
+func observeQuery(ctx context.Context, query string, parameters []string) func() {
+	l, ok := ctx.Value(slowQueryKey{}).(slowQueryLog)
+	if !ok {
+		return func() {}
+	}
+
+	start := time.Now()
+
+	return func() {
+		if duration := time.Since(start); duration > l.threshold {
+			l.log.Warnw("Slow query", "query", query, "parameters", parameters, "duration", duration)
+		}
+	}
+}
+
+// Returns the names of the named parameters of the query, e.g. [id] for "WHERE id = :id".
+func namedParameters(query string) []string {
+	matches := namedParameter.FindAllStringSubmatch(query, -1)
+	names := make([]string, 0, len(matches))
+	for _, m := range matches {
+		names = append(names, m[1])
+	}
+
+	return names
+}
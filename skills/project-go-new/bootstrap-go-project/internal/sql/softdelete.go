@@ -0,0 +1,118 @@
+package sql
+
+import (
+	"context"
+	"fmt"
+	"reflect"
+	"strings"
+)
+
+// Tag value marking the soft delete column, e.g. DeletedAt sql.NullTime `db:"deleted_at" sql:"softdelete"`.
+// Rows with a soft delete column are deleted by setting it, and are excluded from selects unless WithDeleted is given.
+const softDeleteTag = "softdelete"
+
+// QueryOption changes the rows selected by a query, or the node they are read from.
+type QueryOption func(*queryOptions)
+
+type queryOptions struct {
+	withDeleted bool
+	primary     bool
+	fetchSize   int
+}
+
+// WithDeleted includes soft deleted rows.
+func WithDeleted() QueryOption {
+	return func(o *queryOptions) {
+		o.withDeleted = true
+	}
+}
+
+// WithPrimary reads from the primary instead of a replica, e.g. directly after a write.
+func WithPrimary() QueryOption {
+	return func(o *queryOptions) {
+		o.primary = true
+	}
+}
+
+func newQueryOptions(opts []QueryOption) queryOptions {
+	var o queryOptions
+	for _, opt := range opts {
+		opt(&o)
+	}
+
+	return o
+}
+
+// Returns the condition excluding soft deleted rows, empty when the rows are not soft deleted or deleted rows are included.
+func (o queryOptions) deletedCondition(column string) string {
+	if column == "" || o.withDeleted {
+		return ""
+	}
+
+	return column + " IS NULL"
+}
+
+// Returns the soft delete column of the struct, empty when it has none.
+func softDeleteColumn(typ reflect.Type) string {
+	for typ.Kind() == reflect.Ptr {
+		typ = typ.Elem()
+	}
+	if typ.Kind() != reflect.Struct {
+		return ""
+	}
+
+	for i := 0; i < typ.NumField(); i++ {
+		field := typ.Field(i)
+		if hasSQLOption(field, softDeleteTag) {
+			return field.Tag.Get("db")
+		}
+	}
+
+	return ""
+}
+
+// ExecuteSoftDelete deletes the row of the data by setting its soft delete column to the time of the TimestampClock.
+// The data must have a soft delete column, the id is taken from the first field like ExecuteUpdate.
+func ExecuteSoftDelete(conn DBConnection, table string, data interface{}) error {
+	return ExecuteSoftDeleteContext(context.Background(), conn, table, data)
+}
+
+// ExecuteSoftDeleteContext soft deletes the row of the data, the query is limited by the timeout of the connection.
+func ExecuteSoftDeleteContext(ctx context.Context, conn DBConnection, table string, data interface{}) error {
+
+	db := conn.DB(true)
+
+	ctx, cancelfunc := queryContext(ctx, conn)
+	defer cancelfunc()
+
+	value := reflect.Indirect(reflect.ValueOf(data))
+	column := softDeleteColumn(value.Type())
+	if column == "" {
+		return fmt.Errorf("%s has no soft delete column", value.Type())
+	}
+
+	tenantColumn, tenant, err := tenantScope(ctx, value.Type())
+	if err != nil {
+		return err
+	}
+
+	id := value.Type().Field(0).Tag.Get("db")
+	query := fmt.Sprintf("UPDATE %s SET %s = :deleted WHERE %s = :id AND %s IS NULL;", table, column, id, column)
+
+	args := map[string]interface{}{
+		"id":      value.Field(0).Interface(),
+		"deleted": TimestampClock.Now().UTC(),
+	}
+	if tenantColumn != "" {
+		query = strings.TrimSuffix(query, ";") + fmt.Sprintf(" AND %s = :%s;", tenantColumn, tenantParameter)
+		args[tenantParameter] = tenant
+	}
+
+	// Rows that are already deleted are not updated again, so the query can be retried.
+	return retryQuery(ctx, true, func() error {
+		defer observeQuery(ctx, query, namedParameters(query))()
+
+		_, err := namedExec(ctx, db, query, args)
+		return err
+	})
+}
@@ -0,0 +1,70 @@
+package sql_test
+
+import (
+	"context"
+	stdsql "database/sql"
+	"testing"
+	"time"
+
+	"gitlab.com/btcdirect-api/bootstrap-go-service/internal/sql"
+	"gitlab.com/btcdirect-api/bootstrap-go-service/internal/sql/sqltest"
+)
+
+type fixedClock time.Time
+
+func (c fixedClock) Now() time.Time {
+	return time.Time(c)
+}
+
+type note struct {
+	ID        int64           `db:"id" sql:"update"`
+	Text      string          `db:"text" sql:"insert,update"`
+	DeletedAt stdsql.NullTime `db:"deleted_at" sql:"softdelete"`
+}
+
+func TestSoftDeleteUsesTimestampClock(t *testing.T) {
+	now := time.Date(2024, 5, 1, 12, 0, 0, 0, time.FixedZone("CEST", 2*60*60))
+	defer func(clock sql.Clock) { sql.TimestampClock = clock }(sql.TimestampClock)
+	sql.TimestampClock = fixedClock(now)
+
+	tests := []struct {
+		name      string
+		run       func(ctx context.Context, conn sql.DBConnection) error
+		wantQuery string
+	}{
+		{
+			name: "repository",
+			run: func(ctx context.Context, conn sql.DBConnection) error {
+				return sql.NewRepository[note](conn, "notes").Delete(ctx, int64(3))
+			},
+			wantQuery: "UPDATE notes SET deleted_at = ? WHERE id = ? AND deleted_at IS NULL",
+		},
+		{
+			name: "helper",
+			run: func(ctx context.Context, conn sql.DBConnection) error {
+				return sql.ExecuteSoftDeleteContext(ctx, conn, "notes", &note{ID: 3})
+			},
+			wantQuery: "UPDATE notes SET deleted_at = ? WHERE id = ? AND deleted_at IS NULL;",
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			db := sqltest.New(t)
+			if err := tt.run(context.Background(), db); err != nil {
+				t.Fatalf("error = %v", err)
+			}
+
+			s := db.AssertExecuted(t, `^UPDATE notes`)
+			if s.Query != tt.wantQuery {
+				t.Errorf("query = %q, want %q", s.Query, tt.wantQuery)
+			}
+			if len(s.Args) != 2 {
+				t.Fatalf("args = %v, want the deletion time and id", s.Args)
+			}
+			if deleted, ok := s.Args[0].(time.Time); !ok || !deleted.Equal(now) || deleted.Location() != time.UTC {
+				t.Errorf("deleted at = %v, want %v in UTC", s.Args[0], now)
+			}
+		})
+	}
+}
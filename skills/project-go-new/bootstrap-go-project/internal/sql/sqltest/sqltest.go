@@ -0,0 +1,383 @@
+// Package sqltest provides an in-memory fake of the database connection for unit tests of repositories and services.
+//
+// The fake implements sql.DBConnection, so it is passed to the helpers like a real connection:
+//
+//	db := sqltest.New(t)
+//	db.OnQuery(`SELECT .* FROM users`).Returns([]string{"id", "name"}, []driver.Value{1, "Alice"})
+//
+//	user, err := repo.Find(ctx, 1)
+//
+//	db.AssertExecuted(t, `SELECT .* FROM users WHERE id = \?`)
+//
+// Queries without a stub return no rows and statements without a stub affect one row.
+package sqltest
+
+import (
+	"context"
+	stdsql "database/sql"
+	"database/sql/driver"
+	"io"
+	"regexp"
+	"sync"
+	"testing"
+
+	"github.com/jmoiron/sqlx"
+)
+
+// Statement is a statement executed on the fake, transactions are recorded as BEGIN, COMMIT and ROLLBACK.
+type Statement struct {
+	Query string
+	Args  []interface{}
+}
+
+// Option changes the fake.
+type Option func(*DB)
+
+// WithPostgres makes the fake behave as a Postgres database, the queries use $1 placeholders.
+func WithPostgres() Option {
+	return func(d *DB) {
+		d.driverName = "postgres"
+	}
+}
+
+// DB is the fake database connection, create it with New.
+type DB struct {
+	sync.Mutex
+	driverName string
+	db         *sqlx.DB
+	stubs      []*Stub
+	statements []Statement
+}
+
+// New returns a fake database connection, it is closed when the test finishes.
+func New(t testing.TB, opts ...Option) *DB {
+	t.Helper()
+
+	d := &DB{driverName: "mysql"}
+	for _, opt := range opts {
+		opt(d)
+	}
+
+	d.db = sqlx.NewDb(stdsql.OpenDB(connector{d}), d.driverName)
+	t.Cleanup(func() {
+		d.db.Close()
+	})
+
+	return d
+}
+
+// DB returns the database of the fake.
+func (d *DB) DB(bool) *sqlx.DB {
+	return d.db
+}
+
+// IsAlive returns true until the fake is shut down.
+func (d *DB) IsAlive() bool {
+	return d.db.Ping() == nil
+}
+
+// Shutdown closes the database of the fake.
+func (d *DB) Shutdown() error {
+	return d.db.Close()
+}
+
+// OnQuery stubs the result of the queries and statements matching the regular expression.
+// The stubs are matched in the order they were added.
+//
+// This method is thread-safe.
+func (d *DB) OnQuery(pattern string) *Stub {
+	d.Lock()
+	defer d.Unlock()
+
+	s := &Stub{pattern: regexp.MustCompile(pattern), rowsAffected: 1}
+	d.stubs = append(d.stubs, s)
+
+	return s
+}
+
+// Statements returns the statements executed on the fake, in order.
+//
+// This method is thread-safe.
+func (d *DB) Statements() []Statement {
+	d.Lock()
+	defer d.Unlock()
+
+	return append([]Statement(nil), d.statements...)
+}
+
+// Reset removes the stubs and the executed statements.
+//
+// This method is thread-safe.
+func (d *DB) Reset() {
+	d.Lock()
+	defer d.Unlock()
+
+	d.stubs = nil
+	d.statements = nil
+}
+
+// AssertExecuted fails the test when no executed statement matches the regular expression.
+// It returns the first matching statement.
+func (d *DB) AssertExecuted(t testing.TB, pattern string) Statement {
+	t.Helper()
+
+	re := regexp.MustCompile(pattern)
+	for _, s := range d.Statements() {
+		if re.MatchString(s.Query) {
+			return s
+		}
+	}
+
+	t.Errorf("sqltest: no statement executed matching %q, executed:%s", pattern, d.executed())
+	return Statement{}
+}
+
+// AssertNotExecuted fails the test when an executed statement matches the regular expression.
+func (d *DB) AssertNotExecuted(t testing.TB, pattern string) {
+	t.Helper()
+
+	re := regexp.MustCompile(pattern)
+	for _, s := range d.Statements() {
+		if re.MatchString(s.Query) {
+			t.Errorf("sqltest: statement executed matching %q: %s", pattern, s.Query)
+			return
+		}
+	}
+}
+
+// Returns the executed statements, one per line.
+func (d *DB) executed() string {
+	var out string
+	for _, s := range d.Statements() {
+		out += "\n\t" + s.Query
+	}
+	if out == "" {
+		return " none"
+	}
+
+	return out
+}
+
+// Records the statement and returns the stub matching it, nil if none matches.
+//
+// This method is thread-safe.
+func (d *DB) record(query string, args []driver.NamedValue) *Stub {
+	d.Lock()
+	defer d.Unlock()
+
+	values := make([]interface{}, len(args))
+	for i, arg := range args {
+		values[i] = arg.Value
+	}
+	d.statements = append(d.statements, Statement{Query: query, Args: values})
+
+	for _, s := range d.stubs {
+		if s.pattern.MatchString(query) {
+			return s
+		}
+	}
+
+	return nil
+}
+
+// Stub is the result of the statements matching its pattern, see DB.OnQuery.
+type Stub struct {
+	pattern      *regexp.Regexp
+	columns      []string
+	rows         [][]driver.Value
+	lastInsertID int64
+	rowsAffected int64
+	err          error
+}
+
+// Returns sets the columns and rows returned by the queries.
+func (s *Stub) Returns(columns []string, rows ...[]driver.Value) *Stub {
+	s.columns = columns
+	s.rows = rows
+	return s
+}
+
+// ReturnsResult sets the last insert id and affected rows of the statements.
+func (s *Stub) ReturnsResult(lastInsertID, rowsAffected int64) *Stub {
+	s.lastInsertID = lastInsertID
+	s.rowsAffected = rowsAffected
+	return s
+}
+
+// ReturnsError makes the queries and statements fail with the error.
+func (s *Stub) ReturnsError(err error) *Stub {
+	s.err = err
+	return s
+}
+
+// The driver of the fake, every connection records on the same DB.
+type connector struct {
+	db *DB
+}
+
+func (c connector) Connect(context.Context) (driver.Conn, error) {
+	return conn(c), nil
+}
+
+func (c connector) Driver() driver.Driver {
+	return fakeDriver{}
+}
+
+type fakeDriver struct{}
+
+func (fakeDriver) Open(string) (driver.Conn, error) {
+	return nil, driver.ErrSkip
+}
+
+type conn struct {
+	db *DB
+}
+
+func (c conn) Prepare(query string) (driver.Stmt, error) {
+	return stmt{db: c.db, query: query}, nil
+}
+
+func (c conn) Close() error {
+	return nil
+}
+
+func (c conn) Begin() (driver.Tx, error) {
+	return c.BeginTx(context.Background(), driver.TxOptions{})
+}
+
+func (c conn) BeginTx(context.Context, driver.TxOptions) (driver.Tx, error) {
+	if s := c.db.record("BEGIN", nil); s != nil && s.err != nil {
+		return nil, s.err
+	}
+
+	return tx(c), nil
+}
+
+func (c conn) ExecContext(_ context.Context, query string, args []driver.NamedValue) (driver.Result, error) {
+	return execStub(c.db, query, args)
+}
+
+func (c conn) QueryContext(_ context.Context, query string, args []driver.NamedValue) (driver.Rows, error) {
+	return queryStub(c.db, query, args)
+}
+
+type tx struct {
+	db *DB
+}
+
+func (t tx) Commit() error {
+	if s := t.db.record("COMMIT", nil); s != nil {
+		return s.err
+	}
+
+	return nil
+}
+
+func (t tx) Rollback() error {
+	if s := t.db.record("ROLLBACK", nil); s != nil {
+		return s.err
+	}
+
+	return nil
+}
+
+type stmt struct {
+	db    *DB
+	query string
+}
+
+func (s stmt) Close() error {
+	return nil
+}
+
+// The number of arguments is not known, database/sql then does not check it.
+func (s stmt) NumInput() int {
+	return -1
+}
+
+func (s stmt) Exec(args []driver.Value) (driver.Result, error) {
+	return execStub(s.db, s.query, namedValues(args))
+}
+
+func (s stmt) Query(args []driver.Value) (driver.Rows, error) {
+	return queryStub(s.db, s.query, namedValues(args))
+}
+
+func (s stmt) ExecContext(_ context.Context, args []driver.NamedValue) (driver.Result, error) {
+	return execStub(s.db, s.query, args)
+}
+
+func (s stmt) QueryContext(_ context.Context, args []driver.NamedValue) (driver.Rows, error) {
+	return queryStub(s.db, s.query, args)
+}
+
+func namedValues(args []driver.Value) []driver.NamedValue {
+	named := make([]driver.NamedValue, len(args))
+	for i, arg := range args {
+		named[i] = driver.NamedValue{Ordinal: i + 1, Value: arg}
+	}
+
+	return named
+}
+
+func execStub(db *DB, query string, args []driver.NamedValue) (driver.Result, error) {
+	s := db.record(query, args)
+	if s == nil {
+		return result{rowsAffected: 1}, nil
+	}
+	if s.err != nil {
+		return nil, s.err
+	}
+
+	return result{lastInsertID: s.lastInsertID, rowsAffected: s.rowsAffected}, nil
+}
+
+func queryStub(db *DB, query string, args []driver.NamedValue) (driver.Rows, error) {
+	s := db.record(query, args)
+	if s == nil {
+		return &rows{}, nil
+	}
+	if s.err != nil {
+		return nil, s.err
+	}
+
+	return &rows{columns: s.columns, values: s.rows}, nil
+}
+
+type result struct {
+	lastInsertID int64
+	rowsAffected int64
+}
+
+func (r result) LastInsertId() (int64, error) {
+	return r.lastInsertID, nil
+}
+
+func (r result) RowsAffected() (int64, error) {
+	return r.rowsAffected, nil
+}
+
+type rows struct {
+	columns []string
+	values  [][]driver.Value
+	next    int
+}
+
+func (r *rows) Columns() []string {
+	return r.columns
+}
+
+func (r *rows) Close() error {
+	return nil
+}
+
+func (r *rows) Next(dest []driver.Value) error {
+	if r.next >= len(r.values) {
+		return io.EOF
+	}
+
+	copy(dest, r.values[r.next])
+	r.next++
+
+	return nil
+}
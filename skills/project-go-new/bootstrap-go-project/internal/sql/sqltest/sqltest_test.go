@@ -0,0 +1,235 @@
+package sqltest_test
+
+import (
+	"context"
+	"database/sql/driver"
+	"errors"
+	"regexp"
+	"testing"
+
+	"github.com/jmoiron/sqlx"
+	"gitlab.com/btcdirect-api/bootstrap-go-service/internal/sql"
+	"gitlab.com/btcdirect-api/bootstrap-go-service/internal/sql/sqltest"
+)
+
+type user struct {
+	ID   int64  `db:"id" sql:"update"`
+	Name string `db:"name" sql:"insert,update"`
+}
+
+var errStub = errors.New("stubbed error")
+
+func TestDB(t *testing.T) {
+	ctx := context.Background()
+
+	tests := []struct {
+		name    string
+		opts    []sqltest.Option
+		stub    func(db *sqltest.DB)
+		run     func(t *testing.T, db *sqltest.DB) error
+		wantErr error
+		// Patterns of the executed statements, in order.
+		want []string
+	}{
+		{
+			name: "insert returns the stubbed id",
+			stub: func(db *sqltest.DB) {
+				db.OnQuery(`^INSERT INTO users`).ReturnsResult(7, 1)
+			},
+			run: func(t *testing.T, db *sqltest.DB) error {
+				id, err := sql.ExecuteInsertContext(ctx, db, "users", &user{Name: "Alice"})
+				if id != 7 {
+					t.Errorf("id = %d, want 7", id)
+				}
+				return err
+			},
+			want: []string{`^INSERT INTO users\(name\) VALUES\(\?\);$`},
+		},
+		{
+			name: "insert on postgres returns the id of the query",
+			opts: []sqltest.Option{sqltest.WithPostgres()},
+			stub: func(db *sqltest.DB) {
+				db.OnQuery(`RETURNING id`).Returns([]string{"id"}, []driver.Value{int64(9)})
+			},
+			run: func(t *testing.T, db *sqltest.DB) error {
+				id, err := sql.ExecuteInsertContext(ctx, db, "users", &user{Name: "Alice"})
+				if id != 9 {
+					t.Errorf("id = %d, want 9", id)
+				}
+				return err
+			},
+			want: []string{`^INSERT INTO users\(name\) VALUES\(\$1\) RETURNING id;$`},
+		},
+		{
+			name: "get scans the stubbed row",
+			stub: func(db *sqltest.DB) {
+				db.OnQuery(`^SELECT \* FROM users`).Returns([]string{"id", "name"}, []driver.Value{int64(1), "Alice"})
+			},
+			run: func(t *testing.T, db *sqltest.DB) error {
+				var u user
+				_, err := sql.ExecuteGetContext(ctx, db, "users", 1, &u)
+				if u.Name != "Alice" {
+					t.Errorf("name = %q, want Alice", u.Name)
+				}
+				return err
+			},
+			want: []string{`^SELECT \* FROM users WHERE id = \?$`},
+		},
+		{
+			name: "statement without a stub affects one row",
+			run: func(t *testing.T, db *sqltest.DB) error {
+				return sql.ExecuteDeleteContext(ctx, db, "users", 1)
+			},
+			want: []string{`^DELETE FROM users WHERE id = \?;$`},
+		},
+		{
+			name: "stubbed result without affected rows",
+			stub: func(db *sqltest.DB) {
+				db.OnQuery(`^DELETE`).ReturnsResult(0, 0)
+			},
+			run: func(t *testing.T, db *sqltest.DB) error {
+				return sql.ExecuteDeleteContext(ctx, db, "users", 1)
+			},
+			wantErr: sql.ErrNotFound,
+			want:    []string{`^DELETE FROM users`},
+		},
+		{
+			name: "stubbed error",
+			stub: func(db *sqltest.DB) {
+				db.OnQuery(`^UPDATE users`).ReturnsError(errStub)
+			},
+			run: func(t *testing.T, db *sqltest.DB) error {
+				return sql.ExecuteUpdateContext(ctx, db, "users", &user{ID: 1, Name: "Bob"})
+			},
+			wantErr: errStub,
+			want:    []string{`^UPDATE users SET id=\?, name=\? WHERE id = \?;$`},
+		},
+		{
+			name: "first matching stub is used",
+			stub: func(db *sqltest.DB) {
+				db.OnQuery(`^DELETE FROM users`).ReturnsError(errStub)
+				db.OnQuery(`^DELETE`).ReturnsResult(0, 0)
+			},
+			run: func(t *testing.T, db *sqltest.DB) error {
+				return sql.ExecuteDeleteContext(ctx, db, "users", 1)
+			},
+			wantErr: errStub,
+			want:    []string{`^DELETE FROM users`},
+		},
+		{
+			name: "committed transaction",
+			run: func(t *testing.T, db *sqltest.DB) error {
+				return sql.WithTx(ctx, db, func(ctx context.Context, tx *sqlx.Tx) error {
+					_, err := sql.ExecuteInsertTx(ctx, tx, "users", &user{Name: "Alice"})
+					return err
+				})
+			},
+			want: []string{`^BEGIN$`, `^INSERT INTO users`, `^COMMIT$`},
+		},
+		{
+			name: "rolled back transaction",
+			run: func(t *testing.T, db *sqltest.DB) error {
+				return sql.WithTx(ctx, db, func(ctx context.Context, tx *sqlx.Tx) error {
+					return errStub
+				})
+			},
+			wantErr: errStub,
+			want:    []string{`^BEGIN$`, `^ROLLBACK$`},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			db := sqltest.New(t, tt.opts...)
+			if tt.stub != nil {
+				tt.stub(db)
+			}
+
+			if err := tt.run(t, db); !errors.Is(err, tt.wantErr) {
+				t.Fatalf("error = %v, want %v", err, tt.wantErr)
+			}
+
+			executed := db.Statements()
+			if len(executed) != len(tt.want) {
+				t.Fatalf("executed %d statements %v, want %d", len(executed), executed, len(tt.want))
+			}
+			for i, pattern := range tt.want {
+				if !regexp.MustCompile(pattern).MatchString(executed[i].Query) {
+					t.Errorf("statement %d = %q, want match of %q", i, executed[i].Query, pattern)
+				}
+			}
+		})
+	}
+}
+
+func TestDBStatementArgs(t *testing.T) {
+	db := sqltest.New(t)
+
+	if err := sql.ExecuteUpdateContext(context.Background(), db, "users", &user{ID: 3, Name: "Bob"}); err != nil {
+		t.Fatalf("ExecuteUpdateContext() error = %v", err)
+	}
+
+	s := db.AssertExecuted(t, `^UPDATE users`)
+	if len(s.Args) != 3 || s.Args[0] != int64(3) || s.Args[1] != "Bob" || s.Args[2] != int64(3) {
+		t.Errorf("args = %v, want [3 Bob 3]", s.Args)
+	}
+
+	db.Reset()
+	if got := db.Statements(); len(got) != 0 {
+		t.Errorf("Statements() after Reset = %v, want none", got)
+	}
+}
+
+// Records the failures of the assertions.
+type recorder struct {
+	testing.TB
+	failed bool
+}
+
+func (r *recorder) Helper() {}
+
+func (r *recorder) Errorf(string, ...interface{}) {
+	r.failed = true
+}
+
+func TestDBAssertions(t *testing.T) {
+	db := sqltest.New(t)
+	if err := sql.ExecuteDeleteContext(context.Background(), db, "users", 1); err != nil {
+		t.Fatalf("ExecuteDeleteContext() error = %v", err)
+	}
+
+	tests := []struct {
+		name     string
+		assert   func(tb testing.TB)
+		wantFail bool
+	}{
+		{
+			name:   "executed",
+			assert: func(tb testing.TB) { db.AssertExecuted(tb, `^DELETE FROM users`) },
+		},
+		{
+			name:     "not executed",
+			assert:   func(tb testing.TB) { db.AssertExecuted(tb, `^UPDATE users`) },
+			wantFail: true,
+		},
+		{
+			name:   "absent statement",
+			assert: func(tb testing.TB) { db.AssertNotExecuted(tb, `^UPDATE users`) },
+		},
+		{
+			name:     "present statement",
+			assert:   func(tb testing.TB) { db.AssertNotExecuted(tb, `^DELETE`) },
+			wantFail: true,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			r := &recorder{TB: t}
+			tt.assert(r)
+			if r.failed != tt.wantFail {
+				t.Errorf("failed = %v, want %v", r.failed, tt.wantFail)
+			}
+		})
+	}
+}
@@ -0,0 +1,158 @@
+package sql
+
+import (
+	"context"
+	stdsql "database/sql"
+	"sync"
+
+	"github.com/golang/groupcache/lru"
+	"github.com/jmoiron/sqlx"
+	"gitlab.com/btcdirect-api/bootstrap-go-service/internal/metrics"
+)
+
+var statementCache = metrics.NewCounter("sql_statement_cache")
+
+type statementCacheKey struct{}
+
+// stmtCache holds the prepared named statements of the helper queries, the least recently used are closed.
+type stmtCache struct {
+	sync.Mutex
+	size  int
+	cache *lru.Cache
+}
+
+// Statements are prepared per database, so the replicas and a reconnected database have their own statements.
+type stmtKey struct {
+	db    *sqlx.DB
+	query string
+}
+
+// cachedStmt counts the queries using the statement, an evicted statement is closed once it is no longer used.
+type cachedStmt struct {
+	stmt    *sqlx.NamedStmt
+	users   int
+	evicted bool
+}
+
+// Returns the prepared statement of the query on the database, preparing it on a miss.
+// Call release once the statement is no longer used.
+func (c *stmtCache) statement(ctx context.Context, db *sqlx.DB, query string) (stmt *sqlx.NamedStmt, release func(), err error) {
+	c.Lock()
+	defer c.Unlock()
+
+	if c.cache == nil {
+		c.cache = lru.New(c.size)
+		c.cache.OnEvicted = func(_ lru.Key, value interface{}) {
+			statementCache.Inc("evict")
+			cached := value.(*cachedStmt)
+			cached.evicted = true
+			if cached.users == 0 {
+				cached.stmt.Close()
+			}
+		}
+	}
+
+	key := stmtKey{db: db, query: query}
+	value, ok := c.cache.Get(key)
+	if ok {
+		statementCache.Inc("hit")
+	} else {
+		statementCache.Inc("miss")
+
+		prepared, err := db.PrepareNamedContext(ctx, query)
+		if err != nil {
+			return nil, nil, err
+		}
+		value = &cachedStmt{stmt: prepared}
+		c.cache.Add(key, value)
+	}
+
+	cached := value.(*cachedStmt)
+	cached.users++
+
+	return cached.stmt, func() {
+		c.Lock()
+		defer c.Unlock()
+
+		cached.users--
+		if cached.evicted && cached.users == 0 {
+			cached.stmt.Close()
+		}
+	}, nil
+}
+
+// Closes the prepared statements once they are no longer used.
+func (c *stmtCache) clear() {
+	c.Lock()
+	defer c.Unlock()
+
+	if c.cache != nil {
+		c.cache.Clear()
+	}
+}
+
+// Adds the statement cache of the connection to the context, when the connection caches statements.
+func withStatementCache(ctx context.Context, conn DBConnection) context.Context {
+	c, ok := conn.(*Connection)
+	if !ok || c.StatementCacheSize <= 0 {
+		return ctx
+	}
+
+	c.statements.Lock()
+	c.statements.size = c.StatementCacheSize
+	c.statements.Unlock()
+
+	return context.WithValue(ctx, statementCacheKey{}, &c.statements)
+}
+
+// Returns the cached prepared statement of the query for the database or transaction, and the func to release it.
+// Returns nil when the context has no statement cache, or the statement could not be prepared.
+func cachedStatement(ctx context.Context, e sqlx.ExtContext, query string) (*sqlx.NamedStmt, func()) {
+	cache, ok := ctx.Value(statementCacheKey{}).(*stmtCache)
+	if !ok {
+		return nil, nil
+	}
+
+	switch e := e.(type) {
+	case *sqlx.DB:
+		stmt, release, err := cache.statement(ctx, e, query)
+		if err != nil {
+			return nil, nil
+		}
+		return stmt, release
+	case *sqlx.Tx:
+		// Statements of a transaction are prepared on the database and bound to the transaction.
+		db, ok := ctx.Value(txDBKey{}).(*sqlx.DB)
+		if !ok {
+			return nil, nil
+		}
+		stmt, release, err := cache.statement(ctx, db, query)
+		if err != nil {
+			return nil, nil
+		}
+		return e.NamedStmtContext(ctx, stmt), release
+	}
+
+	return nil, nil
+}
+
+// Executes the named query, with a cached prepared statement when the connection caches statements.
+func namedExec(ctx context.Context, e sqlx.ExtContext, query string, arg interface{}) (stdsql.Result, error) {
+	if stmt, release := cachedStatement(ctx, e, query); stmt != nil {
+		defer release()
+		return stmt.ExecContext(ctx, arg)
+	}
+
+	return sqlx.NamedExecContext(ctx, e, query, arg)
+}
+
+// Runs the named query, with a cached prepared statement when the connection caches statements.
+// The rows keep a closed statement open until they are closed, so the statement is released once the query started.
+func namedQuery(ctx context.Context, e sqlx.ExtContext, query string, arg interface{}) (*sqlx.Rows, error) {
+	if stmt, release := cachedStatement(ctx, e, query); stmt != nil {
+		defer release()
+		return stmt.QueryxContext(ctx, arg)
+	}
+
+	return sqlx.NamedQueryContext(ctx, e, query, arg)
+}
@@ -0,0 +1,55 @@
+package sql
+
+import (
+	"reflect"
+	"strings"
+)
+
+// Tag value writing the column on update even when it is unset, e.g. Balance int64 `db:"balance" sql:"update,always"`.
+// Unset pointers and invalid sql.Null fields are then written as NULL.
+const alwaysTag = "always"
+
+// Returns true if the sql tag of the field contains the option, e.g. "update" for sql:"update,always".
+func hasSQLOption(field reflect.StructField, option string) bool {
+	for _, o := range strings.Split(field.Tag.Get("sql"), ",") {
+		if strings.TrimSpace(o) == option {
+			return true
+		}
+	}
+
+	return false
+}
+
+// Returns true if the field is not written on insert: update only fields and the soft delete column.
+func skipOnInsert(field reflect.StructField) bool {
+	return (hasSQLOption(field, "update") && !hasSQLOption(field, "insert")) || hasSQLOption(field, softDeleteTag)
+}
+
+// Returns true if the field is not written on update: insert only fields and the created column.
+func skipOnUpdate(field reflect.StructField) bool {
+	return (hasSQLOption(field, "insert") && !hasSQLOption(field, "update")) || hasSQLOption(field, createdTag)
+}
+
+// Returns true if the field is set and written on update:
+//   - a value is set when it is not the zero value
+//   - a pointer is set when it is not nil, also when it points to a zero value
+//   - a nullable struct with a Valid field (sql.NullString, sql.Null[T], ...) is set when it is valid,
+//     also when it holds a zero value
+//
+// Fields tagged always are written even when they are unset, writing the zero value or NULL.
+func isSetForUpdate(field reflect.StructField, value reflect.Value) bool {
+	if hasSQLOption(field, alwaysTag) {
+		return true
+	}
+
+	switch value.Kind() {
+	case reflect.Ptr:
+		return !value.IsNil()
+	case reflect.Struct:
+		if valid := value.FieldByName("Valid"); valid.IsValid() && valid.Kind() == reflect.Bool {
+			return valid.Bool()
+		}
+	}
+
+	return !value.IsZero()
+}
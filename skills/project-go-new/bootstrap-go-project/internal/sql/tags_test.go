@@ -0,0 +1,99 @@
+package sql
+
+import (
+	"database/sql"
+	"reflect"
+	"testing"
+	"time"
+)
+
+type taggedRow struct {
+	ID        int64          `db:"id" sql:"insert"`
+	Name      string         `db:"name" sql:"insert, update"`
+	Balance   int64          `db:"balance" sql:"update,always"`
+	Email     *string        `db:"email" sql:"update"`
+	Note      sql.NullString `db:"note" sql:"update"`
+	Plain     string         `db:"plain"`
+	CreatedAt time.Time      `db:"created_at" sql:"insert,update,created"`
+	DeletedAt *time.Time     `db:"deleted_at" sql:"insert,update,softdelete"`
+}
+
+func TestSkipTags(t *testing.T) {
+	tests := []struct {
+		field          string
+		wantSkipInsert bool
+		wantSkipUpdate bool
+	}{
+		{field: "ID", wantSkipUpdate: true},
+		{field: "Name"},
+		{field: "Balance", wantSkipInsert: true},
+		{field: "Email", wantSkipInsert: true},
+		{field: "Plain"},
+		{field: "CreatedAt", wantSkipUpdate: true},
+		{field: "DeletedAt", wantSkipInsert: true},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.field, func(t *testing.T) {
+			field, _ := reflect.TypeOf(taggedRow{}).FieldByName(tt.field)
+			if got := skipOnInsert(field); got != tt.wantSkipInsert {
+				t.Errorf("skipOnInsert() = %v, want %v", got, tt.wantSkipInsert)
+			}
+			if got := skipOnUpdate(field); got != tt.wantSkipUpdate {
+				t.Errorf("skipOnUpdate() = %v, want %v", got, tt.wantSkipUpdate)
+			}
+		})
+	}
+}
+
+func TestHasSQLOption(t *testing.T) {
+	field, _ := reflect.TypeOf(taggedRow{}).FieldByName("Name")
+
+	tests := []struct {
+		option string
+		want   bool
+	}{
+		{option: "insert", want: true},
+		{option: "update", want: true},
+		{option: "upd", want: false},
+		{option: "always", want: false},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.option, func(t *testing.T) {
+			if got := hasSQLOption(field, tt.option); got != tt.want {
+				t.Errorf("hasSQLOption(%q) = %v, want %v", tt.option, got, tt.want)
+			}
+		})
+	}
+}
+
+func TestIsSetForUpdate(t *testing.T) {
+	empty := ""
+
+	tests := []struct {
+		name  string
+		field string
+		row   taggedRow
+		want  bool
+	}{
+		{name: "zero value", field: "Name", want: false},
+		{name: "value", field: "Name", row: taggedRow{Name: "alice"}, want: true},
+		{name: "always zero value", field: "Balance", want: true},
+		{name: "nil pointer", field: "Email", want: false},
+		{name: "pointer to zero value", field: "Email", row: taggedRow{Email: &empty}, want: true},
+		{name: "invalid null", field: "Note", row: taggedRow{Note: sql.NullString{String: "ignored"}}, want: false},
+		{name: "valid null zero value", field: "Note", row: taggedRow{Note: sql.NullString{Valid: true}}, want: true},
+		{name: "zero time", field: "CreatedAt", want: false},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			field, _ := reflect.TypeOf(tt.row).FieldByName(tt.field)
+			value := reflect.ValueOf(tt.row).FieldByName(tt.field)
+			if got := isSetForUpdate(field, value); got != tt.want {
+				t.Errorf("isSetForUpdate(%s) = %v, want %v", tt.field, got, tt.want)
+			}
+		})
+	}
+}
@@ -0,0 +1,144 @@
+package sql
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"reflect"
+
+	"github.com/jmoiron/sqlx"
+)
+
+// Tag value marking the tenant column, e.g. TenantID string `db:"tenant_id" sql:"insert,tenant"`.
+// The helpers scope the queries on structs with a tenant column to the tenant in the context, see WithTenant.
+const tenantTag = "tenant"
+
+// Name of the parameter holding the tenant in the named helper queries.
+const tenantParameter = "sql_tenant"
+
+var (
+	ErrNoTenant       = errors.New("no tenant in context")
+	ErrTenantMismatch = errors.New("tenant of the data differs from the tenant in context")
+)
+
+// TenantSessionVariable is set to the tenant at the start of a transaction on Postgres,
+// for row level security policies, e.g. USING (tenant_id = current_setting('app.tenant_id')).
+var TenantSessionVariable = "app.tenant_id"
+
+type tenantKey struct{}
+
+// WithTenant returns a context scoping the helper queries to the tenant.
+// The tenant is assigned to the tenant column of inserted structs, so its type must match the field.
+func WithTenant(ctx context.Context, tenant interface{}) context.Context {
+	return context.WithValue(ctx, tenantKey{}, tenant)
+}
+
+// TenantFromContext returns the tenant of the context, false if it has none.
+func TenantFromContext(ctx context.Context) (interface{}, bool) {
+	tenant := ctx.Value(tenantKey{})
+	return tenant, tenant != nil
+}
+
+// Returns the tenant column of the struct, empty when it has none.
+func tenantColumn(typ reflect.Type) string {
+	for typ.Kind() == reflect.Ptr {
+		typ = typ.Elem()
+	}
+	if typ.Kind() != reflect.Struct {
+		return ""
+	}
+
+	for i := 0; i < typ.NumField(); i++ {
+		field := typ.Field(i)
+		if hasSQLOption(field, tenantTag) {
+			return field.Tag.Get("db")
+		}
+	}
+
+	return ""
+}
+
+// Returns the tenant column of the struct and the tenant of the context to scope a query with.
+// The column is empty when the struct is not tenant scoped, ErrNoTenant is returned when the context has no tenant.
+func tenantScope(ctx context.Context, typ reflect.Type) (string, interface{}, error) {
+	column := tenantColumn(typ)
+	if column == "" {
+		return "", nil, nil
+	}
+
+	tenant, ok := TenantFromContext(ctx)
+	if !ok {
+		return "", nil, fmt.Errorf("%w: %s is tenant scoped", ErrNoTenant, column)
+	}
+
+	return column, tenant, nil
+}
+
+// Returns the tenant scope of the struct registered for the table, see RegisterTable.
+// Tables that are not registered are not tenant scoped.
+func tableTenantScope(ctx context.Context, table string) (string, interface{}, error) {
+	schemaTables.Lock()
+	typ, ok := schemaTables.types[table]
+	schemaTables.Unlock()
+
+	if !ok {
+		return "", nil, nil
+	}
+
+	return tenantScope(ctx, typ)
+}
+
+// Sets the tenant column of the data to the tenant of the context, a copy is returned when data is not a pointer.
+// Data that already holds another tenant returns ErrTenantMismatch.
+func setTenant(ctx context.Context, data interface{}) (interface{}, error) {
+	value := reflect.ValueOf(data)
+	if value.Kind() == reflect.Ptr {
+		value = value.Elem()
+	}
+	if value.Kind() != reflect.Struct {
+		return data, nil
+	}
+
+	column, tenant, err := tenantScope(ctx, value.Type())
+	if err != nil || column == "" {
+		return data, err
+	}
+
+	typ := value.Type()
+	i := 0
+	for ; !hasSQLOption(typ.Field(i), tenantTag); i++ {
+	}
+
+	t := reflect.ValueOf(tenant)
+	if !t.Type().AssignableTo(typ.Field(i).Type) {
+		return data, fmt.Errorf("tenant %T can not be assigned to %s.%s", tenant, typ, typ.Field(i).Name)
+	}
+
+	field := value.Field(i)
+	if !field.IsZero() {
+		if !field.Equal(t) {
+			return data, ErrTenantMismatch
+		}
+		return data, nil
+	}
+
+	if !value.CanSet() {
+		c := reflect.New(typ)
+		c.Elem().Set(value)
+		data, value = c.Interface(), c.Elem()
+	}
+	value.Field(i).Set(t)
+
+	return data, nil
+}
+
+// Sets the tenant session variable within the transaction on Postgres, when the context has a tenant.
+func setTenantSession(ctx context.Context, tx *sqlx.Tx) error {
+	tenant, ok := TenantFromContext(ctx)
+	if !ok || !IsPostgres(tx.DriverName()) {
+		return nil
+	}
+
+	_, err := tx.ExecContext(ctx, "SELECT set_config($1, $2, true)", TenantSessionVariable, fmt.Sprint(tenant))
+	return err
+}
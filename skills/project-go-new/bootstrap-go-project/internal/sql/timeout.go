@@ -0,0 +1,38 @@
+package sql
+
+import (
+	"context"
+	"time"
+)
+
+// DefaultQueryTimeout is the timeout of the helper queries when the Connection has no QueryTimeout.
+const DefaultQueryTimeout = 2 * time.Second
+
+type queryTimeoutKey struct{}
+
+// WithQueryTimeout overrides the timeout of the helper queries using the context, e.g. for a slow report.
+// A zero timeout only applies the deadline of the context itself.
+func WithQueryTimeout(ctx context.Context, timeout time.Duration) context.Context {
+	return context.WithValue(ctx, queryTimeoutKey{}, timeout)
+}
+
+// Returns the context of a helper query, limited by the timeout of the call or the connection.
+// Slow queries are logged when the connection has a slow query threshold.
+func queryContext(ctx context.Context, conn DBConnection) (context.Context, context.CancelFunc) {
+	ctx = withSlowQueryLog(ctx, conn)
+	ctx = withStatementCache(ctx, conn)
+
+	timeout := DefaultQueryTimeout
+	if c, ok := conn.(interface{ queryTimeout() time.Duration }); ok {
+		timeout = c.queryTimeout()
+	}
+	if t, ok := ctx.Value(queryTimeoutKey{}).(time.Duration); ok {
+		timeout = t
+	}
+
+	if timeout <= 0 {
+		return context.WithCancel(ctx)
+	}
+
+	return context.WithTimeout(ctx, timeout)
+}
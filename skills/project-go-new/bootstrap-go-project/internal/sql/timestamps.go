@@ -0,0 +1,84 @@
+package sql
+
+import (
+	stdsql "database/sql"
+	"reflect"
+	"time"
+)
+
+// Tag values of timestamp columns set by the helpers, e.g. CreatedAt time.Time `db:"created_at" sql:"created"`.
+// Created columns are set on insert, updated columns on insert and update.
+const (
+	createdTag = "created"
+	updatedTag = "updated"
+)
+
+// Clock returns the current time.
+type Clock interface {
+	Now() time.Time
+}
+
+type systemClock struct{}
+
+func (systemClock) Now() time.Time {
+	return time.Now()
+}
+
+// TimestampClock sets the created, updated and soft delete columns, replace it to control the timestamps in tests.
+var TimestampClock Clock = systemClock{}
+
+var nullTimeType = reflect.TypeOf(stdsql.NullTime{})
+
+// Sets the created (on insert) and updated columns of the data to the current time in UTC.
+// Returns the data to bind to the query: the data itself when it is a pointer, so the caller sees the timestamps,
+// and a copy of the data otherwise.
+func setTimestamps(data interface{}, insert bool) interface{} {
+	value := reflect.ValueOf(data)
+	if value.Kind() == reflect.Ptr {
+		value = value.Elem()
+	}
+	if value.Kind() != reflect.Struct {
+		return data
+	}
+
+	typ := value.Type()
+	var fields []int
+	for i := 0; i < typ.NumField(); i++ {
+		switch field := typ.Field(i); {
+		case hasSQLOption(field, createdTag):
+			if insert {
+				fields = append(fields, i)
+			}
+		case hasSQLOption(field, updatedTag):
+			fields = append(fields, i)
+		}
+	}
+	if len(fields) == 0 {
+		return data
+	}
+
+	if !value.CanSet() {
+		c := reflect.New(typ)
+		c.Elem().Set(value)
+		data, value = c.Interface(), c.Elem()
+	}
+
+	now := TimestampClock.Now().UTC()
+	for _, i := range fields {
+		setTime(value.Field(i), now)
+	}
+
+	return data
+}
+
+// Sets a time.Time, *time.Time or sql.NullTime field.
+func setTime(field reflect.Value, t time.Time) {
+	switch field.Type() {
+	case timeType:
+		field.Set(reflect.ValueOf(t))
+	case reflect.PtrTo(timeType):
+		field.Set(reflect.ValueOf(&t))
+	case nullTimeType:
+		field.Set(reflect.ValueOf(stdsql.NullTime{Time: t, Valid: true}))
+	}
+}
@@ -0,0 +1,93 @@
+package sql
+
+import (
+	"context"
+	"errors"
+	"time"
+
+	"github.com/go-sql-driver/mysql"
+	"github.com/jmoiron/sqlx"
+)
+
+// Key of the database a transaction runs on, to prepare its cached statements.
+type txDBKey struct{}
+
+// Number of times a transaction is attempted when it is aborted by a deadlock.
+const maxTxAttempts = 3
+
+// Backoff before retrying a deadlocked transaction, multiplied by the attempt.
+const txRetryBackoff = 50 * time.Millisecond
+
+// WithTx runs fn within a transaction on the database connection.
+// The transaction is committed when fn returns nil and rolled back when it returns an error or panics.
+//
+// Transactions aborted by a deadlock are retried, so fn must not have side effects outside the transaction.
+// Use ExecuteInsertTx and ExecuteUpdateTx to write within the transaction, with the ctx given to fn:
+// it carries the cached statements and the slow query log of the connection.
+// On Postgres the tenant of the context is set as TenantSessionVariable within the transaction.
+func WithTx(ctx context.Context, conn DBConnection, fn func(ctx context.Context, tx *sqlx.Tx) error) error {
+	db := conn.DB(true)
+	ctx = withSlowQueryLog(ctx, conn)
+	ctx = withStatementCache(ctx, conn)
+	ctx = context.WithValue(ctx, txDBKey{}, db)
+
+	for attempt := 1; ; attempt++ {
+		err := runTx(ctx, db, fn)
+		if err == nil || !IsDeadlock(err) || attempt == maxTxAttempts {
+			return err
+		}
+
+		select {
+		case <-ctx.Done():
+			return errors.Join(err, ctx.Err())
+		case <-time.After(time.Duration(attempt) * txRetryBackoff):
+		}
+	}
+}
+
+func runTx(ctx context.Context, db *sqlx.DB, fn func(ctx context.Context, tx *sqlx.Tx) error) (err error) {
+	tx, err := db.BeginTxx(ctx, nil)
+	if err != nil {
+		return err
+	}
+
+	if err := setTenantSession(ctx, tx); err != nil {
+		tx.Rollback()
+		return err
+	}
+
+	defer func() {
+		if p := recover(); p != nil {
+			tx.Rollback()
+			panic(p)
+		}
+	}()
+
+	if err := fn(ctx, tx); err != nil {
+		if rbErr := tx.Rollback(); rbErr != nil {
+			return errors.Join(err, rbErr)
+		}
+		return err
+	}
+
+	return tx.Commit()
+}
+
+// IsDeadlock returns true if the error aborted a transaction because of a deadlock or serialization failure,
+// the transaction can be retried.
+func IsDeadlock(err error) bool {
+	var mysqlErr *mysql.MySQLError
+	if errors.As(err, &mysqlErr) {
+		// ER_LOCK_DEADLOCK
+		return mysqlErr.Number == 1213
+	}
+
+	// The pgx and lib/pq errors expose the SQLSTATE.
+	var pgErr interface{ SQLState() string }
+	if errors.As(err, &pgErr) {
+		// deadlock_detected and serialization_failure
+		return pgErr.SQLState() == "40P01" || pgErr.SQLState() == "40001"
+	}
+
+	return false
+}
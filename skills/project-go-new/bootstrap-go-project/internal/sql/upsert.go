@@ -0,0 +1,163 @@
+package sql
+
+import (
+	"context"
+	"fmt"
+	"reflect"
+	"strings"
+
+	"github.com/jmoiron/sqlx"
+)
+
+// UpsertOption changes the behaviour of ExecuteUpsert on conflict.
+type UpsertOption func(*upsertOptions)
+
+type upsertOptions struct {
+	updateColumns   []string
+	conflictColumns []string
+}
+
+// WithUpdateColumns only updates the given columns on conflict, by default all inserted columns are updated.
+func WithUpdateColumns(columns ...string) UpsertOption {
+	return func(o *upsertOptions) {
+		o.updateColumns = columns
+	}
+}
+
+// WithConflictColumns sets the unique columns that conflict, by default the id column (the first field).
+// Only Postgres uses the conflict columns, MySQL updates on a conflict of any unique key.
+func WithConflictColumns(columns ...string) UpsertOption {
+	return func(o *upsertOptions) {
+		o.conflictColumns = columns
+	}
+}
+
+// ExecuteUpsert inserts the data, or updates the existing row when it conflicts with a unique key.
+// The inserted columns follow the sql tags like ExecuteInsert.
+func ExecuteUpsert(conn DBConnection, table string, data interface{}, opts ...UpsertOption) error {
+	return ExecuteUpsertContext(context.Background(), conn, table, data, opts...)
+}
+
+// ExecuteUpsertContext upserts the data, the query is limited by the timeout of the connection.
+// Transient errors are retried, see RetryError.
+func ExecuteUpsertContext(ctx context.Context, conn DBConnection, table string, data interface{}, opts ...UpsertOption) error {
+
+	db := conn.DB(true)
+
+	ctx, cancelfunc := queryContext(ctx, conn)
+	defer cancelfunc()
+
+	return retryQuery(ctx, true, func() error {
+		return executeUpsert(ctx, db, table, data, opts)
+	})
+}
+
+// ExecuteUpsertTx upserts the data within the transaction, see WithTx.
+func ExecuteUpsertTx(ctx context.Context, tx *sqlx.Tx, table string, data interface{}, opts ...UpsertOption) error {
+	return executeUpsert(ctx, tx, table, data, opts)
+}
+
+func executeUpsert(ctx context.Context, e sqlx.ExtContext, table string, data interface{}, opts []UpsertOption) error {
+	data, err := setTenant(ctx, data)
+	if err != nil {
+		return err
+	}
+
+	data = setTimestamps(data, true)
+
+	data, _, err = setUUID(data)
+	if err != nil {
+		return err
+	}
+
+	query, err := generateUpsertQuery(table, data, IsPostgres(e.DriverName()), opts)
+	if err != nil {
+		return err
+	}
+
+	defer observeQuery(ctx, query, namedParameters(query))()
+
+	_, err = namedExec(ctx, e, query, data)
+	return err
+}
+
+// Generates INSERT ... ON DUPLICATE KEY UPDATE for MySQL and INSERT ... ON CONFLICT for Postgres.
+func generateUpsertQuery(tableName string, data interface{}, postgres bool, opts []UpsertOption) (string, error) {
+	var o upsertOptions
+	for _, opt := range opts {
+		opt(&o)
+	}
+
+	insert, err := generateInsertQuery(tableName, data)
+	if err != nil {
+		return "", err
+	}
+
+	if len(o.conflictColumns) == 0 {
+		typ := reflect.TypeOf(data)
+		for typ.Kind() == reflect.Ptr {
+			typ = typ.Elem()
+		}
+		o.conflictColumns = []string{typ.Field(0).Tag.Get("db")}
+	}
+
+	if len(o.updateColumns) == 0 {
+		columns, err := insertColumns(data)
+		if err != nil {
+			return "", err
+		}
+
+		// The conflicting columns are equal, they do not need to be updated.
+		// The created columns keep the time the row was first inserted, and the tenant of a row does not change.
+		conflicting := make(map[string]bool, len(o.conflictColumns))
+		for _, column := range o.conflictColumns {
+			conflicting[column] = true
+		}
+		typ := reflect.TypeOf(data)
+		for typ.Kind() == reflect.Ptr {
+			typ = typ.Elem()
+		}
+		for i := 0; i < typ.NumField(); i++ {
+			if hasSQLOption(typ.Field(i), createdTag) || hasSQLOption(typ.Field(i), tenantTag) {
+				conflicting[typ.Field(i).Tag.Get("db")] = true
+			}
+		}
+		for _, column := range columns {
+			if !conflicting[column] {
+				o.updateColumns = append(o.updateColumns, column)
+			}
+		}
+	}
+
+	if len(o.updateColumns) == 0 {
+		return "", fmt.Errorf("no columns to update")
+	}
+
+	// A row of another tenant is not updated.
+	tenant := tenantColumn(reflect.TypeOf(data))
+
+	updates := make([]string, 0, len(o.updateColumns))
+	for _, column := range o.updateColumns {
+		switch {
+		case postgres:
+			updates = append(updates, fmt.Sprintf("%s = EXCLUDED.%s", column, column))
+		case tenant != "":
+			updates = append(updates, fmt.Sprintf("%s = IF(%s = VALUES(%s), VALUES(%s), %s)", column, tenant, tenant, column, column))
+		default:
+			updates = append(updates, fmt.Sprintf("%s = VALUES(%s)", column, column))
+		}
+	}
+
+	query := strings.TrimSuffix(insert, ";")
+	if postgres {
+		query += fmt.Sprintf(" ON CONFLICT (%s) DO UPDATE SET %s", strings.Join(o.conflictColumns, ", "), strings.Join(updates, ", "))
+		if tenant != "" {
+			query += fmt.Sprintf(" WHERE %s.%s = EXCLUDED.%s", tableName, tenant, tenant)
+		}
+		query += ";"
+	} else {
+		query += fmt.Sprintf(" ON DUPLICATE KEY UPDATE %s;", strings.Join(updates, ", "))
+	}
+
+	return query, nil
+}
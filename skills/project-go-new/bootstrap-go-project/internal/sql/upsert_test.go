@@ -0,0 +1,101 @@
+package sql
+
+import (
+	"testing"
+	"time"
+)
+
+type upsertUser struct {
+	ID        int64     `db:"id" sql:"insert,update"`
+	Email     string    `db:"email" sql:"insert,update"`
+	Name      string    `db:"name" sql:"insert,update"`
+	CreatedAt time.Time `db:"created_at" sql:"created"`
+}
+
+type upsertTenantUser struct {
+	ID       int64  `db:"id" sql:"insert"`
+	TenantID string `db:"tenant_id" sql:"insert,tenant"`
+	Email    string `db:"email" sql:"insert,update"`
+}
+
+type upsertKey struct {
+	ID int64 `db:"id" sql:"insert"`
+}
+
+func TestGenerateUpsertQuery(t *testing.T) {
+	const insertUser = "INSERT INTO users(id, email, name, created_at) VALUES(:id, :email, :name, :created_at)"
+	const insertTenantUser = "INSERT INTO users(id, tenant_id, email) VALUES(:id, :tenant_id, :email)"
+
+	tests := []struct {
+		name     string
+		data     interface{}
+		postgres bool
+		opts     []UpsertOption
+		want     string
+		wantErr  bool
+	}{
+		{
+			name: "mysql",
+			data: &upsertUser{},
+			want: insertUser + " ON DUPLICATE KEY UPDATE email = VALUES(email), name = VALUES(name);",
+		},
+		{
+			name:     "postgres",
+			data:     &upsertUser{},
+			postgres: true,
+			want:     insertUser + " ON CONFLICT (id) DO UPDATE SET email = EXCLUDED.email, name = EXCLUDED.name;",
+		},
+		{
+			name: "mysql update columns",
+			data: upsertUser{},
+			opts: []UpsertOption{WithUpdateColumns("name")},
+			want: insertUser + " ON DUPLICATE KEY UPDATE name = VALUES(name);",
+		},
+		{
+			name:     "postgres conflict columns",
+			data:     &upsertUser{},
+			postgres: true,
+			opts:     []UpsertOption{WithConflictColumns("email")},
+			want:     insertUser + " ON CONFLICT (email) DO UPDATE SET id = EXCLUDED.id, name = EXCLUDED.name;",
+		},
+		{
+			name: "mysql ignores conflict columns",
+			data: &upsertUser{},
+			opts: []UpsertOption{WithConflictColumns("email"), WithUpdateColumns("name")},
+			want: insertUser + " ON DUPLICATE KEY UPDATE name = VALUES(name);",
+		},
+		{
+			name: "mysql tenant",
+			data: &upsertTenantUser{},
+			want: insertTenantUser + " ON DUPLICATE KEY UPDATE email = IF(tenant_id = VALUES(tenant_id), VALUES(email), email);",
+		},
+		{
+			name:     "postgres tenant",
+			data:     &upsertTenantUser{},
+			postgres: true,
+			want:     insertTenantUser + " ON CONFLICT (id) DO UPDATE SET email = EXCLUDED.email WHERE users.tenant_id = EXCLUDED.tenant_id;",
+		},
+		{
+			name:    "only the key",
+			data:    &upsertKey{},
+			wantErr: true,
+		},
+		{
+			name:    "not a struct",
+			data:    "users",
+			wantErr: true,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, err := generateUpsertQuery("users", tt.data, tt.postgres, tt.opts)
+			if (err != nil) != tt.wantErr {
+				t.Fatalf("generateUpsertQuery() error = %v, want error %v", err, tt.wantErr)
+			}
+			if got != tt.want {
+				t.Errorf("generateUpsertQuery() =\n%s\nwant\n%s", got, tt.want)
+			}
+		})
+	}
+}
@@ -0,0 +1,121 @@
+package sql
+
+import (
+	"context"
+	"fmt"
+	"reflect"
+
+	"github.com/google/uuid"
+	"github.com/jmoiron/sqlx"
+)
+
+// Tag value of a UUID primary key, e.g. ID string `db:"id" sql:"uuid"`.
+// The helpers generate a UUIDv7 on insert when it is empty, which sorts by creation time like an auto-increment id.
+const uuidTag = "uuid"
+
+var uuidType = reflect.TypeOf(uuid.UUID{})
+
+// ExecuteInsertUUID inserts the data with a UUID primary key and returns the id.
+// The id is generated when the uuid tagged field is empty, and written back when the data is passed as pointer.
+func ExecuteInsertUUID(conn DBConnection, table string, data interface{}) (string, error) {
+	return ExecuteInsertUUIDContext(context.Background(), conn, table, data)
+}
+
+// ExecuteInsertUUIDContext inserts the data with a UUID primary key, the query is limited by the timeout of the connection.
+// The id is generated once, so retries after a deadlock insert the same id.
+func ExecuteInsertUUIDContext(ctx context.Context, conn DBConnection, table string, data interface{}) (string, error) {
+
+	db := conn.DB(true)
+
+	ctx, cancelfunc := queryContext(ctx, conn)
+	defer cancelfunc()
+
+	data, id, err := setUUID(data)
+	if err != nil {
+		return "", err
+	}
+
+	err = retryQuery(ctx, false, func() error {
+		_, err := executeInsert(ctx, db, table, data)
+		return err
+	})
+
+	return id, err
+}
+
+// ExecuteInsertUUIDTx inserts the data with a UUID primary key within the transaction, see WithTx.
+func ExecuteInsertUUIDTx(ctx context.Context, tx *sqlx.Tx, table string, data interface{}) (string, error) {
+	data, id, err := setUUID(data)
+	if err != nil {
+		return "", err
+	}
+
+	_, err = executeInsert(ctx, tx, table, data)
+
+	return id, err
+}
+
+// Returns the index of the uuid tagged field, -1 when the struct has none.
+func uuidField(typ reflect.Type) int {
+	for typ.Kind() == reflect.Ptr {
+		typ = typ.Elem()
+	}
+	if typ.Kind() != reflect.Struct {
+		return -1
+	}
+
+	for i := 0; i < typ.NumField(); i++ {
+		if hasSQLOption(typ.Field(i), uuidTag) {
+			return i
+		}
+	}
+
+	return -1
+}
+
+// Generates a UUIDv7 for the empty uuid tagged field of the data and returns the id.
+// Returns the data to bind to the query like setTimestamps.
+func setUUID(data interface{}) (interface{}, string, error) {
+	i := uuidField(reflect.TypeOf(data))
+	if i < 0 {
+		return data, "", nil
+	}
+
+	value := reflect.Indirect(reflect.ValueOf(data))
+	field := value.Field(i)
+	if field.Type() != uuidType && field.Kind() != reflect.String {
+		return nil, "", fmt.Errorf("uuid field %s must be a string or uuid.UUID", value.Type().Field(i).Name)
+	}
+	if !field.IsZero() {
+		return data, idString(field), nil
+	}
+
+	id, err := uuid.NewV7()
+	if err != nil {
+		return nil, "", err
+	}
+
+	if !value.CanSet() {
+		c := reflect.New(value.Type())
+		c.Elem().Set(value)
+		data, value = c.Interface(), c.Elem()
+		field = value.Field(i)
+	}
+
+	switch field.Type() {
+	case uuidType:
+		field.Set(reflect.ValueOf(id))
+	default:
+		field.SetString(id.String())
+	}
+
+	return data, id.String(), nil
+}
+
+func idString(field reflect.Value) string {
+	if id, ok := field.Interface().(uuid.UUID); ok {
+		return id.String()
+	}
+
+	return field.String()
+}
@@ -0,0 +1,85 @@
+package task
+
+import (
+	"context"
+
+	"gitlab.com/btcdirect-api/bootstrap-go-service/internal/messenger"
+)
+
+// Messages of tracked tasks implement this interface.
+type TrackedMessage interface {
+	messenger.Message
+	TrackingID() string
+}
+
+type handler struct {
+	messenger.MessageHandler
+	tracker *Tracker
+}
+
+// Handler wraps a message handler to update the task of the message.
+// The task is processing while the handler runs, and done or failed depending on its result.
+// Messages without a tracking ID are handled without updating a task.
+//
+// A failed message is redelivered, so its task is processed again until the message is dead lettered.
+func (t *Tracker) Handler(h messenger.MessageHandler) messenger.MessageHandler {
+	wrapped := &handler{
+		MessageHandler: h,
+		tracker:        t,
+	}
+
+	if th, ok := h.(messenger.TimeoutHandler); ok {
+		return &timeoutHandler{handler: wrapped, TimeoutHandler: th}
+	}
+
+	return wrapped
+}
+
+// Keeps the timeout of a wrapped handler that implements messenger.TimeoutHandler.
+type timeoutHandler struct {
+	*handler
+	messenger.TimeoutHandler
+}
+
+// Handle implements messenger.MessageHandler
+func (h *handler) Handle(m messenger.Message) error {
+	return h.HandleContext(context.Background(), m)
+}
+
+// HandleContext implements messenger.ContextMessageHandler
+func (h *handler) HandleContext(ctx context.Context, m messenger.Message) error {
+	msg, ok := m.(TrackedMessage)
+	if !ok || msg.TrackingID() == "" {
+		return h.handle(ctx, m)
+	}
+
+	id := msg.TrackingID()
+	if err := h.tracker.Processing(ctx, id); err != nil {
+		h.tracker.log.Warnw("Error updating task", "id", id, "error", err)
+	}
+
+	err := h.handle(ctx, m)
+
+	// The task is updated even when the handler timed out.
+	ctx = context.WithoutCancel(ctx)
+	if err != nil {
+		if err := h.tracker.Fail(ctx, id, err); err != nil {
+			h.tracker.log.Warnw("Error updating task", "id", id, "error", err)
+		}
+		return err
+	}
+
+	if err := h.tracker.Done(ctx, id); err != nil {
+		h.tracker.log.Warnw("Error updating task", "id", id, "error", err)
+	}
+
+	return nil
+}
+
+func (h *handler) handle(ctx context.Context, m messenger.Message) error {
+	if ch, ok := h.MessageHandler.(messenger.ContextMessageHandler); ok {
+		return ch.HandleContext(ctx, m)
+	}
+
+	return h.MessageHandler.Handle(m)
+}
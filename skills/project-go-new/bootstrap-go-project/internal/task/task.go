@@ -0,0 +1,143 @@
+package task
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"strings"
+	"time"
+
+	gosql "gitlab.com/btcdirect-api/bootstrap-go-service/internal/sql"
+	"go.uber.org/zap"
+)
+
+type Status string
+
+const (
+	Accepted   Status = "accepted"
+	Processing Status = "processing"
+	Done       Status = "done"
+	Failed     Status = "failed"
+)
+
+const queryTimeout = 2 * time.Second
+
+var (
+	ErrNotFound          = errors.New("task not found")
+	ErrInvalidTransition = errors.New("invalid task status transition")
+)
+
+// The statuses a task may be in before transitioning to the status.
+// A failed task can be processed again when its message is redelivered, a done task is final.
+var transitions = map[Status][]Status{
+	Processing: {Accepted, Processing, Failed},
+	Done:       {Accepted, Processing},
+	Failed:     {Accepted, Processing},
+}
+
+// Task is the state of an asynchronously handled request.
+type Task struct {
+	ID        string         `db:"id" json:"id"`
+	Status    Status         `db:"status" json:"status"`
+	Error     sql.NullString `db:"error" json:"-"`
+	CreatedAt time.Time      `db:"created_at" json:"createdAt"`
+	UpdatedAt time.Time      `db:"updated_at" json:"updatedAt"`
+}
+
+// Tracker persists the status transitions of tasks in the tasks table.
+type Tracker struct {
+	conn gosql.DBConnection
+	log  *zap.SugaredLogger
+}
+
+// Creates a tracker that stores the tasks using the database connection.
+func NewTracker(conn gosql.DBConnection, log *zap.SugaredLogger) *Tracker {
+	return &Tracker{
+		conn: conn,
+		log:  log.With("component", "task"),
+	}
+}
+
+// Accept creates a new task with the accepted status.
+func (t *Tracker) Accept(ctx context.Context, id string) error {
+	ctx, cancel := context.WithTimeout(ctx, queryTimeout)
+	defer cancel()
+
+	now := time.Now().UTC()
+	db := t.conn.DB(true)
+	_, err := db.ExecContext(ctx,
+		db.Rebind("INSERT INTO tasks (id, status, created_at, updated_at) VALUES (?, ?, ?, ?)"),
+		id, Accepted, now, now,
+	)
+
+	return err
+}
+
+// Processing marks the task as being processed.
+func (t *Tracker) Processing(ctx context.Context, id string) error {
+	return t.transition(ctx, id, Processing, nil)
+}
+
+// Done marks the task as successfully processed.
+func (t *Tracker) Done(ctx context.Context, id string) error {
+	return t.transition(ctx, id, Done, nil)
+}
+
+// Fail marks the task as failed with the error that caused it.
+func (t *Tracker) Fail(ctx context.Context, id string, cause error) error {
+	return t.transition(ctx, id, Failed, cause)
+}
+
+// Get retrieves the task, ErrNotFound is returned when it does not exist.
+func (t *Tracker) Get(ctx context.Context, id string) (Task, error) {
+	ctx, cancel := context.WithTimeout(ctx, queryTimeout)
+	defer cancel()
+
+	var task Task
+	db := t.conn.DB(true)
+	err := db.GetContext(ctx, &task, db.Rebind("SELECT id, status, error, created_at, updated_at FROM tasks WHERE id = ?"), id)
+	if errors.Is(err, sql.ErrNoRows) {
+		return Task{}, ErrNotFound
+	}
+
+	return task, err
+}
+
+// Update the status of the task if the transition is allowed.
+// The error of the task is replaced, so it is cleared when the task is processed again.
+func (t *Tracker) transition(ctx context.Context, id string, status Status, cause error) error {
+	ctx, cancel := context.WithTimeout(ctx, queryTimeout)
+	defer cancel()
+
+	var errMsg sql.NullString
+	if cause != nil {
+		errMsg = sql.NullString{String: cause.Error(), Valid: true}
+	}
+
+	from := transitions[status]
+	args := []interface{}{status, errMsg, time.Now().UTC(), id}
+	for _, s := range from {
+		args = append(args, s)
+	}
+
+	db := t.conn.DB(true)
+	res, err := db.ExecContext(ctx,
+		db.Rebind("UPDATE tasks SET status = ?, error = ?, updated_at = ? WHERE id = ? AND status IN (?"+strings.Repeat(", ?", len(from)-1)+")"),
+		args...,
+	)
+	if err != nil {
+		return err
+	}
+
+	if n, err := res.RowsAffected(); err != nil || n > 0 {
+		return err
+	}
+
+	task, err := t.Get(ctx, id)
+	if err != nil {
+		return err
+	}
+
+	t.log.Warnw("Invalid task status transition", "id", id, "from", task.Status, "to", status)
+	return ErrInvalidTransition
+}